@@ -229,14 +229,22 @@ func (l *Light) getCache(blockNum uint64) *cache {
 // dag wraps an ethash_full_t with some metadata
 // and automatic memory management.
 type dag struct {
-	epoch uint64
-	test  bool
-	dir   string
+	epoch    uint64
+	test     bool
+	dir      string
+	progress func(percent int) bool // reports generation progress, aborting it if false is returned
 
 	gen sync.Once // ensures DAG is only generated once.
 	ptr *C.struct_ethash_full
 }
 
+var (
+	// genMu serializes DAG generations, so that the progress reported through the
+	// C callback can be attributed to the generation in flight.
+	genMu       sync.Mutex
+	genProgress func(percent int) bool
+)
+
 // generate creates the actual DAG. it can be called from multiple
 // goroutines. the first call will generate the DAG, subsequent
 // calls wait until it is generated.
@@ -262,6 +270,8 @@ func (d *dag) generate() {
 		cache := C.ethash_light_new_internal(cacheSize, (*C.ethash_h256_t)(unsafe.Pointer(&seedHash[0])))
 		defer C.ethash_light_delete(cache)
 		// Generate the actual DAG.
+		genMu.Lock()
+		genProgress = d.progress
 		d.ptr = C.ethash_full_new_internal(
 			C.CString(d.dir),
 			hashToH256(seedHash),
@@ -269,7 +279,13 @@ func (d *dag) generate() {
 			cache,
 			(C.ethash_callback_t)(unsafe.Pointer(C.ethashGoCallback_cgo)),
 		)
+		genProgress = nil
+		genMu.Unlock()
+
 		if d.ptr == nil {
+			if d.progress != nil {
+				return // aborted or failed, reported by MakeDAGProgress
+			}
 			panic("ethash_full_new IO or memory error")
 		}
 		runtime.SetFinalizer(d, freeDAG)
@@ -289,6 +305,9 @@ func (d *dag) Ptr() unsafe.Pointer {
 //export ethashGoCallback
 func ethashGoCallback(percent C.unsigned) C.int {
 	glog.V(logger.Info).Infof("Generating DAG: %d%%", percent)
+	if genProgress != nil && !genProgress(int(percent)) {
+		return 1
+	}
 	return 0
 }
 
@@ -296,7 +315,14 @@ func ethashGoCallback(percent C.unsigned) C.int {
 // given directory. If dir is the empty string, the default directory
 // is used.
 func MakeDAG(blockNum uint64, dir string) error {
-	d := &dag{epoch: blockNum / epochLength, dir: dir}
+	return MakeDAGProgress(blockNum, dir, nil)
+}
+
+// MakeDAGProgress is like MakeDAG, but reports the generation progress in
+// percent to the given function, which can abort the generation by returning
+// false. An aborted generation leaves a partial DAG file behind.
+func MakeDAGProgress(blockNum uint64, dir string, progress func(percent int) bool) error {
+	d := &dag{epoch: blockNum / epochLength, dir: dir, progress: progress}
 	if blockNum >= epochLength*2048 {
 		return fmt.Errorf("block number too high, limit is %d", epochLength*2048)
 	}
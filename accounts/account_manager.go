@@ -136,6 +136,17 @@ func (am *Manager) DeleteAccount(a Account, passphrase string) error {
 	return err
 }
 
+// VerifyPassphrase checks whether the key matching the given account can be
+// decrypted with the passphrase, without unlocking it. The decrypted key is
+// zeroed out immediately. ErrDecrypt is returned if the passphrase is wrong.
+func (am *Manager) VerifyPassphrase(a Account, passphrase string) error {
+	_, key, err := am.getDecryptedKey(a, passphrase)
+	if key != nil {
+		zeroKey(key.PrivateKey)
+	}
+	return err
+}
+
 // Sign signs hash with an unlocked private key matching the given address.
 func (am *Manager) Sign(addr common.Address, hash []byte) (signature []byte, err error) {
 	am.mu.RLock()
@@ -313,9 +324,13 @@ func (am *Manager) importKey(key *Key, passphrase string) (Account, error) {
 	return a, nil
 }
 
-// Update changes the passphrase of an existing account.
+// Update changes the passphrase of an existing account. The key file is replaced
+// atomically, so it is left intact if re-encrypting or writing it fails.
 func (am *Manager) Update(a Account, passphrase, newPassphrase string) error {
 	a, key, err := am.getDecryptedKey(a, passphrase)
+	if key != nil {
+		defer zeroKey(key.PrivateKey)
+	}
 	if err != nil {
 		return err
 	}
@@ -64,6 +64,28 @@ func TestManager(t *testing.T) {
 	}
 }
 
+func TestVerifyPassphrase(t *testing.T) {
+	dir, am := tmpManager(t, true)
+	defer os.RemoveAll(dir)
+
+	a, err := am.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := am.VerifyPassphrase(a, "foo"); err != nil {
+		t.Errorf("VerifyPassphrase error for correct passphrase: %v", err)
+	}
+	if err := am.VerifyPassphrase(a, "bar"); err != ErrDecrypt {
+		t.Errorf("VerifyPassphrase error mismatch for wrong passphrase: got %v, want %v", err, ErrDecrypt)
+	}
+	if err := am.VerifyPassphrase(Account{Address: common.Address{1}}, "foo"); err != ErrNoMatch {
+		t.Errorf("VerifyPassphrase error mismatch for unknown account: got %v, want %v", err, ErrNoMatch)
+	}
+	if _, err := am.Sign(a.Address, testSigData); err != ErrLocked {
+		t.Errorf("account unlocked by VerifyPassphrase: got %v, want %v", err, ErrLocked)
+	}
+}
+
 func TestSign(t *testing.T) {
 	dir, am := tmpManager(t, true)
 	defer os.RemoveAll(dir)
@@ -206,8 +206,15 @@ func writeKeyFile(file string, content []byte) error {
 		os.Remove(f.Name())
 		return err
 	}
-	f.Close()
-	return os.Rename(f.Name(), file)
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), file); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
 }
 
 // keyFileName implements the naming convention for keyfiles:
@@ -134,6 +134,7 @@ participating.
 		utils.KeyStoreDirFlag,
 		utils.OlympicFlag,
 		utils.FastSyncFlag,
+		utils.SelfCheckFlag,
 		utils.LightKDFFlag,
 		utils.CacheFlag,
 		utils.TrieCacheGenFlag,
@@ -177,6 +178,10 @@ participating.
 		utils.VMEnableJitFlag,
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
+		utils.StrictChainIdFlag,
+		utils.InsecureUnlockFlag,
+		utils.DebugCallsFlag,
+		utils.MinServingPeersFlag,
 		utils.MetricsEnabledFlag,
 		utils.FakePoWFlag,
 		utils.SolcPathFlag,
@@ -72,6 +72,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.DevModeFlag,
 			utils.IdentityFlag,
 			utils.FastSyncFlag,
+			utils.SelfCheckFlag,
 			utils.LightKDFFlag,
 		},
 	},
@@ -105,6 +106,10 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.IPCApiFlag,
 			utils.IPCPathFlag,
 			utils.RPCCORSDomainFlag,
+			utils.StrictChainIdFlag,
+			utils.InsecureUnlockFlag,
+			utils.DebugCallsFlag,
+			utils.MinServingPeersFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -145,6 +145,10 @@ var (
 		Name:  "fast",
 		Usage: "Enable fast syncing through state downloads",
 	}
+	SelfCheckFlag = cli.BoolFlag{
+		Name:  "selfcheck",
+		Usage: "Verify the transaction indexes and receipts of recent blocks on startup",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -258,6 +262,22 @@ var (
 		Usage: "HTTP-RPC server listening port",
 		Value: node.DefaultHTTPPort,
 	}
+	StrictChainIdFlag = cli.BoolFlag{
+		Name:  "strictchainid",
+		Usage: "Reject raw transactions not signed for the configured chain id",
+	}
+	InsecureUnlockFlag = cli.BoolFlag{
+		Name:  "insecureunlock",
+		Usage: "Allow indefinite account unlocks over non-local endpoints while the HTTP-RPC server is enabled",
+	}
+	DebugCallsFlag = cli.IntFlag{
+		Name:  "debugcalls",
+		Usage: "Maximum number of expensive debug calls (traces, state dumps) running concurrently (0 = unlimited)",
+	}
+	MinServingPeersFlag = cli.IntFlag{
+		Name:  "rpcminpeers",
+		Usage: "Minimum number of peers (and a finished initial sync) before state reading RPC calls are served (0 = always serve)",
+	}
 	RPCCORSDomainFlag = cli.StringFlag{
 		Name:  "rpccorsdomain",
 		Usage: "Comma separated list of domains from which to accept cross origin requests (browser enforced)",
@@ -685,6 +705,11 @@ func RegisterEthService(ctx *cli.Context, stack *node.Node, extra []byte) {
 		Etherbase:               MakeEtherbase(stack.AccountManager(), ctx),
 		ChainConfig:             MakeChainConfig(ctx, stack),
 		FastSync:                ctx.GlobalBool(FastSyncFlag.Name),
+		StrictChainId:           ctx.GlobalBool(StrictChainIdFlag.Name),
+		SelfCheck:               ctx.GlobalBool(SelfCheckFlag.Name),
+		RestrictUnlock:          ctx.GlobalBool(RPCEnabledFlag.Name) && !ctx.GlobalBool(InsecureUnlockFlag.Name),
+		MaxDebugCalls:           ctx.GlobalInt(DebugCallsFlag.Name),
+		MinServingPeers:         ctx.GlobalInt(MinServingPeersFlag.Name),
 		DatabaseCache:           ctx.GlobalInt(CacheFlag.Name),
 		DatabaseHandles:         MakeDatabaseHandles(),
 		NetworkId:               ctx.GlobalInt(NetworkIdFlag.Name),
@@ -759,6 +784,7 @@ func SetupNetwork(ctx *cli.Context) {
 		core.ExpDiffPeriod = big.NewInt(math.MaxInt64)
 	}
 	params.TargetGasLimit = common.String2Big(ctx.GlobalString(TargetGasLimitFlag.Name))
+
 }
 
 // MakeChainConfig reads the chain configuration from the database in ctx.Datadir.
@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func BenchmarkInsertChain_empty_memdb(b *testing.B) {
@@ -67,6 +68,9 @@ func BenchmarkInsertChain_ring1000_memdb(b *testing.B) {
 func BenchmarkInsertChain_ring1000_diskdb(b *testing.B) {
 	benchInsertChain(b, true, genTxRing(1000))
 }
+func BenchmarkInsertChain_ring200_decoded_memdb(b *testing.B) {
+	benchInsertDecodedChain(b, false, genTxRing(200))
+}
 
 var (
 	// This is the content of the genesis block used by the benchmarks.
@@ -143,6 +147,17 @@ func genUncles(i int, gen *BlockGen) {
 }
 
 func benchInsertChain(b *testing.B, disk bool, gen func(int, *BlockGen)) {
+	benchInsert(b, disk, false, gen)
+}
+
+// benchInsertDecodedChain is like benchInsertChain, but round-trips the chain
+// through RLP before insertion, so that no transaction senders are cached, as
+// is the case for blocks arriving from the network.
+func benchInsertDecodedChain(b *testing.B, disk bool, gen func(int, *BlockGen)) {
+	benchInsert(b, disk, true, gen)
+}
+
+func benchInsert(b *testing.B, disk bool, decode bool, gen func(int, *BlockGen)) {
 	// Create the database in memory or in a temporary directory.
 	var db ethdb.Database
 	if !disk {
@@ -164,6 +179,16 @@ func benchInsertChain(b *testing.B, disk bool, gen func(int, *BlockGen)) {
 	// generator function.
 	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{benchRootAddr, benchRootFunds})
 	chain, _ := GenerateChain(nil, genesis, db, b.N, gen)
+	if decode {
+		blob, err := rlp.EncodeToBytes(chain)
+		if err != nil {
+			b.Fatalf("failed to encode chain: %v", err)
+		}
+		chain = nil
+		if err := rlp.DecodeBytes(blob, &chain); err != nil {
+			b.Fatalf("failed to decode chain: %v", err)
+		}
+	}
 
 	// Time the insertion of the new chain.
 	// State and blocks are stored in the same DB.
@@ -102,6 +102,12 @@ func (v *BlockValidator) ValidateBlock(block *types.Block) error {
 	if txSha != header.TxHash {
 		return fmt.Errorf("invalid transaction root hash. received=%x calculated=%x", header.TxHash, txSha)
 	}
+	// Replay protected transactions must be bound to this chain and the fork active
+	for i, tx := range block.Transactions() {
+		if !v.config.ValidChainId(header.Number, tx) {
+			return fmt.Errorf("transaction %d [%x]: %v", i, tx.Hash().Bytes()[:4], ErrInvalidChainId)
+		}
+	}
 
 	return nil
 }
@@ -24,6 +24,7 @@ import (
 	"math/big"
 	mrand "math/rand"
 	"runtime"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -49,8 +50,11 @@ var (
 	jsonlogger  = logger.NewJsonLogger()
 
 	blockInsertTimer = metrics.NewTimer("chain/inserts")
+	blockKnownMeter  = metrics.NewMeter("chain/inserts/known")
 
 	ErrNoGenesis = errors.New("Genesis not found in chain")
+
+	importStreamBatch = 2500 // Number of blocks decoded and inserted at once by ImportChainStream
 )
 
 const (
@@ -58,11 +62,65 @@ const (
 	blockCacheLimit     = 256
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
+	badBlockLimit       = 10
 	// must be bumped when consensus algorithm is changed, this forces the upgradedb
 	// command to be run (forces the blocks to be imported again using the new algorithm)
 	BlockChainVersion = 3
 )
 
+// CacheConfig contains the sizes, in number of entries, of the in-memory LRU
+// caches of the chain. Larger caches serve recent chain data without database
+// lookups at the cost of memory: headers take around half a kilobyte each, block
+// bodies and full blocks as much as the transactions they contain (tens of KBs
+// for full blocks, each body being cached both decoded and RLP encoded), while
+// total difficulties and block numbers only take tens of bytes each.
+type CacheConfig struct {
+	Headers      int // Most recent block headers
+	Bodies       int // Most recent block bodies, both decoded and RLP encoded
+	Blocks       int // Most recent entire blocks
+	Tds          int // Most recent block total difficulties
+	Numbers      int // Most recent block hash to number mappings
+	FutureBlocks int // Blocks queued for import until their timestamp is reached
+}
+
+// DefaultCacheConfig contains the default cache sizes of the chain.
+var DefaultCacheConfig = CacheConfig{
+	Headers:      headerCacheLimit,
+	Bodies:       bodyCacheLimit,
+	Blocks:       blockCacheLimit,
+	Tds:          tdCacheLimit,
+	Numbers:      numberCacheLimit,
+	FutureBlocks: maxFutureBlocks,
+}
+
+// sanitize returns a copy of the cache config with all unset (non-positive)
+// sizes replaced by their defaults. A nil config yields the defaults.
+func (c *CacheConfig) sanitize() *CacheConfig {
+	config := DefaultCacheConfig
+	if c == nil {
+		return &config
+	}
+	if c.Headers > 0 {
+		config.Headers = c.Headers
+	}
+	if c.Bodies > 0 {
+		config.Bodies = c.Bodies
+	}
+	if c.Blocks > 0 {
+		config.Blocks = c.Blocks
+	}
+	if c.Tds > 0 {
+		config.Tds = c.Tds
+	}
+	if c.Numbers > 0 {
+		config.Numbers = c.Numbers
+	}
+	if c.FutureBlocks > 0 {
+		config.FutureBlocks = c.FutureBlocks
+	}
+	return &config
+}
+
 // BlockChain represents the canonical chain given a database with a genesis
 // block. The Blockchain manages chain imports, reverts, chain reorganisations.
 //
@@ -99,6 +157,17 @@ type BlockChain struct {
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
 	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
 
+	futureTolerance int64  // max time in nanoseconds a block may be ahead to get queued (atomic)
+	maxReorgDepth   uint64 // max number of canonical blocks a reorg may drop, 0 if unlimited (atomic)
+	knownSkipped    uint64 // number of already known blocks skipped during import (atomic)
+
+	badBlocks   [badBlockLimit]*BadBlock // ring buffer of the most recently rejected blocks
+	badBlockIdx int                      // index in badBlocks the next rejected block is stored at
+	badBlockMu  sync.RWMutex             // protects the bad block ring buffer
+
+	oldest   oldestBlock // result of the last OldestBlock search, narrowing the next one
+	oldestMu sync.Mutex  // protects oldest
+
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
 	// procInterrupt must be atomically called
@@ -114,10 +183,19 @@ type BlockChain struct {
 // available in the database. It initialiser the default Ethereum Validator and
 // Processor.
 func NewBlockChain(chainDb ethdb.Database, config *ChainConfig, pow pow.PoW, mux *event.TypeMux) (*BlockChain, error) {
-	bodyCache, _ := lru.New(bodyCacheLimit)
-	bodyRLPCache, _ := lru.New(bodyCacheLimit)
-	blockCache, _ := lru.New(blockCacheLimit)
-	futureBlocks, _ := lru.New(maxFutureBlocks)
+	return NewBlockChainWithCache(chainDb, config, pow, mux, nil)
+}
+
+// NewBlockChainWithCache returns a fully initialised block chain like
+// NewBlockChain, sizing its in-memory caches according to cacheConfig. Unset
+// sizes, or all of them if cacheConfig is nil, default to DefaultCacheConfig.
+func NewBlockChainWithCache(chainDb ethdb.Database, config *ChainConfig, pow pow.PoW, mux *event.TypeMux, cacheConfig *CacheConfig) (*BlockChain, error) {
+	cacheConfig = cacheConfig.sanitize()
+
+	bodyCache, _ := lru.New(cacheConfig.Bodies)
+	bodyRLPCache, _ := lru.New(cacheConfig.Bodies)
+	blockCache, _ := lru.New(cacheConfig.Blocks)
+	futureBlocks, _ := lru.New(cacheConfig.FutureBlocks)
 
 	bc := &BlockChain{
 		config:       config,
@@ -130,12 +208,13 @@ func NewBlockChain(chainDb ethdb.Database, config *ChainConfig, pow pow.PoW, mux
 		futureBlocks: futureBlocks,
 		pow:          pow,
 	}
+	bc.futureTolerance = int64(maxTimeFutureBlocks * time.Second)
 	bc.SetValidator(NewBlockValidator(config, bc, pow))
 	bc.SetProcessor(NewStateProcessor(config, bc))
 
 	gv := func() HeaderValidator { return bc.Validator() }
 	var err error
-	bc.hc, err = NewHeaderChain(chainDb, config, gv, bc.getProcInterrupt)
+	bc.hc, err = NewHeaderChain(chainDb, config, gv, bc.getProcInterrupt, cacheConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -217,6 +296,20 @@ func (self *BlockChain) loadLastState() error {
 	return nil
 }
 
+// RunLocked executes fn while holding both the chain insertion lock and the
+// chain lock, so no block or header import, reorg or rewind can modify the chain
+// meanwhile. fn must access the chain through the database, as calling back into
+// the blockchain would deadlock.
+func (bc *BlockChain) RunLocked(fn func()) {
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	fn()
+}
+
 // SetHead rewinds the local chain to a new head. In the case of headers, everything
 // above the new head will be deleted and the new one set. In the case of blocks
 // though, the head may be further rewound if block bodies are missing (non-archive
@@ -281,6 +374,50 @@ func (self *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	return nil
 }
 
+// SetCanonicalHead forcibly makes the given block the head of the canonical chain,
+// irrespective of its total difficulty, reorganising the canonical number and
+// transaction indexes onto its branch. The block and all its ancestors, as well
+// as its state, need to be present. It's meant for testing and recovery, as any
+// later block import will run the regular fork choice again.
+func (self *BlockChain) SetCanonicalHead(hash common.Hash) error {
+	self.wg.Add(1)
+	defer self.wg.Done()
+
+	block := self.GetBlockByHash(hash)
+	if block == nil {
+		return fmt.Errorf("non existent block [%x…]", hash[:4])
+	}
+	if !self.HasBlockAndState(hash) {
+		return fmt.Errorf("missing state of block #%d [%x…]", block.Number(), hash[:4])
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	head := self.currentBlock
+	if head.Hash() == hash {
+		return nil
+	}
+	if err := self.reorg(head, block, 0); err != nil {
+		return err
+	}
+	self.insert(block)
+
+	// Drop the canonical numbers of the old chain above the new head
+	for number := head.NumberU64(); number > block.NumberU64(); number-- {
+		DeleteCanonicalHash(self.chainDb, number)
+	}
+	self.hc.SetCurrentHeader(block.Header())
+	if err := WriteHeadFastBlockHash(self.chainDb, hash); err != nil {
+		glog.Fatalf("failed to insert head fast block hash: %v", err)
+	}
+	self.currentFastBlock = block
+
+	glog.V(logger.Info).Infof("forced block #%d [%x…] as new head, replacing #%d [%x…]", block.Number(), hash[:4], head.Number(), head.Hash().Bytes()[:4])
+	go self.eventMux.Post(ChainHeadEvent{block})
+
+	return nil
+}
+
 // GasLimit returns the gas limit of the current HEAD block.
 func (self *BlockChain) GasLimit() *big.Int {
 	self.mu.RLock()
@@ -427,6 +564,52 @@ func (self *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 	return nil
 }
 
+// ImportChainStream decodes RLP encoded blocks from the given reader, as written
+// by Export, and inserts them in batches as they are read, so that arbitrarily
+// large chain dumps can be imported with bounded memory. The genesis block and
+// batches that are already fully known are skipped. The number of imported blocks
+// is returned, along with an error identifying the failing block, if any.
+func (self *BlockChain) ImportChainStream(r io.Reader) (int, error) {
+	stream := rlp.NewStream(r, 0)
+
+	imported, index := 0, 0
+	blocks := make(types.Blocks, 0, importStreamBatch)
+	for {
+		// Load a batch of blocks from the input stream
+		for len(blocks) < cap(blocks) {
+			block := new(types.Block)
+			if err := stream.Decode(block); err == io.EOF {
+				break
+			} else if err != nil {
+				return imported, fmt.Errorf("block %d: failed to decode: %v", index, err)
+			}
+			index++
+			if block.NumberU64() == 0 {
+				continue
+			}
+			blocks = append(blocks, block)
+		}
+		if len(blocks) == 0 {
+			return imported, nil
+		}
+		// Import the batch, unless already known, and reset the buffer
+		known := true
+		for _, block := range blocks {
+			if !self.HasBlock(block.Hash()) {
+				known = false
+				break
+			}
+		}
+		if !known {
+			if n, err := self.InsertChain(blocks); err != nil {
+				return imported + n, fmt.Errorf("block #%d [%x…]: %v", blocks[n].NumberU64(), blocks[n].Hash().Bytes()[:4], err)
+			}
+			imported += len(blocks)
+		}
+		blocks = blocks[:0]
+	}
+}
+
 // insert injects a new head block into the current block chain. This method
 // assumes that the block is indeed a true head. It will also reset the head
 // header and the head fast sync block to this very same block if they are older
@@ -514,6 +697,47 @@ func (bc *BlockChain) HasBlockAndState(hash common.Hash) bool {
 	return err == nil
 }
 
+// oldestBlock is the result of an OldestBlock search along with the chain it was
+// made against, allowing later searches to skip the blocks already known pruned.
+type oldestBlock struct {
+	number uint64      // lowest canonical block with available state
+	hash   common.Hash // canonical hash of that block, zero if there was none
+	head   uint64      // number of the head block at the time
+}
+
+// OldestBlock returns the number of the lowest canonical block whose state is
+// still available, assuming that it's retained for all the blocks above it, as
+// on fast synced or pruned nodes. The result never exceeds the current head,
+// which is returned if not even its state is available.
+//
+// The search resumes from the previous result, as pruned state doesn't come
+// back, unless the head moved backward or the block found was reorged since.
+func (bc *BlockChain) OldestBlock() uint64 {
+	bc.oldestMu.Lock()
+	defer bc.oldestMu.Unlock()
+
+	head := bc.CurrentBlock().NumberU64()
+
+	from, last := uint64(0), bc.oldest
+	if last.hash != (common.Hash{}) && last.head <= head && GetCanonicalHash(bc.chainDb, last.number) == last.hash {
+		from = last.number
+	}
+	oldest := from + uint64(sort.Search(int(head-from)+1, func(i int) bool {
+		header := bc.GetHeaderByNumber(from + uint64(i))
+		if header == nil {
+			return false
+		}
+		_, err := state.New(header.Root, bc.chainDb)
+		return err == nil
+	}))
+	if oldest > head {
+		bc.oldest = oldestBlock{}
+		return head
+	}
+	bc.oldest = oldestBlock{number: oldest, hash: GetCanonicalHash(bc.chainDb, oldest), head: head}
+	return oldest
+}
+
 // GetBlock retrieves a block from the database by hash and number,
 // caching it if found.
 func (self *BlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
@@ -586,15 +810,22 @@ func (bc *BlockChain) Stop() {
 	glog.V(logger.Info).Infoln("Chain manager stopped")
 }
 
-func (self *BlockChain) procFutureBlocks() {
+// FutureBlocks returns the blocks queued for a later import attempt, because
+// they were timestamped ahead of the local clock or their parent was queued,
+// ordered by number.
+func (self *BlockChain) FutureBlocks() []*types.Block {
 	blocks := make([]*types.Block, 0, self.futureBlocks.Len())
 	for _, hash := range self.futureBlocks.Keys() {
-		if block, exist := self.futureBlocks.Get(hash); exist {
+		if block, exist := self.futureBlocks.Peek(hash); exist {
 			blocks = append(blocks, block.(*types.Block))
 		}
 	}
-	if len(blocks) > 0 {
-		types.BlockBy(types.Number).Sort(blocks)
+	types.BlockBy(types.Number).Sort(blocks)
+	return blocks
+}
+
+func (self *BlockChain) procFutureBlocks() {
+	if blocks := self.FutureBlocks(); len(blocks) > 0 {
 		self.InsertChain(blocks)
 	}
 }
@@ -810,7 +1041,7 @@ func (self *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err
 	if externTd.Cmp(localTd) > 0 || (externTd.Cmp(localTd) == 0 && mrand.Float64() < 0.5) {
 		// Reorganise the chain if the parent is not the head block
 		if block.ParentHash() != self.currentBlock.Hash() {
-			if err := self.reorg(self.currentBlock, block); err != nil {
+			if err := self.reorg(self.currentBlock, block, self.MaxReorgDepth()); err != nil {
 				return NonStatTy, err
 			}
 		}
@@ -842,33 +1073,59 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 		events        = make([]interface{}, 0, len(chain))
 		coalescedLogs vm.Logs
 		nonceChecked  = make([]bool, len(chain))
+		known         = make([]bool, len(chain))
+		pending       = make([]*types.Block, 0, len(chain)) // Blocks needing verification
+		pendingIdx    = make([]int, 0, len(chain))          // Indexes of the pending blocks in chain
 	)
-
+	// Blocks already present together with their state (e.g. redelivered after
+	// a restart or brief disconnect) need neither nonce verification nor state
+	// processing, so filter them out before any work is started on them.
+	for i, block := range chain {
+		if self.HasBlockAndState(block.Hash()) {
+			known[i] = true
+			continue
+		}
+		pending = append(pending, block)
+		pendingIdx = append(pendingIdx, i)
+	}
 	// Start the parallel nonce verifier.
-	nonceAbort, nonceResults := verifyNoncesFromBlocks(self.pow, chain)
+	nonceAbort, nonceResults := verifyNoncesFromBlocks(self.pow, pending)
 	defer close(nonceAbort)
 
+	// Start recovering the transaction senders in the background, so that the
+	// serial state processing finds them cached.
+	senderAbort := recoverSenders(pending)
+	defer close(senderAbort)
+
 	for i, block := range chain {
 		if atomic.LoadInt32(&self.procInterrupt) == 1 {
 			glog.V(logger.Debug).Infoln("Premature abort during block chain processing")
 			break
 		}
 
+		if known[i] {
+			self.skipKnownBlock()
+			stats.ignored++
+			continue
+		}
 		bstart := time.Now()
 		// Wait for block i's nonce to be verified before processing
 		// its state transition.
 		for !nonceChecked[i] {
 			r := <-nonceResults
-			nonceChecked[r.index] = true
+			index := pendingIdx[r.index]
+			nonceChecked[index] = true
 			if !r.valid {
-				block := chain[r.index]
-				return r.index, &BlockNonceErr{Hash: block.Hash(), Number: block.Number(), Nonce: block.Nonce()}
+				block := chain[index]
+				err := &BlockNonceErr{Hash: block.Hash(), Number: block.Number(), Nonce: block.Nonce()}
+				self.reportBlock(block, err)
+				return index, err
 			}
 		}
 
 		if BadHashes[block.Hash()] {
 			err := BadHashError(block.Hash())
-			reportBlock(block, err)
+			self.reportBlock(block, err)
 			return i, err
 		}
 		// Stage 1 validation of the block using the chain's validator
@@ -876,15 +1133,16 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 		err := self.Validator().ValidateBlock(block)
 		if err != nil {
 			if IsKnownBlockErr(err) {
+				self.skipKnownBlock()
 				stats.ignored++
 				continue
 			}
 
 			if err == BlockFutureErr {
-				// Allow up to MaxFuture second in the future blocks. If this limit
-				// is exceeded the chain is discarded and processed at a later time
-				// if given.
-				max := big.NewInt(time.Now().Unix() + maxTimeFutureBlocks)
+				// Allow blocks up to the future tolerance ahead of the local clock.
+				// If this limit is exceeded the chain is discarded and processed
+				// at a later time if given.
+				max := big.NewInt(time.Now().Add(self.FutureBlockTolerance()).Unix())
 				if block.Time().Cmp(max) == 1 {
 					return i, fmt.Errorf("%v: BlockFutureErr, %v > %v", BlockFutureErr, block.Time(), max)
 				}
@@ -900,7 +1158,7 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 				continue
 			}
 
-			reportBlock(block, err)
+			self.reportBlock(block, err)
 
 			return i, err
 		}
@@ -914,19 +1172,19 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 			err = self.stateCache.Reset(chain[i-1].Root())
 		}
 		if err != nil {
-			reportBlock(block, err)
+			self.reportBlock(block, err)
 			return i, err
 		}
 		// Process block using the parent state as reference point.
 		receipts, logs, usedGas, err := self.processor.Process(block, self.stateCache, self.config.VmConfig)
 		if err != nil {
-			reportBlock(block, err)
+			self.reportBlock(block, err)
 			return i, err
 		}
 		// Validate the state using the default validator
 		err = self.Validator().ValidateState(block, self.GetBlock(block.ParentHash(), block.NumberU64()-1), self.stateCache, receipts, usedGas)
 		if err != nil {
-			reportBlock(block, err)
+			self.reportBlock(block, err)
 			return i, err
 		}
 		// Write state changes to database
@@ -990,6 +1248,42 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 	return 0, nil
 }
 
+// recoverSenders concurrently recovers and caches the senders of all the
+// transactions in the given blocks, returning a quit channel to abort the
+// operation. Recovery failures are ignored, they are reported by the state
+// processing of the offending block.
+func recoverSenders(blocks types.Blocks) chan<- struct{} {
+	// Spawn as many workers as allowed threads
+	workers := runtime.GOMAXPROCS(0)
+	if len(blocks) < workers {
+		workers = len(blocks)
+	}
+	tasks := make(chan *types.Block, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for block := range tasks {
+				for _, tx := range block.Transactions() {
+					tx.From()
+				}
+			}
+		}()
+	}
+	// Feed the blocks to the workers in order until done or aborted
+	abort := make(chan struct{})
+	go func() {
+		defer close(tasks)
+
+		for _, block := range blocks {
+			select {
+			case tasks <- block:
+			case <-abort:
+				return
+			}
+		}
+	}()
+	return abort
+}
+
 // insertStats tracks and reports on block insertion.
 type insertStats struct {
 	queued, processed, ignored int
@@ -1031,8 +1325,8 @@ func countTransactions(chain []*types.Block) (c int) {
 
 // reorgs takes two blocks, an old chain and a new chain and will reconstruct the blocks and inserts them
 // to be part of the new canonical chain and accumulates potential missing transactions and post an
-// event about them
-func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
+// event about them. Reorgs dropping more than limit canonical blocks are refused, zero meaning no limit.
+func (self *BlockChain) reorg(oldBlock, newBlock *types.Block, limit uint64) error {
 	var (
 		newChain          types.Blocks
 		oldChain          types.Blocks
@@ -1099,6 +1393,14 @@ func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		}
 	}
 
+	// Refuse reorganisations deeper than the configured limit
+	if limit > 0 && uint64(len(oldChain)) > limit {
+		depth := uint64(len(oldChain))
+		glog.V(logger.Warn).Infof("Refusing %d deep reorg (limit %d) from #%v [%x…] to #%v [%x…]", depth, limit, oldStart.Number(), oldStart.Hash().Bytes()[:4], newStart.Number(), newStart.Hash().Bytes()[:4])
+		go self.eventMux.Post(ReorgRejectedEvent{Head: oldStart, Block: newStart, Depth: depth})
+
+		return &ReorgDepthErr{Depth: depth, Limit: limit}
+	}
 	if glog.V(logger.Debug) {
 		commonHash := commonBlock.Hash()
 		glog.Infof("Chain split detected @ %x. Reorganising chain from #%v %x to %x", commonHash[:4], numSplit, oldStart.Hash().Bytes()[:4], newStart.Hash().Bytes()[:4])
@@ -1183,12 +1485,89 @@ func (self *BlockChain) update() {
 	}
 }
 
-// reportBlock logs a bad block error.
-func reportBlock(block *types.Block, err error) {
+// BadBlock is a block that was rejected during import, along with the reason
+// of the failure and its RLP encoding so it can be re-examined later.
+type BadBlock struct {
+	Hash   common.Hash
+	Number *big.Int
+	Reason string
+	RLP    []byte
+}
+
+// reportBlock logs a bad block error and records the block in the bad block
+// ring buffer, evicting the oldest entry if full.
+func (self *BlockChain) reportBlock(block *types.Block, err error) {
 	if glog.V(logger.Error) {
 		glog.Errorf("Bad block #%v (%s)\n", block.Number(), block.Hash().Hex())
 		glog.Errorf("    %v", err)
 	}
+	blockRlp, _ := rlp.EncodeToBytes(block)
+
+	self.badBlockMu.Lock()
+	defer self.badBlockMu.Unlock()
+
+	self.badBlocks[self.badBlockIdx] = &BadBlock{
+		Hash:   block.Hash(),
+		Number: block.Number(),
+		Reason: err.Error(),
+		RLP:    blockRlp,
+	}
+	self.badBlockIdx = (self.badBlockIdx + 1) % badBlockLimit
+}
+
+// SetFutureBlockTolerance sets how far ahead of the local clock a block may be
+// timestamped to still be queued for later import. Blocks further ahead are
+// rejected. Networks with clock skew between nodes may need a larger window.
+func (self *BlockChain) SetFutureBlockTolerance(d time.Duration) {
+	atomic.StoreInt64(&self.futureTolerance, int64(d))
+}
+
+// FutureBlockTolerance returns how far ahead of the local clock a block may be
+// timestamped to still be queued for later import.
+func (self *BlockChain) FutureBlockTolerance() time.Duration {
+	return time.Duration(atomic.LoadInt64(&self.futureTolerance))
+}
+
+// SetMaxReorgDepth sets the maximum number of canonical blocks a chain
+// reorganisation triggered by a block import may drop. Deeper reorgs are refused,
+// the competing chain being kept only as a side chain. Explicit rewinds through
+// SetHead or SetCanonicalHead are not limited. Zero disables the limit.
+func (self *BlockChain) SetMaxReorgDepth(depth uint64) {
+	atomic.StoreUint64(&self.maxReorgDepth, depth)
+}
+
+// MaxReorgDepth returns the maximum number of canonical blocks a chain
+// reorganisation may drop, or zero if unlimited.
+func (self *BlockChain) MaxReorgDepth() uint64 {
+	return atomic.LoadUint64(&self.maxReorgDepth)
+}
+
+// skipKnownBlock records that a block already present in the database was
+// skipped during import.
+func (self *BlockChain) skipKnownBlock() {
+	atomic.AddUint64(&self.knownSkipped, 1)
+	blockKnownMeter.Mark(1)
+}
+
+// KnownBlocksSkipped returns the number of blocks that were skipped during
+// import since the chain was created because they were already known.
+func (self *BlockChain) KnownBlocksSkipped() uint64 {
+	return atomic.LoadUint64(&self.knownSkipped)
+}
+
+// BadBlocks returns the most recently rejected blocks, at most badBlockLimit of
+// them, ordered from oldest to newest.
+func (self *BlockChain) BadBlocks() []*BadBlock {
+	self.badBlockMu.RLock()
+	defer self.badBlockMu.RUnlock()
+
+	blocks := make([]*BadBlock, 0, badBlockLimit)
+	for i := 0; i < badBlockLimit; i++ {
+		if bad := self.badBlocks[(self.badBlockIdx+i)%badBlockLimit]; bad != nil {
+			blocks = append(blocks, bad)
+		}
+	}
+	return blocks
 }
 
 // InsertHeaderChain attempts to insert the given header chain in to the local
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -24,6 +25,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -143,12 +146,12 @@ func testBlockChainImport(chain types.Blocks, blockchain *BlockChain) error {
 		}
 		receipts, _, usedGas, err := blockchain.Processor().Process(block, statedb, vm.Config{})
 		if err != nil {
-			reportBlock(block, err)
+			blockchain.reportBlock(block, err)
 			return err
 		}
 		err = blockchain.Validator().ValidateState(block, blockchain.GetBlockByHash(block.ParentHash()), statedb, receipts, usedGas)
 		if err != nil {
-			reportBlock(block, err)
+			blockchain.reportBlock(block, err)
 			return err
 		}
 		blockchain.mu.Lock()
@@ -479,12 +482,14 @@ func chm(genesis *types.Block, db ethdb.Database) *BlockChain {
 		pow:          FakePow{},
 		config:       testChainConfig(),
 	}
+	cacheConfig := &CacheConfig{Headers: 100, Bodies: 100, Blocks: 100, Tds: 100, Numbers: 100, FutureBlocks: 100}
+
 	valFn := func() HeaderValidator { return bc.Validator() }
-	bc.hc, _ = NewHeaderChain(db, testChainConfig(), valFn, bc.getProcInterrupt)
-	bc.bodyCache, _ = lru.New(100)
-	bc.bodyRLPCache, _ = lru.New(100)
-	bc.blockCache, _ = lru.New(100)
-	bc.futureBlocks, _ = lru.New(100)
+	bc.hc, _ = NewHeaderChain(db, testChainConfig(), valFn, bc.getProcInterrupt, cacheConfig)
+	bc.bodyCache, _ = lru.New(cacheConfig.Bodies)
+	bc.bodyRLPCache, _ = lru.New(cacheConfig.Bodies)
+	bc.blockCache, _ = lru.New(cacheConfig.Blocks)
+	bc.futureBlocks, _ = lru.New(cacheConfig.FutureBlocks)
 	bc.SetValidator(bproc{})
 	bc.SetProcessor(bproc{})
 	bc.ResetWithGenesisBlock(genesis)
@@ -701,6 +706,55 @@ func testInsertNonceError(t *testing.T, full bool) {
 	}
 }
 
+// Tests that blocks rejected during import are recorded in the bad block list,
+// which only retains the most recent ones.
+func TestBadBlocksTracking(t *testing.T) {
+	db, blockchain, err := newCanonical(0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	// Insert a chain with a failing nonce and ensure the block gets recorded
+	blocks := makeBlockChain(blockchain.CurrentBlock(), 3, db, 0)
+	blockchain.pow = failPow{blocks[1].NumberU64()}
+
+	if _, err := blockchain.InsertChain(blocks); !IsBlockNonceErr(err) {
+		t.Fatalf("error mismatch: have %v, want nonce error", err)
+	}
+	bad := blockchain.BadBlocks()
+	if len(bad) != 1 {
+		t.Fatalf("bad block count mismatch: have %d, want 1", len(bad))
+	}
+	if bad[0].Hash != blocks[1].Hash() || bad[0].Number.Cmp(blocks[1].Number()) != 0 {
+		t.Errorf("bad block mismatch: have #%v [%x…], want #%v [%x…]", bad[0].Number, bad[0].Hash[:4], blocks[1].Number(), blocks[1].Hash().Bytes()[:4])
+	}
+	if bad[0].Reason == "" {
+		t.Errorf("bad block missing rejection reason")
+	}
+	var block types.Block
+	if err := rlp.DecodeBytes(bad[0].RLP, &block); err != nil {
+		t.Fatalf("failed to decode bad block: %v", err)
+	}
+	if block.Hash() != blocks[1].Hash() {
+		t.Errorf("decoded bad block hash mismatch: have %x, want %x", block.Hash(), blocks[1].Hash())
+	}
+	// Overflow the list and ensure only the most recent blocks are retained
+	blocks = makeBlockChain(blockchain.CurrentBlock(), badBlockLimit+2, db, 1)
+	for _, block := range blocks {
+		blockchain.reportBlock(block, BlockFutureErr)
+	}
+	bad = blockchain.BadBlocks()
+	if len(bad) != badBlockLimit {
+		t.Fatalf("bad block count mismatch: have %d, want %d", len(bad), badBlockLimit)
+	}
+	for i, block := range blocks[2:] {
+		if bad[i].Hash != block.Hash() {
+			t.Errorf("bad block %d mismatch: have %x, want %x", i, bad[i].Hash, block.Hash())
+		}
+	}
+}
+
 // Tests that fast importing a block chain produces the same chain data as the
 // classical full block processing.
 func TestFastVsFullChains(t *testing.T) {
@@ -1128,3 +1182,329 @@ func TestCanonicalBlockRetrieval(t *testing.T) {
 		blockchain.InsertChain(types.Blocks{chain[i]})
 	}
 }
+
+// Tests that blocks timestamped within the configured future tolerance are queued
+// for later import, while ones beyond it are rejected.
+func TestFutureBlockTolerance(t *testing.T) {
+	db, blockchain, err := newCanonical(0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	blockchain.SetFutureBlockTolerance(time.Minute)
+	if have := blockchain.FutureBlockTolerance(); have != time.Minute {
+		t.Fatalf("tolerance mismatch: have %v, want %v", have, time.Minute)
+	}
+	// makeFuture creates a block timestamped the given duration ahead of now
+	genesis := blockchain.CurrentBlock()
+	makeFuture := func(ahead time.Duration, seed byte) *types.Block {
+		blocks, _ := GenerateChain(nil, genesis, db, 1, func(i int, b *BlockGen) {
+			b.SetCoinbase(common.Address{seed})
+			b.OffsetTime(time.Now().Add(ahead).Unix() - b.header.Time.Int64())
+		})
+		return blocks[0]
+	}
+	// A block just inside the tolerance should be queued
+	inside := makeFuture(time.Minute-10*time.Second, 1)
+	if _, err := blockchain.InsertChain(types.Blocks{inside}); err != nil {
+		t.Fatalf("failed to queue block inside tolerance: %v", err)
+	}
+	if !blockchain.futureBlocks.Contains(inside.Hash()) {
+		t.Errorf("block inside tolerance not queued")
+	}
+	// A block just outside the tolerance should be rejected
+	outside := makeFuture(time.Minute+10*time.Second, 2)
+	if _, err := blockchain.InsertChain(types.Blocks{outside}); err == nil {
+		t.Errorf("block outside tolerance accepted")
+	}
+	if blockchain.futureBlocks.Contains(outside.Hash()) {
+		t.Errorf("block outside tolerance queued")
+	}
+	if blockchain.CurrentBlock().Hash() != genesis.Hash() {
+		t.Errorf("future block imported as head")
+	}
+}
+
+// Tests that reorganisations deeper than the configured limit are refused with
+// a warning event, while shallower ones still apply.
+func TestReorgDepthLimit(t *testing.T) {
+	db, blockchain, err := newCanonical(0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	genesis := blockchain.CurrentBlock()
+	canon := makeBlockChain(genesis, 10, db, 0)
+	if _, err := blockchain.InsertChain(canon); err != nil {
+		t.Fatalf("failed to insert canonical chain: %v", err)
+	}
+	blockchain.SetMaxReorgDepth(3)
+
+	sub := blockchain.eventMux.Subscribe(ReorgRejectedEvent{})
+	defer sub.Unsubscribe()
+
+	// A longer fork dropping 5 canonical blocks should be refused
+	deep := makeBlockChain(canon[4], 6, db, 1)
+	if _, err := blockchain.InsertChain(deep); !IsReorgDepthErr(err) {
+		t.Fatalf("deep reorg error mismatch: have %v, want reorg depth error", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != canon[9].Hash() {
+		t.Errorf("head changed by deep reorg: have #%d [%x…], want #%d [%x…]", head.Number(), head.Hash().Bytes()[:4], canon[9].Number(), canon[9].Hash().Bytes()[:4])
+	}
+	select {
+	case ev := <-sub.Chan():
+		rejected := ev.Data.(ReorgRejectedEvent)
+		if rejected.Depth != 5 || rejected.Head.Hash() != canon[9].Hash() {
+			t.Errorf("rejection event mismatch: have depth %d head %x, want depth 5 head %x", rejected.Depth, rejected.Head.Hash(), canon[9].Hash())
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no reorg rejection event posted")
+	}
+	// A longer fork dropping only 2 canonical blocks should still apply
+	shallow := makeBlockChain(canon[7], 3, db, 2)
+	if _, err := blockchain.InsertChain(shallow); err != nil {
+		t.Fatalf("failed to insert shallow fork: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != shallow[2].Hash() {
+		t.Errorf("head mismatch after shallow reorg: have #%d [%x…], want #%d [%x…]", head.Number(), head.Hash().Bytes()[:4], shallow[2].Number(), shallow[2].Hash().Bytes()[:4])
+	}
+	// Explicitly forcing the refused fork as head must not be limited
+	if err := blockchain.SetCanonicalHead(deep[4].Hash()); err != nil {
+		t.Fatalf("failed to force deep fork head: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != deep[4].Hash() {
+		t.Errorf("head mismatch after forced reorg: have #%d [%x…], want #%d [%x…]", head.Number(), head.Hash().Bytes()[:4], deep[4].Number(), deep[4].Hash().Bytes()[:4])
+	}
+}
+
+// Tests that a lower difficulty side chain can be forced to become the canonical
+// one, updating the canonical numbers and transaction indexes.
+func TestSetCanonicalHead(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		db, _   = ethdb.NewMemDatabase()
+		genesis = WriteGenesisBlockForTesting(db, GenesisAccount{addr, big.NewInt(1000000)})
+	)
+	blockchain, _ := NewBlockChain(db, testChainConfig(), FakePow{}, &event.TypeMux{})
+	defer blockchain.Stop()
+
+	// Import a canonical chain and a shorter sibling chain including a transaction
+	var canonTx, sideTx *types.Transaction
+	canon, _ := GenerateChain(nil, genesis, db, 4, func(i int, gen *BlockGen) {
+		if i == 1 {
+			canonTx, _ = types.NewTransaction(gen.TxNonce(addr), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil).SignECDSA(key)
+			gen.AddTx(canonTx)
+		}
+	})
+	if _, err := blockchain.InsertChain(canon); err != nil {
+		t.Fatalf("failed to insert canonical chain: %v", err)
+	}
+	side, _ := GenerateChain(nil, canon[0], db, 2, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.Address{0x02})
+		if i == 0 {
+			sideTx, _ = types.NewTransaction(gen.TxNonce(addr), common.Address{0x02}, big.NewInt(1000), params.TxGas, nil, nil).SignECDSA(key)
+			gen.AddTx(sideTx)
+		}
+	})
+	if _, err := blockchain.InsertChain(side); err != nil {
+		t.Fatalf("failed to insert side chain: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != canon[3].Hash() {
+		t.Fatalf("side chain became canonical: head #%d [%x…]", head.Number(), head.Hash().Bytes()[:4])
+	}
+	// Unknown blocks can't be forced as head
+	if err := blockchain.SetCanonicalHead(common.Hash{0xff}); err == nil {
+		t.Errorf("unknown block forced as head")
+	}
+	// Force the weaker sibling as head and verify the canonical chain switched
+	if err := blockchain.SetCanonicalHead(side[1].Hash()); err != nil {
+		t.Fatalf("failed to force side chain head: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != side[1].Hash() {
+		t.Errorf("head mismatch: have #%d [%x…], want #%d [%x…]", head.Number(), head.Hash().Bytes()[:4], side[1].Number(), side[1].Hash().Bytes()[:4])
+	}
+	if head := blockchain.CurrentHeader(); head.Hash() != side[1].Hash() {
+		t.Errorf("head header mismatch: have #%d [%x…], want #%d [%x…]", head.Number, head.Hash().Bytes()[:4], side[1].Number(), side[1].Hash().Bytes()[:4])
+	}
+	want := []common.Hash{genesis.Hash(), canon[0].Hash(), side[0].Hash(), side[1].Hash(), {}}
+	for number, hash := range want {
+		if have := GetCanonicalHash(db, uint64(number)); have != hash {
+			t.Errorf("canonical hash #%d mismatch: have %x, want %x", number, have, hash)
+		}
+	}
+	if tx, _, _, _ := GetTransaction(db, canonTx.Hash()); tx != nil {
+		t.Errorf("transaction of the old chain still indexed")
+	}
+	if tx, hash, _, _ := GetTransaction(db, sideTx.Hash()); tx == nil || hash != side[0].Hash() {
+		t.Errorf("transaction of the new chain not indexed: have %v in %x", tx, hash)
+	}
+}
+
+// Tests that the chain's caches are sized according to the given cache config,
+// with unset sizes falling back to the defaults.
+func TestConfigurableCacheSizes(t *testing.T) {
+	if have := (*CacheConfig)(nil).sanitize(); *have != DefaultCacheConfig {
+		t.Fatalf("nil cache config mismatch: have %+v, want %+v", *have, DefaultCacheConfig)
+	}
+	db, _ := ethdb.NewMemDatabase()
+	genesis, _ := WriteTestNetGenesisBlock(db)
+
+	blockchain, err := NewBlockChainWithCache(db, MakeChainConfig(), FakePow{}, new(event.TypeMux), &CacheConfig{Headers: 3, Bodies: 2, Blocks: 2})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	blocks := makeBlockChain(genesis, 8, db, canonicalSeed)
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for _, block := range blocks {
+		if blockchain.GetBlockByNumber(block.NumberU64()) == nil {
+			t.Fatalf("block #%d missing", block.NumberU64())
+		}
+		if blockchain.GetBody(block.Hash()) == nil {
+			t.Fatalf("body #%d missing", block.NumberU64())
+		}
+		if blockchain.GetHeaderByNumber(block.NumberU64()) == nil {
+			t.Fatalf("header #%d missing", block.NumberU64())
+		}
+	}
+	if n := blockchain.blockCache.Len(); n != 2 {
+		t.Errorf("block cache size mismatch: have %d, want %d", n, 2)
+	}
+	if n := blockchain.bodyCache.Len(); n != 2 {
+		t.Errorf("body cache size mismatch: have %d, want %d", n, 2)
+	}
+	if n := blockchain.hc.headerCache.Len(); n != 3 {
+		t.Errorf("header cache size mismatch: have %d, want %d", n, 3)
+	}
+	// Unset sizes should have been left at their defaults
+	if n := blockchain.hc.tdCache.Len(); n == 0 || n > tdCacheLimit {
+		t.Errorf("td cache size out of range: have %d, want 1..%d", n, tdCacheLimit)
+	}
+}
+
+// countingPow is a fake proof-of-work counting the number of seals verified.
+type countingPow struct {
+	FakePow
+	verified int32
+}
+
+func (p *countingPow) Verify(block pow.Block) bool {
+	atomic.AddInt32(&p.verified, 1)
+	return true
+}
+
+// countingProcessor wraps a block processor, counting the blocks processed.
+type countingProcessor struct {
+	Processor
+	processed int
+}
+
+func (p *countingProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, vm.Logs, *big.Int, error) {
+	p.processed++
+	return p.Processor.Process(block, statedb, cfg)
+}
+
+// Tests that reinserting already known blocks skips them before any seal
+// verification or state processing is done, counting them as skipped.
+func TestKnownBlockSkipping(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis, _ := WriteTestNetGenesisBlock(db)
+
+	checker := new(countingPow)
+	blockchain, err := NewBlockChain(db, MakeChainConfig(), checker, new(event.TypeMux))
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	processor := &countingProcessor{Processor: blockchain.processor}
+	blockchain.SetProcessor(processor)
+
+	blocks := makeBlockChain(genesis, 8, db, canonicalSeed)
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if verified := atomic.LoadInt32(&checker.verified); verified != int32(len(blocks)) {
+		t.Fatalf("seals verified mismatch: have %d, want %d", verified, len(blocks))
+	}
+	if processor.processed != len(blocks) {
+		t.Fatalf("blocks processed mismatch: have %d, want %d", processor.processed, len(blocks))
+	}
+	if skipped := blockchain.KnownBlocksSkipped(); skipped != 0 {
+		t.Fatalf("skipped blocks mismatch: have %d, want %d", skipped, 0)
+	}
+	// Reinsert the chain along with a few new blocks, only the latter should be processed
+	extended := append(blocks, makeBlockChain(blocks[len(blocks)-1], 2, db, canonicalSeed)...)
+	if _, err := blockchain.InsertChain(extended); err != nil {
+		t.Fatalf("failed to reinsert chain: %v", err)
+	}
+	if verified := atomic.LoadInt32(&checker.verified); verified != int32(len(extended)) {
+		t.Errorf("seals verified mismatch: have %d, want %d", verified, len(extended))
+	}
+	if processor.processed != len(extended) {
+		t.Errorf("blocks processed mismatch: have %d, want %d", processor.processed, len(extended))
+	}
+	if skipped := blockchain.KnownBlocksSkipped(); skipped != uint64(len(blocks)) {
+		t.Errorf("skipped blocks mismatch: have %d, want %d", skipped, len(blocks))
+	}
+	if head := blockchain.CurrentBlock().Hash(); head != extended[len(extended)-1].Hash() {
+		t.Errorf("head mismatch: have %x, want %x", head, extended[len(extended)-1].Hash())
+	}
+	// The known blocks must still be rejected by the validator
+	if err := blockchain.Validator().ValidateBlock(blocks[0]); !IsKnownBlockErr(err) {
+		t.Errorf("known block validation error mismatch: have %v, want known block error", err)
+	}
+}
+
+// Tests that a chain export can be streamed back in multiple batches, and that a
+// broken stream stops the import at the failing block.
+func TestImportChainStream(t *testing.T) {
+	// Reduce the batch size to import in multiple batches
+	defer func(old int) { importStreamBatch = old }(importStreamBatch)
+	importStreamBatch = 3
+
+	_, source, err := newCanonical(10, true)
+	if err != nil {
+		t.Fatalf("failed to create source chain: %v", err)
+	}
+	dump := new(bytes.Buffer)
+	if err := source.Export(dump); err != nil {
+		t.Fatalf("failed to export chain: %v", err)
+	}
+	// Stream the full export into a fresh chain
+	_, blockchain, _ := newCanonical(0, true)
+	imported, err := blockchain.ImportChainStream(dump)
+	if err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	if imported != 10 {
+		t.Errorf("imported block count mismatch: have %d, want %d", imported, 10)
+	}
+	if head, want := blockchain.CurrentBlock().Hash(), source.CurrentBlock().Hash(); head != want {
+		t.Errorf("head mismatch: have %x, want %x", head, want)
+	}
+	// Stream an export missing block #4 and ensure the import stops at #5
+	dump.Reset()
+	for number := uint64(1); number <= 10; number++ {
+		if number != 4 {
+			source.GetBlockByNumber(number).EncodeRLP(dump)
+		}
+	}
+	_, blockchain, _ = newCanonical(0, true)
+	imported, err = blockchain.ImportChainStream(dump)
+	if err == nil || !strings.Contains(err.Error(), "block #5") {
+		t.Errorf("gapped import error mismatch: have %v, want failure at block #5", err)
+	}
+	if imported != 3 {
+		t.Errorf("gapped imported block count mismatch: have %d, want %d", imported, 3)
+	}
+	if head := blockchain.CurrentBlock().NumberU64(); head != 3 {
+		t.Errorf("gapped head mismatch: have #%d, want #3", head)
+	}
+}
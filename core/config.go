@@ -20,6 +20,7 @@ import (
 	"errors"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -38,9 +39,56 @@ type ChainConfig struct {
 
 	HomesteadGasRepriceBlock *big.Int `json:"homesteadGasRepriceBlock"` // Homestead gas reprice switch block (nil = no fork)
 
+	EIP155Block *big.Int `json:"eip155Block"` // EIP155 replay protection switch block (nil = no fork)
+	ChainId     *big.Int `json:"chainId"`     // Chain id replay protected transactions must be bound to from EIP155Block on
+
+	IntrinsicGasCosts *IntrinsicGasCosts `json:"intrinsicGas,omitempty"` // Intrinsic gas costs of a private network (nil = mainnet costs)
+
 	VmConfig vm.Config `json:"-"`
 }
 
+// IntrinsicGasCosts are the gas costs charged to every transaction before its
+// execution. Private networks may override them in their genesis configuration,
+// unset fields keep the mainnet values of the params package.
+type IntrinsicGasCosts struct {
+	TxGas                 *big.Int `json:"txGas"`                 // Per transaction not creating a contract
+	TxGasContractCreation *big.Int `json:"txGasContractCreation"` // Per transaction creating a contract
+	TxDataZeroGas         *big.Int `json:"txDataZeroGas"`         // Per zero byte of transaction data
+	TxDataNonZeroGas      *big.Int `json:"txDataNonZeroGas"`      // Per non-zero byte of transaction data
+}
+
+// IntrinsicCosts returns the intrinsic gas costs in effect on the chain, i.e.
+// the mainnet costs with any overrides of the configuration applied.
+func (c *ChainConfig) IntrinsicCosts() *IntrinsicGasCosts {
+	costs := &IntrinsicGasCosts{
+		TxGas:                 params.TxGas,
+		TxGasContractCreation: params.TxGasContractCreation,
+		TxDataZeroGas:         params.TxDataZeroGas,
+		TxDataNonZeroGas:      params.TxDataNonZeroGas,
+	}
+	if override := c.IntrinsicGasCosts; override != nil {
+		if override.TxGas != nil {
+			costs.TxGas = override.TxGas
+		}
+		if override.TxGasContractCreation != nil {
+			costs.TxGasContractCreation = override.TxGasContractCreation
+		}
+		if override.TxDataZeroGas != nil {
+			costs.TxDataZeroGas = override.TxDataZeroGas
+		}
+		if override.TxDataNonZeroGas != nil {
+			costs.TxDataNonZeroGas = override.TxDataNonZeroGas
+		}
+	}
+	return costs
+}
+
+// IntrinsicGas computes the 'intrinsic gas' for a message with the given data
+// according to the intrinsic gas costs of the chain.
+func (c *ChainConfig) IntrinsicGas(data []byte, contractCreation, homestead bool) *big.Int {
+	return c.IntrinsicCosts().intrinsicGas(data, contractCreation, homestead)
+}
+
 // IsHomestead returns whether num is either equal to the homestead block or greater.
 func (c *ChainConfig) IsHomestead(num *big.Int) bool {
 	if c.HomesteadBlock == nil || num == nil {
@@ -49,6 +97,26 @@ func (c *ChainConfig) IsHomestead(num *big.Int) bool {
 	return num.Cmp(c.HomesteadBlock) >= 0
 }
 
+// IsEIP155 returns whether num is either equal to the EIP155 block or greater.
+func (c *ChainConfig) IsEIP155(num *big.Int) bool {
+	if c.EIP155Block == nil || num == nil {
+		return false
+	}
+	return num.Cmp(c.EIP155Block) >= 0
+}
+
+// ValidChainId returns whether the transaction may be included in the block with
+// the given number as far as replay protection is concerned: unprotected
+// transactions always may, while protected ones only from the EIP155 block on
+// and if their signature is bound to the chain's id. Before the fork protected
+// signatures are invalid, just like without replay protection support.
+func (c *ChainConfig) ValidChainId(num *big.Int, tx *types.Transaction) bool {
+	if !tx.Protected() {
+		return true
+	}
+	return c.IsEIP155(num) && c.ChainId != nil && tx.ChainId().Cmp(c.ChainId) == 0
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -103,6 +103,22 @@ func IsNonceErr(err error) bool {
 	return ok
 }
 
+// ReorgDepthErr indicates that importing a block would reorganise the chain
+// deeper than the configured limit.
+type ReorgDepthErr struct {
+	Depth, Limit uint64
+}
+
+func (err *ReorgDepthErr) Error() string {
+	return fmt.Sprintf("chain reorganisation too deep (%d blocks, limit %d)", err.Depth, err.Limit)
+}
+
+// IsReorgDepthErr returns true for reorganisations refused for their depth.
+func IsReorgDepthErr(err error) bool {
+	_, ok := err.(*ReorgDepthErr)
+	return ok
+}
+
 // BlockNonceErr indicates that a block's nonce is invalid.
 type BlockNonceErr struct {
 	Number *big.Int
@@ -30,6 +30,28 @@ type TxPreEvent struct{ Tx *types.Transaction }
 // TxPostEvent is posted when a transaction has been processed.
 type TxPostEvent struct{ Tx *types.Transaction }
 
+// TxTransitionEvent is posted when a transaction moves between the queued and
+// pending sets of the transaction pool, e.g. when a nonce gap before it is filled
+// or a preceding transaction is dropped. From and To are TxStatusQueued or
+// TxStatusPending.
+type TxTransitionEvent struct {
+	Tx       *types.Transaction
+	From, To string
+}
+
+// TxPoolPressureEvent is posted when the utilization of the transaction pool
+// crosses one of its pressure thresholds, in either direction. PctFull is the
+// percentage of the capacity taken by the pending and queued transactions.
+type TxPoolPressureEvent struct {
+	Pending, Queued, Capacity int
+	PctFull                   float64
+}
+
+// TxBumpEvent is posted when a pooled transaction is replaced by one with the
+// same nonce at a different gas price, either bumped automatically while stuck
+// or resent explicitly.
+type TxBumpEvent struct{ Old, New *types.Transaction }
+
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
 type PendingLogsEvent struct {
 	Logs vm.Logs
@@ -67,6 +89,7 @@ type ChainSideEvent struct {
 	Logs  vm.Logs
 }
 
+// PendingBlockEvent is posted when the miner rebuilds its pending block.
 type PendingBlockEvent struct {
 	Block *types.Block
 	Logs  vm.Logs
@@ -78,6 +101,14 @@ type ChainUncleEvent struct {
 
 type ChainHeadEvent struct{ Block *types.Block }
 
+// ReorgRejectedEvent is posted when a competing chain would have reorganised
+// the canonical one deeper than the configured maximum and was refused.
+type ReorgRejectedEvent struct {
+	Head  *types.Block // Canonical head kept
+	Block *types.Block // Head of the refused competing chain
+	Depth uint64       // Number of canonical blocks the reorg would have dropped
+}
+
 type GasPriceChanged struct{ Price *big.Int }
 
 // Mining operation events
@@ -73,10 +73,13 @@ type getHeaderValidatorFn func() HeaderValidator
 //  getValidator should return the parent's validator
 //  procInterrupt points to the parent's interrupt semaphore
 //  wg points to the parent's shutdown wait group
-func NewHeaderChain(chainDb ethdb.Database, config *ChainConfig, getValidator getHeaderValidatorFn, procInterrupt func() bool) (*HeaderChain, error) {
-	headerCache, _ := lru.New(headerCacheLimit)
-	tdCache, _ := lru.New(tdCacheLimit)
-	numberCache, _ := lru.New(numberCacheLimit)
+//  cacheConfig sizes the header caches, nil for the defaults
+func NewHeaderChain(chainDb ethdb.Database, config *ChainConfig, getValidator getHeaderValidatorFn, procInterrupt func() bool, cacheConfig *CacheConfig) (*HeaderChain, error) {
+	cacheConfig = cacheConfig.sanitize()
+
+	headerCache, _ := lru.New(cacheConfig.Headers)
+	tdCache, _ := lru.New(cacheConfig.Tds)
+	numberCache, _ := lru.New(cacheConfig.Numbers)
 
 	// Seed a fast but crypto originating random generator
 	seed, err := crand.Int(crand.Reader, big.NewInt(math.MaxInt64))
@@ -278,6 +278,37 @@ func (self *StateDB) GetState(a common.Address, b common.Hash) common.Hash {
 	return common.Hash{}
 }
 
+// GetProof returns the merkle proof of the given account against the root of
+// the state trie. Modifications not yet hashed into the trie are not reflected.
+func (self *StateDB) GetProof(a common.Address) []rlp.RawValue {
+	return self.trie.Prove(a[:])
+}
+
+// GetStorageProof returns the storage root of the given account, along with the
+// merkle proof of the given storage slot against it. If the slot is empty, the
+// proof proves its absence. Nonexistent accounts have an empty storage trie and
+// a nil proof.
+func (self *StateDB) GetStorageProof(a common.Address, key common.Hash) (common.Hash, []rlp.RawValue) {
+	stateObject := self.GetStateObject(a)
+	if stateObject == nil {
+		return trie.EmptyRoot, nil
+	}
+	stateObject.updateRoot(self.db)
+	return stateObject.data.Root, stateObject.getTrie(self.db).Prove(key[:])
+}
+
+// GetStorageRoot returns the root of the storage trie of the given account,
+// including any modifications not yet committed. Nonexistent accounts have an
+// empty storage trie.
+func (self *StateDB) GetStorageRoot(a common.Address) common.Hash {
+	stateObject := self.GetStateObject(a)
+	if stateObject == nil {
+		return trie.EmptyRoot
+	}
+	stateObject.updateRoot(self.db)
+	return stateObject.data.Root
+}
+
 func (self *StateDB) HasSuicided(addr common.Address) bool {
 	stateObject := self.GetStateObject(addr)
 	if stateObject != nil {
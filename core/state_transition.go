@@ -79,14 +79,26 @@ func MessageCreatesContract(msg Message) bool {
 	return msg.To() == nil
 }
 
+// intrinsicGasRules is implemented by the rule sets able to override the mainnet
+// intrinsic gas costs, e.g. ChainConfig.
+type intrinsicGasRules interface {
+	IntrinsicGas(data []byte, contractCreation, homestead bool) *big.Int
+}
+
 // IntrinsicGas computes the 'intrinsic gas' for a message
 // with the given data.
 func IntrinsicGas(data []byte, contractCreation, homestead bool) *big.Int {
+	return (&IntrinsicGasCosts{params.TxGas, params.TxGasContractCreation, params.TxDataZeroGas, params.TxDataNonZeroGas}).intrinsicGas(data, contractCreation, homestead)
+}
+
+// intrinsicGas computes the 'intrinsic gas' for a message with the given data
+// using the given costs.
+func (c *IntrinsicGasCosts) intrinsicGas(data []byte, contractCreation, homestead bool) *big.Int {
 	igas := new(big.Int)
 	if contractCreation && homestead {
-		igas.Set(params.TxGasContractCreation)
+		igas.Set(c.TxGasContractCreation)
 	} else {
-		igas.Set(params.TxGas)
+		igas.Set(c.TxGas)
 	}
 	if len(data) > 0 {
 		var nz int64
@@ -96,10 +108,10 @@ func IntrinsicGas(data []byte, contractCreation, homestead bool) *big.Int {
 			}
 		}
 		m := big.NewInt(nz)
-		m.Mul(m, params.TxDataNonZeroGas)
+		m.Mul(m, c.TxDataNonZeroGas)
 		igas.Add(igas, m)
 		m.SetInt64(int64(len(data)) - nz)
-		m.Mul(m, params.TxDataZeroGas)
+		m.Mul(m, c.TxDataZeroGas)
 		igas.Add(igas, m)
 	}
 	return igas
@@ -236,8 +248,12 @@ func (self *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *b
 
 	homestead := self.env.RuleSet().IsHomestead(self.env.BlockNumber())
 	contractCreation := MessageCreatesContract(msg)
-	// Pay intrinsic gas
-	if err = self.useGas(IntrinsicGas(self.data, contractCreation, homestead)); err != nil {
+	// Pay intrinsic gas, charging the chain's own costs if it overrides them
+	intrinsic := IntrinsicGas(self.data, contractCreation, homestead)
+	if rules, ok := self.env.RuleSet().(intrinsicGasRules); ok {
+		intrinsic = rules.IntrinsicGas(self.data, contractCreation, homestead)
+	}
+	if err = self.useGas(intrinsic); err != nil {
 		return nil, nil, nil, InvalidTxError(err)
 	}
 
@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/hashicorp/golang-lru"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
@@ -44,6 +45,21 @@ var (
 	ErrIntrinsicGas       = errors.New("Intrinsic gas too low")
 	ErrGasLimit           = errors.New("Exceeds block gas limit")
 	ErrNegativeValue      = errors.New("Negative value")
+	ErrInvalidChainId     = errors.New("Invalid chain id")
+	ErrSenderNotAllowed   = errors.New("Sender not allowed by pool policy")
+)
+
+// Sender policy modes of the transaction pool.
+const (
+	SenderPolicyNone  = ""      // Accept transactions from any sender
+	SenderPolicyAllow = "allow" // Accept only listed senders, or any if none are listed
+	SenderPolicyDeny  = "deny"  // Accept any sender but the listed ones
+)
+
+// Sub-structures of the transaction pool reported in TxTransitionEvents.
+const (
+	TxStatusQueued  = "queued"  // Future transactions, not yet executable
+	TxStatusPending = "pending" // Processable transactions
 )
 
 var (
@@ -53,6 +69,11 @@ var (
 	maxQueuedInTotal     = uint64(1024)  // Max limit of queued transactions from all accounts
 	maxQueuedLifetime    = 3 * time.Hour // Max amount of time transactions from idle accounts are queued
 	evictionInterval     = time.Minute   // Time interval to check for evictable transactions
+	maxReplacedHistory   = 4096          // Max number of replaced transactions remembered
+
+	// defaultPressureThresholds are the pool utilization percentages crossing
+	// which posts a TxPoolPressureEvent, unless configured otherwise.
+	defaultPressureThresholds = []float64{80, 95}
 )
 
 type stateFn func() (*state.StateDB, error)
@@ -70,6 +91,9 @@ type TxPool struct {
 	pendingState *state.ManagedState
 	gasLimit     func() *big.Int // The current gas limit function callback
 	minGasPrice  *big.Int
+	minPriceOf   map[common.Address]*big.Int // Per-sender overrides of minGasPrice
+	senderPolicy string                      // Sender policy mode (SenderPolicy*)
+	senderList   map[common.Address]struct{} // Senders allowed or denied by the policy
 	eventMux     *event.TypeMux
 	events       event.Subscription
 	localTx      *txSet
@@ -80,6 +104,11 @@ type TxPool struct {
 	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
 	beats   map[common.Address]time.Time       // Last heartbeat from each known account
 
+	replaced *lru.Cache // Hashes of recently replaced transactions, mapped to their replacements
+
+	pressureThresholds []float64 // Ascending utilization percentages reported when crossed
+	pressureLevel      int       // Number of pressure thresholds currently reached
+
 	wg   sync.WaitGroup // for shutdown sync
 	quit chan struct{}
 
@@ -87,21 +116,26 @@ type TxPool struct {
 }
 
 func NewTxPool(config *ChainConfig, eventMux *event.TypeMux, currentStateFn stateFn, gasLimitFn func() *big.Int) *TxPool {
+	replaced, _ := lru.New(maxReplacedHistory)
+
 	pool := &TxPool{
 		config:       config,
 		pending:      make(map[common.Address]*txList),
 		queue:        make(map[common.Address]*txList),
 		all:          make(map[common.Hash]*types.Transaction),
 		beats:        make(map[common.Address]time.Time),
+		replaced:     replaced,
 		eventMux:     eventMux,
 		currentState: currentStateFn,
 		gasLimit:     gasLimitFn,
 		minGasPrice:  new(big.Int),
+		minPriceOf:   make(map[common.Address]*big.Int),
 		pendingState: nil,
 		localTx:      newTxSet(),
 		events:       eventMux.Subscribe(ChainHeadEvent{}, GasPriceChanged{}, RemovedTransactionEvent{}),
 		quit:         make(chan struct{}),
 	}
+	pool.pressureThresholds = append([]float64(nil), defaultPressureThresholds...)
 
 	pool.wg.Add(2)
 	go pool.eventLoop()
@@ -179,12 +213,65 @@ func (pool *TxPool) State() *state.ManagedState {
 	return pool.pendingState
 }
 
+// MinGasPrice returns the minimum gas price a remote transaction needs to pay to
+// be accepted into the pool, as configured by the local miner.
+func (pool *TxPool) MinGasPrice() *big.Int {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return new(big.Int).Set(pool.minGasPrice)
+}
+
+// SetSenderMinGasPrice overrides the minimum gas price transactions of the given
+// sender need to pay to be accepted into the pool, e.g. allowing a sponsoring
+// relayer to submit zero priced transactions. A nil price removes the override.
+func (pool *TxPool) SetSenderMinGasPrice(addr common.Address, price *big.Int) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if price == nil {
+		delete(pool.minPriceOf, addr)
+		return
+	}
+	pool.minPriceOf[addr] = new(big.Int).Set(price)
+}
+
+// SenderMinGasPrice returns the minimum gas price overridden for the given
+// sender, or nil if it's subject to the global minimum.
+func (pool *TxPool) SenderMinGasPrice(addr common.Address) *big.Int {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	if price, ok := pool.minPriceOf[addr]; ok {
+		return new(big.Int).Set(price)
+	}
+	return nil
+}
+
+// minPrice returns the minimum gas price accepted from the given sender.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) minPrice(from common.Address) *big.Int {
+	if price, ok := pool.minPriceOf[from]; ok {
+		return price
+	}
+	return pool.minGasPrice
+}
+
 // Stats retrieves the current pool stats, namely the number of pending and the
 // number of queued (non-executable) transactions.
 func (pool *TxPool) Stats() (pending int, queued int) {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
 
+	return pool.stats()
+}
+
+// stats retrieves the current pool stats, namely the number of pending and the
+// number of queued (non-executable) transactions.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) stats() (pending int, queued int) {
 	for _, list := range pool.pending {
 		pending += list.Len()
 	}
@@ -194,6 +281,80 @@ func (pool *TxPool) Stats() (pending int, queued int) {
 	return
 }
 
+// Capacity returns the number of transactions the pool holds before it starts
+// dropping them, the sum of the pending and queued limits.
+func (pool *TxPool) Capacity() int {
+	return int(maxPendingTotal + maxQueuedInTotal)
+}
+
+// PressureThresholds returns the pool utilization percentages crossing which
+// posts a TxPoolPressureEvent.
+func (pool *TxPool) PressureThresholds() []float64 {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return append([]float64(nil), pool.pressureThresholds...)
+}
+
+// SetPressureThresholds sets the pool utilization percentages crossing which,
+// in either direction, posts a TxPoolPressureEvent. An empty list disables the
+// events.
+func (pool *TxPool) SetPressureThresholds(thresholds []float64) error {
+	sorted := append([]float64(nil), thresholds...)
+	for _, threshold := range sorted {
+		if threshold <= 0 || threshold > 100 {
+			return fmt.Errorf("invalid pressure threshold %v%%, must be within (0, 100]", threshold)
+		}
+	}
+	sort.Float64s(sorted)
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.pressureThresholds = sorted
+	pool.pressureLevel = pool.pressure().level
+	return nil
+}
+
+// poolPressure is the utilization of the pool and the number of pressure
+// thresholds it reaches.
+type poolPressure struct {
+	pending, queued, capacity int
+	pct                       float64
+	level                     int
+}
+
+// pressure calculates the current utilization of the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) pressure() poolPressure {
+	pending, queued := pool.stats()
+	p := poolPressure{pending: pending, queued: queued, capacity: pool.Capacity()}
+	if p.capacity > 0 {
+		p.pct = 100 * float64(pending+queued) / float64(p.capacity)
+	}
+	for _, threshold := range pool.pressureThresholds {
+		if p.pct >= threshold {
+			p.level++
+		}
+	}
+	return p
+}
+
+// checkPressure posts a TxPoolPressureEvent if the pool utilization crossed any
+// of the pressure thresholds since the last check.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) checkPressure() {
+	p := pool.pressure()
+	if p.level == pool.pressureLevel {
+		return
+	}
+	pool.pressureLevel = p.level
+	glog.V(logger.Debug).Infof("Transaction pool %.1f%% full (%d pending, %d queued, capacity %d)", p.pct, p.pending, p.queued, p.capacity)
+	go pool.eventMux.Post(TxPoolPressureEvent{Pending: p.pending, Queued: p.queued, Capacity: p.capacity, PctFull: p.pct})
+}
+
 // Content retrieves the data content of the transaction pool, returning all the
 // pending as well as queued transactions, grouped by account and sorted by nonce.
 func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
@@ -239,24 +400,69 @@ func (pool *TxPool) SetLocal(tx *types.Transaction) {
 	pool.localTx.add(tx.Hash())
 }
 
+// SetSenderPolicy restricts the senders transactions are accepted from. In
+// SenderPolicyAllow mode only the listed senders are accepted (all of them if
+// the list is empty), in SenderPolicyDeny mode the listed ones are rejected and
+// SenderPolicyNone lifts any restriction. Transactions already in the pool are
+// not affected.
+func (pool *TxPool) SetSenderPolicy(mode string, addresses []common.Address) error {
+	switch mode {
+	case SenderPolicyNone, SenderPolicyAllow, SenderPolicyDeny:
+	default:
+		return fmt.Errorf("unknown sender policy %q", mode)
+	}
+	list := make(map[common.Address]struct{}, len(addresses))
+	for _, addr := range addresses {
+		list[addr] = struct{}{}
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.senderPolicy, pool.senderList = mode, list
+	return nil
+}
+
+// senderAllowed checks the sender of a transaction against the sender policy.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) senderAllowed(from common.Address) bool {
+	_, listed := pool.senderList[from]
+	switch pool.senderPolicy {
+	case SenderPolicyAllow:
+		return listed || len(pool.senderList) == 0
+	case SenderPolicyDeny:
+		return !listed
+	default:
+		return true
+	}
+}
+
 // validateTx checks whether a transaction is valid according
 // to the consensus rules.
 func (pool *TxPool) validateTx(tx *types.Transaction) error {
-	local := pool.localTx.contains(tx.Hash())
-	// Drop transactions under our own minimal accepted gas price
-	if !local && pool.minGasPrice.Cmp(tx.GasPrice()) > 0 {
-		return ErrCheap
-	}
-
 	currentState, err := pool.currentState()
 	if err != nil {
 		return err
 	}
 
+	// Reject transactions signed for a different chain. Whether replay protection
+	// is already enabled is only checked when the transaction is included.
+	if !pool.config.ValidChainId(pool.config.EIP155Block, tx) {
+		return ErrInvalidChainId
+	}
+
 	from, err := tx.From()
 	if err != nil {
 		return ErrInvalidSender
 	}
+	if !pool.senderAllowed(from) {
+		return ErrSenderNotAllowed
+	}
+	// Drop transactions under our own minimal accepted gas price
+	local := pool.localTx.contains(tx.Hash())
+	if !local && pool.minPrice(from).Cmp(tx.GasPrice()) > 0 {
+		return ErrCheap
+	}
 
 	// Make sure the account exist. Non existent accounts
 	// haven't got funds and well therefor never pass.
@@ -288,7 +494,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction) error {
 		return ErrInsufficientFunds
 	}
 
-	intrGas := IntrinsicGas(tx.Data(), MessageCreatesContract(tx), pool.homestead)
+	intrGas := pool.config.IntrinsicGas(tx.Data(), MessageCreatesContract(tx), pool.homestead)
 	if tx.Gas().Cmp(intrGas) < 0 {
 		return ErrIntrinsicGas
 	}
@@ -322,10 +528,11 @@ func (pool *TxPool) add(tx *types.Transaction) error {
 	return nil
 }
 
-// enqueueTx inserts a new transaction into the non-executable transaction queue.
+// enqueueTx inserts a new transaction into the non-executable transaction queue,
+// reporting whether it was inserted.
 //
 // Note, this method assumes the pool lock is held!
-func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) {
+func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) bool {
 	// Try to insert the transaction into the future queue
 	from, _ := tx.From() // already validated
 	if pool.queue[from] == nil {
@@ -333,19 +540,32 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) {
 	}
 	inserted, old := pool.queue[from].Add(tx)
 	if !inserted {
-		return // An older transaction was better, discard this
+		return false // An older transaction was better, discard this
 	}
 	// Discard any previous transaction and mark this
 	if old != nil {
 		delete(pool.all, old.Hash())
+		pool.replaced.Add(old.Hash(), hash)
 	}
 	pool.all[hash] = tx
+	return true
 }
 
-// promoteTx adds a transaction to the pending (processable) list of transactions.
+// demoteTx moves a transaction that became non-executable from the pending set
+// back into the future queue, notifying any subsystems of the transition.
 //
 // Note, this method assumes the pool lock is held!
-func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.Transaction) {
+func (pool *TxPool) demoteTx(tx *types.Transaction) {
+	if pool.enqueueTx(tx.Hash(), tx) {
+		go pool.eventMux.Post(TxTransitionEvent{Tx: tx, From: TxStatusPending, To: TxStatusQueued})
+	}
+}
+
+// promoteTx adds a transaction to the pending (processable) list of transactions,
+// reporting whether it was inserted.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.Transaction) bool {
 	// Init delayed since tx pool could have been started before any state sync
 	if pool.pendingState == nil {
 		pool.resetState()
@@ -360,11 +580,12 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	if !inserted {
 		// An older transaction was better, discard this
 		delete(pool.all, hash)
-		return
+		return false
 	}
 	// Otherwise discard any previous transaction and mark this
 	if old != nil {
 		delete(pool.all, old.Hash())
+		pool.replaced.Add(old.Hash(), hash)
 	}
 	pool.all[hash] = tx // Failsafe to work around direct pending inserts (tests)
 
@@ -372,6 +593,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	pool.beats[addr] = time.Now()
 	pool.pendingState.SetNonce(addr, tx.Nonce()+1)
 	go pool.eventMux.Post(TxPreEvent{tx})
+	return true
 }
 
 // Add queues a single transaction in the pool if it is valid.
@@ -387,6 +609,18 @@ func (pool *TxPool) Add(tx *types.Transaction) error {
 	return nil
 }
 
+// Validate runs the checks Add would on a transaction, without adding it to the
+// pool.
+func (pool *TxPool) Validate(tx *types.Transaction) error {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	if hash := tx.Hash(); pool.all[hash] != nil {
+		return fmt.Errorf("Known transaction: %x", hash[:4])
+	}
+	return pool.validateTx(tx)
+}
+
 // AddBatch attempts to queue a batch of transactions.
 func (pool *TxPool) AddBatch(txs []*types.Transaction) {
 	pool.mu.Lock()
@@ -409,12 +643,22 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all[hash]
 }
 
+// ReplacedBy returns the hash of the transaction that replaced the given one at
+// the same nonce, if the replacement is still remembered by the pool.
+func (pool *TxPool) ReplacedBy(hash common.Hash) (common.Hash, bool) {
+	if replacement, ok := pool.replaced.Get(hash); ok {
+		return replacement.(common.Hash), true
+	}
+	return common.Hash{}, false
+}
+
 // Remove removes the transaction with the given hash from the pool.
 func (pool *TxPool) Remove(hash common.Hash) {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 
 	pool.removeTx(hash)
+	pool.checkPressure()
 }
 
 // RemoveBatch removes all given transactions from the pool.
@@ -425,6 +669,7 @@ func (pool *TxPool) RemoveBatch(txs types.Transactions) {
 	for _, tx := range txs {
 		pool.removeTx(tx.Hash())
 	}
+	pool.checkPressure()
 }
 
 // removeTx removes a single transaction from the queue, moving all subsequent
@@ -443,15 +688,14 @@ func (pool *TxPool) removeTx(hash common.Hash) {
 	// Remove the transaction from the pending lists and reset the account nonce
 	if pending := pool.pending[addr]; pending != nil {
 		if removed, invalids := pending.Remove(tx); removed {
-			// If no more transactions are left, remove the list
+			// If no more pending transactions are left, remove the list
 			if pending.Empty() {
 				delete(pool.pending, addr)
 				delete(pool.beats, addr)
-			} else {
-				// Otherwise postpone any invalidated transactions
-				for _, tx := range invalids {
-					pool.enqueueTx(tx.Hash(), tx)
-				}
+			}
+			// Postpone any invalidated transactions
+			for _, tx := range invalids {
+				pool.demoteTx(tx)
 			}
 			// Update the account nonce if needed
 			if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
@@ -472,6 +716,8 @@ func (pool *TxPool) removeTx(hash common.Hash) {
 // future queue to the set of pending transactions. During this process, all
 // invalidated transactions (low nonce, low balance) are deleted.
 func (pool *TxPool) promoteExecutables() {
+	defer pool.checkPressure()
+
 	// Init delayed since tx pool could have been started before any state sync
 	if pool.pendingState == nil {
 		pool.resetState()
@@ -505,7 +751,9 @@ func (pool *TxPool) promoteExecutables() {
 			if glog.V(logger.Core) {
 				glog.Infof("Promoting queued transaction: %v", tx)
 			}
-			pool.promoteTx(addr, tx.Hash(), tx)
+			if pool.promoteTx(addr, tx.Hash(), tx) {
+				go pool.eventMux.Post(TxTransitionEvent{Tx: tx, From: TxStatusQueued, To: TxStatusPending})
+			}
 		}
 		// Drop all transactions over the allowed limit
 		for _, tx := range list.Cap(int(maxQueuedPerAccount)) {
@@ -612,6 +860,8 @@ func (pool *TxPool) promoteExecutables() {
 // executable/pending queue and any subsequent transactions that become unexecutable
 // are moved back into the future queue.
 func (pool *TxPool) demoteUnexecutables() {
+	defer pool.checkPressure()
+
 	// Retrieve the current state to allow nonce and balance checking
 	state, err := pool.currentState()
 	if err != nil {
@@ -641,7 +891,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			if glog.V(logger.Core) {
 				glog.Infof("Demoting pending transaction: %v", tx)
 			}
-			pool.enqueueTx(tx.Hash(), tx)
+			pool.demoteTx(tx)
 		}
 		// Delete the entire queue entry if it became empty.
 		if list.Empty() {
@@ -671,6 +921,7 @@ func (pool *TxPool) expirationLoop() {
 					}
 				}
 			}
+			pool.checkPressure()
 			pool.mu.Unlock()
 
 		case <-pool.quit:
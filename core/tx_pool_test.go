@@ -170,6 +170,33 @@ func TestNegativeValue(t *testing.T) {
 	}
 }
 
+// Tests that replay protected transactions are only accepted if they are bound
+// to the chain id the pool is configured with.
+func TestTransactionChainId(t *testing.T) {
+	pool, key := setupTxPool()
+	currentState, _ := pool.currentState()
+	currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+
+	tx, _ := types.NewTransaction(0, common.Address{}, big.NewInt(100), big.NewInt(100000), big.NewInt(1), nil).SignProtectedECDSA(key, big.NewInt(2))
+	if err := pool.Add(tx); err != ErrInvalidChainId {
+		t.Error("expected", ErrInvalidChainId, "got", err)
+	}
+	// Without the EIP155 fork configured even a matching chain id is rejected
+	pool.config.ChainId = big.NewInt(2)
+	if err := pool.Add(tx); err != ErrInvalidChainId {
+		t.Error("expected", ErrInvalidChainId, "got", err)
+	}
+	pool.config.EIP155Block = big.NewInt(0)
+	pool.config.ChainId = big.NewInt(1)
+	if err := pool.Add(tx); err != ErrInvalidChainId {
+		t.Error("expected", ErrInvalidChainId, "got", err)
+	}
+	pool.config.ChainId = big.NewInt(2)
+	if err := pool.Add(tx); err != nil {
+		t.Error("didn't expect error", err)
+	}
+}
+
 func TestTransactionChainFork(t *testing.T) {
 	pool, key := setupTxPool()
 	addr := crypto.PubkeyToAddress(key.PublicKey)
@@ -800,3 +827,187 @@ func benchmarkPoolBatchInsert(b *testing.B, size int) {
 		pool.AddBatch(batch)
 	}
 }
+
+// Tests that the sender policy of the pool admits only allowed senders, or
+// rejects denied ones, depending on its mode.
+func TestTransactionSenderPolicy(t *testing.T) {
+	pool, listed := setupTxPool()
+	other, _ := crypto.GenerateKey()
+
+	currentState, _ := pool.currentState()
+	for _, key := range []*ecdsa.PrivateKey{listed, other} {
+		currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+	}
+	addrs := []common.Address{crypto.PubkeyToAddress(listed.PublicKey)}
+
+	tests := []struct {
+		mode     string
+		list     []common.Address
+		listed   error
+		unlisted error
+	}{
+		{SenderPolicyNone, addrs, nil, nil},
+		{SenderPolicyAllow, nil, nil, nil},
+		{SenderPolicyAllow, addrs, nil, ErrSenderNotAllowed},
+		{SenderPolicyDeny, addrs, ErrSenderNotAllowed, nil},
+	}
+	for i, tt := range tests {
+		if err := pool.SetSenderPolicy(tt.mode, tt.list); err != nil {
+			t.Fatalf("test %d: failed to set policy: %v", i, err)
+		}
+		nonce := uint64(i)
+		if err := pool.Add(transaction(nonce, big.NewInt(100000), listed)); err != tt.listed {
+			t.Errorf("test %d (%s): listed sender error mismatch: have %v, want %v", i, tt.mode, err, tt.listed)
+		}
+		if err := pool.Add(transaction(nonce, big.NewInt(100000), other)); err != tt.unlisted {
+			t.Errorf("test %d (%s): unlisted sender error mismatch: have %v, want %v", i, tt.mode, err, tt.unlisted)
+		}
+	}
+	if err := pool.SetSenderPolicy("bogus", nil); err == nil {
+		t.Errorf("unknown policy mode accepted")
+	}
+}
+
+// Tests that per-sender gas price overrides take precedence over the global
+// minimum gas price of the pool.
+func TestTransactionSenderMinGasPrice(t *testing.T) {
+	pool, exempt := setupTxPool()
+	other, _ := crypto.GenerateKey()
+
+	currentState, _ := pool.currentState()
+	for _, key := range []*ecdsa.PrivateKey{exempt, other} {
+		currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+	}
+	pool.minGasPrice = big.NewInt(1000)
+	pool.SetSenderMinGasPrice(crypto.PubkeyToAddress(exempt.PublicKey), common.Big0)
+
+	free := func(nonce uint64, key *ecdsa.PrivateKey) *types.Transaction {
+		tx, _ := types.NewTransaction(nonce, common.Address{}, big.NewInt(100), big.NewInt(100000), common.Big0, nil).SignECDSA(key)
+		return tx
+	}
+	if err := pool.Add(free(0, exempt)); err != nil {
+		t.Errorf("exempt sender rejected: %v", err)
+	}
+	if err := pool.Add(free(0, other)); err != ErrCheap {
+		t.Errorf("non-exempt sender error mismatch: have %v, want %v", err, ErrCheap)
+	}
+	// Removing the override should subject the sender to the global minimum again
+	pool.SetSenderMinGasPrice(crypto.PubkeyToAddress(exempt.PublicKey), nil)
+	if price := pool.SenderMinGasPrice(crypto.PubkeyToAddress(exempt.PublicKey)); price != nil {
+		t.Errorf("override not removed: have %v", price)
+	}
+	if err := pool.Add(free(1, exempt)); err != ErrCheap {
+		t.Errorf("formerly exempt sender error mismatch: have %v, want %v", err, ErrCheap)
+	}
+}
+
+// Tests that transactions moving between the queued and pending sets of the pool
+// are announced as transitions.
+func TestTransactionTransitionEvents(t *testing.T) {
+	pool, key := setupTxPool()
+	from, _ := transaction(0, big.NewInt(100000), key).From()
+	state, _ := pool.currentState()
+	state.AddBalance(from, big.NewInt(1000000000))
+
+	sub := pool.eventMux.Subscribe(TxTransitionEvent{})
+	defer sub.Unsubscribe()
+
+	expect := func(want map[common.Hash]string) {
+		for len(want) > 0 {
+			select {
+			case ev := <-sub.Chan():
+				transition := ev.Data.(TxTransitionEvent)
+				hash := transition.Tx.Hash()
+				if to, ok := want[hash]; !ok || transition.To != to {
+					t.Fatalf("unexpected transition of %x: %s -> %s", hash[:4], transition.From, transition.To)
+				}
+				delete(want, hash)
+			case <-time.After(time.Second):
+				t.Fatalf("transition timeout, missing %d", len(want))
+			}
+		}
+		select {
+		case ev := <-sub.Chan():
+			t.Fatalf("unexpected transition: %+v", ev.Data)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	// A gapped transaction is only queued
+	tx0, tx1 := transaction(0, big.NewInt(100000), key), transaction(1, big.NewInt(100000), key)
+	if err := pool.Add(tx1); err != nil {
+		t.Fatalf("failed to add gapped transaction: %v", err)
+	}
+	expect(nil)
+
+	// Filling the gap promotes both transactions
+	if err := pool.Add(tx0); err != nil {
+		t.Fatalf("failed to add gap filling transaction: %v", err)
+	}
+	expect(map[common.Hash]string{tx0.Hash(): TxStatusPending, tx1.Hash(): TxStatusPending})
+
+	// Dropping the first transaction demotes the second
+	pool.Remove(tx0.Hash())
+	expect(map[common.Hash]string{tx1.Hash(): TxStatusQueued})
+}
+
+// Tests that crossing the pool utilization thresholds in either direction posts
+// pressure events reporting the current utilization.
+func TestTransactionPoolPressureEvents(t *testing.T) {
+	// Reduce the pool limits to shorten test time
+	defer func(old uint64) { maxPendingTotal = old }(maxPendingTotal)
+	maxPendingTotal = 16
+	defer func(old uint64) { maxQueuedInTotal = old }(maxQueuedInTotal)
+	maxQueuedInTotal = 4
+
+	pool, key := setupTxPool()
+	from, _ := transaction(0, big.NewInt(100000), key).From()
+	state, _ := pool.currentState()
+	state.AddBalance(from, big.NewInt(1000000000))
+
+	if err := pool.SetPressureThresholds([]float64{95, 80}); err != nil {
+		t.Fatalf("failed to set pressure thresholds: %v", err)
+	}
+	if err := pool.SetPressureThresholds([]float64{80, 120}); err == nil {
+		t.Fatalf("out of range threshold accepted")
+	}
+	sub := pool.eventMux.Subscribe(TxPoolPressureEvent{})
+	defer sub.Unsubscribe()
+
+	expect := func(pending, queued int, pct float64) {
+		select {
+		case ev := <-sub.Chan():
+			want := TxPoolPressureEvent{Pending: pending, Queued: queued, Capacity: 20, PctFull: pct}
+			if pressure := ev.Data.(TxPoolPressureEvent); pressure != want {
+				t.Fatalf("pressure event mismatch: have %+v, want %+v", pressure, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("pressure event timeout, want %v%%", pct)
+		}
+		select {
+		case ev := <-sub.Chan():
+			t.Fatalf("unexpected pressure event: %+v", ev.Data)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	// Filling the pool up to the first threshold fires a single event
+	for i := uint64(0); i < 16; i++ {
+		if err := pool.Add(transaction(i, big.NewInt(100000), key)); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	expect(16, 0, 80)
+
+	// Queueing gapped transactions past the second threshold fires another
+	gapped := make([]*types.Transaction, 3)
+	for i := range gapped {
+		gapped[i] = transaction(uint64(17+i), big.NewInt(100000), key)
+		if err := pool.Add(gapped[i]); err != nil {
+			t.Fatalf("failed to add gapped transaction %d: %v", i, err)
+		}
+	}
+	expect(16, 3, 95)
+
+	// Dropping back below the threshold is reported too
+	pool.Remove(gapped[2].Hash())
+	expect(16, 2, 90)
+}
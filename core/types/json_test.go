@@ -139,6 +139,7 @@ func TestMarshalTransaction(t *testing.T) {
 			continue
 		}
 		proced.Hash() // hack private fields to pass deep equal
+		proced.time = original.time
 		if !reflect.DeepEqual(original, proced) {
 			t.Errorf("test %q: transaction mismatch: have %+v, want %+v", name, proced, original)
 			continue
@@ -25,6 +25,7 @@ import (
 	"io"
 	"math/big"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -33,6 +34,10 @@ import (
 
 var ErrInvalidSig = errors.New("invalid transaction v, r, s values")
 
+// protectedVBase is the lowest signature V value of replay protected (EIP-155)
+// transactions, which encode the chain id as V = {0,1} + chainId*2 + 35.
+var protectedVBase = big.NewInt(35)
+
 var (
 	errMissingTxSignatureFields = errors.New("missing required JSON transaction signature fields")
 	errMissingTxFields          = errors.New("missing required JSON transaction fields")
@@ -40,6 +45,7 @@ var (
 
 type Transaction struct {
 	data txdata
+	time time.Time // Time first seen locally, used for first-in-first-out ordering
 	// caches
 	hash atomic.Value
 	size atomic.Value
@@ -52,8 +58,7 @@ type txdata struct {
 	Recipient       *common.Address `rlp:"nil"` // nil means contract creation
 	Amount          *big.Int
 	Payload         []byte
-	V               byte     // signature
-	R, S            *big.Int // signature
+	V, R, S         *big.Int // signature
 }
 
 type jsonTransaction struct {
@@ -64,7 +69,7 @@ type jsonTransaction struct {
 	Recipient    *common.Address `json:"to"`
 	Amount       *hexBig         `json:"value"`
 	Payload      *hexBytes       `json:"input"`
-	V            *hexUint64      `json:"v"`
+	V            *hexBig         `json:"v"`
 	R            *hexBig         `json:"r"`
 	S            *hexBig         `json:"s"`
 }
@@ -74,13 +79,14 @@ func NewContractCreation(nonce uint64, amount, gasLimit, gasPrice *big.Int, data
 	if len(data) > 0 {
 		data = common.CopyBytes(data)
 	}
-	return &Transaction{data: txdata{
+	return &Transaction{time: time.Now(), data: txdata{
 		AccountNonce: nonce,
 		Recipient:    nil,
 		Amount:       new(big.Int).Set(amount),
 		GasLimit:     new(big.Int).Set(gasLimit),
 		Price:        new(big.Int).Set(gasPrice),
 		Payload:      data,
+		V:            new(big.Int),
 		R:            new(big.Int),
 		S:            new(big.Int),
 	}}
@@ -98,6 +104,7 @@ func NewTransaction(nonce uint64, to common.Address, amount, gasLimit, gasPrice
 		Amount:       new(big.Int),
 		GasLimit:     new(big.Int),
 		Price:        new(big.Int),
+		V:            new(big.Int),
 		R:            new(big.Int),
 		S:            new(big.Int),
 	}
@@ -110,7 +117,7 @@ func NewTransaction(nonce uint64, to common.Address, amount, gasLimit, gasPrice
 	if gasPrice != nil {
 		d.Price.Set(gasPrice)
 	}
-	return &Transaction{data: d}
+	return &Transaction{data: d, time: time.Now()}
 }
 
 // DecodeRLP implements rlp.Encoder
@@ -120,17 +127,22 @@ func (tx *Transaction) EncodeRLP(w io.Writer) error {
 
 // DecodeRLP implements rlp.Decoder
 func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
+	var data txdata
+
 	_, size, _ := s.Kind()
-	err := s.Decode(&tx.data)
-	if err == nil {
-		tx.size.Store(common.StorageSize(rlp.ListSize(size)))
+	if err := s.Decode(&data); err != nil {
+		return err
 	}
-	return err
+	// Drop the caches of any previously decoded transaction, most notably the
+	// sender, which would not match the new signature.
+	*tx = Transaction{data: data, time: time.Now()}
+	tx.size.Store(common.StorageSize(rlp.ListSize(size)))
+	return nil
 }
 
 // MarshalJSON encodes transactions into the web3 RPC response block format.
 func (tx *Transaction) MarshalJSON() ([]byte, error) {
-	hash, v := tx.Hash(), uint64(tx.data.V)
+	hash := tx.Hash()
 
 	return json.Marshal(&jsonTransaction{
 		Hash:         &hash,
@@ -140,7 +152,7 @@ func (tx *Transaction) MarshalJSON() ([]byte, error) {
 		Recipient:    tx.data.Recipient,
 		Amount:       (*hexBig)(tx.data.Amount),
 		Payload:      (*hexBytes)(&tx.data.Payload),
-		V:            (*hexUint64)(&v),
+		V:            (*hexBig)(tx.data.V),
 		R:            (*hexBig)(tx.data.R),
 		S:            (*hexBig)(tx.data.S),
 	})
@@ -159,26 +171,31 @@ func (tx *Transaction) UnmarshalJSON(input []byte) error {
 	if dec.V == nil || dec.R == nil || dec.S == nil {
 		return errMissingTxSignatureFields
 	}
-	if !crypto.ValidateSignatureValues(byte(*dec.V), (*big.Int)(dec.R), (*big.Int)(dec.S), false) {
+	if !crypto.ValidateSignatureValues(recoveryV((*big.Int)(dec.V)), (*big.Int)(dec.R), (*big.Int)(dec.S), false) {
 		return ErrInvalidSig
 	}
 	if dec.AccountNonce == nil || dec.Price == nil || dec.GasLimit == nil || dec.Amount == nil || dec.Payload == nil {
 		return errMissingTxFields
 	}
-	// Assign the fields. This is not atomic but reusing transactions
-	// for decoding isn't thread safe anyway.
-	*tx = Transaction{}
-	tx.data = txdata{
+	decoded := txdata{
 		AccountNonce: uint64(*dec.AccountNonce),
 		Recipient:    dec.Recipient,
 		Amount:       (*big.Int)(dec.Amount),
 		GasLimit:     (*big.Int)(dec.GasLimit),
 		Price:        (*big.Int)(dec.Price),
 		Payload:      *dec.Payload,
-		V:            byte(*dec.V),
+		V:            (*big.Int)(dec.V),
 		R:            (*big.Int)(dec.R),
 		S:            (*big.Int)(dec.S),
 	}
+	// A replay protected V can't be told apart from a corrupted legacy one by its
+	// range alone, so require it to be consistent with the reported hash.
+	if isProtectedV(decoded.V) && dec.Hash != nil && rlpHash(decoded) != *dec.Hash {
+		return ErrInvalidSig
+	}
+	// Assign the fields. This is not atomic but reusing transactions
+	// for decoding isn't thread safe anyway.
+	*tx = Transaction{data: decoded, time: time.Now()}
 	return nil
 }
 
@@ -222,6 +239,54 @@ func (tx *Transaction) SigHash() common.Hash {
 	})
 }
 
+// ProtectedSigHash returns the hash to be signed by the sender in order to bind
+// the transaction to the given chain id, protecting it from being replayed on
+// other chains (EIP-155).
+func (tx *Transaction) ProtectedSigHash(chainId *big.Int) common.Hash {
+	return rlpHash([]interface{}{
+		tx.data.AccountNonce,
+		tx.data.Price,
+		tx.data.GasLimit,
+		tx.data.Recipient,
+		tx.data.Amount,
+		tx.data.Payload,
+		chainId, uint(0), uint(0),
+	})
+}
+
+// Protected returns whether the transaction's signature is bound to a specific
+// chain id.
+func (tx *Transaction) Protected() bool {
+	return isProtectedV(tx.data.V)
+}
+
+// ChainId returns the chain id the transaction's signature is bound to, or zero
+// for unprotected transactions.
+func (tx *Transaction) ChainId() *big.Int {
+	if !tx.Protected() {
+		return new(big.Int)
+	}
+	chainId := new(big.Int).Sub(tx.data.V, protectedVBase)
+	return chainId.Rsh(chainId, 1)
+}
+
+// isProtectedV returns whether a signature V value encodes a chain id.
+func isProtectedV(v *big.Int) bool {
+	return v != nil && v.Cmp(protectedVBase) >= 0
+}
+
+// recoveryV converts a signature V value into its legacy 27/28 form, stripping
+// any encoded chain id.
+func recoveryV(v *big.Int) byte {
+	if v == nil {
+		return 0
+	}
+	if !isProtectedV(v) {
+		return byte(v.Uint64())
+	}
+	return byte(new(big.Int).Sub(v, protectedVBase).Bit(0)) + 27
+}
+
 func (tx *Transaction) Size() common.StorageSize {
 	if size := tx.size.Load(); size != nil {
 		return size.(common.StorageSize)
@@ -290,12 +355,13 @@ func (tx *Transaction) Cost() *big.Int {
 }
 
 // SignatureValues returns the ECDSA signature values contained in the transaction.
-func (tx *Transaction) SignatureValues() (v byte, r *big.Int, s *big.Int) {
-	return tx.data.V, new(big.Int).Set(tx.data.R), new(big.Int).Set(tx.data.S)
+func (tx *Transaction) SignatureValues() (v, r, s *big.Int) {
+	return new(big.Int).Set(tx.data.V), new(big.Int).Set(tx.data.R), new(big.Int).Set(tx.data.S)
 }
 
 func (tx *Transaction) publicKey(homestead bool) ([]byte, error) {
-	if !crypto.ValidateSignatureValues(tx.data.V, tx.data.R, tx.data.S, homestead) {
+	v := recoveryV(tx.data.V)
+	if !crypto.ValidateSignatureValues(v, tx.data.R, tx.data.S, homestead) {
 		return nil, ErrInvalidSig
 	}
 
@@ -304,10 +370,13 @@ func (tx *Transaction) publicKey(homestead bool) ([]byte, error) {
 	sig := make([]byte, 65)
 	copy(sig[32-len(r):32], r)
 	copy(sig[64-len(s):64], s)
-	sig[64] = tx.data.V - 27
+	sig[64] = v - 27
 
 	// recover the public key from the signature
 	hash := tx.SigHash()
+	if tx.Protected() {
+		hash = tx.ProtectedSigHash(tx.ChainId())
+	}
 	pub, err := crypto.Ecrecover(hash[:], sig)
 	if err != nil {
 		return nil, err
@@ -322,10 +391,25 @@ func (tx *Transaction) WithSignature(sig []byte) (*Transaction, error) {
 	if len(sig) != 65 {
 		panic(fmt.Sprintf("wrong size for signature: got %d, want 65", len(sig)))
 	}
-	cpy := &Transaction{data: tx.data}
+	cpy := &Transaction{data: tx.data, time: tx.time}
+	cpy.data.R = new(big.Int).SetBytes(sig[:32])
+	cpy.data.S = new(big.Int).SetBytes(sig[32:64])
+	cpy.data.V = big.NewInt(int64(sig[64]) + 27)
+	return cpy, nil
+}
+
+// WithProtectedSignature returns a copy of the transaction with the given
+// signature, which must have been made over ProtectedSigHash(chainId).
+func (tx *Transaction) WithProtectedSignature(sig []byte, chainId *big.Int) (*Transaction, error) {
+	if len(sig) != 65 {
+		panic(fmt.Sprintf("wrong size for signature: got %d, want 65", len(sig)))
+	}
+	cpy := &Transaction{data: tx.data, time: tx.time}
 	cpy.data.R = new(big.Int).SetBytes(sig[:32])
 	cpy.data.S = new(big.Int).SetBytes(sig[32:64])
-	cpy.data.V = sig[64] + 27
+	cpy.data.V = new(big.Int).Lsh(chainId, 1)
+	cpy.data.V.Add(cpy.data.V, protectedVBase)
+	cpy.data.V.Add(cpy.data.V, big.NewInt(int64(sig[64])))
 	return cpy, nil
 }
 
@@ -338,6 +422,17 @@ func (tx *Transaction) SignECDSA(prv *ecdsa.PrivateKey) (*Transaction, error) {
 	return tx.WithSignature(sig)
 }
 
+// SignProtectedECDSA signs the transaction with the given key, binding it to the
+// given chain id.
+func (tx *Transaction) SignProtectedECDSA(prv *ecdsa.PrivateKey, chainId *big.Int) (*Transaction, error) {
+	h := tx.ProtectedSigHash(chainId)
+	sig, err := crypto.Sign(h[:], prv)
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithProtectedSignature(sig, chainId)
+}
+
 func (tx *Transaction) String() string {
 	var from, to string
 	if f, err := tx.From(); err != nil {
@@ -444,6 +539,26 @@ func (s *TxByPrice) Pop() interface{} {
 	return x
 }
 
+// TxByArrival implements both the sort and the heap interface, ordering
+// transactions by the time they were first seen locally.
+type TxByArrival Transactions
+
+func (s TxByArrival) Len() int           { return len(s) }
+func (s TxByArrival) Less(i, j int) bool { return s[i].time.Before(s[j].time) }
+func (s TxByArrival) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (s *TxByArrival) Push(x interface{}) {
+	*s = append(*s, x.(*Transaction))
+}
+
+func (s *TxByArrival) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
 // TransactionsByPriceAndNonce represents a set of transactions that can return
 // transactions in a profit-maximising sorted order, while supporting removing
 // entire batches of transactions for non-executable accounts.
@@ -499,3 +614,59 @@ func (t *TransactionsByPriceAndNonce) Shift() {
 func (t *TransactionsByPriceAndNonce) Pop() {
 	heap.Pop(&t.heads)
 }
+
+// TransactionsByArrivalAndNonce represents a set of transactions that can return
+// transactions in first-in-first-out order, while supporting removing entire
+// batches of transactions for non-executable accounts.
+type TransactionsByArrivalAndNonce struct {
+	txs   map[common.Address]Transactions // Per account nonce-sorted list of transactions
+	heads TxByArrival                     // Next transaction for each unique account (arrival heap)
+}
+
+// NewTransactionsByArrivalAndNonce creates a transaction set that can retrieve
+// arrival sorted transactions in a nonce-honouring way.
+//
+// Note, the input map is reowned so the caller should not interact any more with
+// if after providng it to the constructor.
+func NewTransactionsByArrivalAndNonce(txs map[common.Address]Transactions) *TransactionsByArrivalAndNonce {
+	// Initialize an arrival based heap with the head transactions
+	heads := make(TxByArrival, 0, len(txs))
+	for acc, accTxs := range txs {
+		heads = append(heads, accTxs[0])
+		txs[acc] = accTxs[1:]
+	}
+	heap.Init(&heads)
+
+	// Assemble and return the transaction set
+	return &TransactionsByArrivalAndNonce{
+		txs:   txs,
+		heads: heads,
+	}
+}
+
+// Peek returns the next transaction by arrival.
+func (t *TransactionsByArrivalAndNonce) Peek() *Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0]
+}
+
+// Shift replaces the current earliest head with the next one from the same account.
+func (t *TransactionsByArrivalAndNonce) Shift() {
+	acc, _ := t.heads[0].From() // we only sort valid txs so this cannot fail
+
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		t.heads[0], t.txs[acc] = txs[0], txs[1:]
+		heap.Fix(&t.heads, 0)
+	} else {
+		heap.Pop(&t.heads)
+	}
+}
+
+// Pop removes the earliest transaction, *not* replacing it with the next one
+// from the same account. This should be used when a transaction cannot be
+// executed and hence all subsequent ones should be discarded from the same account.
+func (t *TransactionsByArrivalAndNonce) Pop() {
+	heap.Pop(&t.heads)
+}
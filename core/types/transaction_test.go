@@ -19,6 +19,7 @@ package types
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"encoding/json"
 	"math/big"
 	"testing"
 
@@ -118,6 +119,113 @@ func TestRecipientNormal(t *testing.T) {
 	}
 }
 
+// Tests replay protected signing and sender recovery using the example from
+// EIP-155.
+func TestProtectedTransaction(t *testing.T) {
+	key := crypto.ToECDSA(common.Hex2Bytes("4646464646464646464646464646464646464646464646464646464646464646"))
+	addr := common.HexToAddress("0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f")
+
+	tx := NewTransaction(9, common.HexToAddress("0x3535353535353535353535353535353535353535"), big.NewInt(1000000000000000000), big.NewInt(21000), big.NewInt(20000000000), nil)
+	if hash := tx.ProtectedSigHash(big.NewInt(1)); hash != common.HexToHash("daf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53") {
+		t.Errorf("protected signing hash mismatch, got %x", hash)
+	}
+	// Decode the reference transaction and ensure the sender is recovered
+	decoded, err := decodeTx(common.FromHex("f86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"))
+	if err != nil {
+		t.Fatalf("failed to decode transaction: %v", err)
+	}
+	if !decoded.Protected() || decoded.ChainId().Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("replay protection mismatch: protected %v, chain id %v", decoded.Protected(), decoded.ChainId())
+	}
+	if from, err := decoded.From(); err != nil || from != addr {
+		t.Errorf("sender mismatch: have %x (%v), want %x", from, err, addr)
+	}
+	// Sign the transaction locally and ensure the sender is recovered
+	signed, err := tx.SignProtectedECDSA(key, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if signed.ChainId().Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("signed chain id mismatch: have %v, want 1", signed.ChainId())
+	}
+	if from, err := signed.From(); err != nil || from != addr {
+		t.Errorf("signed sender mismatch: have %x (%v), want %x", from, err, addr)
+	}
+	if rightvrsTx.Protected() || rightvrsTx.ChainId().Sign() != 0 {
+		t.Errorf("legacy transaction reported as protected")
+	}
+}
+
+// Tests that the cached sender of a transaction is dropped whenever its
+// signature changes.
+func TestSenderCache(t *testing.T) {
+	key, addr := defaultTestKey()
+	otherKey, _ := crypto.GenerateKey()
+	otherAddr := crypto.PubkeyToAddress(otherKey.PublicKey)
+
+	tx, _ := NewTransaction(0, common.Address{}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(key)
+	if from, err := tx.From(); err != nil || from != addr {
+		t.Fatalf("sender mismatch: have %x (%v), want %x", from, err, addr)
+	}
+	// Re-signing creates a copy, which must not inherit the sender
+	resigned, _ := tx.SignECDSA(otherKey)
+	if from, err := resigned.From(); err != nil || from != otherAddr {
+		t.Errorf("re-signed sender mismatch: have %x (%v), want %x", from, err, otherAddr)
+	}
+	// Decoding into an already used transaction must drop its sender
+	enc, _ := rlp.EncodeToBytes(resigned)
+	if err := rlp.DecodeBytes(enc, tx); err != nil {
+		t.Fatalf("failed to decode transaction: %v", err)
+	}
+	if from, err := tx.From(); err != nil || from != otherAddr {
+		t.Errorf("decoded sender mismatch: have %x (%v), want %x", from, err, otherAddr)
+	}
+}
+
+// Tests that decoded transactions are stamped with the time they were decoded
+// at, so they don't sort before the ones seen earlier in arrival order.
+func TestDecodedArrivalTime(t *testing.T) {
+	key, _ := defaultTestKey()
+	tx, _ := NewTransaction(0, common.Address{}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(key)
+	enc, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("failed to encode transaction: %v", err)
+	}
+	var dec Transaction
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatalf("failed to decode transaction: %v", err)
+	}
+	if dec.time.Before(tx.time) {
+		t.Errorf("JSON decoded transaction arrival mismatch: have %v, want after %v", dec.time, tx.time)
+	}
+	if (TxByArrival{&dec, tx}).Less(0, 1) {
+		t.Errorf("JSON decoded transaction sorted before the earlier one")
+	}
+}
+
+func BenchmarkSenderRecovery(b *testing.B) {
+	key, _ := defaultTestKey()
+	tx, _ := NewTransaction(0, common.Address{}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(key)
+	enc, _ := rlp.EncodeToBytes(tx)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tx, _ := decodeTx(enc)
+		tx.From()
+	}
+}
+
+func BenchmarkSenderCached(b *testing.B) {
+	key, _ := defaultTestKey()
+	tx, _ := NewTransaction(0, common.Address{}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(key)
+	tx.From()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tx.From()
+	}
+}
+
 // Tests that transactions can be correctly sorted according to their price in
 // decreasing order, but at the same time with increasing nonces when issued by
 // the same account.
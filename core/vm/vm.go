@@ -19,6 +19,7 @@ package vm
 import (
 	"fmt"
 	"math/big"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -34,6 +35,7 @@ type Config struct {
 	EnableJit bool
 	ForceJit  bool
 	Tracer    Tracer
+	Interrupt *int32 // Aborts the execution once set to non-zero, may be nil
 }
 
 // EVM is used to run Ethereum based contracts and will utilise the
@@ -168,6 +170,10 @@ func (evm *EVM) Run(contract *Contract, input []byte) (ret []byte, err error) {
 			}
 		*/
 
+		// Abort the execution if it was interrupted externally
+		if evm.cfg.Interrupt != nil && atomic.LoadInt32(evm.cfg.Interrupt) != 0 {
+			return nil, ErrExecutionInterrupted
+		}
 		// Get the memory location of pc
 		op = contract.GetOp(pc)
 		// calculate the new memory size and gas price for the current executing opcode
@@ -65,6 +65,12 @@ func CreateAddress(b common.Address, nonce uint64) common.Address {
 	return common.BytesToAddress(Keccak256(data)[12:])
 }
 
+// CreateAddress2 creates an ethereum address given the address bytes, the salt
+// and the hash of the contract init code, as done by the CREATE2 opcode.
+func CreateAddress2(b common.Address, salt [32]byte, inithash []byte) common.Address {
+	return common.BytesToAddress(Keccak256([]byte{0xff}, b.Bytes(), salt[:], inithash)[12:])
+}
+
 func Sha256(data []byte) []byte {
 	hash := sha256.Sum256(data)
 
@@ -0,0 +1,129 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// AccountActivity summarises the transactions of an account in the canonical
+// chain. The block numbers are nil if the account was never active.
+type AccountActivity struct {
+	FirstBlock *rpc.HexNumber `json:"firstBlock"`
+	LastBlock  *rpc.HexNumber `json:"lastBlock"`
+	TxsSent    *rpc.HexNumber `json:"txsSent"`
+}
+
+// activity is the indexed activity of a single account.
+type activity struct {
+	first, last uint64
+	sent        uint64
+}
+
+// activityIndex tracks, for every account, the first and last canonical blocks
+// in which it sent a transaction or received value through one, as well as the
+// number of transactions it sent. The index is built on first use by scanning
+// the chain, after which only new blocks are processed. If the indexed chain is
+// reorganised away, the index is rebuilt.
+type activityIndex struct {
+	chain *core.BlockChain
+
+	accounts map[common.Address]*activity
+	head     *types.Header // Last block processed into the index
+	lock     sync.Mutex
+}
+
+// newActivityIndex creates an empty account activity index over the given chain.
+func newActivityIndex(chain *core.BlockChain) *activityIndex {
+	return &activityIndex{
+		chain:    chain,
+		accounts: make(map[common.Address]*activity),
+	}
+}
+
+// get returns the activity of the given account, bringing the index up to date
+// with the current head of the chain first.
+func (idx *activityIndex) get(address common.Address) (*AccountActivity, error) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	if err := idx.update(); err != nil {
+		return nil, err
+	}
+	result := &AccountActivity{TxsSent: rpc.NewHexNumber(0)}
+	if act, ok := idx.accounts[address]; ok {
+		result.FirstBlock = rpc.NewHexNumber(act.first)
+		result.LastBlock = rpc.NewHexNumber(act.last)
+		result.TxsSent = rpc.NewHexNumber(act.sent)
+	}
+	return result, nil
+}
+
+// update processes all the canonical blocks not yet in the index. The caller
+// must hold the index lock.
+func (idx *activityIndex) update() error {
+	// Drop the index if the chain was reorganised below its head
+	if idx.head != nil {
+		if canon := idx.chain.GetHeaderByNumber(idx.head.Number.Uint64()); canon == nil || canon.Hash() != idx.head.Hash() {
+			glog.V(logger.Debug).Infof("Account activity index head #%d [%x…] reorged, rebuilding", idx.head.Number, idx.head.Hash().Bytes()[:4])
+			idx.accounts = make(map[common.Address]*activity)
+			idx.head = nil
+		}
+	}
+	next := uint64(0)
+	if idx.head != nil {
+		next = idx.head.Number.Uint64() + 1
+	}
+	head := idx.chain.CurrentBlock().NumberU64()
+	for number := next; number <= head; number++ {
+		block := idx.chain.GetBlockByNumber(number)
+		if block == nil {
+			return fmt.Errorf("block #%d not found", number)
+		}
+		for _, tx := range block.Transactions() {
+			from, err := tx.From()
+			if err != nil {
+				return fmt.Errorf("block #%d: tx %x: failed to retrieve sender: %v", number, tx.Hash(), err)
+			}
+			idx.touch(from, number).sent++
+			if to := tx.To(); to != nil && tx.Value().Sign() > 0 {
+				idx.touch(*to, number)
+			}
+		}
+		idx.head = block.Header()
+	}
+	return nil
+}
+
+// touch records activity of an account in the given block, returning its entry.
+func (idx *activityIndex) touch(address common.Address, number uint64) *activity {
+	act, ok := idx.accounts[address]
+	if !ok {
+		act = &activity{first: number}
+		idx.accounts[address] = act
+	}
+	act.last = number
+	return act
+}
@@ -25,20 +25,25 @@ import (
 	"math/big"
 	"os"
 	"runtime"
+	"sort"
+	"sync/atomic"
 	"time"
 
-	"github.com/ethereum/ethash"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
 	"golang.org/x/net/context"
 )
 
@@ -70,6 +75,12 @@ func (s *PublicEthereumAPI) Hashrate() *rpc.HexNumber {
 	return rpc.NewHexNumber(s.e.Miner().HashRate())
 }
 
+// OldestBlock returns the number of the lowest block whose state is still
+// available on this node.
+func (s *PublicEthereumAPI) OldestBlock() *rpc.HexNumber {
+	return rpc.NewHexNumber(s.e.BlockChain().OldestBlock())
+}
+
 // PublicMinerAPI provides an API to control the miner.
 // It offers only methods that operate on data that pose no security risk when it is publicly accessible.
 type PublicMinerAPI struct {
@@ -124,12 +135,13 @@ func (s *PublicMinerAPI) SubmitHashrate(hashrate rpc.HexNumber, id common.Hash)
 // PrivateMinerAPI provides private RPC methods to control the miner.
 // These methods can be abused by external users and must be considered insecure for use by untrusted users.
 type PrivateMinerAPI struct {
-	e *Ethereum
+	e    *Ethereum
+	dags *dagJobs
 }
 
 // NewPrivateMinerAPI create a new RPC service which controls the miner of this node.
 func NewPrivateMinerAPI(e *Ethereum) *PrivateMinerAPI {
-	return &PrivateMinerAPI{e: e}
+	return &PrivateMinerAPI{e: e, dags: e.dags}
 }
 
 // Start the miner with the given number of threads. If threads is nil the number of
@@ -168,12 +180,115 @@ func (s *PrivateMinerAPI) SetGasPrice(gasPrice rpc.HexNumber) bool {
 	return true
 }
 
+// SetTxOrdering sets the strategy for selecting transactions into new blocks,
+// either "price" for highest gas price first or "fifo" for first seen first.
+func (s *PrivateMinerAPI) SetTxOrdering(mode string) (bool, error) {
+	if err := s.e.Miner().SetTxOrdering(mode); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PredictBlockTransactions runs the block builder's transaction selection against
+// the current chain head and transaction pool, returning the transactions the
+// next block would include without sealing it. Useful for debugging why some
+// transaction isn't getting mined.
+func (s *PrivateMinerAPI) PredictBlockTransactions() ([]*ethapi.RPCTransaction, error) {
+	txs, err := s.e.Miner().PredictTransactions()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*ethapi.RPCTransaction, len(txs))
+	for i, tx := range txs {
+		results[i] = ethapi.NewRPCPendingTransaction(tx)
+	}
+	return results, nil
+}
+
+// MinerState returns a snapshot of the miner's configuration and progress, to aid
+// debugging a node that doesn't seem to be mining.
+func (s *PrivateMinerAPI) MinerState() (map[string]interface{}, error) {
+	miner := s.e.Miner()
+
+	pending, _ := miner.Pending()
+	if pending == nil {
+		return nil, errors.New("no pending block")
+	}
+	autoDAG, generating := s.e.AutoDAGStatus()
+
+	var (
+		dagEpoch   uint64
+		dagPercent int
+	)
+	if generating != nil {
+		dagEpoch, dagPercent = generating.Block/epochLength, generating.Percent
+	}
+
+	return map[string]interface{}{
+		"mining":              miner.Mining(),
+		"coinbase":            miner.Coinbase(),
+		"coinbaseRotation":    miner.EtherbaseRotation(),
+		"extraData":           common.ToHex(miner.Extra()),
+		"gasPrice":            rpc.NewHexNumber(miner.GasPrice()),
+		"autoDAG":             autoDAG,
+		"dagGenerating":       generating != nil,
+		"dagEpoch":            rpc.NewHexNumber(dagEpoch),
+		"dagPercent":          dagPercent,
+		"agents":              miner.Agents(),
+		"pendingNumber":       rpc.NewHexNumber(pending.Number()),
+		"pendingTransactions": len(pending.Transactions()),
+	}, nil
+}
+
+// PendingBlockFeeStats returns the spread of the gas prices paid by the
+// transactions included in the pending block, i.e. the minimum, median and
+// maximum price, along with the total fees they pay. For even transaction counts
+// the median is the average of the two middle prices, as in eth_gasStats. All
+// are zero for an empty pending block.
+func (s *PrivateMinerAPI) PendingBlockFeeStats() (map[string]*rpc.HexNumber, error) {
+	pending, receipts := s.e.Miner().PendingReceipts()
+	if pending == nil {
+		return nil, errors.New("no pending block")
+	}
+	txs := pending.Transactions()
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("pending block has %d receipts for %d transactions", len(receipts), len(txs))
+	}
+	stats := map[string]*rpc.HexNumber{
+		"min":       rpc.NewHexNumber(0),
+		"median":    rpc.NewHexNumber(0),
+		"max":       rpc.NewHexNumber(0),
+		"totalFees": rpc.NewHexNumber(0),
+	}
+	if len(txs) == 0 {
+		return stats, nil
+	}
+	fees := new(big.Int)
+	for i, tx := range txs {
+		fees.Add(fees, new(big.Int).Mul(tx.GasPrice(), receipts[i].GasUsed))
+	}
+	min, median, max := ethapi.GasPriceSpread(txs)
+
+	stats["min"] = rpc.NewHexNumber(min)
+	stats["median"] = rpc.NewHexNumber(median)
+	stats["max"] = rpc.NewHexNumber(max)
+	stats["totalFees"] = rpc.NewHexNumber(fees)
+	return stats, nil
+}
+
 // SetEtherbase sets the etherbase of the miner
 func (s *PrivateMinerAPI) SetEtherbase(etherbase common.Address) bool {
 	s.e.SetEtherbase(etherbase)
 	return true
 }
 
+// SetEtherbaseRotation makes successive mined blocks credit the given addresses
+// in round-robin order instead of the etherbase. An empty list disables the
+// rotation, while lists containing the zero address are refused.
+func (s *PrivateMinerAPI) SetEtherbaseRotation(addresses []common.Address) bool {
+	return s.e.Miner().SetEtherbaseRotation(addresses)
+}
+
 // StartAutoDAG starts auto DAG generation. This will prevent the DAG generating on epoch change
 // which will cause the node to stop mining during the generation process.
 func (s *PrivateMinerAPI) StartAutoDAG() bool {
@@ -181,18 +296,126 @@ func (s *PrivateMinerAPI) StartAutoDAG() bool {
 	return true
 }
 
+// SetAutoDAGLookahead sets how many blocks before an epoch change the auto DAG
+// generation for the next epoch starts. Operators on slow disks may want to start
+// earlier than the default of half an epoch.
+func (s *PrivateMinerAPI) SetAutoDAGLookahead(blocks uint64) (bool, error) {
+	if err := s.e.SetAutoDAGLookahead(blocks); err != nil {
+		return false, rpc.ErrInvalidArgs("%v", err)
+	}
+	return true, nil
+}
+
 // StopAutoDAG stops auto DAG generation
 func (s *PrivateMinerAPI) StopAutoDAG() bool {
 	s.e.StopAutoDAG()
 	return true
 }
 
-// MakeDAG creates the new DAG for the given block number
-func (s *PrivateMinerAPI) MakeDAG(blockNr rpc.BlockNumber) (bool, error) {
-	if err := ethash.MakeDAG(uint64(blockNr.Int64()), ""); err != nil {
-		return false, err
+// MakeDAG starts creating the DAG for the given block number in the background,
+// returning an id to track the generation with DAGProgress or abort it with
+// CancelDAG.
+func (s *PrivateMinerAPI) MakeDAG(blockNr rpc.BlockNumber) (int, error) {
+	block := uint64(blockNr.Int64())
+	if blockNr < 0 {
+		block = s.e.BlockChain().CurrentBlock().NumberU64()
+	}
+	return s.dags.start(block), nil
+}
+
+// DAGProgress returns the progress of a DAG generation started with MakeDAG.
+func (s *PrivateMinerAPI) DAGProgress(id int) (*DAGStatus, error) {
+	status, ok := s.dags.status(id)
+	if !ok {
+		return nil, rpc.ErrNotFound("DAG generation %d not found", id)
+	}
+	return &status, nil
+}
+
+// CancelDAG aborts a DAG generation started with MakeDAG, removing the files it
+// created. DAG files existing before the generation started are kept. It returns
+// false if the generation is unknown or finished.
+func (s *PrivateMinerAPI) CancelDAG(id int) bool {
+	return s.dags.cancel(id)
+}
+
+// pendingBlockInterval is the minimum time between two pending block
+// notifications sent to a subscriber.
+const pendingBlockInterval = 250 * time.Millisecond
+
+// PendingBlockResult is the notification sent when the pending block changes.
+type PendingBlockResult struct {
+	Header       *types.Header `json:"header"`
+	Transactions int           `json:"transactions"`
+}
+
+// NewPendingBlock creates a subscription that is notified whenever the miner
+// rebuilds its pending block, e.g. on a new parent or an included transaction.
+// Rapid rebuilds are coalesced, delivering only the latest pending block.
+func (s *PrivateMinerAPI) NewPendingBlock(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	events := s.e.EventMux().Subscribe(core.PendingBlockEvent{})
+
+	go func() {
+		quit := make(chan struct{})
+		go func() {
+			select {
+			case <-rpcSub.Err(): // client send an unsubscribe request
+			case <-notifier.Closed(): // connection dropped
+			}
+			close(quit)
+		}()
+		throttlePendingBlocks(events.Chan(), quit, pendingBlockInterval, func(block *types.Block) {
+			notifier.Notify(rpcSub.ID, &PendingBlockResult{Header: block.Header(), Transactions: len(block.Transactions())})
+		})
+		events.Unsubscribe()
+	}()
+
+	return rpcSub, nil
+}
+
+// throttlePendingBlocks forwards the pending blocks of the given event stream to
+// deliver, calling it at most once per interval. Blocks arriving before the
+// interval passes are coalesced, with only the latest one delivered when it
+// does. The method returns when quit is closed or the event stream ends.
+func throttlePendingBlocks(events <-chan *event.Event, quit <-chan struct{}, interval time.Duration, deliver func(*types.Block)) {
+	var (
+		last    time.Time    // Time of the last delivery
+		pending *types.Block // Latest block waiting for the interval to pass
+		timer   = time.NewTimer(0)
+	)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		select {
+		case ev := <-events:
+			if ev == nil {
+				return
+			}
+			block := ev.Data.(core.PendingBlockEvent).Block
+			if pending == nil {
+				if wait := interval - time.Since(last); wait > 0 {
+					timer.Reset(wait)
+				} else {
+					deliver(block)
+					last = time.Now()
+					continue
+				}
+			}
+			pending = block
+		case <-timer.C:
+			deliver(pending)
+			last, pending = time.Now(), nil
+		case <-quit:
+			return
+		}
 	}
-	return true, nil
 }
 
 // PrivateAdminAPI is the collection of Etheruem full node-related APIs
@@ -223,16 +446,6 @@ func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
 	return true, nil
 }
 
-func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
-	for _, b := range bs {
-		if !chain.HasBlock(b.Hash()) {
-			return false
-		}
-	}
-
-	return true
-}
-
 // ImportChain imports a blockchain from a local file.
 func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	// Make sure the can access the file to import
@@ -243,38 +456,342 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	defer in.Close()
 
 	// Run actual the import in pre-configured batches
-	stream := rlp.NewStream(in, 0)
+	if _, err := api.eth.BlockChain().ImportChainStream(in); err != nil {
+		return false, err
+	}
+	return true, nil
+}
 
-	blocks, index := make([]*types.Block, 0, 2500), 0
-	for batch := 0; ; batch++ {
-		// Load a batch of blocks from the input file
-		for len(blocks) < cap(blocks) {
-			block := new(types.Block)
-			if err := stream.Decode(block); err == io.EOF {
-				break
-			} else if err != nil {
-				return false, fmt.Errorf("block %d: failed to parse: %v", index, err)
-			}
-			blocks = append(blocks, block)
-			index++
+// RepairCanonical walks the chain back from the stored head block, rewriting the
+// canonical number to hash mappings and transaction lookup entries disagreeing
+// with it, e.g. after an unclean shutdown left transactions unfindable. The
+// walk stops once a long stretch of consistent blocks was seen. A report of the
+// repaired blocks is returned.
+func (api *PrivateAdminAPI) RepairCanonical() (*RepairReport, error) {
+	report, err := repairCanonical(api.eth.BlockChain(), api.eth.ChainDb())
+	if err != nil {
+		return nil, err
+	}
+	glog.V(logger.Info).Infof("Repaired canonical chain from #%d: %d blocks checked, %d remapped, %d stale, %d reindexed",
+		report.Head, report.Checked, len(report.Canonical), len(report.Stale), len(report.Reindexed))
+	return report, nil
+}
+
+// ExportPool returns the hex encoded RLP list of all the transactions currently
+// in the transaction pool, ordered by account and nonce, so that they can be
+// imported into another node with ImportPool.
+func (api *PrivateAdminAPI) ExportPool() (string, error) {
+	data, err := rlp.EncodeToBytes(api.poolTransactions())
+	if err != nil {
+		return "", err
+	}
+	return common.ToHex(data), nil
+}
+
+// ImportPool decodes a list of transactions exported by ExportPool and adds them
+// to the transaction pool, returning the number of transactions accepted. Those
+// which are no longer valid are skipped.
+func (api *PrivateAdminAPI) ImportPool(data string) (int, error) {
+	var txs types.Transactions
+	if err := rlp.DecodeBytes(common.FromHex(data), &txs); err != nil {
+		return 0, rpc.ErrInvalidArgs("failed to decode transactions: %v", err)
+	}
+	accepted := 0
+	for _, tx := range txs {
+		if api.importPoolTx(tx) {
+			accepted++
 		}
-		if len(blocks) == 0 {
+	}
+	glog.V(logger.Info).Infof("Imported %d pool transactions, skipped %d", accepted, len(txs)-accepted)
+	return accepted, nil
+}
+
+// DumpPoolToFile writes all the transactions currently in the transaction pool
+// to a local file as a stream of RLP encoded transactions, returning their count.
+// Unlike ExportPool the transactions are not held in memory as a whole, which
+// suits large pools, e.g. to persist them across a restart with LoadPoolFromFile.
+func (api *PrivateAdminAPI) DumpPoolToFile(file string) (int, error) {
+	// Write into a temporary file first, so a failed dump leaves no partial file
+	out, err := os.OpenFile(file+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	txs := api.poolTransactions()
+	for _, tx := range txs {
+		if err = rlp.Encode(out, tx); err != nil {
 			break
 		}
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file+".tmp", file)
+	}
+	if err != nil {
+		os.Remove(file + ".tmp")
+		return 0, err
+	}
+	glog.V(logger.Info).Infof("Dumped %d pool transactions to %s", len(txs), file)
+	return len(txs), nil
+}
 
-		if hasAllBlocks(api.eth.BlockChain(), blocks) {
-			blocks = blocks[:0]
-			continue
+// LoadPoolFromFile adds the transactions dumped to a local file by DumpPoolToFile
+// to the transaction pool, returning the number of transactions accepted. Those
+// which are no longer valid are skipped.
+func (api *PrivateAdminAPI) LoadPoolFromFile(file string) (int, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	stream := rlp.NewStream(in, 0)
+
+	accepted, index := 0, 0
+	for ; ; index++ {
+		tx := new(types.Transaction)
+		if err := stream.Decode(tx); err == io.EOF {
+			break
+		} else if err != nil {
+			return accepted, fmt.Errorf("transaction %d: failed to parse: %v", index, err)
+		}
+		if api.importPoolTx(tx) {
+			accepted++
+		}
+	}
+	glog.V(logger.Info).Infof("Loaded %d pool transactions from %s, skipped %d", accepted, file, index-accepted)
+	return accepted, nil
+}
+
+// poolTransactions returns all the transactions in the transaction pool, the
+// pending ones first, grouped by account in ascending address order and ordered
+// by nonce.
+func (api *PrivateAdminAPI) poolTransactions() types.Transactions {
+	pending, queued := api.eth.TxPool().Content()
+
+	var txs types.Transactions
+	for _, content := range []map[common.Address]types.Transactions{pending, queued} {
+		accounts := make(addressesByBytes, 0, len(content))
+		for addr := range content {
+			accounts = append(accounts, addr)
 		}
-		// Import the batch and reset the buffer
-		if _, err := api.eth.BlockChain().InsertChain(blocks); err != nil {
-			return false, fmt.Errorf("batch %d: failed to insert: %v", batch, err)
+		sort.Sort(accounts)
+		for _, addr := range accounts {
+			txs = append(txs, content[addr]...)
 		}
-		blocks = blocks[:0]
 	}
+	return txs
+}
+
+// addressesByBytes implements sort.Interface for a list of addresses, ordering
+// them by their byte representation.
+type addressesByBytes []common.Address
+
+func (a addressesByBytes) Len() int           { return len(a) }
+func (a addressesByBytes) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a addressesByBytes) Less(i, j int) bool { return bytes.Compare(a[i][:], a[j][:]) < 0 }
+
+// importPoolTx adds an imported transaction to the transaction pool, reporting
+// whether it was accepted. Transactions no longer valid are skipped.
+func (api *PrivateAdminAPI) importPoolTx(tx *types.Transaction) bool {
+	if err := api.eth.TxPool().Add(tx); err != nil {
+		glog.V(logger.Debug).Infof("Skipping imported tx %x: %v", tx.Hash(), err)
+		return false
+	}
+	return true
+}
+
+// SetSenderPolicy restricts the senders the transaction pool accepts new
+// transactions from. In "allow" mode only the listed senders are accepted (any
+// if the list is empty), in "deny" mode the listed ones are rejected, while an
+// empty mode lifts all restrictions.
+func (api *PrivateAdminAPI) SetSenderPolicy(mode string, addresses []common.Address) (bool, error) {
+	if err := api.eth.TxPool().SetSenderPolicy(mode, addresses); err != nil {
+		return false, rpc.ErrInvalidArgs("%v", err)
+	}
+	return true, nil
+}
+
+// SetSenderMinGasPrice overrides the minimum gas price the transaction pool and
+// the miner accept from the given sender, e.g. to let a sponsoring relayer submit
+// zero priced transactions. Omitting the price restores the global minimum.
+func (api *PrivateAdminAPI) SetSenderMinGasPrice(addr common.Address, price *rpc.HexNumber) bool {
+	if price == nil {
+		api.eth.TxPool().SetSenderMinGasPrice(addr, nil)
+	} else {
+		api.eth.TxPool().SetSenderMinGasPrice(addr, price.BigInt())
+	}
+	return true
+}
+
+// PoolPressureThresholds returns the transaction pool utilization percentages
+// crossing which notifies txpool_poolPressure subscribers.
+func (api *PrivateAdminAPI) PoolPressureThresholds() []float64 {
+	return api.eth.TxPool().PressureThresholds()
+}
+
+// SetPoolPressureThresholds sets the transaction pool utilization percentages
+// (e.g. [80, 95]) crossing which, in either direction, notifies txpool_poolPressure
+// subscribers. An empty list disables the notifications.
+func (api *PrivateAdminAPI) SetPoolPressureThresholds(thresholds []float64) (bool, error) {
+	if err := api.eth.TxPool().SetPressureThresholds(thresholds); err != nil {
+		return false, rpc.ErrInvalidArgs("%v", err)
+	}
+	return true, nil
+}
+
+// MaxReorgDepth returns the maximum number of canonical blocks a chain
+// reorganisation may drop, or zero if unlimited.
+func (api *PrivateAdminAPI) MaxReorgDepth() uint64 {
+	return api.eth.BlockChain().MaxReorgDepth()
+}
+
+// SetMaxReorgDepth sets the maximum number of canonical blocks a chain
+// reorganisation caused by a block import may drop. Zero disables the limit.
+func (api *PrivateAdminAPI) SetMaxReorgDepth(depth uint64) bool {
+	api.eth.BlockChain().SetMaxReorgDepth(depth)
+	return true
+}
+
+// TxBroadcastWindow returns the time transactions accepted into the pool are
+// gathered for before being announced to peers together, zero if disabled.
+func (api *PrivateAdminAPI) TxBroadcastWindow() string {
+	return api.eth.protocolManager.TxBroadcastWindow().String()
+}
+
+// SetTxBroadcastWindow sets the time transactions accepted into the pool are
+// gathered for before being announced to peers together (e.g. "100ms"), which
+// reduces message overhead under high submission rates. Zero disables batching.
+func (api *PrivateAdminAPI) SetTxBroadcastWindow(window string) (bool, error) {
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		return false, err
+	}
+	if d < 0 {
+		return false, fmt.Errorf("negative broadcast window %v", d)
+	}
+	api.eth.protocolManager.SetTxBroadcastWindow(d)
+	return true, nil
+}
+
+// MaxDebugCalls returns the maximum number of expensive debug calls allowed to
+// run concurrently, or zero if unlimited.
+func (api *PrivateAdminAPI) MaxDebugCalls() int {
+	return api.eth.debugCalls.Limit()
+}
+
+// SetMaxDebugCalls sets the maximum number of expensive debug calls (traces,
+// state dumps, block reprocessing) allowed to run concurrently. Calls beyond the
+// limit are rejected as the server being busy. Zero disables the limit.
+func (api *PrivateAdminAPI) SetMaxDebugCalls(limit int) (bool, error) {
+	if limit < 0 {
+		return false, rpc.ErrInvalidArgs("negative debug call limit %d", limit)
+	}
+	api.eth.debugCalls.SetLimit(limit)
+	return true, nil
+}
+
+// TxBumpingResult is the configuration of the gas price bumping of stuck local
+// transactions.
+type TxBumpingResult struct {
+	Delay    string         `json:"delay"`
+	Percent  int            `json:"percent"`
+	MaxPrice *rpc.HexNumber `json:"maxPrice"`
+}
+
+// TxBumping returns the delay after which pending transactions of local accounts
+// get their gas price bumped, zero if disabled, the bump percentage and the gas
+// price bumping never exceeds.
+func (api *PrivateAdminAPI) TxBumping() TxBumpingResult {
+	delay, percent, maxPrice := api.eth.txBumper.Config()
+
+	result := TxBumpingResult{Delay: delay.String(), Percent: percent}
+	if maxPrice != nil {
+		result.MaxPrice = rpc.NewHexNumber(maxPrice)
+	}
+	return result
+}
+
+// SetTxBumping enables automatic fee escalation: the lowest nonce transaction of
+// every local account, once pending for longer than the given delay (e.g. "5m"),
+// is re-signed at a gas price bumped by the given percentage and rebroadcast.
+// Bumping never raises the gas price above maxPrice, so a transaction stuck for
+// long can't drain its account. The accounts need to be unlocked for this. A
+// zero delay disables bumping.
+func (api *PrivateAdminAPI) SetTxBumping(delay string, percent int, maxPrice *rpc.HexNumber) (bool, error) {
+	d, err := time.ParseDuration(delay)
+	if err != nil {
+		return false, rpc.ErrInvalidArgs("invalid delay: %v", err)
+	}
+	if d < 0 {
+		return false, rpc.ErrInvalidArgs("negative delay %v", d)
+	}
+	if d == 0 {
+		api.eth.txBumper.SetConfig(0, 0, nil)
+		return true, nil
+	}
+	if percent <= 0 {
+		return false, rpc.ErrInvalidArgs("non-positive bump percentage %d", percent)
+	}
+	if maxPrice == nil || maxPrice.BigInt().Sign() <= 0 {
+		return false, rpc.ErrInvalidArgs("missing or non-positive maximum gas price")
+	}
+	api.eth.txBumper.SetConfig(d, percent, maxPrice.BigInt())
+	return true, nil
+}
+
+// MinServingPeers returns the minimum number of peers the node needs before it
+// serves state reads, or zero if they are always served.
+func (api *PrivateAdminAPI) MinServingPeers() int {
+	return api.eth.MinServingPeers()
+}
+
+// SetMinServingPeers sets the minimum number of peers the node needs, besides
+// having finished its initial sync, before state reading calls are served. Until
+// then they fail with a node not ready error. Zero serves them unconditionally.
+func (api *PrivateAdminAPI) SetMinServingPeers(peers int) (bool, error) {
+	if peers < 0 {
+		return false, rpc.ErrInvalidArgs("negative peer count %d", peers)
+	}
+	api.eth.SetMinServingPeers(peers)
 	return true, nil
 }
 
+// debugLimiter bounds the number of expensive debug calls running concurrently,
+// protecting the node from running out of memory under a storm of them.
+type debugLimiter struct {
+	limit   int32 // Maximum number of concurrent calls, 0 if unlimited (atomic)
+	running int32 // Number of calls currently running (atomic)
+}
+
+// Limit returns the maximum number of concurrent calls, zero if unlimited.
+func (l *debugLimiter) Limit() int {
+	return int(atomic.LoadInt32(&l.limit))
+}
+
+// SetLimit sets the maximum number of concurrent calls, zero for unlimited.
+// Calls already running are not affected.
+func (l *debugLimiter) SetLimit(limit int) {
+	atomic.StoreInt32(&l.limit, int32(limit))
+}
+
+// acquire reserves a slot for an expensive call, failing with a server busy
+// error if all of them are taken. Successful reservations must be released.
+func (l *debugLimiter) acquire() error {
+	limit := atomic.LoadInt32(&l.limit)
+	if running := atomic.AddInt32(&l.running, 1); limit > 0 && running > limit {
+		atomic.AddInt32(&l.running, -1)
+		return rpc.ErrServerBusy("server busy: %d expensive debug calls already running", limit)
+	}
+	return nil
+}
+
+// release frees a slot reserved by acquire.
+func (l *debugLimiter) release() {
+	atomic.AddInt32(&l.running, -1)
+}
+
 // PublicDebugAPI is the collection of Etheruem full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -289,9 +806,14 @@ func NewPublicDebugAPI(eth *Ethereum) *PublicDebugAPI {
 
 // DumpBlock retrieves the entire state of the database at a given block.
 func (api *PublicDebugAPI) DumpBlock(number uint64) (state.Dump, error) {
+	if err := api.eth.debugCalls.acquire(); err != nil {
+		return state.Dump{}, err
+	}
+	defer api.eth.debugCalls.release()
+
 	block := api.eth.BlockChain().GetBlockByNumber(number)
 	if block == nil {
-		return state.Dump{}, fmt.Errorf("block #%d not found", number)
+		return state.Dump{}, rpc.ErrNotFound("block #%d not found", number)
 	}
 	stateDb, err := api.eth.BlockChain().StateAt(block.Root())
 	if err != nil {
@@ -300,17 +822,63 @@ func (api *PublicDebugAPI) DumpBlock(number uint64) (state.Dump, error) {
 	return stateDb.RawDump(), nil
 }
 
+// GetRawHeaderByNumber retrieves the RLP encoded form of a canonical block
+// header, or an empty string if the block is unknown.
+func (api *PublicDebugAPI) GetRawHeaderByNumber(number uint64) (string, error) {
+	return encodeRawHeader(api.eth.BlockChain().GetHeaderByNumber(number))
+}
+
+// GetRawHeaderByHash retrieves the RLP encoded form of a block header, or an
+// empty string if the block is unknown.
+func (api *PublicDebugAPI) GetRawHeaderByHash(hash common.Hash) (string, error) {
+	return encodeRawHeader(api.eth.BlockChain().GetHeaderByHash(hash))
+}
+
+// GetBlockBody retrieves the RLP encoded body (transactions and uncles) of a
+// block, or an empty string if the block is unknown. Together with the raw
+// header it can be reassembled into the full block RLP.
+func (api *PublicDebugAPI) GetBlockBody(hash common.Hash) (string, error) {
+	body := api.eth.BlockChain().GetBodyRLP(hash)
+	if len(body) == 0 {
+		return "", nil
+	}
+	return common.ToHex(body), nil
+}
+
+// encodeRawHeader RLP encodes a header into its 0x prefixed hex form, returning
+// an empty string for missing headers.
+func encodeRawHeader(header *types.Header) (string, error) {
+	if header == nil {
+		return "", nil
+	}
+	encoded, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		return "", err
+	}
+	return common.ToHex(encoded), nil
+}
+
 // PrivateDebugAPI is the collection of Etheruem full node APIs exposed over
 // the private debugging endpoint.
 type PrivateDebugAPI struct {
-	config *core.ChainConfig
-	eth    *Ethereum
+	config   *core.ChainConfig
+	eth      *Ethereum
+	activity *activityIndex
 }
 
 // NewPrivateDebugAPI creates a new API definition for the full node-related
 // private debug methods of the Ethereum service.
 func NewPrivateDebugAPI(config *core.ChainConfig, eth *Ethereum) *PrivateDebugAPI {
-	return &PrivateDebugAPI{config: config, eth: eth}
+	return &PrivateDebugAPI{config: config, eth: eth, activity: newActivityIndex(eth.BlockChain())}
+}
+
+// GetAccountActivity returns the first and last canonical blocks in which the
+// given account sent a transaction or received value through one, along with
+// the number of transactions it sent. The chain is indexed on first use, which
+// scans it from the genesis block and keeps every account seen in memory, so
+// the method is only available through the private debug API.
+func (api *PrivateDebugAPI) GetAccountActivity(address common.Address) (*AccountActivity, error) {
+	return api.activity.get(address)
 }
 
 // BlockTraceResult is the returned value when replaying a block to check for
@@ -389,6 +957,11 @@ func (api *PrivateDebugAPI) TraceBlockByHash(hash common.Hash, config *vm.LogCon
 
 // traceBlock processes the given block but does not save the state.
 func (api *PrivateDebugAPI) traceBlock(block *types.Block, logConfig *vm.LogConfig) (bool, []vm.StructLog, error) {
+	if err := api.eth.debugCalls.acquire(); err != nil {
+		return false, nil, err
+	}
+	defer api.eth.debugCalls.release()
+
 	// Validate and reprocess the block
 	var (
 		blockchain = api.eth.BlockChain()
@@ -421,6 +994,146 @@ func (api *PrivateDebugAPI) traceBlock(block *types.Block, logConfig *vm.LogConf
 	return true, structLogger.StructLogs(), nil
 }
 
+// ProcessBlockResult is the outcome of reprocessing a block, comparing the gas
+// used and the state root computed locally to the ones declared in its header.
+type ProcessBlockResult struct {
+	UsedGas      *rpc.HexNumber `json:"usedGas"`
+	ExpectedGas  *rpc.HexNumber `json:"expectedGas"`
+	Root         common.Hash    `json:"root"`
+	ExpectedRoot common.Hash    `json:"expectedRoot"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// ProcessBlock reprocesses the given canonical block on top of its parent state
+// without saving anything, reporting the gas used and state root it results in
+// along with any validation error, to help diagnosing consensus issues.
+func (api *PrivateDebugAPI) ProcessBlock(number uint64) (*ProcessBlockResult, error) {
+	if err := api.eth.debugCalls.acquire(); err != nil {
+		return nil, err
+	}
+	defer api.eth.debugCalls.release()
+
+	block := api.eth.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return nil, rpc.ErrNotFound("block #%d not found", number)
+	}
+	return api.processBlock(block)
+}
+
+// processBlock reprocesses a block on top of its parent state, comparing the
+// results to its header.
+func (api *PrivateDebugAPI) processBlock(block *types.Block) (*ProcessBlockResult, error) {
+	blockchain := api.eth.BlockChain()
+
+	parent := blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, rpc.ErrNotFound("block parent %x not found", block.ParentHash())
+	}
+	statedb, err := blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	result := &ProcessBlockResult{
+		ExpectedGas:  rpc.NewHexNumber(block.GasUsed()),
+		ExpectedRoot: block.Root(),
+	}
+	receipts, _, usedGas, err := blockchain.Processor().Process(block, statedb, vm.Config{})
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.UsedGas = rpc.NewHexNumber(usedGas)
+	result.Root = statedb.IntermediateRoot()
+
+	if err := blockchain.Validator().ValidateState(block, parent, statedb, receipts, usedGas); err != nil {
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+// maxReplayBlocks is the maximum number of blocks ReplayBlocks re-executes in a
+// single call.
+const maxReplayBlocks = 1024
+
+// ReplayMismatch is a block whose re-execution did not match its header.
+type ReplayMismatch struct {
+	Number uint64 `json:"number"`
+	Error  string `json:"error"`
+}
+
+// ReplayStats is the outcome of re-executing a range of blocks.
+type ReplayStats struct {
+	Blocks      uint64           `json:"blocks"`
+	Txs         uint64           `json:"txs"`
+	GasUsed     *rpc.HexNumber   `json:"gasUsed"`
+	TotalTime   string           `json:"totalTime"`
+	AverageTime string           `json:"averageTime"`
+	Mismatches  []ReplayMismatch `json:"mismatches"`
+}
+
+// ReplayBlocks re-executes the canonical blocks in the given inclusive range on
+// top of the state preceding it without saving anything, reporting the time
+// spent executing and validating them, the gas processed and any blocks whose
+// results did not match their headers. At most maxReplayBlocks are replayed.
+func (api *PrivateDebugAPI) ReplayBlocks(from, to uint64) (*ReplayStats, error) {
+	if from == 0 || from > to {
+		return nil, rpc.ErrInvalidArgs("invalid block range #%d-#%d", from, to)
+	}
+	if to-from >= maxReplayBlocks {
+		return nil, rpc.ErrInvalidArgs("block range #%d-#%d exceeds %d blocks", from, to, maxReplayBlocks)
+	}
+	if err := api.eth.debugCalls.acquire(); err != nil {
+		return nil, err
+	}
+	defer api.eth.debugCalls.release()
+
+	blockchain := api.eth.BlockChain()
+
+	parent := blockchain.GetBlockByNumber(from - 1)
+	if parent == nil {
+		return nil, rpc.ErrNotFound("block #%d not found", from-1)
+	}
+	statedb, err := blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		stats   = &ReplayStats{Mismatches: []ReplayMismatch{}}
+		gasUsed = new(big.Int)
+		elapsed time.Duration
+	)
+	for number := from; number <= to; number++ {
+		block := blockchain.GetBlockByNumber(number)
+		if block == nil {
+			return nil, rpc.ErrNotFound("block #%d not found", number)
+		}
+		start := time.Now()
+		receipts, _, usedGas, err := blockchain.Processor().Process(block, statedb, vm.Config{})
+		if err == nil {
+			gasUsed.Add(gasUsed, usedGas)
+			err = blockchain.Validator().ValidateState(block, parent, statedb, receipts, usedGas)
+		}
+		elapsed += time.Since(start)
+
+		if err != nil {
+			stats.Mismatches = append(stats.Mismatches, ReplayMismatch{Number: number, Error: err.Error()})
+
+			// Continue from the canonical state to avoid cascading mismatches
+			if statedb, err = blockchain.StateAt(block.Root()); err != nil {
+				return nil, fmt.Errorf("block #%d: %v", number, err)
+			}
+		}
+		stats.Blocks++
+		stats.Txs += uint64(len(block.Transactions()))
+		parent = block
+	}
+	stats.GasUsed = rpc.NewHexNumber(gasUsed)
+	stats.TotalTime = elapsed.String()
+	stats.AverageTime = (elapsed / time.Duration(stats.Blocks)).String()
+
+	return stats, nil
+}
+
 // callmsg is the message type used for call transations.
 type callmsg struct {
 	addr          common.Address
@@ -459,94 +1172,479 @@ func (t *timeoutError) Error() string {
 // TraceTransaction returns the structured logs created during the execution of EVM
 // and returns them as a JSON object.
 func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.Hash, config *TraceArgs) (interface{}, error) {
-	var tracer vm.Tracer
-	if config != nil && config.Tracer != nil {
-		timeout := defaultTraceTimeout
-		if config.Timeout != nil {
-			var err error
-			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
-				return nil, err
-			}
-		}
-
-		var err error
-		if tracer, err = ethapi.NewJavascriptTracer(*config.Tracer); err != nil {
-			return nil, err
-		}
+	if err := api.eth.debugCalls.acquire(); err != nil {
+		return nil, err
+	}
+	defer api.eth.debugCalls.release()
 
-		// Handle timeouts and RPC cancellations
-		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
-		go func() {
-			<-deadlineCtx.Done()
-			tracer.(*ethapi.JavascriptTracer).Stop(&timeoutError{})
-		}()
-		defer cancel()
-	} else if config == nil {
-		tracer = vm.NewStructLogger(nil)
-	} else {
-		tracer = vm.NewStructLogger(config.LogConfig)
+	tracer, cancel, err := newTracer(ctx, config)
+	if err != nil {
+		return nil, err
 	}
+	defer cancel()
 
 	// Retrieve the tx from the chain and the containing block
 	tx, blockHash, _, txIndex := core.GetTransaction(api.eth.ChainDb(), txHash)
 	if tx == nil {
-		return nil, fmt.Errorf("transaction %x not found", txHash)
+		return nil, rpc.ErrNotFound("transaction %x not found", txHash)
 	}
 	block := api.eth.BlockChain().GetBlockByHash(blockHash)
 	if block == nil {
-		return nil, fmt.Errorf("block %x not found", blockHash)
+		return nil, rpc.ErrNotFound("block %x not found", blockHash)
 	}
 	// Create the state database to mutate and eventually trace
 	parent := api.eth.BlockChain().GetBlock(block.ParentHash(), block.NumberU64()-1)
 	if parent == nil {
-		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+		return nil, rpc.ErrNotFound("block parent %x not found", block.ParentHash())
 	}
 	stateDb, err := api.eth.BlockChain().StateAt(parent.Root())
 	if err != nil {
 		return nil, err
 	}
-	// Mutate the state and trace the selected transaction
+	// Mutate the state and trace the selected transaction, aborting on the deadline
+	interrupt := deadlineInterrupt(ctx)
 	for idx, tx := range block.Transactions() {
 		// Assemble the transaction call message
-		from, err := tx.FromFrontier()
+		msg, err := txCallMsg(tx)
 		if err != nil {
-			return nil, fmt.Errorf("sender retrieval failed: %v", err)
-		}
-		msg := callmsg{
-			addr:     from,
-			to:       tx.To(),
-			gas:      tx.Gas(),
-			gasPrice: tx.GasPrice(),
-			value:    tx.Value(),
-			data:     tx.Data(),
+			return nil, err
 		}
 		// Mutate the state if we haven't reached the tracing transaction yet
 		if uint64(idx) < txIndex {
-			vmenv := core.NewEnv(stateDb, api.config, api.eth.BlockChain(), msg, block.Header(), vm.Config{})
+			vmenv := core.NewEnv(stateDb, api.config, api.eth.BlockChain(), msg, block.Header(), vm.Config{Interrupt: interrupt})
 			_, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
 			if err != nil {
 				return nil, fmt.Errorf("mutation failed: %v", err)
 			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			stateDb.DeleteSuicides()
 			continue
 		}
 		// Otherwise trace the transaction and return
-		vmenv := core.NewEnv(stateDb, api.config, api.eth.BlockChain(), msg, block.Header(), vm.Config{Debug: true, Tracer: tracer})
+		vmenv := core.NewEnv(stateDb, api.config, api.eth.BlockChain(), msg, block.Header(), vm.Config{Debug: true, Tracer: tracer, Interrupt: interrupt})
 		ret, gas, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
 		if err != nil {
 			return nil, fmt.Errorf("tracing failed: %v", err)
 		}
-
-		switch tracer := tracer.(type) {
-		case *vm.StructLogger:
-			return &ethapi.ExecutionResult{
-				Gas:         gas,
-				ReturnValue: fmt.Sprintf("%x", ret),
-				StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
-			}, nil
-		case *ethapi.JavascriptTracer:
-			return tracer.GetResult()
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
+		return traceResult(tracer, ret, gas)
 	}
 	return nil, errors.New("database inconsistency")
 }
+
+// TxTraceResult is the trace of a single transaction of a block, either the
+// result of the requested tracer or the error the tracing failed with.
+type TxTraceResult struct {
+	TxHash common.Hash `json:"txHash"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// TraceBlockTransactions re-executes all the transactions of the given canonical
+// block in order on top of its parent state, returning the trace of each one.
+// Unlike TraceBlockByNumber the traces are kept separate per transaction and may
+// be produced by a custom tracer. The state is discarded afterwards.
+func (api *PrivateDebugAPI) TraceBlockTransactions(ctx context.Context, number uint64, config *TraceArgs) ([]TxTraceResult, error) {
+	if err := api.eth.debugCalls.acquire(); err != nil {
+		return nil, err
+	}
+	defer api.eth.debugCalls.release()
+
+	block := api.eth.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return nil, rpc.ErrNotFound("block #%d not found", number)
+	}
+	parent := api.eth.BlockChain().GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, rpc.ErrNotFound("block parent %x not found", block.ParentHash())
+	}
+	stateDb, err := api.eth.BlockChain().StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	results := make([]TxTraceResult, len(block.Transactions()))
+	interrupt := deadlineInterrupt(ctx)
+	for i, tx := range block.Transactions() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		results[i].TxHash = tx.Hash()
+
+		msg, err := txCallMsg(tx)
+		if err != nil {
+			return nil, err
+		}
+		// Trace each transaction with a fresh tracer, so only the logs of the
+		// current one are held in their raw form
+		tracer, cancel, err := newTracer(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		vmenv := core.NewEnv(stateDb, api.config, api.eth.BlockChain(), msg, block.Header(), vm.Config{Debug: true, Tracer: tracer, Interrupt: interrupt})
+		ret, gas, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
+		if err != nil {
+			results[i].Error = fmt.Sprintf("tracing failed: %v", err)
+		} else if results[i].Result, err = traceResult(tracer, ret, gas); err != nil {
+			results[i].Error = err.Error()
+		}
+		cancel()
+		stateDb.DeleteSuicides()
+	}
+	return results, nil
+}
+
+// newTracer creates the tracer requested by the trace arguments: a JavaScript
+// tracer bounded by the configured timeout and the lifetime of the context if
+// any, or a struct logger otherwise. The returned function releases the timeout
+// and must be called once tracing is done.
+func newTracer(ctx context.Context, config *TraceArgs) (vm.Tracer, func(), error) {
+	if config == nil {
+		return vm.NewStructLogger(nil), func() {}, nil
+	}
+	if config.Tracer == nil {
+		return vm.NewStructLogger(config.LogConfig), func() {}, nil
+	}
+	timeout := defaultTraceTimeout
+	if config.Timeout != nil {
+		var err error
+		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+			return nil, nil, err
+		}
+	}
+	tracer, err := ethapi.NewJavascriptTracer(*config.Tracer)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Handle timeouts and RPC cancellations
+	deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+	go func() {
+		<-deadlineCtx.Done()
+		tracer.Stop(&timeoutError{})
+	}()
+	return tracer, cancel, nil
+}
+
+// traceResult assembles the result of a traced execution from its tracer.
+func traceResult(tracer vm.Tracer, ret []byte, gas *big.Int) (interface{}, error) {
+	switch tracer := tracer.(type) {
+	case *vm.StructLogger:
+		return &ethapi.ExecutionResult{
+			Gas:         gas,
+			ReturnValue: fmt.Sprintf("%x", ret),
+			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
+		}, nil
+	case *ethapi.JavascriptTracer:
+		return tracer.GetResult()
+	}
+	return nil, fmt.Errorf("unsupported tracer %T", tracer)
+}
+
+// TracePendingTransaction executes a transaction of the pool on top of the pending
+// state and returns the structured logs of its execution. If the transaction was
+// already applied to the pending block, the block is replayed up to it instead.
+// The state is always rebuilt from the parent of the pending block, as the one
+// held by the miner is shared with the worker still applying transactions to it.
+// Neither the pending block nor the pool are modified.
+func (api *PrivateDebugAPI) TracePendingTransaction(txHash common.Hash, config *vm.LogConfig) (*ethapi.ExecutionResult, error) {
+	tx := api.eth.TxPool().Get(txHash)
+	if tx == nil {
+		return nil, rpc.ErrNotFound("transaction %x not in the pool", txHash)
+	}
+	block, _ := api.eth.Miner().PendingReceipts()
+	parent := api.eth.BlockChain().GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, rpc.ErrNotFound("block parent %x not found", block.ParentHash())
+	}
+	stateDb, err := api.eth.BlockChain().StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	// Replay the pending transactions preceding the traced one
+	for _, prev := range block.Transactions() {
+		if prev.Hash() == txHash {
+			break
+		}
+		msg, err := txCallMsg(prev)
+		if err != nil {
+			return nil, err
+		}
+		vmenv := core.NewEnv(stateDb, api.config, api.eth.BlockChain(), msg, block.Header(), vm.Config{})
+		if _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(prev.Gas())); err != nil {
+			return nil, fmt.Errorf("mutation failed: %v", err)
+		}
+		stateDb.DeleteSuicides()
+	}
+	// Trace the transaction on top of the assembled state
+	msg, err := txCallMsg(tx)
+	if err != nil {
+		return nil, err
+	}
+	tracer := vm.NewStructLogger(config)
+	vmenv := core.NewEnv(stateDb, api.config, api.eth.BlockChain(), msg, block.Header(), vm.Config{Debug: true, Tracer: tracer})
+	ret, gas, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	return &ethapi.ExecutionResult{
+		Gas:         gas,
+		ReturnValue: fmt.Sprintf("%x", ret),
+		StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
+	}, nil
+}
+
+// txCallMsg assembles the call message executing a transaction.
+func txCallMsg(tx *types.Transaction) (callmsg, error) {
+	from, err := tx.FromFrontier()
+	if err != nil {
+		return callmsg{}, fmt.Errorf("sender retrieval failed: %v", err)
+	}
+	return callmsg{
+		addr:     from,
+		to:       tx.To(),
+		gas:      tx.Gas(),
+		gasPrice: tx.GasPrice(),
+		value:    tx.Value(),
+		data:     tx.Data(),
+	}, nil
+}
+
+// BadBlockResult is the returned value when listing the blocks recently rejected
+// by the chain, containing the RLP encoded block for further examination.
+type BadBlockResult struct {
+	Hash   common.Hash    `json:"hash"`
+	Number *rpc.HexNumber `json:"number"`
+	Reason string         `json:"reason"`
+	RLP    string         `json:"rlp"`
+}
+
+// GetBadBlocks returns the most recent blocks that failed import, ordered from
+// oldest to newest.
+func (api *PrivateDebugAPI) GetBadBlocks() ([]BadBlockResult, error) {
+	blocks := api.eth.BlockChain().BadBlocks()
+
+	results := make([]BadBlockResult, len(blocks))
+	for i, block := range blocks {
+		results[i] = BadBlockResult{
+			Hash:   block.Hash,
+			Number: rpc.NewHexNumber(block.Number),
+			Reason: block.Reason,
+			RLP:    fmt.Sprintf("0x%x", block.RLP),
+		}
+	}
+	return results, nil
+}
+
+// FutureBlocks returns the blocks queued for a later import attempt, ordered by
+// number. Blocks are queued if timestamped ahead of the local clock, often a sign
+// of clock skew, or if their parent is queued itself.
+func (api *PrivateDebugAPI) FutureBlocks() ([]map[string]interface{}, error) {
+	blocks := api.eth.BlockChain().FutureBlocks()
+
+	results := make([]map[string]interface{}, len(blocks))
+	for i, block := range blocks {
+		results[i] = map[string]interface{}{
+			"number":     rpc.NewHexNumber(block.Number()),
+			"hash":       block.Hash(),
+			"parentHash": block.ParentHash(),
+			"timestamp":  rpc.NewHexNumber(block.Time()),
+		}
+	}
+	return results, nil
+}
+
+// VerifyState loads the state of the given canonical block and walks its entire
+// trie, including contract storage and code, checking that every referenced
+// node is present in the database. The first missing node is reported in the
+// returned error. Nodes are resolved lazily, so memory use is bounded by the
+// depth of the trie rather than its size.
+func (api *PrivateDebugAPI) VerifyState(number uint64) (bool, error) {
+	block := api.eth.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return false, rpc.ErrNotFound("block #%d not found", number)
+	}
+	statedb, err := state.New(block.Root(), api.eth.ChainDb())
+	if err != nil {
+		return false, err
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	if it.Error != nil {
+		return false, it.Error
+	}
+	return true, nil
+}
+
+// StateSizeDelta is the change in the size of the state database caused by a
+// block, counted in hashed trie nodes (and contract codes) and their bytes.
+type StateSizeDelta struct {
+	NewNodes     int   `json:"newNodes"`
+	DeletedNodes int   `json:"deletedNodes"`
+	BytesDelta   int64 `json:"bytesDelta"`
+}
+
+// StateSizeDelta reports how many trie nodes the given canonical block added to
+// and removed from the state of its parent, including contract storage and code,
+// along with the resulting change in bytes. The two states are walked side by
+// side, skipping the subtries they have in common, so the cost is proportional
+// to the changes made by the block rather than the size of the state. Nodes are
+// compared by their position in the tries.
+func (api *PrivateDebugAPI) StateSizeDelta(number uint64) (*StateSizeDelta, error) {
+	if number == 0 {
+		return nil, rpc.ErrInvalidArgs("genesis block has no parent state")
+	}
+	if err := api.eth.debugCalls.acquire(); err != nil {
+		return nil, err
+	}
+	defer api.eth.debugCalls.release()
+
+	block := api.eth.BlockChain().GetBlockByNumber(number)
+	if block == nil {
+		return nil, rpc.ErrNotFound("block #%d not found", number)
+	}
+	parent := api.eth.BlockChain().GetBlock(block.ParentHash(), number-1)
+	if parent == nil {
+		return nil, rpc.ErrNotFound("block parent %x not found", block.ParentHash())
+	}
+	delta := new(StateSizeDelta)
+	if err := diffStates(api.eth.ChainDb(), parent.Root(), block.Root(), delta); err != nil {
+		return nil, err
+	}
+	return delta, nil
+}
+
+// diffStates accumulates into delta the trie nodes and contract codes differing
+// between the states with the given roots.
+func diffStates(db ethdb.Database, before, after common.Hash, delta *StateSizeDelta) error {
+	emptyCodeHash := crypto.Keccak256(nil)
+
+	onNode := func(hash common.Hash, size int, inBefore bool) error {
+		if inBefore {
+			delta.DeletedNodes++
+			delta.BytesDelta -= int64(size)
+		} else {
+			delta.NewNodes++
+			delta.BytesDelta += int64(size)
+		}
+		return nil
+	}
+	onAccount := func(prev, next []byte) error {
+		var old, cur state.Account
+		if prev != nil {
+			if err := rlp.DecodeBytes(prev, &old); err != nil {
+				return fmt.Errorf("invalid account: %v", err)
+			}
+		}
+		if next != nil {
+			if err := rlp.DecodeBytes(next, &cur); err != nil {
+				return fmt.Errorf("invalid account: %v", err)
+			}
+		}
+		if old.Root != cur.Root {
+			if err := trie.Diff(db, old.Root, cur.Root, onNode, nil); err != nil {
+				return err
+			}
+		}
+		if !bytes.Equal(old.CodeHash, cur.CodeHash) {
+			for i, hash := range [][]byte{old.CodeHash, cur.CodeHash} {
+				if hash == nil || bytes.Equal(hash, emptyCodeHash) {
+					continue
+				}
+				code, err := db.Get(hash)
+				if err != nil {
+					return fmt.Errorf("code %x: %v", hash, err)
+				}
+				if err := onNode(common.BytesToHash(hash), len(code), i == 0); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return trie.Diff(db, before, after, onNode, onAccount)
+}
+
+// SelfCheck verifies that the transaction indexes and receipts of the most recent
+// canonical blocks are present and consistent, reporting the number of blocks
+// checked and the anomalies found.
+func (api *PrivateDebugAPI) SelfCheck() (map[string]interface{}, error) {
+	checked, anomalies := selfCheck(api.eth.BlockChain(), api.eth.ChainDb(), selfCheckBlocks)
+	if anomalies == nil {
+		anomalies = []string{}
+	}
+	return map[string]interface{}{
+		"blocks":    checked,
+		"anomalies": anomalies,
+	}, nil
+}
+
+// ReindexTransactions rewrites the transaction lookup entries of all canonical
+// blocks in the given inclusive range, repairing an index that got out of sync
+// with the chain. It returns the number of transactions reindexed.
+func (api *PrivateDebugAPI) ReindexTransactions(from, to uint64) (int, error) {
+	if from > to {
+		return 0, rpc.ErrInvalidArgs("invalid block range: from #%d > to #%d", from, to)
+	}
+	if head := api.eth.BlockChain().CurrentBlock().NumberU64(); to > head {
+		return 0, rpc.ErrInvalidArgs("block #%d beyond current head #%d", to, head)
+	}
+	count := 0
+	for number := from; number <= to; number++ {
+		block := api.eth.BlockChain().GetBlockByNumber(number)
+		if block == nil {
+			return count, rpc.ErrNotFound("block #%d not found", number)
+		}
+		if err := core.WriteTransactions(api.eth.ChainDb(), block); err != nil {
+			return count, fmt.Errorf("block #%d: failed to write transactions: %v", number, err)
+		}
+		count += len(block.Transactions())
+	}
+	return count, nil
+}
+
+// SetCanonicalHead forces the given block to become the head of the canonical
+// chain even if it isn't the one with the highest total difficulty, rewriting the
+// canonical chain and transaction indexes accordingly. The block, its ancestors
+// and its state need to be available locally. Meant for testing and recovery.
+func (api *PrivateDebugAPI) SetCanonicalHead(hash common.Hash) (bool, error) {
+	if err := api.eth.BlockChain().SetCanonicalHead(hash); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// VerifyHeaders decodes an RLP encoded list of headers and checks each of them
+// against its parent (proof-of-work, difficulty, timestamp and gas limit bounds)
+// without importing anything. Parents are looked up among the preceding headers
+// of the batch first, then in the local chain. Headers with an unknown or
+// invalid parent are reported invalid.
+func (api *PrivateDebugAPI) VerifyHeaders(rlpHeaders string) ([]bool, error) {
+	var headers []*types.Header
+	if err := rlp.DecodeBytes(common.FromHex(rlpHeaders), &headers); err != nil {
+		return nil, rpc.ErrInvalidArgs("failed to decode headers: %v", err)
+	}
+	var (
+		chain = api.eth.BlockChain()
+		batch = make(map[common.Hash]int) // Index of each already checked header in the batch
+		valid = make([]bool, len(headers))
+	)
+	for i, header := range headers {
+		var parent *types.Header
+		if j, ok := batch[header.ParentHash]; ok {
+			if valid[j] {
+				parent = headers[j]
+			}
+		} else if header.Number.Sign() > 0 {
+			parent = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+		}
+		if parent != nil {
+			valid[i] = core.ValidateHeader(api.config, chain.AuxValidator(), header, parent, true, false) == nil
+		}
+		batch[header.Hash()] = i
+	}
+	return valid, nil
+}
@@ -18,6 +18,8 @@ package eth
 
 import (
 	"math/big"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
@@ -30,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rlp"
 	rpc "github.com/ethereum/go-ethereum/rpc"
 	"golang.org/x/net/context"
 )
@@ -47,7 +50,7 @@ func (b *EthApiBackend) SetHead(number uint64) {
 func (b *EthApiBackend) HeaderByNumber(blockNr rpc.BlockNumber) *types.Header {
 	// Pending block is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
-		block, _ := b.eth.miner.Pending()
+		block, _, _ := b.eth.miner.PendingSnapshot()
 		return block.Header()
 	}
 	// Otherwise resolve and return the block
@@ -60,7 +63,7 @@ func (b *EthApiBackend) HeaderByNumber(blockNr rpc.BlockNumber) *types.Header {
 func (b *EthApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
 	// Pending block is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
-		block, _ := b.eth.miner.Pending()
+		block, _, _ := b.eth.miner.PendingSnapshot()
 		return block, nil
 	}
 	// Otherwise resolve and return the block
@@ -71,10 +74,13 @@ func (b *EthApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumb
 }
 
 func (b *EthApiBackend) StateAndHeaderByNumber(blockNr rpc.BlockNumber) (ethapi.State, *types.Header, error) {
-	// Pending state is only known by the miner
+	if err := b.eth.servingReady(); err != nil {
+		return nil, nil, err
+	}
+	// Pending state is only known by the miner, share it until it's rebuilt
 	if blockNr == rpc.PendingBlockNumber {
-		block, state := b.eth.miner.Pending()
-		return EthApiState{state}, block.Header(), nil
+		block, state, lock := b.eth.miner.PendingSnapshot()
+		return EthApiState{state: state, lock: lock}, block.Header(), nil
 	}
 	// Otherwise resolve the block number and return its state
 	header := b.HeaderByNumber(blockNr)
@@ -82,7 +88,13 @@ func (b *EthApiBackend) StateAndHeaderByNumber(blockNr rpc.BlockNumber) (ethapi.
 		return nil, nil, nil
 	}
 	stateDb, err := b.eth.BlockChain().StateAt(header.Root)
-	return EthApiState{stateDb}, header, err
+	if err != nil {
+		if oldest := b.eth.BlockChain().OldestBlock(); header.Number.Uint64() < oldest {
+			return nil, nil, rpc.ErrNotFound("state unavailable (pruned below %d)", oldest)
+		}
+		return nil, nil, err
+	}
+	return EthApiState{state: stateDb}, header, nil
 }
 
 func (b *EthApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
@@ -97,13 +109,38 @@ func (b *EthApiBackend) GetTd(blockHash common.Hash) *big.Int {
 	return b.eth.blockchain.GetTdByHash(blockHash)
 }
 
-func (b *EthApiBackend) GetVMEnv(ctx context.Context, msg core.Message, state ethapi.State, header *types.Header) (vm.Environment, func() error, error) {
-	statedb := state.(EthApiState).state
+func (b *EthApiBackend) GetVMEnv(ctx context.Context, msg core.Message, state ethapi.State, header *types.Header, tracer vm.Tracer) (vm.Environment, func() error, error) {
+	statedb := state.(EthApiState).writable()
 	addr, _ := msg.From()
 	from := statedb.GetOrNewStateObject(addr)
 	from.SetBalance(common.MaxBig)
-	vmError := func() error { return nil }
-	return core.NewEnv(statedb, b.eth.chainConfig, b.eth.blockchain, msg, header, b.eth.chainConfig.VmConfig), vmError, nil
+	config := b.eth.chainConfig.VmConfig
+	config.Interrupt = deadlineInterrupt(ctx)
+	vmError := func() error {
+		if config.Interrupt != nil && atomic.LoadInt32(config.Interrupt) != 0 {
+			return ctx.Err()
+		}
+		return nil
+	}
+	if tracer != nil {
+		config.Debug, config.Tracer = true, tracer
+	}
+	return core.NewEnv(statedb, b.eth.chainConfig, b.eth.blockchain, msg, header, config), vmError, nil
+}
+
+// deadlineInterrupt returns an EVM interrupt flag raised once the given context
+// is done, aborting executions exceeding the deadline of the request. Contexts
+// without a deadline don't bound the execution and nil is returned for them.
+func deadlineInterrupt(ctx context.Context) *int32 {
+	if _, ok := ctx.Deadline(); !ok {
+		return nil
+	}
+	interrupt := new(int32)
+	go func() {
+		<-ctx.Done()
+		atomic.StoreInt32(interrupt, 1)
+	}()
+	return interrupt
 }
 
 func (b *EthApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
@@ -114,6 +151,10 @@ func (b *EthApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction)
 	return b.eth.txPool.Add(signedTx)
 }
 
+func (b *EthApiBackend) ValidateTx(ctx context.Context, signedTx *types.Transaction) error {
+	return b.eth.txPool.Validate(signedTx)
+}
+
 func (b *EthApiBackend) RemoveTx(txHash common.Hash) {
 	b.eth.txMu.Lock()
 	defer b.eth.txMu.Unlock()
@@ -139,6 +180,10 @@ func (b *EthApiBackend) GetPoolTransaction(hash common.Hash) *types.Transaction
 	return b.eth.txPool.Get(hash)
 }
 
+func (b *EthApiBackend) GetPoolReplacement(hash common.Hash) (common.Hash, bool) {
+	return b.eth.txPool.ReplacedBy(hash)
+}
+
 func (b *EthApiBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
 	b.eth.txMu.Lock()
 	defer b.eth.txMu.Unlock()
@@ -153,6 +198,34 @@ func (b *EthApiBackend) Stats() (pending int, queued int) {
 	return b.eth.txPool.Stats()
 }
 
+func (b *EthApiBackend) MinGasPrice() *big.Int {
+	b.eth.txMu.Lock()
+	defer b.eth.txMu.Unlock()
+
+	return b.eth.txPool.MinGasPrice()
+}
+
+func (b *EthApiBackend) SenderMinGasPrice(addr common.Address) *big.Int {
+	b.eth.txMu.Lock()
+	defer b.eth.txMu.Unlock()
+
+	if price := b.eth.txPool.SenderMinGasPrice(addr); price != nil {
+		return price
+	}
+	return b.eth.txPool.MinGasPrice()
+}
+
+func (b *EthApiBackend) StrictChainId() *big.Int {
+	if !b.eth.strictChainId {
+		return nil
+	}
+	return b.eth.chainConfig.ChainId
+}
+
+func (b *EthApiBackend) RestrictUnlock() bool {
+	return b.eth.restrictUnlock
+}
+
 func (b *EthApiBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
 	b.eth.txMu.Lock()
 	defer b.eth.txMu.Unlock()
@@ -176,6 +249,10 @@ func (b *EthApiBackend) ChainDb() ethdb.Database {
 	return b.eth.ChainDb()
 }
 
+func (b *EthApiBackend) ChainConfig() *core.ChainConfig {
+	return b.eth.chainConfig
+}
+
 func (b *EthApiBackend) EventMux() *event.TypeMux {
 	return b.eth.EventMux()
 }
@@ -184,22 +261,73 @@ func (b *EthApiBackend) AccountManager() *accounts.Manager {
 	return b.eth.AccountManager()
 }
 
+// EthApiState exposes a state database to the API. If lock is set, the state is
+// shared (e.g. the cached pending state) and accesses are serialised through it.
 type EthApiState struct {
 	state *state.StateDB
+	lock  sync.Locker
+}
+
+// acquire locks a shared state for access, returning the function to release it.
+func (s EthApiState) acquire() func() {
+	if s.lock == nil {
+		return func() {}
+	}
+	s.lock.Lock()
+	return s.lock.Unlock
+}
+
+// writable returns the state for modification, copying it first if shared.
+func (s EthApiState) writable() *state.StateDB {
+	if s.lock == nil {
+		return s.state
+	}
+	defer s.acquire()()
+	return s.state.Copy()
 }
 
 func (s EthApiState) GetBalance(ctx context.Context, addr common.Address) (*big.Int, error) {
+	defer s.acquire()()
 	return s.state.GetBalance(addr), nil
 }
 
 func (s EthApiState) GetCode(ctx context.Context, addr common.Address) ([]byte, error) {
+	defer s.acquire()()
 	return s.state.GetCode(addr), nil
 }
 
 func (s EthApiState) GetState(ctx context.Context, a common.Address, b common.Hash) (common.Hash, error) {
+	defer s.acquire()()
 	return s.state.GetState(a, b), nil
 }
 
 func (s EthApiState) GetNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	defer s.acquire()()
 	return s.state.GetNonce(addr), nil
 }
+
+func (s EthApiState) GetProof(ctx context.Context, addr common.Address) ([]rlp.RawValue, error) {
+	defer s.acquire()()
+	return s.state.GetProof(addr), nil
+}
+
+func (s EthApiState) GetStorageProof(ctx context.Context, a common.Address, b common.Hash) (common.Hash, []rlp.RawValue, error) {
+	defer s.acquire()()
+	root, proof := s.state.GetStorageProof(a, b)
+	return root, proof, nil
+}
+
+func (s EthApiState) GetStorageRoot(ctx context.Context, addr common.Address) (common.Hash, error) {
+	defer s.acquire()()
+	return s.state.GetStorageRoot(addr), nil
+}
+
+func (s EthApiState) Exist(ctx context.Context, addr common.Address) (bool, error) {
+	defer s.acquire()()
+	return s.state.Exist(addr), nil
+}
+
+func (s EthApiState) Copy() ethapi.State {
+	defer s.acquire()()
+	return EthApiState{state: s.state.Copy()}
+}
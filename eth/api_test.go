@@ -0,0 +1,1459 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"golang.org/x/net/context"
+)
+
+// Tests that state verification succeeds on a healthy database, and reports the
+// missing node if part of the state trie was deleted.
+func TestVerifyState(t *testing.T) {
+	// Create a chain with a handful of accounts in its state
+	pm := newTestProtocolManagerMust(t, false, 4, func(i int, block *core.BlockGen) {
+		for j := 0; j < 4; j++ {
+			tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.Address{byte(i), byte(j)}, big.NewInt(1000), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+			block.AddTx(tx)
+		}
+	}, nil)
+	defer pm.Stop()
+
+	api := NewPrivateDebugAPI(pm.blockchain.Config(), newTestChainEthereum(pm))
+	head := pm.blockchain.CurrentBlock()
+
+	if ok, err := api.VerifyState(head.NumberU64()); !ok || err != nil {
+		t.Fatalf("healthy state verification failed: %v, %v", ok, err)
+	}
+	// Delete an inner node of the state trie and ensure it's detected
+	statedb, _ := state.New(head.Root(), pm.chaindb)
+	var missing common.Hash
+	for it := state.NewNodeIterator(statedb); it.Next(); {
+		if it.Hash != (common.Hash{}) && it.Hash != head.Root() {
+			missing = it.Hash
+			break
+		}
+	}
+	if missing == (common.Hash{}) {
+		t.Fatalf("no inner state node found")
+	}
+	pm.chaindb.(*ethdb.MemDatabase).Delete(missing[:])
+
+	ok, err := api.VerifyState(head.NumberU64())
+	if ok || err == nil {
+		t.Fatalf("corrupt state verification succeeded")
+	}
+	if merr, isMissing := err.(*trie.MissingNodeError); !isMissing || merr.NodeHash != missing {
+		t.Errorf("error mismatch: have %v, want missing node %x", err, missing)
+	}
+}
+
+// Tests that raw headers can be retrieved by both number and hash, decoding into
+// the originally requested header.
+func TestGetRawHeader(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 4, nil, nil)
+	defer pm.Stop()
+
+	api := NewPublicDebugAPI(newTestChainEthereum(pm))
+	decode := func(encoded string) common.Hash {
+		if !strings.HasPrefix(encoded, "0x") {
+			t.Fatalf("hex %q missing 0x prefix", encoded)
+		}
+		blob, err := hex.DecodeString(strings.TrimPrefix(encoded, "0x"))
+		if err != nil {
+			t.Fatalf("failed to decode hex header: %v", err)
+		}
+		var header types.Header
+		if err := rlp.DecodeBytes(blob, &header); err != nil {
+			t.Fatalf("failed to decode rlp header: %v", err)
+		}
+		return header.Hash()
+	}
+	for number := uint64(0); number <= 4; number++ {
+		want := pm.blockchain.GetBlockByNumber(number).Hash()
+
+		encoded, err := api.GetRawHeaderByNumber(number)
+		if err != nil {
+			t.Fatalf("header #%d: failed to retrieve by number: %v", number, err)
+		}
+		if have := decode(encoded); have != want {
+			t.Errorf("header #%d: hash mismatch by number: have %x, want %x", number, have, want)
+		}
+		if encoded, err = api.GetRawHeaderByHash(want); err != nil {
+			t.Fatalf("header #%d: failed to retrieve by hash: %v", number, err)
+		}
+		if have := decode(encoded); have != want {
+			t.Errorf("header #%d: hash mismatch by hash: have %x, want %x", number, have, want)
+		}
+	}
+	// Unknown headers should be reported as empty
+	if encoded, err := api.GetRawHeaderByNumber(5); encoded != "" || err != nil {
+		t.Errorf("unknown number: have %q, %v, want empty", encoded, err)
+	}
+	if encoded, err := api.GetRawHeaderByHash(common.Hash{0x01}); encoded != "" || err != nil {
+		t.Errorf("unknown hash: have %q, %v, want empty", encoded, err)
+	}
+}
+
+// Tests that reprocessing a block reports the gas used and state root computed
+// locally along with the expected ones, flagging any mismatch.
+func TestProcessBlock(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 4, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.Address{byte(i)}, big.NewInt(1000), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}, nil)
+	defer pm.Stop()
+
+	api := NewPrivateDebugAPI(pm.blockchain.Config(), newTestChainEthereum(pm))
+
+	// Reprocess a valid block and ensure everything matches
+	block := pm.blockchain.GetBlockByNumber(2)
+	result, err := api.ProcessBlock(2)
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	if result.Error != "" {
+		t.Errorf("valid block reported invalid: %s", result.Error)
+	}
+	if result.Root != block.Root() || result.ExpectedRoot != block.Root() {
+		t.Errorf("root mismatch: have %x (expected %x), want %x", result.Root, result.ExpectedRoot, block.Root())
+	}
+	if result.UsedGas.BigInt().Cmp(block.GasUsed()) != 0 || result.ExpectedGas.BigInt().Cmp(block.GasUsed()) != 0 {
+		t.Errorf("gas mismatch: have %v (expected %v), want %v", result.UsedGas, result.ExpectedGas, block.GasUsed())
+	}
+	// Doctor the state root of the block and ensure the mismatch is reported
+	header := types.CopyHeader(block.Header())
+	header.Root = common.Hash{0x01}
+	doctored := types.NewBlockWithHeader(header).WithBody(block.Transactions(), block.Uncles())
+
+	if result, err = api.processBlock(doctored); err != nil {
+		t.Fatalf("failed to process doctored block: %v", err)
+	}
+	if result.Error == "" {
+		t.Errorf("doctored block reported valid")
+	}
+	if result.Root != block.Root() || result.ExpectedRoot != header.Root {
+		t.Errorf("doctored root mismatch: have %x (expected %x), want %x (expected %x)", result.Root, result.ExpectedRoot, block.Root(), header.Root)
+	}
+	if _, err := api.ProcessBlock(5); err == nil {
+		t.Errorf("unknown block processed")
+	}
+}
+
+// Tests that the state size delta of a block writing new storage reports the
+// nodes it added.
+func TestStateSizeDelta(t *testing.T) {
+	// Create a contract writing two storage slots, followed by a plain transfer
+	code := common.FromHex("0x6001600055600160015500") // SSTORE(0, 1), SSTORE(1, 1), STOP
+	pm := newTestProtocolManagerMust(t, false, 2, func(i int, block *core.BlockGen) {
+		var tx *types.Transaction
+		if i == 0 {
+			tx, _ = types.NewContractCreation(block.TxNonce(testBank.Address), new(big.Int), big.NewInt(100000), new(big.Int), code).SignECDSA(testBankKey)
+		} else {
+			tx, _ = types.NewTransaction(block.TxNonce(testBank.Address), common.Address{0x01}, big.NewInt(1000), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		}
+		block.AddTx(tx)
+	}, nil)
+	defer pm.Stop()
+
+	api := NewPrivateDebugAPI(pm.blockchain.Config(), newTestChainEthereum(pm))
+
+	storage, err := api.StateSizeDelta(1)
+	if err != nil {
+		t.Fatalf("failed to compute storage block delta: %v", err)
+	}
+	if storage.NewNodes <= storage.DeletedNodes || storage.BytesDelta <= 0 {
+		t.Errorf("storage block did not grow the state: %+v", storage)
+	}
+	transfer, err := api.StateSizeDelta(2)
+	if err != nil {
+		t.Fatalf("failed to compute transfer block delta: %v", err)
+	}
+	if transfer.NewNodes >= storage.NewNodes {
+		t.Errorf("transfer block added more nodes than storage block: have %d, storage %d", transfer.NewNodes, storage.NewNodes)
+	}
+	if _, err := api.StateSizeDelta(0); err == nil {
+		t.Errorf("genesis block delta computed")
+	}
+	if _, err := api.StateSizeDelta(3); err == nil {
+		t.Errorf("missing block delta computed")
+	}
+}
+
+// Tests that tracing a block returns the traces of all its transactions in order,
+// each executed on top of the state left by the previous ones.
+func TestTraceBlockTransactions(t *testing.T) {
+	// Create a block with a contract creation followed by a plain transfer
+	code := common.FromHex("0x60016000526001601ff3") // Return a single 0x01 byte
+	pm := newTestProtocolManagerMust(t, false, 1, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewContractCreation(block.TxNonce(testBank.Address), new(big.Int), big.NewInt(100000), new(big.Int), code).SignECDSA(testBankKey)
+		block.AddTx(tx)
+		tx, _ = types.NewTransaction(block.TxNonce(testBank.Address), common.Address{0x01}, big.NewInt(1000), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}, nil)
+	defer pm.Stop()
+
+	eth := newTestChainEthereum(pm)
+	api := NewPrivateDebugAPI(pm.blockchain.Config(), eth)
+
+	results, err := api.TraceBlockTransactions(context.Background(), 1, nil)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	txs := pm.blockchain.GetBlockByNumber(1).Transactions()
+	if len(results) != len(txs) {
+		t.Fatalf("trace count mismatch: have %d, want %d", len(results), len(txs))
+	}
+	for i, tx := range txs {
+		if results[i].TxHash != tx.Hash() {
+			t.Errorf("trace %d: transaction mismatch: have %x, want %x", i, results[i].TxHash, tx.Hash())
+		}
+		if results[i].Error != "" {
+			t.Errorf("trace %d: tracing failed: %v", i, results[i].Error)
+		}
+		want, err := api.TraceTransaction(context.Background(), tx.Hash(), nil)
+		if err != nil {
+			t.Fatalf("trace %d: failed to trace transaction: %v", i, err)
+		}
+		if !reflect.DeepEqual(results[i].Result, want) {
+			t.Errorf("trace %d: mismatch with transaction trace: have %+v, want %+v", i, results[i].Result, want)
+		}
+	}
+	if logs := results[0].Result.(*ethapi.ExecutionResult).StructLogs; len(logs) == 0 {
+		t.Errorf("contract creation trace has no logs")
+	}
+	if logs := results[1].Result.(*ethapi.ExecutionResult).StructLogs; len(logs) != 0 {
+		t.Errorf("transfer trace has %d logs, want none", len(logs))
+	}
+	// Custom tracers should be run separately for every transaction, counting the
+	// six opcodes of the contract creation and none of the transfer
+	tracer := "{count: 0, step: function() { this.count++ }, result: function() { return this.count }}"
+	if results, err = api.TraceBlockTransactions(context.Background(), 1, &TraceArgs{Tracer: &tracer}); err != nil {
+		t.Fatalf("failed to trace block with custom tracer: %v", err)
+	}
+	if len(results) != 2 || fmt.Sprint(results[0].Result) != "6" || fmt.Sprint(results[1].Result) != "0" {
+		t.Errorf("custom tracer results mismatch: have %+v", results)
+	}
+	if _, err := api.TraceBlockTransactions(context.Background(), 2, nil); err == nil {
+		t.Errorf("missing block traced")
+	}
+}
+
+// Tests that expensive debug calls beyond the configured concurrency limit are
+// rejected as the server being busy, while those within it proceed.
+func TestDebugCallLimit(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 2, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.Address{byte(i)}, big.NewInt(1000), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}, nil)
+	defer pm.Stop()
+
+	eth := newTestChainEthereum(pm)
+	api, public := NewPrivateDebugAPI(pm.blockchain.Config(), eth), NewPublicDebugAPI(eth)
+	admin := NewPrivateAdminAPI(eth)
+
+	if ok, err := admin.SetMaxDebugCalls(2); !ok || err != nil {
+		t.Fatalf("failed to set debug call limit: %v", err)
+	}
+	if limit := admin.MaxDebugCalls(); limit != 2 {
+		t.Fatalf("debug call limit mismatch: have %d, want %d", limit, 2)
+	}
+	// Fire more slow traces than allowed, spinning until their timeout
+	txHash := pm.blockchain.GetBlockByNumber(1).Transactions()[0].Hash()
+	tracer, timeout := "{step: function() {}, result: function() { while (true) {} }}", "500ms"
+
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := api.TraceTransaction(context.Background(), txHash, &TraceArgs{Tracer: &tracer, Timeout: &timeout})
+			errs <- err
+		}()
+	}
+	busy := 0
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errs:
+			if _, ok := err.(*rpc.ServerBusyError); ok {
+				busy++
+				// While the slots are taken, other expensive calls are refused too
+				if _, err := public.DumpBlock(1); err == nil {
+					t.Errorf("state dump allowed over the limit")
+				}
+				if _, err := api.ProcessBlock(1); err == nil {
+					t.Errorf("block processing allowed over the limit")
+				}
+			} else if err == nil {
+				t.Errorf("spinning trace did not time out")
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("trace timeout")
+		}
+	}
+	if busy != 1 {
+		t.Fatalf("busy rejections mismatch: have %d, want %d", busy, 1)
+	}
+	// Once the traces are done, calls are accepted again
+	if _, err := api.TraceTransaction(context.Background(), txHash, nil); err != nil {
+		t.Errorf("failed to trace after the limit cleared: %v", err)
+	}
+	if _, err := admin.SetMaxDebugCalls(-1); err == nil {
+		t.Errorf("negative debug call limit accepted")
+	}
+}
+
+// Tests that replaying a range of blocks reports execution statistics without
+// any mismatches for a valid chain, and that invalid ranges are rejected.
+func TestReplayBlocks(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 4, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.Address{byte(i)}, big.NewInt(1000), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}, nil)
+	defer pm.Stop()
+
+	api := NewPrivateDebugAPI(pm.blockchain.Config(), newTestChainEthereum(pm))
+
+	stats, err := api.ReplayBlocks(2, 4)
+	if err != nil {
+		t.Fatalf("failed to replay blocks: %v", err)
+	}
+	if len(stats.Mismatches) != 0 {
+		t.Errorf("valid blocks reported mismatching: %v", stats.Mismatches)
+	}
+	if stats.Blocks != 3 || stats.Txs != 3 {
+		t.Errorf("replay count mismatch: have %d blocks, %d txs, want 3 blocks, 3 txs", stats.Blocks, stats.Txs)
+	}
+	if stats.GasUsed.Int64() != 3*21000 {
+		t.Errorf("gas used mismatch: have %v, want %d", stats.GasUsed, 3*21000)
+	}
+	if total, err := time.ParseDuration(stats.TotalTime); err != nil || total <= 0 {
+		t.Errorf("invalid total time %q: %v", stats.TotalTime, err)
+	}
+	if average, err := time.ParseDuration(stats.AverageTime); err != nil || average <= 0 {
+		t.Errorf("invalid average time %q: %v", stats.AverageTime, err)
+	}
+	// Ensure invalid ranges are rejected
+	for _, r := range [][2]uint64{{0, 2}, {3, 2}, {1, maxReplayBlocks + 1}, {2, 5}} {
+		if _, err := api.ReplayBlocks(r[0], r[1]); err == nil {
+			t.Errorf("invalid range #%d-#%d replayed", r[0], r[1])
+		}
+	}
+}
+
+// Tests that the raw block body combined with the raw header reconstructs the
+// RLP encoding of the full block.
+func TestGetBlockBody(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 4, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.Address{byte(i)}, big.NewInt(1000), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}, nil)
+	defer pm.Stop()
+
+	api := NewPublicDebugAPI(newTestChainEthereum(pm))
+	decode := func(encoded string) []byte {
+		if !strings.HasPrefix(encoded, "0x") {
+			t.Fatalf("hex %q missing 0x prefix", encoded)
+		}
+		blob, err := hex.DecodeString(strings.TrimPrefix(encoded, "0x"))
+		if err != nil {
+			t.Fatalf("failed to decode hex: %v", err)
+		}
+		return blob
+	}
+	for number := uint64(0); number <= 4; number++ {
+		block := pm.blockchain.GetBlockByNumber(number)
+
+		header, err := api.GetRawHeaderByHash(block.Hash())
+		if err != nil {
+			t.Fatalf("block #%d: failed to retrieve header: %v", number, err)
+		}
+		body, err := api.GetBlockBody(block.Hash())
+		if err != nil {
+			t.Fatalf("block #%d: failed to retrieve body: %v", number, err)
+		}
+		var fields []rlp.RawValue
+		if err := rlp.DecodeBytes(decode(body), &fields); err != nil || len(fields) != 2 {
+			t.Fatalf("block #%d: invalid body: %v (%d fields)", number, err, len(fields))
+		}
+		have, err := rlp.EncodeToBytes(append([]rlp.RawValue{decode(header)}, fields...))
+		if err != nil {
+			t.Fatalf("block #%d: failed to reassemble block: %v", number, err)
+		}
+		want, _ := rlp.EncodeToBytes(block)
+		if !bytes.Equal(have, want) {
+			t.Errorf("block #%d: reassembled rlp mismatch: have %x, want %x", number, have, want)
+		}
+	}
+	if body, err := api.GetBlockBody(common.Hash{0x01}); body != "" || err != nil {
+		t.Errorf("unknown hash: have %q, %v, want empty", body, err)
+	}
+}
+
+// Tests that a transaction still in the pool can be traced on top of the pending
+// state, and that unknown transactions are rejected.
+func TestTracePendingTransaction(t *testing.T) {
+	eth, teardown := newTestMinerEthereum(t, 0, nil)
+	defer teardown()
+	api := NewPrivateDebugAPI(eth.blockchain.Config(), eth)
+
+	// Create a contract whose constructor stores 1 into slot 0
+	code := []byte{byte(vm.PUSH1), 0x01, byte(vm.PUSH1), 0x00, byte(vm.SSTORE)}
+	tx, _ := types.NewContractCreation(0, new(big.Int), big.NewInt(100000), big.NewInt(1), code).SignECDSA(testBankKey)
+	if err := eth.txPool.Add(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	trace := func() {
+		result, err := api.TracePendingTransaction(tx.Hash(), nil)
+		if err != nil {
+			t.Fatalf("failed to trace pending transaction: %v", err)
+		}
+		var ops []string
+		for _, log := range result.StructLogs {
+			ops = append(ops, log.Op)
+		}
+		if want := []string{"PUSH1", "PUSH1", "SSTORE", "STOP"}; !reflect.DeepEqual(ops, want) {
+			t.Errorf("traced opcodes mismatch: have %v, want %v", ops, want)
+		}
+	}
+	trace()
+
+	// Wait for the transaction to be applied to the pending block and trace again
+	waitPendingTxs(t, eth, 1)
+	trace()
+	if _, err := api.TracePendingTransaction(common.Hash{0x01}, nil); err == nil {
+		t.Errorf("unknown transaction traced")
+	} else if _, ok := err.(*rpc.NotFoundError); !ok {
+		t.Errorf("unknown transaction error mismatch: have %T, want *rpc.NotFoundError", err)
+	}
+}
+
+// Tests that state queries below the oldest block with available state report
+// the pruning height instead of a generic missing trie node error.
+func TestOldestBlock(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 20, nil, nil)
+	defer pm.Stop()
+
+	eth := newTestChainEthereum(pm)
+	api := NewPublicEthereumAPI(eth)
+	state := ethapi.NewPublicBlockChainAPI(&EthApiBackend{eth: eth})
+
+	if oldest := api.OldestBlock().Uint64(); oldest != 0 {
+		t.Fatalf("oldest block mismatch before pruning: have %d, want 0", oldest)
+	}
+	// Simulate pruning by dropping the state roots below block 5
+	for number := uint64(0); number < 5; number++ {
+		pm.chaindb.(*ethdb.MemDatabase).Delete(pm.blockchain.GetBlockByNumber(number).Root().Bytes())
+	}
+	if oldest := api.OldestBlock().Uint64(); oldest != 5 {
+		t.Fatalf("oldest block mismatch after pruning: have %d, want 5", oldest)
+	}
+	_, err := state.GetBalance(context.Background(), testBank.Address, 4)
+	if _, ok := err.(*rpc.NotFoundError); !ok || !strings.Contains(err.Error(), "pruned below 5") {
+		t.Errorf("pruned state error mismatch: have %v, want pruned below 5", err)
+	}
+	if _, err := state.GetBalance(context.Background(), testBank.Address, 5); err != nil {
+		t.Errorf("failed to retrieve balance at oldest block: %v", err)
+	}
+	// Rewinding the head should restart the search, still finding the oldest block
+	pm.blockchain.SetHead(10)
+	if oldest := api.OldestBlock().Uint64(); oldest != 5 {
+		t.Fatalf("oldest block mismatch after rewind: have %d, want 5", oldest)
+	}
+	// Rewinding below the oldest block should never report one beyond the head
+	pm.blockchain.SetHead(3)
+	if oldest := api.OldestBlock().Uint64(); oldest != 3 {
+		t.Fatalf("oldest block mismatch after rewind below it: have %d, want head 3", oldest)
+	}
+}
+
+// Tests that reindexing a block range restores lost transaction lookup entries,
+// making the transactions retrievable through the RPC API again.
+func TestReindexTransactions(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 4, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.Address{byte(i)}, big.NewInt(1000), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}, nil)
+	defer pm.Stop()
+
+	eth := newTestChainEthereum(pm)
+	debug := NewPrivateDebugAPI(pm.blockchain.Config(), eth)
+	txapi := ethapi.NewPublicTransactionPoolAPI(&EthApiBackend{eth: eth})
+
+	// Drop the lookup entry of a mined transaction and ensure it's lost
+	hash := pm.blockchain.GetBlockByNumber(2).Transactions()[0].Hash()
+	pm.chaindb.(*ethdb.MemDatabase).Delete(append(hash.Bytes(), 0x01))
+
+	if tx, _ := txapi.GetTransactionByHash(context.Background(), hash, nil); tx != nil {
+		t.Fatalf("transaction retrievable without lookup entry")
+	}
+	// Reindex the chain and ensure the transaction is found again
+	if _, err := debug.ReindexTransactions(3, 2); err == nil {
+		t.Errorf("inverted range accepted")
+	}
+	if _, err := debug.ReindexTransactions(0, 5); err == nil {
+		t.Errorf("range beyond head accepted")
+	}
+	count, err := debug.ReindexTransactions(1, 4)
+	if err != nil {
+		t.Fatalf("failed to reindex transactions: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("reindexed count mismatch: have %d, want %d", count, 4)
+	}
+	result, err := txapi.GetTransactionByHash(context.Background(), hash, nil)
+	tx, ok := result.(*ethapi.RPCTransaction)
+	if err != nil || !ok {
+		t.Fatalf("failed to retrieve reindexed transaction: %v, %v", result, err)
+	}
+	if tx.Hash != hash || tx.BlockNumber.BigInt().Uint64() != 2 {
+		t.Errorf("transaction mismatch: have %x in #%v, want %x in #2", tx.Hash, tx.BlockNumber, hash)
+	}
+}
+
+// Tests that the self-check reports no anomalies for a consistent database and
+// flags missing lookup entries, receipt count mismatches and invalid blooms.
+func TestSelfCheck(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 4, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.Address{byte(i)}, big.NewInt(1000), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}, nil)
+	defer pm.Stop()
+
+	debug := NewPrivateDebugAPI(pm.blockchain.Config(), newTestChainEthereum(pm))
+
+	result, err := debug.SelfCheck()
+	if err != nil {
+		t.Fatalf("failed to run self-check: %v", err)
+	}
+	if blocks := result["blocks"].(uint64); blocks != 5 {
+		t.Errorf("checked block count mismatch: have %d, want %d", blocks, 5)
+	}
+	if anomalies := result["anomalies"].([]string); len(anomalies) != 0 {
+		t.Errorf("consistent database reported anomalies: %v", anomalies)
+	}
+	// Drop a lookup entry, truncate the receipts of a block and corrupt a bloom
+	block2, block3, block4 := pm.blockchain.GetBlockByNumber(2), pm.blockchain.GetBlockByNumber(3), pm.blockchain.GetBlockByNumber(4)
+
+	core.DeleteTransaction(pm.chaindb, block2.Transactions()[0].Hash())
+	core.WriteBlockReceipts(pm.chaindb, block3.Hash(), 3, types.Receipts{})
+
+	receipts := core.GetBlockReceipts(pm.chaindb, block4.Hash(), 4)
+	receipts[0].Bloom = types.Bloom{0x01}
+	core.WriteBlockReceipts(pm.chaindb, block4.Hash(), 4, receipts)
+
+	if result, err = debug.SelfCheck(); err != nil {
+		t.Fatalf("failed to run self-check: %v", err)
+	}
+	anomalies := result["anomalies"].([]string)
+	for _, want := range []string{"block #2: tx", "block #3: 0 receipts for 1 transactions", "block #4: receipt 0: bloom"} {
+		found := false
+		for _, anomaly := range anomalies {
+			if strings.HasPrefix(anomaly, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("anomaly %q not reported: %v", want, anomalies)
+		}
+	}
+	if len(anomalies) != 3 {
+		t.Errorf("anomaly count mismatch: have %d, want %d: %v", len(anomalies), 3, anomalies)
+	}
+}
+
+// Tests that a batch of headers can be verified against each other and the local
+// chain, invalidating both broken headers and their descendants.
+func TestVerifyHeaders(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	defer pm.Stop()
+
+	api := NewPrivateDebugAPI(pm.blockchain.Config(), newTestChainEthereum(pm))
+
+	// Generate a chain of headers on top of the local genesis without importing them
+	blocks, _ := core.GenerateChain(pm.blockchain.Config(), pm.blockchain.Genesis(), pm.chaindb, 4, nil)
+	headers := func(broken int) string {
+		var batch []*types.Header
+		for i, block := range blocks {
+			header := block.Header()
+			if i == broken {
+				header.Difficulty = new(big.Int).Add(header.Difficulty, big.NewInt(1))
+			}
+			batch = append(batch, header)
+		}
+		blob, err := rlp.EncodeToBytes(batch)
+		if err != nil {
+			t.Fatalf("failed to encode headers: %v", err)
+		}
+		return common.ToHex(blob)
+	}
+	tests := []struct {
+		broken int
+		want   []bool
+	}{
+		{-1, []bool{true, true, true, true}},
+		{3, []bool{true, true, true, false}},
+		{1, []bool{true, false, false, false}},
+	}
+	for i, tt := range tests {
+		valid, err := api.VerifyHeaders(headers(tt.broken))
+		if err != nil {
+			t.Fatalf("test %d: failed to verify headers: %v", i, err)
+		}
+		if !reflect.DeepEqual(valid, tt.want) {
+			t.Errorf("test %d: validity mismatch: have %v, want %v", i, valid, tt.want)
+		}
+	}
+	if _, err := api.VerifyHeaders("0x1234"); err == nil {
+		t.Errorf("malformed header list accepted")
+	}
+}
+
+// Tests that the transaction pool can be exported and imported into a fresh one,
+// skipping transactions that are no longer acceptable.
+func TestPoolExportImport(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	defer pm.Stop()
+
+	newPool := func() *core.TxPool {
+		pool := core.NewTxPool(pm.blockchain.Config(), new(event.TypeMux), pm.blockchain.State, pm.blockchain.GasLimit)
+		pool.Pending() // Initializes the pending state of the pool
+		return pool
+	}
+	source, target := newPool(), newPool()
+	defer source.Stop()
+	defer target.Stop()
+
+	// Populate the source pool with both executable and queued transactions
+	for _, nonce := range []uint64{0, 1, 3} {
+		tx, _ := types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		if err := source.Add(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+	}
+	data, err := NewPrivateAdminAPI(&Ethereum{blockchain: pm.blockchain, txPool: source}).ExportPool()
+	if err != nil {
+		t.Fatalf("failed to export pool: %v", err)
+	}
+	api := NewPrivateAdminAPI(&Ethereum{blockchain: pm.blockchain, txPool: target})
+	if _, err := api.ImportPool("0x0102"); err == nil {
+		t.Errorf("malformed pool data accepted")
+	}
+	accepted, err := api.ImportPool(data)
+	if err != nil {
+		t.Fatalf("failed to import pool: %v", err)
+	}
+	if accepted != 3 {
+		t.Errorf("accepted count mismatch: have %d, want 3", accepted)
+	}
+	if pending, queued := target.Stats(); pending != 2 || queued != 1 {
+		t.Errorf("pool stats mismatch: have %d/%d, want 2/1", pending, queued)
+	}
+	// Importing again should skip all the already known transactions
+	if accepted, err = api.ImportPool(data); err != nil || accepted != 0 {
+		t.Errorf("reimport mismatch: have %d accepted (%v), want 0", accepted, err)
+	}
+}
+
+// Tests that exported pool transactions are ordered by account and nonce.
+func TestPoolExportOrder(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	// Fund the accounts in the first block
+	pm := newTestProtocolManagerMust(t, false, 1, func(i int, block *core.BlockGen) {
+		for _, key := range keys {
+			tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), crypto.PubkeyToAddress(key.PublicKey), big.NewInt(100000), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+			block.AddTx(tx)
+		}
+	}, nil)
+	defer pm.Stop()
+
+	pool := core.NewTxPool(pm.blockchain.Config(), new(event.TypeMux), pm.blockchain.State, pm.blockchain.GasLimit)
+	defer pool.Stop()
+	pool.Pending() // Initializes the pending state of the pool
+
+	for _, key := range keys {
+		for _, nonce := range []uint64{1, 0} {
+			tx, _ := types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(key)
+			if err := pool.Add(tx); err != nil {
+				t.Fatalf("failed to add transaction: %v", err)
+			}
+		}
+	}
+	data, err := NewPrivateAdminAPI(&Ethereum{blockchain: pm.blockchain, txPool: pool}).ExportPool()
+	if err != nil {
+		t.Fatalf("failed to export pool: %v", err)
+	}
+	var txs types.Transactions
+	if err := rlp.DecodeBytes(common.FromHex(data), &txs); err != nil {
+		t.Fatalf("failed to decode exported pool: %v", err)
+	}
+	if len(txs) != 2*len(keys) {
+		t.Fatalf("exported transaction count mismatch: have %d, want %d", len(txs), 2*len(keys))
+	}
+	for i := 1; i < len(txs); i++ {
+		prev, _ := txs[i-1].From()
+		from, _ := txs[i].From()
+		if cmp := bytes.Compare(prev[:], from[:]); cmp > 0 || (cmp == 0 && txs[i-1].Nonce() >= txs[i].Nonce()) {
+			t.Errorf("transaction %d out of order: %x #%d after %x #%d", i, from, txs[i].Nonce(), prev, txs[i-1].Nonce())
+		}
+	}
+}
+
+// Tests that the gas price spread and total fees of the pending block are
+// reported, and that they're zero for an empty pending block.
+func TestPendingBlockFeeStats(t *testing.T) {
+	eth, teardown := newTestMinerEthereum(t, 0, nil)
+	defer teardown()
+	api := NewPrivateMinerAPI(eth)
+
+	check := func(stats map[string]*rpc.HexNumber, want map[string]int64) {
+		for name, value := range want {
+			if have := stats[name].BigInt(); have.Cmp(big.NewInt(value)) != 0 {
+				t.Errorf("%s mismatch: have %v, want %d", name, have, value)
+			}
+		}
+	}
+	stats, err := api.PendingBlockFeeStats()
+	if err != nil {
+		t.Fatalf("failed to retrieve empty block stats: %v", err)
+	}
+	check(stats, map[string]int64{"min": 0, "median": 0, "max": 0, "totalFees": 0})
+
+	// Pool transactions of varied prices, waiting for each to be pending in turn
+	for nonce, price := range []int64{5, 1, 2, 4} {
+		tx, _ := types.NewTransaction(uint64(nonce), common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(price), nil).SignECDSA(testBankKey)
+		if err := eth.txPool.Add(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+		waitPendingTxs(t, eth, nonce+1)
+	}
+	if stats, err = api.PendingBlockFeeStats(); err != nil {
+		t.Fatalf("failed to retrieve pending block stats: %v", err)
+	}
+	check(stats, map[string]int64{"min": 1, "median": 3, "max": 5, "totalFees": 21000 * 12})
+}
+
+// Tests that the code of a contract deployed by a transaction in the pending block
+// is visible at the pending block, but not at the latest one.
+func TestGetCodePending(t *testing.T) {
+	eth, teardown := newTestMinerEthereum(t, 0, nil)
+	defer teardown()
+	api := ethapi.NewPublicBlockChainAPI(&EthApiBackend{eth: eth})
+
+	// Deploy a contract whose runtime code is the single byte 0x2a
+	tx, _ := types.NewContractCreation(0, new(big.Int), big.NewInt(100000), big.NewInt(1), common.FromHex("602a60005360016000f3")).SignECDSA(testBankKey)
+	if err := eth.txPool.Add(tx); err != nil {
+		t.Fatalf("failed to add deployment: %v", err)
+	}
+	contract := crypto.CreateAddress(testBank.Address, 0)
+
+	// Wait for the deployment to be applied to the pending block and check the code
+	waitPendingTxs(t, eth, 1)
+	if code, err := api.GetCode(context.Background(), contract, rpc.PendingBlockNumber); err != nil || code != "0x2a" {
+		t.Errorf("pending code mismatch: have %s, %v, want 0x2a", code, err)
+	}
+	if code, err := api.GetCode(context.Background(), contract, rpc.LatestBlockNumber); err != nil || code != "0x" {
+		t.Errorf("latest code mismatch: have %s, %v, want 0x", code, err)
+	}
+}
+
+// Tests that the canonical chain repair restores corrupted canonical mappings and
+// transaction lookup entries, and drops stale mappings beyond the head.
+func TestRepairCanonical(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 4, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.Address{byte(i)}, big.NewInt(1000), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}, nil)
+	defer pm.Stop()
+
+	api := NewPrivateAdminAPI(newTestChainEthereum(pm))
+
+	report, err := api.RepairCanonical()
+	if err != nil {
+		t.Fatalf("failed to repair consistent chain: %v", err)
+	}
+	if report.Head != 4 || report.Checked != 5 || len(report.Canonical)+len(report.Stale)+len(report.Reindexed) != 0 {
+		t.Fatalf("consistent chain repaired: %+v", report)
+	}
+	// Point a canonical number elsewhere, drop a lookup entry and leave a stale mapping
+	block2, block3 := pm.blockchain.GetBlockByNumber(2), pm.blockchain.GetBlockByNumber(3)
+
+	core.WriteCanonicalHash(pm.chaindb, common.Hash{0xde, 0xad}, 2)
+	core.DeleteTransaction(pm.chaindb, block3.Transactions()[0].Hash())
+	core.WriteCanonicalHash(pm.chaindb, common.Hash{0xbe, 0xef}, 5)
+
+	if report, err = api.RepairCanonical(); err != nil {
+		t.Fatalf("failed to repair corrupted chain: %v", err)
+	}
+	if !reflect.DeepEqual(report.Canonical, []uint64{2}) {
+		t.Errorf("remapped blocks mismatch: have %v, want [2]", report.Canonical)
+	}
+	if !reflect.DeepEqual(report.Reindexed, []uint64{3}) {
+		t.Errorf("reindexed blocks mismatch: have %v, want [3]", report.Reindexed)
+	}
+	if !reflect.DeepEqual(report.Stale, []uint64{5}) {
+		t.Errorf("stale mappings mismatch: have %v, want [5]", report.Stale)
+	}
+	if hash := core.GetCanonicalHash(pm.chaindb, 2); hash != block2.Hash() {
+		t.Errorf("canonical hash #2 mismatch: have %x, want %x", hash, block2.Hash())
+	}
+	if hash := core.GetCanonicalHash(pm.chaindb, 5); hash != (common.Hash{}) {
+		t.Errorf("stale canonical hash #5 kept: %x", hash)
+	}
+	if _, blockHash, _, _ := core.GetTransaction(pm.chaindb, block3.Transactions()[0].Hash()); blockHash != block3.Hash() {
+		t.Errorf("lookup entry not restored: have block %x, want %x", blockHash, block3.Hash())
+	}
+	// Rewind the head block only, as fast sync leaves it behind the head header
+	block4 := pm.blockchain.GetBlockByNumber(4)
+
+	core.WriteHeadBlockHash(pm.chaindb, block2.Hash())
+	core.WriteCanonicalHash(pm.chaindb, common.Hash{0xbe, 0xef}, 5)
+
+	if report, err = api.RepairCanonical(); err != nil {
+		t.Fatalf("failed to repair fast synced chain: %v", err)
+	}
+	if report.Head != 2 || !reflect.DeepEqual(report.Stale, []uint64{5}) {
+		t.Errorf("fast synced chain repair mismatch: have head #%d stale %v, want head #2 stale [5]", report.Head, report.Stale)
+	}
+	if hash := core.GetCanonicalHash(pm.chaindb, 4); hash != block4.Hash() {
+		t.Errorf("header chain canonical hash #4 mismatch: have %x, want %x", hash, block4.Hash())
+	}
+}
+
+// Tests that the transaction pool can be dumped to and loaded from a local file,
+// skipping the transactions no longer valid on load.
+func TestPoolDumpLoad(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	defer pm.Stop()
+
+	newPool := func() *core.TxPool {
+		pool := core.NewTxPool(pm.blockchain.Config(), new(event.TypeMux), pm.blockchain.State, pm.blockchain.GasLimit)
+		pool.Pending() // Initializes the pending state of the pool
+		return pool
+	}
+	source, target := newPool(), newPool()
+	defer source.Stop()
+	defer target.Stop()
+
+	// Populate the source pool with both executable and queued transactions
+	for _, nonce := range []uint64{0, 1, 3} {
+		tx, _ := types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		if err := source.Add(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+	}
+	dir, err := ioutil.TempDir("", "pooldump")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "pool.rlp")
+
+	dumped, err := NewPrivateAdminAPI(&Ethereum{blockchain: pm.blockchain, txPool: source}).DumpPoolToFile(file)
+	if err != nil {
+		t.Fatalf("failed to dump pool: %v", err)
+	}
+	if dumped != 3 {
+		t.Errorf("dumped count mismatch: have %d, want 3", dumped)
+	}
+	// Append a transaction from an unfunded account, invalid on load
+	key, _ := crypto.GenerateKey()
+	invalid, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(key)
+	out, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open dump: %v", err)
+	}
+	if err := rlp.Encode(out, invalid); err != nil {
+		t.Fatalf("failed to append transaction: %v", err)
+	}
+	out.Close()
+
+	api := NewPrivateAdminAPI(&Ethereum{blockchain: pm.blockchain, txPool: target})
+	accepted, err := api.LoadPoolFromFile(file)
+	if err != nil {
+		t.Fatalf("failed to load pool: %v", err)
+	}
+	if accepted != 3 {
+		t.Errorf("accepted count mismatch: have %d, want 3", accepted)
+	}
+	if pending, queued := target.Stats(); pending != 2 || queued != 1 {
+		t.Errorf("pool stats mismatch: have %d/%d, want 2/1", pending, queued)
+	}
+	if _, err := api.LoadPoolFromFile(filepath.Join(dir, "missing.rlp")); err == nil {
+		t.Errorf("missing dump loaded")
+	}
+}
+
+// Tests that the miner state snapshot reflects the configured mining parameters
+// and the contents of the pending block.
+func TestMinerState(t *testing.T) {
+	eth, teardown := newTestMinerEthereum(t, 0, nil)
+	defer teardown()
+	api := NewPrivateMinerAPI(eth)
+
+	coinbase := common.Address{0xc0}
+	eth.SetEtherbase(coinbase)
+	if _, err := api.SetExtra("stuck?"); err != nil {
+		t.Fatalf("failed to set extra data: %v", err)
+	}
+	tx, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(40), nil).SignECDSA(testBankKey)
+	if err := eth.txPool.Add(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	// Wait for the transaction to be applied to the pending block
+	waitPendingTxs(t, eth, 1)
+
+	// Raise the gas price floor, which doesn't affect the current pending block
+	api.SetGasPrice(*rpc.NewHexNumber(1000))
+	state, err := api.MinerState()
+	if err != nil {
+		t.Fatalf("failed to retrieve miner state: %v", err)
+	}
+	if state["pendingTransactions"] != 1 {
+		t.Errorf("pending transaction count mismatch: have %v, want 1", state["pendingTransactions"])
+	}
+	if state["coinbase"] != coinbase {
+		t.Errorf("coinbase mismatch: have %v, want %x", state["coinbase"], coinbase)
+	}
+	if state["extraData"] != common.ToHex([]byte("stuck?")) {
+		t.Errorf("extra data mismatch: have %v, want %x", state["extraData"], "stuck?")
+	}
+	if price := state["gasPrice"].(*rpc.HexNumber).BigInt(); price.Cmp(big.NewInt(900)) != 0 {
+		t.Errorf("gas price floor mismatch: have %v, want 900", price)
+	}
+	if number := state["pendingNumber"].(*rpc.HexNumber).BigInt(); number.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("pending block number mismatch: have %v, want 1", number)
+	}
+	if state["mining"] != false || state["agents"] != 0 || state["autoDAG"] != false {
+		t.Errorf("idle miner state mismatch: mining %v, agents %v, autoDAG %v", state["mining"], state["agents"], state["autoDAG"])
+	}
+}
+
+// Tests that the miner state reports the progress of automatic DAG pregeneration.
+func TestMinerStateDAGProgress(t *testing.T) {
+	eth, teardown := newTestMinerEthereum(t, 0, nil)
+	defer teardown()
+
+	dir, err := ioutil.TempDir("", "eth-dag-test")
+	if err != nil {
+		t.Fatalf("failed to create DAG directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	steps := make(chan int)
+	eth.dags = newDAGJobs(func(block uint64, dir string, progress func(int), abort <-chan struct{}) error {
+		for percent := range steps {
+			progress(percent)
+		}
+		return nil
+	}, dir)
+	api := NewPrivateMinerAPI(eth)
+
+	// dagState retrieves the DAG related fields of the miner state
+	dagState := func() (bool, uint64, int) {
+		state, err := api.MinerState()
+		if err != nil {
+			t.Fatalf("failed to retrieve miner state: %v", err)
+		}
+		return state["dagGenerating"].(bool), state["dagEpoch"].(*rpc.HexNumber).Uint64(), state["dagPercent"].(int)
+	}
+	if generating, _, _ := dagState(); generating {
+		t.Fatalf("idle miner reports DAG generation")
+	}
+	done := make(chan DAGStatus)
+	go func() { done <- eth.pregenerateDAG(2) }()
+
+	steps <- 35
+	for i := 0; ; i++ {
+		generating, epoch, percent := dagState()
+		if generating && epoch == 2 && percent == 35 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("DAG progress mismatch: generating %v, epoch %d, percent %d", generating, epoch, percent)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	steps <- 100
+	close(steps)
+
+	if status := <-done; !status.Done || status.Percent != 100 || status.Error != "" {
+		t.Errorf("pregeneration status mismatch: %+v", status)
+	}
+	if generating, _, percent := dagState(); generating || percent != 0 {
+		t.Errorf("finished DAG generation still reported: percent %d", percent)
+	}
+}
+
+// Tests that adding a transaction to the pool results in exactly one pending
+// block notification, containing the transaction.
+func TestPendingBlockNotifications(t *testing.T) {
+	eth, teardown := newTestMinerEthereum(t, 0, nil)
+	defer teardown()
+
+	events := eth.eventMux.Subscribe(core.PendingBlockEvent{})
+	defer events.Unsubscribe()
+
+	quit := make(chan struct{})
+	defer close(quit)
+
+	blocks := make(chan *types.Block, 10)
+	go throttlePendingBlocks(events.Chan(), quit, 50*time.Millisecond, func(block *types.Block) { blocks <- block })
+
+	// Drop the notification of the initial pending block built by the miner
+	select {
+	case <-blocks:
+	case <-time.After(200 * time.Millisecond):
+	}
+	tx, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(40), nil).SignECDSA(testBankKey)
+	if err := eth.txPool.Add(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	select {
+	case block := <-blocks:
+		if txs := block.Transactions(); len(txs) != 1 || txs[0].Hash() != tx.Hash() {
+			t.Errorf("pending block transactions mismatch: have %d, want [%x]", len(txs), tx.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("pending block notification timeout")
+	}
+	select {
+	case block := <-blocks:
+		t.Errorf("unexpected notification with %d txs", len(block.Transactions()))
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// Tests that pending block subscriptions made through the RPC API see the pending
+// block advance as transactions are added, never going backwards.
+func TestNewPendingBlockSubscription(t *testing.T) {
+	eth, teardown := newTestMinerEthereum(t, 0, nil)
+	defer teardown()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", NewPrivateMinerAPI(eth)); err != nil {
+		t.Fatalf("failed to register miner API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	results := make(chan map[string]interface{}, 16)
+	sub, err := client.EthSubscribe(context.Background(), results, "newPendingBlock")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Add transactions one by one, each rebuilding the pending block
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, _ := types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		if err := eth.txPool.Add(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+		waitPendingTxs(t, eth, int(nonce)+1)
+	}
+	// Wait until the last transaction shows up, ensuring the counts never decrease
+	last := -1
+	for last < 3 {
+		select {
+		case result := <-results:
+			count := int(result["transactions"].(float64))
+			if count < last {
+				t.Fatalf("pending block went backwards: have %d transactions, had %d", count, last)
+			}
+			last = count
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("pending block notification timeout, last seen %d transactions", last)
+		}
+	}
+}
+
+// Tests that DAG generations run in the background, reporting their progress,
+// and that cancelled ones get their partial files cleaned up.
+func TestMakeDAG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-dag-test")
+	if err != nil {
+		t.Fatalf("failed to create DAG directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a fake generator writing a partial DAG file and advancing its
+	// progress one step at a time as requested by the test
+	steps := make(chan int)
+	generate := func(block uint64, dir string, progress func(int), abort <-chan struct{}) error {
+		dag, _ := dagFiles(block / epochLength)
+		if err := ioutil.WriteFile(filepath.Join(dir, dag), []byte("partial"), 0600); err != nil {
+			return err
+		}
+		for {
+			select {
+			case percent := <-steps:
+				progress(percent)
+				if percent == 100 {
+					return nil
+				}
+			case <-abort:
+				return errDAGCancelled
+			}
+		}
+	}
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	defer pm.Stop()
+
+	api := &PrivateMinerAPI{e: newTestChainEthereum(pm), dags: newDAGJobs(generate, dir)}
+
+	// waitStatus polls a generation until its status satisfies the condition
+	waitStatus := func(id int, cond func(*DAGStatus) bool) *DAGStatus {
+		for i := 0; ; i++ {
+			status, err := api.DAGProgress(id)
+			if err != nil {
+				t.Fatalf("generation %d: failed to retrieve progress: %v", id, err)
+			}
+			if cond(status) {
+				return status
+			}
+			if i == 100 {
+				t.Fatalf("generation %d: status timeout: %+v", id, status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	// Run a generation to completion, tracking its progress
+	done, _ := api.MakeDAG(rpc.BlockNumber(epochLength))
+	steps <- 40
+	waitStatus(done, func(s *DAGStatus) bool { return s.Percent == 40 && !s.Done })
+
+	// Generating the DAG of the same epoch again must join the running generation
+	if dup, _ := api.MakeDAG(rpc.BlockNumber(epochLength + 1)); dup != done {
+		t.Fatalf("duplicate generation started for the same epoch: have %d, want %d", dup, done)
+	}
+	steps <- 100
+	status := waitStatus(done, func(s *DAGStatus) bool { return s.Done })
+	if status.Percent != 100 || status.Error != "" || status.Block != epochLength {
+		t.Errorf("finished generation status mismatch: %+v", status)
+	}
+	if api.CancelDAG(done) {
+		t.Errorf("finished generation cancelled")
+	}
+	// Cancel a generation midway and ensure its files are removed
+	cancelled, _ := api.MakeDAG(rpc.BlockNumber(2 * epochLength))
+	steps <- 10
+	waitStatus(cancelled, func(s *DAGStatus) bool { return s.Percent == 10 })
+	if !api.CancelDAG(cancelled) {
+		t.Fatalf("running generation not cancelled")
+	}
+	status = waitStatus(cancelled, func(s *DAGStatus) bool { return s.Done })
+	if status.Error != errDAGCancelled.Error() {
+		t.Errorf("cancelled generation error mismatch: have %q, want %q", status.Error, errDAGCancelled)
+	}
+	dag, _ := dagFiles(2)
+	if _, err := os.Stat(filepath.Join(dir, dag)); !os.IsNotExist(err) {
+		t.Errorf("partial DAG file not removed: %v", err)
+	}
+	// Cancel a generation of a partially pre-existing DAG and ensure it's kept
+	dag, _ = dagFiles(3)
+	if err := ioutil.WriteFile(filepath.Join(dir, dag), []byte("existing"), 0600); err != nil {
+		t.Fatalf("failed to create existing DAG file: %v", err)
+	}
+	existing, _ := api.MakeDAG(rpc.BlockNumber(3 * epochLength))
+	steps <- 10
+	waitStatus(existing, func(s *DAGStatus) bool { return s.Percent == 10 })
+	api.CancelDAG(existing)
+	waitStatus(existing, func(s *DAGStatus) bool { return s.Done })
+	if _, err := os.Stat(filepath.Join(dir, dag)); err != nil {
+		t.Errorf("pre-existing DAG file removed: %v", err)
+	}
+	// Generating an already complete DAG finishes right away
+	_, dagFull := dagFiles(3)
+	if err := ioutil.WriteFile(filepath.Join(dir, dagFull), []byte("existing"), 0600); err != nil {
+		t.Fatalf("failed to create existing DAG file: %v", err)
+	}
+	complete, _ := api.MakeDAG(rpc.BlockNumber(3 * epochLength))
+	if status, err := api.DAGProgress(complete); err != nil || !status.Done || status.Percent != 100 || status.Error != "" {
+		t.Errorf("complete DAG generation status mismatch: %+v, %v", status, err)
+	}
+	// Finished generations are dropped from the running ones, keeping a bounded history
+	api.dags.lock.Lock()
+	if len(api.dags.jobs) != 0 {
+		t.Errorf("finished generations still tracked as running: %d", len(api.dags.jobs))
+	}
+	api.dags.lock.Unlock()
+
+	for i := 0; i < dagJobHistory; i++ {
+		api.MakeDAG(rpc.BlockNumber(3 * epochLength))
+	}
+	if _, err := api.DAGProgress(done); err == nil {
+		t.Errorf("generation beyond the history still reported")
+	}
+	if _, err := api.DAGProgress(1000); err == nil {
+		t.Errorf("unknown generation reported")
+	}
+}
+
+// Tests that account activity is indexed from the chain and kept up to date as
+// new blocks are imported.
+func TestAccountActivity(t *testing.T) {
+	var (
+		receiver = common.Address{0x01}
+		idle     = common.Address{0x02}
+	)
+	// Send value to the receiver in blocks #1 and #3, and nothing to the idle account in #2
+	pm := newTestProtocolManagerMust(t, false, 4, func(i int, block *core.BlockGen) {
+		var tx *types.Transaction
+		switch i {
+		case 0, 2:
+			tx, _ = types.NewTransaction(block.TxNonce(testBank.Address), receiver, big.NewInt(1000), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		case 1:
+			tx, _ = types.NewTransaction(block.TxNonce(testBank.Address), idle, new(big.Int), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		default:
+			return
+		}
+		block.AddTx(tx)
+	}, nil)
+	defer pm.Stop()
+
+	api := NewPrivateDebugAPI(pm.blockchain.Config(), newTestChainEthereum(pm))
+	check := func(address common.Address, first, last *rpc.HexNumber, sent uint64) {
+		act, err := api.GetAccountActivity(address)
+		if err != nil {
+			t.Fatalf("%x: failed to retrieve activity: %v", address, err)
+		}
+		if !reflect.DeepEqual(act.FirstBlock, first) || !reflect.DeepEqual(act.LastBlock, last) || act.TxsSent.Uint64() != sent {
+			t.Errorf("%x: activity mismatch: have first %v, last %v, sent %v; want first %v, last %v, sent %d", address, act.FirstBlock, act.LastBlock, act.TxsSent, first, last, sent)
+		}
+	}
+	check(testBank.Address, rpc.NewHexNumber(1), rpc.NewHexNumber(3), 3)
+	check(receiver, rpc.NewHexNumber(1), rpc.NewHexNumber(3), 0)
+	check(idle, nil, nil, 0)
+
+	// Import new blocks and ensure they are picked up incrementally
+	blocks, _ := core.GenerateChain(nil, pm.blockchain.CurrentBlock(), pm.chaindb, 2, func(i int, block *core.BlockGen) {
+		if i == 1 {
+			tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), idle, big.NewInt(1), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	if _, err := pm.blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import new blocks: %v", err)
+	}
+	check(testBank.Address, rpc.NewHexNumber(1), rpc.NewHexNumber(6), 4)
+	check(idle, rpc.NewHexNumber(6), rpc.NewHexNumber(6), 0)
+}
+
+// Tests that state reads are refused until the node has enough peers and finished
+// its initial sync, and served normally afterwards.
+func TestMinServingPeers(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 1, nil, nil)
+	defer pm.Stop()
+
+	eth := newTestChainEthereum(pm)
+	eth.protocolManager = pm
+	api := ethapi.NewPublicBlockChainAPI(&EthApiBackend{eth: eth})
+	admin := NewPrivateAdminAPI(eth)
+
+	if ok, err := admin.SetMinServingPeers(2); !ok || err != nil {
+		t.Fatalf("failed to set serving peer threshold: %v", err)
+	}
+	if peers := admin.MinServingPeers(); peers != 2 {
+		t.Fatalf("serving peer threshold mismatch: have %d, want %d", peers, 2)
+	}
+	// Connect peers one by one, checking that reads are refused below the threshold
+	for i := 0; i < 2; i++ {
+		_, err := api.GetBalance(context.Background(), testBank.Address, rpc.LatestBlockNumber)
+		if _, ok := err.(*rpc.ServerBusyError); !ok || !strings.Contains(err.Error(), "node not ready") {
+			t.Fatalf("%d peers: state read error mismatch: have %v, want server busy node not ready", i, err)
+		}
+		peer, _ := newTestPeer(fmt.Sprintf("peer %d", i), eth63, pm, true)
+		defer peer.close()
+
+		for start := time.Now(); pm.peers.Len() < i+1; time.Sleep(10 * time.Millisecond) {
+			if time.Since(start) > time.Second {
+				t.Fatalf("peer %d not registered", i)
+			}
+		}
+	}
+	// Enough peers are connected, but the initial sync did not finish yet
+	_, err := api.GetBalance(context.Background(), testBank.Address, rpc.LatestBlockNumber)
+	if _, ok := err.(*rpc.ServerBusyError); !ok || !strings.Contains(err.Error(), "syncing") {
+		t.Fatalf("unsynced state read error mismatch: have %v, want server busy syncing", err)
+	}
+	atomic.StoreUint32(&pm.synced, 1)
+
+	balance, err := api.GetBalance(context.Background(), testBank.Address, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to read balance: %v", err)
+	}
+	if balance.Cmp(testBank.Balance) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", balance, testBank.Balance)
+	}
+	// Disabling the threshold should serve reads regardless of the peers
+	admin.SetMinServingPeers(0)
+	atomic.StoreUint32(&pm.synced, 0)
+	if _, err := api.GetBalance(context.Background(), testBank.Address, rpc.LatestBlockNumber); err != nil {
+		t.Errorf("failed to read balance without threshold: %v", err)
+	}
+}
+
+// Tests that stuck transactions of unlocked local accounts get re-signed at a
+// bumped gas price and replaced in the pool once the bumping delay expires. Only
+// the lowest nonce transaction is bumped, and never above the maximum price.
+func TestTxBumping(t *testing.T) {
+	eth, teardown := newTestMinerEthereum(t, 0, nil)
+	defer teardown()
+
+	am, pool, mux := eth.accountManager, eth.txPool, eth.eventMux
+	account, err := am.ImportECDSA(testBankKey, "")
+	if err != nil {
+		t.Fatalf("failed to import account: %v", err)
+	}
+	eth.txBumper = newTxBumper(pool, am, mux)
+	defer eth.txBumper.Stop()
+	admin := NewPrivateAdminAPI(eth)
+
+	bumps := mux.Subscribe(core.TxBumpEvent{})
+	defer bumps.Unsubscribe()
+
+	tx, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(10), nil).SignECDSA(testBankKey)
+	next, _ := types.NewTransaction(1, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(10), nil).SignECDSA(testBankKey)
+	for _, tx := range []*types.Transaction{tx, next} {
+		if err := pool.Add(tx); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	if _, err := admin.SetTxBumping("50ms", 50, nil); err == nil {
+		t.Errorf("bumping without maximum price accepted")
+	}
+	if ok, err := admin.SetTxBumping("50ms", 50, rpc.NewHexNumber(20)); !ok || err != nil {
+		t.Fatalf("failed to enable bumping: %v", err)
+	}
+	if config := admin.TxBumping(); config.Delay != "50ms" || config.Percent != 50 || config.MaxPrice.Int() != 20 {
+		t.Fatalf("bumping config mismatch: have %+v, want 50ms, 50%% and 20 wei", config)
+	}
+	// Locked accounts can't sign replacements, so nothing should be bumped
+	select {
+	case ev := <-bumps.Chan():
+		t.Fatalf("locked account transaction bumped: %v", ev.Data)
+	case <-time.After(200 * time.Millisecond):
+	}
+	// Once unlocked, the stuck transaction should be replaced until the maximum
+	// price is reached, leaving the one queued behind it untouched
+	if err := am.Unlock(account, ""); err != nil {
+		t.Fatalf("failed to unlock account: %v", err)
+	}
+	stuck := tx
+	for i, price := range []int64{15, 20} {
+		select {
+		case ev := <-bumps.Chan():
+			bump := ev.Data.(core.TxBumpEvent)
+			if bump.Old.Hash() != stuck.Hash() {
+				t.Fatalf("bump %d: bumped transaction mismatch: have %x, want %x", i, bump.Old.Hash(), stuck.Hash())
+			}
+			if bump.New.Nonce() != tx.Nonce() || bump.New.GasPrice().Cmp(big.NewInt(price)) != 0 {
+				t.Errorf("bump %d: replacement mismatch: have nonce %d price %v, want nonce %d price %d", i, bump.New.Nonce(), bump.New.GasPrice(), tx.Nonce(), price)
+			}
+			if from, err := bump.New.From(); err != nil || from != testBank.Address {
+				t.Errorf("bump %d: replacement sender mismatch: have %x (%v), want %x", i, from, err, testBank.Address)
+			}
+			if replacement, ok := pool.ReplacedBy(stuck.Hash()); !ok || replacement != bump.New.Hash() {
+				t.Errorf("bump %d: pool replacement mismatch: have %x, want %x", i, replacement, bump.New.Hash())
+			}
+			stuck = bump.New
+		case <-time.After(time.Second):
+			t.Fatalf("bump %d: stuck transaction not bumped", i)
+		}
+	}
+	select {
+	case ev := <-bumps.Chan():
+		t.Fatalf("transaction bumped beyond maximum price: %v", ev.Data)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if _, err := admin.SetTxBumping("1s", 0, rpc.NewHexNumber(20)); err == nil {
+		t.Errorf("zero bump percentage accepted")
+	}
+	if ok, err := admin.SetTxBumping("0", 0, nil); !ok || err != nil {
+		t.Errorf("failed to disable bumping: %v", err)
+	}
+}
+
+// Tests that blocks timestamped ahead of the local clock are reported as queued
+// for a later import.
+func TestFutureBlocks(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 1, nil, nil)
+	defer pm.Stop()
+
+	api := NewPrivateDebugAPI(pm.blockchain.Config(), newTestChainEthereum(pm))
+
+	if blocks, err := api.FutureBlocks(); err != nil || len(blocks) != 0 {
+		t.Fatalf("pristine queue mismatch: have %v, %v, want none", blocks, err)
+	}
+	// Insert a block timestamped within the future tolerance
+	head := pm.blockchain.CurrentBlock()
+	future, _ := core.GenerateChain(nil, head, pm.chaindb, 1, func(i int, b *core.BlockGen) {
+		b.OffsetTime(time.Now().Add(20*time.Second).Unix() - head.Time().Int64())
+	})
+	if _, err := pm.blockchain.InsertChain(future); err != nil {
+		t.Fatalf("failed to queue future block: %v", err)
+	}
+	if pm.blockchain.CurrentBlock().Hash() != head.Hash() {
+		t.Fatalf("future block imported as head")
+	}
+	blocks, err := api.FutureBlocks()
+	if err != nil {
+		t.Fatalf("failed to dump future blocks: %v", err)
+	}
+	if len(blocks) != 1 {
+		t.Fatalf("queued block count mismatch: have %d, want 1", len(blocks))
+	}
+	if hash := blocks[0]["hash"]; hash != future[0].Hash() {
+		t.Errorf("hash mismatch: have %v, want %x", hash, future[0].Hash())
+	}
+	if number := blocks[0]["number"].(*rpc.HexNumber).Int64(); number != 2 {
+		t.Errorf("number mismatch: have %d, want 2", number)
+	}
+	if timestamp := blocks[0]["timestamp"].(*rpc.HexNumber).BigInt(); timestamp.Cmp(future[0].Time()) != 0 {
+		t.Errorf("timestamp mismatch: have %v, want %v", timestamp, future[0].Time())
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/ethash"
@@ -55,7 +56,8 @@ const (
 	ethashRevision = 23
 
 	autoDAGcheckInterval = 10 * time.Hour
-	autoDAGepochHeight   = epochLength / 2
+	autoDAGlookahead     = epochLength / 2 // Default blocks before an epoch change to pregenerate its DAG
+	autoDAGminLookahead  = 3000            // Minimum look-ahead, more than the blocks between two checks
 )
 
 var (
@@ -70,9 +72,16 @@ type Config struct {
 	Genesis   string // Genesis JSON to seed the chain database with
 	FastSync  bool   // Enables the state download based fast synchronisation algorithm
 
+	StrictChainId  bool // Reject raw transactions not signed for the configured chain id
+	RestrictUnlock bool // Forbid indefinite account unlocks over non-local RPC endpoints
+
+	SelfCheck          bool // Verify the indexes and receipts of recent blocks on startup
+	MaxDebugCalls      int  // Maximum number of expensive debug calls running concurrently, 0 if unlimited
+	MinServingPeers    int  // Minimum number of peers before state reads are served, 0 to always serve
 	SkipBcVersionCheck bool // e.g. blockchain export
 	DatabaseCache      int
 	DatabaseHandles    int
+	ChainCache         *core.CacheConfig // Sizes of the in-memory chain caches, nil for the defaults
 
 	NatSpec   bool
 	DocRoot   string
@@ -126,13 +135,21 @@ type Ethereum struct {
 	MinerThreads int
 	AutoDAG      bool
 	autodagquit  chan bool
+	dags         *dagJobs
+	dagJob       int64  // Id+1 of the generation pregenerating the next DAG, 0 if idle (atomic access)
+	dagLookahead uint64 // Blocks before an epoch change to pregenerate its DAG, 0 = default (atomic access)
 	etherbase    common.Address
 	solcPath     string
 
-	NatSpec       bool
-	PowTest       bool
-	netVersionId  int
-	netRPCService *ethapi.PublicNetAPI
+	NatSpec        bool
+	PowTest        bool
+	netVersionId   int
+	netRPCService  *ethapi.PublicNetAPI
+	strictChainId  bool
+	restrictUnlock bool
+	debugCalls     debugLimiter // Limiter of the concurrent expensive debug calls
+	servingPeers   int32        // Minimum number of peers before state reads are served (atomic access)
+	txBumper       *txBumper    // Gas price escalator of stuck local transactions
 }
 
 // New creates a new Ethereum object (including the
@@ -166,7 +183,12 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		MinerThreads:   config.MinerThreads,
 		AutoDAG:        config.AutoDAG,
 		solcPath:       config.SolcPath,
+		strictChainId:  config.StrictChainId,
+		restrictUnlock: config.RestrictUnlock,
+		dags:           newDAGJobs(ethashDAGGenerator, ""),
 	}
+	eth.debugCalls.SetLimit(config.MaxDebugCalls)
+	eth.SetMinServingPeers(config.MinServingPeers)
 
 	if err := upgradeChainDatabase(chainDb); err != nil {
 		return nil, err
@@ -207,15 +229,23 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		ForceJit:  config.ForceJit,
 	}
 
-	eth.blockchain, err = core.NewBlockChain(chainDb, eth.chainConfig, eth.pow, eth.EventMux())
+	eth.blockchain, err = core.NewBlockChainWithCache(chainDb, eth.chainConfig, eth.pow, eth.EventMux(), config.ChainCache)
 	if err != nil {
 		if err == core.ErrNoGenesis {
 			return nil, fmt.Errorf(`No chain found. Please initialise a new chain using the "init" subcommand.`)
 		}
 		return nil, err
 	}
+	if config.SelfCheck {
+		checked, anomalies := selfCheck(eth.blockchain, chainDb, selfCheckBlocks)
+		for _, anomaly := range anomalies {
+			glog.V(logger.Warn).Infof("Self-check anomaly: %s", anomaly)
+		}
+		glog.V(logger.Info).Infof("Self-check of %d recent blocks found %d anomalies", checked, len(anomalies))
+	}
 	newPool := core.NewTxPool(eth.chainConfig, eth.EventMux(), eth.blockchain.State, eth.blockchain.GasLimit)
 	eth.txPool = newPool
+	eth.txBumper = newTxBumper(eth.txPool, eth.accountManager, eth.eventMux)
 
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.FastSync, config.NetworkId, eth.eventMux, eth.txPool, eth.pow, eth.blockchain, chainDb); err != nil {
 		return nil, err
@@ -377,6 +407,38 @@ func (s *Ethereum) EthVersion() int                    { return int(s.protocolMa
 func (s *Ethereum) NetVersion() int                    { return s.netVersionId }
 func (s *Ethereum) Downloader() *downloader.Downloader { return s.protocolManager.downloader }
 
+// MinServingPeers returns the minimum number of peers the node needs before it
+// serves state reads, zero if they are always served.
+func (s *Ethereum) MinServingPeers() int {
+	return int(atomic.LoadInt32(&s.servingPeers))
+}
+
+// SetMinServingPeers sets the minimum number of peers the node needs, besides
+// having finished its initial sync, before it serves state reads. Zero serves
+// them unconditionally.
+func (s *Ethereum) SetMinServingPeers(peers int) {
+	atomic.StoreInt32(&s.servingPeers, int32(peers))
+}
+
+// servingReady returns an error if state reads should not be served yet, since
+// the node has too few peers or did not finish its initial sync, so its state is
+// likely stale.
+func (s *Ethereum) servingReady() error {
+	min := s.MinServingPeers()
+	if min == 0 {
+		return nil
+	}
+	peers, synced := s.protocolManager.peers.Len(), atomic.LoadUint32(&s.protocolManager.synced) == 1
+	if peers >= min && synced {
+		return nil
+	}
+	status := "syncing"
+	if synced {
+		status = "synced"
+	}
+	return rpc.ErrServerBusy("node not ready (%d peers, %s)", peers, status)
+}
+
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
 func (s *Ethereum) Protocols() []p2p.Protocol {
@@ -402,6 +464,7 @@ func (s *Ethereum) Stop() error {
 	}
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
+	s.txBumper.Stop()
 	s.txPool.Stop()
 	s.miner.Stop()
 	s.eventMux.Stop()
@@ -421,7 +484,7 @@ func (s *Ethereum) WaitForShutdown() {
 
 // StartAutoDAG() spawns a go routine that checks the DAG every autoDAGcheckInterval
 // by default that is 10 times per epoch
-// in epoch n, if we past autoDAGepochHeight within-epoch blocks,
+// in epoch n, if we are within the look-ahead distance of the next epoch,
 // it calls ethash.MakeDAG  to pregenerate the DAG for the next epoch n+1
 // if it does not exist yet as well as remove the DAG for epoch n-1
 // the loop quits if autodagquit channel is closed, it can safely restart and
@@ -444,7 +507,7 @@ func (self *Ethereum) StartAutoDAG() {
 				currentBlock := self.BlockChain().CurrentBlock().NumberU64()
 				thisEpoch := currentBlock / epochLength
 				if nextEpoch <= thisEpoch {
-					if currentBlock%epochLength > autoDAGepochHeight {
+					if autoDAGDue(currentBlock, self.AutoDAGLookahead()) {
 						if thisEpoch > 0 {
 							previousDag, previousDagFull := dagFiles(thisEpoch - 1)
 							os.Remove(filepath.Join(ethash.DefaultDir, previousDag))
@@ -455,9 +518,8 @@ func (self *Ethereum) StartAutoDAG() {
 						dag, _ := dagFiles(nextEpoch)
 						if _, err := os.Stat(dag); os.IsNotExist(err) {
 							glog.V(logger.Info).Infof("Pregenerating DAG for epoch %d (%s)", nextEpoch, dag)
-							err := ethash.MakeDAG(nextEpoch*epochLength, "") // "" -> ethash.DefaultDir
-							if err != nil {
-								glog.V(logger.Error).Infof("Error generating DAG for epoch %d (%s)", nextEpoch, dag)
+							if status := self.pregenerateDAG(nextEpoch); status.Error != "" {
+								glog.V(logger.Error).Infof("Error generating DAG for epoch %d (%s): %s", nextEpoch, dag, status.Error)
 								return
 							}
 						} else {
@@ -482,6 +544,53 @@ func (self *Ethereum) StopAutoDAG() {
 	glog.V(logger.Info).Infof("Automatic pregeneration of ethash DAG OFF (ethash dir: %s)", ethash.DefaultDir)
 }
 
+// SetAutoDAGLookahead sets how many blocks before an epoch change automatic DAG
+// pregeneration for the next epoch starts.
+func (self *Ethereum) SetAutoDAGLookahead(blocks uint64) error {
+	if blocks < autoDAGminLookahead || blocks > epochLength {
+		return fmt.Errorf("invalid DAG look-ahead %d, want between %d and %d blocks", blocks, autoDAGminLookahead, epochLength)
+	}
+	atomic.StoreUint64(&self.dagLookahead, blocks)
+	return nil
+}
+
+// AutoDAGLookahead returns how many blocks before an epoch change automatic DAG
+// pregeneration for the next epoch starts.
+func (self *Ethereum) AutoDAGLookahead() uint64 {
+	if blocks := atomic.LoadUint64(&self.dagLookahead); blocks != 0 {
+		return blocks
+	}
+	return autoDAGlookahead
+}
+
+// autoDAGDue returns whether the given block is close enough to the next epoch
+// to start pregenerating its DAG.
+func autoDAGDue(block uint64, lookahead uint64) bool {
+	return block%epochLength+lookahead > epochLength
+}
+
+// pregenerateDAG generates the DAG of the given epoch into the default ethash
+// directory, tracking its progress for AutoDAGStatus. It blocks until the
+// generation finishes, returning its final status.
+func (self *Ethereum) pregenerateDAG(epoch uint64) DAGStatus {
+	id := self.dags.start(epoch * epochLength)
+	atomic.StoreInt64(&self.dagJob, int64(id)+1)
+	defer atomic.StoreInt64(&self.dagJob, 0)
+
+	return self.dags.wait(id)
+}
+
+// AutoDAGStatus reports whether automatic DAG pregeneration is running and, if a
+// DAG is being generated at the moment, the progress of its generation.
+func (self *Ethereum) AutoDAGStatus() (running bool, generating *DAGStatus) {
+	if id := atomic.LoadInt64(&self.dagJob); id != 0 {
+		if status, ok := self.dags.status(int(id - 1)); ok && !status.Done {
+			generating = &status
+		}
+	}
+	return self.autodagquit != nil, generating
+}
+
 // HTTPClient returns the light http client used for fetching offchain docs
 // (natspec, source for verification)
 func (self *Ethereum) HTTPClient() *httpclient.HTTPClient {
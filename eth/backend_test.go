@@ -81,3 +81,41 @@ func TestMipmapUpgrade(t *testing.T) {
 		t.Error("setting-mipmap-version not written to database")
 	}
 }
+
+// Tests that the automatic DAG pregeneration for the next epoch is triggered at
+// the height determined by the configured look-ahead.
+func TestAutoDAGLookahead(t *testing.T) {
+	eth := new(Ethereum)
+	if have := eth.AutoDAGLookahead(); have != autoDAGlookahead {
+		t.Errorf("default look-ahead mismatch: have %d, want %d", have, autoDAGlookahead)
+	}
+	for _, blocks := range []uint64{0, autoDAGminLookahead - 1, epochLength + 1} {
+		if err := eth.SetAutoDAGLookahead(blocks); err == nil {
+			t.Errorf("invalid look-ahead %d accepted", blocks)
+		}
+	}
+	tests := []struct {
+		lookahead uint64
+		trigger   uint64
+	}{
+		{autoDAGlookahead, epochLength + epochLength/2 + 1},
+		{25000, epochLength + 5001},
+		{epochLength, epochLength + 1},
+	}
+	for _, tt := range tests {
+		if err := eth.SetAutoDAGLookahead(tt.lookahead); err != nil {
+			t.Fatalf("look-ahead %d: failed to set: %v", tt.lookahead, err)
+		}
+		// Find the first block of the second epoch triggering the generation
+		var trigger uint64
+		for block := uint64(epochLength); block < 2*epochLength; block++ {
+			if autoDAGDue(block, eth.AutoDAGLookahead()) {
+				trigger = block
+				break
+			}
+		}
+		if trigger != tt.trigger {
+			t.Errorf("look-ahead %d: trigger height mismatch: have %d, want %d", tt.lookahead, trigger, tt.trigger)
+		}
+	}
+}
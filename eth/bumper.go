@@ -0,0 +1,197 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// txBumper escalates the gas price of transactions sent by the locally managed
+// accounts that stay pending for too long. Once the lowest nonce transaction of
+// an account has been pending for the configured delay, it is re-signed at a gas
+// price bumped by the given percentage, capped at the configured maximum, and
+// replaced in the pool, which rebroadcasts it. Later transactions of the account
+// are only stuck behind it, so they are left alone. The sending account must be
+// unlocked for this to work; locked ones are skipped.
+type txBumper struct {
+	pool *core.TxPool
+	am   *accounts.Manager
+	mux  *event.TypeMux
+
+	delay    time.Duration             // Time a transaction may stay pending before being bumped, 0 if disabled
+	percent  int                       // Percentage the gas price is bumped by
+	maxPrice *big.Int                  // Gas price never exceeded by bumping
+	seen     map[common.Hash]time.Time // Time the pending transactions of managed accounts were first seen
+	quit     chan struct{}             // Quit channel of the bumping loop, nil if not running
+	lock     sync.Mutex
+}
+
+// newTxBumper creates a disabled transaction price bumper.
+func newTxBumper(pool *core.TxPool, am *accounts.Manager, mux *event.TypeMux) *txBumper {
+	return &txBumper{
+		pool: pool,
+		am:   am,
+		mux:  mux,
+		seen: make(map[common.Hash]time.Time),
+	}
+}
+
+// Config returns the delay after which pending transactions are bumped, zero if
+// bumping is disabled, the percentage their gas price is bumped by and the gas
+// price bumping never exceeds.
+func (b *txBumper) Config() (time.Duration, int, *big.Int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.delay, b.percent, b.maxPrice
+}
+
+// SetConfig sets the delay after which pending transactions are bumped, the
+// percentage their gas price is bumped by and the gas price bumping never exceeds,
+// starting or stopping the bumping as needed. A zero delay disables bumping.
+func (b *txBumper) SetConfig(delay time.Duration, percent int, maxPrice *big.Int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.delay, b.percent, b.maxPrice = delay, percent, maxPrice
+	if b.quit != nil {
+		close(b.quit)
+		b.quit = nil
+	}
+	if delay > 0 {
+		b.quit = make(chan struct{})
+		go b.loop(delay, b.quit)
+	}
+}
+
+// Stop terminates the bumping, if running.
+func (b *txBumper) Stop() {
+	b.SetConfig(0, 0, nil)
+}
+
+// loop periodically checks for stuck transactions, often enough for them to be
+// bumped shortly after the delay expires.
+func (b *txBumper) loop(delay time.Duration, quit chan struct{}) {
+	ticker := time.NewTicker(delay / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.bump(quit)
+		case <-quit:
+			return
+		}
+	}
+}
+
+// bump replaces the lowest nonce transactions of managed accounts that have been
+// pending for longer than the configured delay with ones paying a higher gas
+// price. Transactions already at the maximum price are left pending. The quit
+// channel of the calling loop is checked to avoid acting on a configuration it
+// was not started for.
+func (b *txBumper) bump(quit chan struct{}) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	select {
+	case <-quit:
+		return
+	default:
+	}
+	now := time.Now()
+	seen := make(map[common.Hash]time.Time)
+	for addr, txs := range b.pool.Pending() {
+		if !b.am.HasAddress(addr) {
+			continue
+		}
+		for _, tx := range txs {
+			if first, ok := b.seen[tx.Hash()]; ok {
+				seen[tx.Hash()] = first
+			} else {
+				seen[tx.Hash()] = now
+			}
+		}
+		// Pending transactions are nonce sorted, only the first one can be stuck
+		tx := txs[0]
+		if now.Sub(seen[tx.Hash()]) < b.delay || tx.GasPrice().Cmp(b.maxPrice) >= 0 {
+			continue
+		}
+		bumped, err := b.bumpTx(addr, tx)
+		if err != nil {
+			glog.V(logger.Debug).Infof("Failed to bump transaction %x: %v", tx.Hash(), err)
+			continue
+		}
+		glog.V(logger.Info).Infof("Bumped stuck transaction %x to %x at gas price %v", tx.Hash(), bumped.Hash(), bumped.GasPrice())
+		go b.mux.Post(core.TxBumpEvent{Old: tx, New: bumped})
+
+		delete(seen, tx.Hash())
+		seen[bumped.Hash()] = now
+	}
+	b.seen = seen
+}
+
+// bumpTx re-signs the given transaction of a managed account at a bumped gas
+// price, capped at the maximum, and replaces the original with it in the pool.
+func (b *txBumper) bumpTx(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	price := new(big.Int).Mul(tx.GasPrice(), big.NewInt(int64(100+b.percent)))
+	price.Div(price, big.NewInt(100))
+	if price.Cmp(tx.GasPrice()) <= 0 {
+		price.Add(tx.GasPrice(), common.Big1)
+	}
+	if price.Cmp(b.maxPrice) > 0 {
+		price.Set(b.maxPrice)
+	}
+	var bumped *types.Transaction
+	if to := tx.To(); to == nil {
+		bumped = types.NewContractCreation(tx.Nonce(), tx.Value(), tx.Gas(), price, tx.Data())
+	} else {
+		bumped = types.NewTransaction(tx.Nonce(), *to, tx.Value(), tx.Gas(), price, tx.Data())
+	}
+	// Sign the replacement the same way as the original, failing if locked
+	hash := bumped.SigHash()
+	if tx.Protected() {
+		hash = bumped.ProtectedSigHash(tx.ChainId())
+	}
+	signature, err := b.am.Sign(addr, hash.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if tx.Protected() {
+		bumped, err = bumped.WithProtectedSignature(signature, tx.ChainId())
+	} else {
+		bumped, err = bumped.WithSignature(signature)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.pool.SetLocal(bumped)
+	if err := b.pool.Add(bumped); err != nil {
+		return nil, err
+	}
+	return bumped, nil
+}
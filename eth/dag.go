@@ -0,0 +1,241 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// errDAGCancelled is returned by DAG generators if the generation was aborted.
+var errDAGCancelled = errors.New("DAG generation cancelled")
+
+// dagJobHistory is the number of finished DAG generations whose final status is
+// retained for retrieval.
+const dagJobHistory = 16
+
+// dagGenerator builds the DAG for the epoch of the given block into a directory,
+// reporting its progress in percent. If the abort channel is closed, generation
+// should stop as soon as possible, returning errDAGCancelled.
+type dagGenerator func(block uint64, dir string, progress func(percent int), abort <-chan struct{}) error
+
+// ethashDAGGenerator builds DAGs using the ethash library, which reports its
+// progress in single percent steps and checks for cancellation at each of them.
+// Generations are serialized by the library, so a generation might wait for
+// another one to finish before making any progress.
+func ethashDAGGenerator(block uint64, dir string, progress func(int), abort <-chan struct{}) error {
+	aborted := false
+	err := ethash.MakeDAGProgress(block, dir, func(percent int) bool {
+		select {
+		case <-abort:
+			aborted = true
+			return false
+		default:
+			progress(percent)
+			return true
+		}
+	})
+	if aborted {
+		return errDAGCancelled
+	}
+	if err == nil {
+		progress(100)
+	}
+	return err
+}
+
+// missingDAGFiles returns the paths of the DAG files of the epoch of the given
+// block not present in a directory.
+func missingDAGFiles(block uint64, dir string) []string {
+	if dir == "" {
+		dir = ethash.DefaultDir
+	}
+	dag, dagFull := dagFiles(block / epochLength)
+
+	var missing []string
+	for _, file := range []string{dag, dagFull} {
+		path := filepath.Join(dir, file)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			missing = append(missing, path)
+		}
+	}
+	return missing
+}
+
+// removeFiles deletes the given files, ignoring the ones not existing.
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+// DAGStatus is the progress report of an asynchronous DAG generation.
+type DAGStatus struct {
+	Block   uint64 `json:"block"`
+	Percent int    `json:"percent"`
+	Done    bool   `json:"done"`
+	Error   string `json:"error,omitempty"`
+}
+
+// dagJob is a single asynchronous DAG generation.
+type dagJob struct {
+	status DAGStatus
+	abort  chan struct{}
+	done   chan struct{} // closed once the final status is recorded
+}
+
+// dagJobs tracks the asynchronous DAG generations started through the API.
+type dagJobs struct {
+	generate dagGenerator
+	dir      string
+
+	jobs     map[int]*dagJob   // generations still running
+	epochs   map[uint64]int    // ids of the running generations by epoch
+	finished map[int]DAGStatus // final status of the most recently finished generations
+	history  []int             // ids of the finished generations, oldest first
+	nextId   int
+	lock     sync.Mutex
+}
+
+// newDAGJobs creates a DAG generation tracker building DAGs into the given
+// directory, or the default ethash directory if empty.
+func newDAGJobs(generate dagGenerator, dir string) *dagJobs {
+	return &dagJobs{
+		generate: generate,
+		dir:      dir,
+		jobs:     make(map[int]*dagJob),
+		epochs:   make(map[uint64]int),
+		finished: make(map[int]DAGStatus),
+	}
+}
+
+// start begins generating the DAG for the given block in the background,
+// returning the id the generation can be tracked with. If the DAG already
+// exists, the generation finishes right away. If the DAG of the same epoch is
+// already being generated, the id of that generation is returned instead, as
+// two generations would write the same files.
+func (d *dagJobs) start(block uint64) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	epoch := block / epochLength
+	if id, ok := d.epochs[epoch]; ok {
+		return id
+	}
+	id := d.nextId
+	d.nextId++
+
+	// Only the files created by this generation may be removed on cancellation
+	missing := missingDAGFiles(block, d.dir)
+	if len(missing) == 0 {
+		d.finish(id, DAGStatus{Block: block, Percent: 100, Done: true})
+		return id
+	}
+	job := &dagJob{status: DAGStatus{Block: block}, abort: make(chan struct{}), done: make(chan struct{})}
+	d.jobs[id] = job
+	d.epochs[epoch] = id
+
+	go func() {
+		err := d.generate(block, d.dir, func(percent int) {
+			d.lock.Lock()
+			job.status.Percent = percent
+			d.lock.Unlock()
+		}, job.abort)
+
+		if err == errDAGCancelled {
+			removeFiles(missing)
+		}
+		if err != nil {
+			glog.V(logger.Warn).Infof("DAG generation #%d for block %d failed: %v", id, block, err)
+		}
+		d.lock.Lock()
+		defer d.lock.Unlock()
+
+		status := job.status
+		status.Done = true
+		if err != nil {
+			status.Error = err.Error()
+		}
+		delete(d.jobs, id)
+		delete(d.epochs, epoch)
+		d.finish(id, status)
+		close(job.done)
+	}()
+	return id
+}
+
+// finish records the final status of a generation, forgetting the oldest ones
+// beyond the retained history. The lock must be held by the caller.
+func (d *dagJobs) finish(id int, status DAGStatus) {
+	d.finished[id] = status
+	d.history = append(d.history, id)
+	if len(d.history) > dagJobHistory {
+		delete(d.finished, d.history[0])
+		d.history = d.history[1:]
+	}
+}
+
+// status returns the progress of the given generation, or false if unknown.
+func (d *dagJobs) status(id int) (DAGStatus, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if job, ok := d.jobs[id]; ok {
+		return job.status, true
+	}
+	status, ok := d.finished[id]
+	return status, ok
+}
+
+// wait blocks until the given generation finishes, returning its final status.
+func (d *dagJobs) wait(id int) DAGStatus {
+	d.lock.Lock()
+	job, ok := d.jobs[id]
+	d.lock.Unlock()
+
+	if ok {
+		<-job.done
+	}
+	status, _ := d.status(id)
+	return status
+}
+
+// cancel aborts the given generation, returning whether it was still running.
+// Generations are shared by all requests for the same epoch, so it's aborted for
+// all of them.
+func (d *dagJobs) cancel(id int) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	job, ok := d.jobs[id]
+	if !ok {
+		return false
+	}
+	select {
+	case <-job.abort:
+		return false // already cancelled, waiting for the generator to stop
+	default:
+		close(job.abort)
+		return true
+	}
+}
@@ -25,10 +25,16 @@ import (
 	"golang.org/x/net/context"
 )
 
+// progressReader is the part of the downloader the API depends on, allowing the
+// sync status reporting to be tested without a live downloader.
+type progressReader interface {
+	Progress() ethereum.SyncProgress
+}
+
 // PublicDownloaderAPI provides an API which gives information about the current synchronisation status.
 // It offers only methods that operates on data that can be available to anyone without security risks.
 type PublicDownloaderAPI struct {
-	d                         *Downloader
+	d                         progressReader
 	mux                       *event.TypeMux
 	installSyncSubscription   chan chan interface{}
 	uninstallSyncSubscription chan *uninstallSyncSubscriptionRequest
@@ -39,6 +45,12 @@ type PublicDownloaderAPI struct {
 // these events it broadcasts it to all syncing subscriptions that are installed through the
 // installSyncSubscription channel.
 func NewPublicDownloaderAPI(d *Downloader, m *event.TypeMux) *PublicDownloaderAPI {
+	return newPublicDownloaderAPI(d, m)
+}
+
+// newPublicDownloaderAPI creates a new PublicDownloaderAPI reporting the progress
+// of an arbitrary progress source.
+func newPublicDownloaderAPI(d progressReader, m *event.TypeMux) *PublicDownloaderAPI {
 	api := &PublicDownloaderAPI{
 		d:   d,
 		mux: m,
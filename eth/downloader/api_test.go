@@ -0,0 +1,120 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// testProgress is a progress source whose reported progress can be set by tests.
+type testProgress struct {
+	progress ethereum.SyncProgress
+	lock     sync.Mutex
+}
+
+func (p *testProgress) Progress() ethereum.SyncProgress {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.progress
+}
+
+func (p *testProgress) set(progress ethereum.SyncProgress) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.progress = progress
+}
+
+// syncStep is a single downloader event to post, along with the progress to be
+// reported at the time and the notification expected in return.
+type syncStep struct {
+	progress ethereum.SyncProgress
+	event    interface{}
+	notify   interface{}
+}
+
+// Tests that the sync status subscription emits the expected notifications for
+// sequences of downloader events.
+func TestSyncStatusNotifications(t *testing.T) {
+	var (
+		begin  = ethereum.SyncProgress{StartingBlock: 0, CurrentBlock: 0, HighestBlock: 1024}
+		midway = ethereum.SyncProgress{StartingBlock: 0, CurrentBlock: 512, HighestBlock: 1024}
+		resume = ethereum.SyncProgress{StartingBlock: 512, CurrentBlock: 512, HighestBlock: 2048, PulledStates: 10, KnownStates: 20}
+	)
+	tests := []struct {
+		name  string
+		steps []syncStep
+	}{
+		{
+			name: "start-progress-done",
+			steps: []syncStep{
+				{begin, StartEvent{}, &SyncingResult{Syncing: true, Status: begin}},
+				{midway, StartEvent{}, &SyncingResult{Syncing: true, Status: midway}},
+				{midway, DoneEvent{}, false},
+			},
+		},
+		{
+			name: "start-failed",
+			steps: []syncStep{
+				{begin, StartEvent{}, &SyncingResult{Syncing: true, Status: begin}},
+				{begin, FailedEvent{errors.New("peer dropped")}, false},
+			},
+		},
+		{
+			name: "failed-restart-done",
+			steps: []syncStep{
+				{begin, StartEvent{}, &SyncingResult{Syncing: true, Status: begin}},
+				{midway, FailedEvent{errors.New("peer dropped")}, false},
+				{resume, StartEvent{}, &SyncingResult{Syncing: true, Status: resume}},
+				{resume, DoneEvent{}, false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		var (
+			mux      = new(event.TypeMux)
+			progress = new(testProgress)
+			api      = newPublicDownloaderAPI(progress, mux)
+			statuses = make(chan interface{})
+			sub      = api.SubscribeSyncStatus(statuses)
+		)
+		for i, step := range tt.steps {
+			progress.set(step.progress)
+			if err := mux.Post(step.event); err != nil {
+				t.Fatalf("%s: step %d: failed to post event: %v", tt.name, i, err)
+			}
+			select {
+			case status := <-statuses:
+				if !reflect.DeepEqual(status, step.notify) {
+					t.Errorf("%s: step %d: notification mismatch: have %#v, want %#v", tt.name, i, status, step.notify)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: step %d: notification timeout", tt.name, i)
+			}
+		}
+		sub.Unsubscribe()
+		mux.Stop()
+	}
+}
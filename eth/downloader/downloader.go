@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -243,6 +244,41 @@ func (d *Downloader) Synchronising() bool {
 	return atomic.LoadInt32(&d.synchronising) > 0
 }
 
+// PeerStats contains the measured round trip time of a download peer and the
+// number of items it is currently allowed to be asked for in a single request.
+type PeerStats struct {
+	Id  string
+	RTT time.Duration
+
+	HeaderBatch  int
+	BlockBatch   int
+	ReceiptBatch int
+	StateBatch   int
+}
+
+// PeerStats retrieves the statistics of all the download peers, sorted by id.
+// The batch sizes of the peers scale with their throughput measured over past
+// requests: slow peers are asked for fewer items at once, fast ones for more,
+// bounded by the maximum number of items fetched in a single request.
+func (d *Downloader) PeerStats() []PeerStats {
+	targetRTT := d.requestRTT()
+
+	peers := d.peers.AllPeers()
+	stats := make([]PeerStats, len(peers))
+	for i, p := range peers {
+		stats[i] = p.Stats(targetRTT)
+	}
+	sort.Sort(peerStatsById(stats))
+	return stats
+}
+
+// peerStatsById implements sort.Interface to order peer statistics by peer id.
+type peerStatsById []PeerStats
+
+func (s peerStatsById) Len() int           { return len(s) }
+func (s peerStatsById) Less(i, j int) bool { return s[i].Id < s[j].Id }
+func (s peerStatsById) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
 // RegisterPeer injects a new download peer into the set of block source to be
 // used for fetching hashes and blocks from.
 func (d *Downloader) RegisterPeer(id string, version int, currentHead currentHeadRetrievalFn,
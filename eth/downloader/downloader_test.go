@@ -1744,3 +1744,52 @@ func testFastCriticalRestarts(t *testing.T, protocol int) {
 	}
 	assertOwnChain(t, tester, targetBlocks+1)
 }
+
+// Tests that the batch sizes reported for download peers adapt to their measured
+// responsiveness, diverging between a fast and a slow peer within the bounds.
+func TestPeerStatsBatchTuning(t *testing.T) {
+	tester := newTester()
+	defer tester.terminate()
+
+	hashes, headers, blocks, receipts := makeChain(0, 0, genesis, nil, false)
+	tester.newPeer("fast", 63, hashes, headers, blocks, receipts)
+	tester.newPeer("slow", 63, hashes, headers, blocks, receipts)
+
+	// Target a round trip time low enough for the slow peer not to keep up
+	atomic.StoreUint64(&tester.downloader.rttEstimate, uint64(time.Second))
+	latencies := map[string]time.Duration{"fast": 10 * time.Millisecond, "slow": 5 * time.Second}
+
+	stats := tester.downloader.PeerStats()
+	if len(stats) != 2 || stats[0].Id != "fast" || stats[1].Id != "slow" {
+		t.Fatalf("peer stats mismatch: have %+v, want fast and slow", stats)
+	}
+	if stats[0].HeaderBatch != stats[1].HeaderBatch || stats[0].BlockBatch != stats[1].BlockBatch {
+		t.Fatalf("initial batch sizes differ: fast %+v, slow %+v", stats[0], stats[1])
+	}
+	// Simulate a series of requests, each peer delivering everything it was asked
+	for i := 0; i < 100; i++ {
+		for id, latency := range latencies {
+			p := tester.downloader.peers.Peer(id)
+			rtt := tester.downloader.requestRTT()
+
+			p.headerStarted = time.Now().Add(-latency)
+			p.SetHeadersIdle(p.HeaderCapacity(rtt))
+			p.blockStarted = time.Now().Add(-latency)
+			p.SetBodiesIdle(p.BlockCapacity(rtt))
+		}
+	}
+	stats = tester.downloader.PeerStats()
+	fast, slow := stats[0], stats[1]
+	if fast.HeaderBatch != MaxHeaderFetch || fast.BlockBatch != MaxBlockFetch {
+		t.Errorf("fast peer batch sizes mismatch: have %d headers, %d blocks, want %d, %d", fast.HeaderBatch, fast.BlockBatch, MaxHeaderFetch, MaxBlockFetch)
+	}
+	if slow.HeaderBatch >= fast.HeaderBatch/10 || slow.BlockBatch >= fast.BlockBatch/10 {
+		t.Errorf("slow peer batch sizes not reduced: have %d headers, %d blocks", slow.HeaderBatch, slow.BlockBatch)
+	}
+	if slow.HeaderBatch < 1 || slow.BlockBatch < 1 {
+		t.Errorf("slow peer batch sizes below bounds: have %d headers, %d blocks", slow.HeaderBatch, slow.BlockBatch)
+	}
+	if fast.RTT >= slow.RTT {
+		t.Errorf("round trip times not diverged: fast %v, slow %v", fast.RTT, slow.RTT)
+	}
+}
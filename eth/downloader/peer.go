@@ -306,6 +306,23 @@ func (p *peer) NodeDataCapacity(targetRTT time.Duration) int {
 	return int(math.Min(1+math.Max(1, p.stateThroughput*float64(targetRTT)/float64(time.Second)), float64(MaxStateFetch)))
 }
 
+// Stats retrieves the measured round trip time of the peer along with its
+// download allowances for the given target round trip time.
+func (p *peer) Stats(targetRTT time.Duration) PeerStats {
+	stats := PeerStats{
+		Id:           p.id,
+		HeaderBatch:  p.HeaderCapacity(targetRTT),
+		BlockBatch:   p.BlockCapacity(targetRTT),
+		ReceiptBatch: p.ReceiptCapacity(targetRTT),
+		StateBatch:   p.NodeDataCapacity(targetRTT),
+	}
+	p.lock.RLock()
+	stats.RTT = p.rtt
+	p.lock.RUnlock()
+
+	return stats
+}
+
 // MarkLacking appends a new entity to the set of items (blocks, receipts, states)
 // that a peer is known not to have (i.e. have been requested before). If the
 // set reaches its maximum allowed capacity, items are randomly dropped off.
@@ -228,6 +228,77 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 	return rpcSub, nil
 }
 
+// NewHeadsThrottled sends a notification for blocks appended to the chain, but at
+// most one every minInterval milliseconds. Heads arriving in quick succession are
+// coalesced and the latest one is delivered once the interval passes, so clients
+// always end up at the current chain head.
+func (api *PublicFilterAPI) NewHeadsThrottled(ctx context.Context, minInterval int) (*rpc.Subscription, error) {
+	if minInterval < 0 {
+		return &rpc.Subscription{}, fmt.Errorf("invalid interval %d", minInterval)
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		headers := make(chan *types.Header)
+		headersSub := api.events.SubscribeNewHeads(headers)
+
+		quit := make(chan struct{})
+		go func() {
+			select {
+			case <-rpcSub.Err(): // client send an unsubscribe request
+			case <-notifier.Closed(): // connection dropped
+			}
+			close(quit)
+		}()
+		throttleHeads(headers, quit, time.Duration(minInterval)*time.Millisecond, func(h *types.Header) {
+			notifier.Notify(rpcSub.ID, h)
+		})
+		headersSub.Unsubscribe()
+	}()
+
+	return rpcSub, nil
+}
+
+// throttleHeads forwards the headers arriving on the given channel to deliver,
+// calling it at most once per interval. Headers arriving before the interval
+// passes are coalesced, with only the latest one delivered when it does. The
+// method returns when quit is closed.
+func throttleHeads(headers <-chan *types.Header, quit <-chan struct{}, interval time.Duration, deliver func(*types.Header)) {
+	var (
+		last    time.Time     // Time of the last delivery
+		pending *types.Header // Latest header waiting for the interval to pass
+		timer   = time.NewTimer(0)
+	)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		select {
+		case h := <-headers:
+			if pending == nil {
+				if wait := interval - time.Since(last); wait > 0 {
+					timer.Reset(wait)
+				} else {
+					deliver(h)
+					last = time.Now()
+					continue
+				}
+			}
+			pending = h
+		case <-timer.C:
+			deliver(pending)
+			last, pending = time.Now(), nil
+		case <-quit:
+			return
+		}
+	}
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
 func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
@@ -312,9 +383,10 @@ func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) rpc.ID {
 }
 
 // GetLogs returns logs matching the given argument that are stored within the state.
+// The search is aborted once the deadline of the request expires.
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getlogs
-func (api *PublicFilterAPI) GetLogs(crit FilterCriteria) []Log {
+func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]Log, error) {
 	if crit.FromBlock == nil {
 		crit.FromBlock = big.NewInt(rpc.LatestBlockNumber.Int64())
 	}
@@ -328,7 +400,11 @@ func (api *PublicFilterAPI) GetLogs(crit FilterCriteria) []Log {
 	filter.SetAddresses(crit.Addresses)
 	filter.SetTopics(crit.Topics)
 
-	return returnLogs(filter.Find())
+	logs, err := filter.FindContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return returnLogs(logs), nil
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -352,13 +428,13 @@ func (api *PublicFilterAPI) UninstallFilter(id rpc.ID) bool {
 // If the filter could not be found an empty array of logs is returned.
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getfilterlogs
-func (api *PublicFilterAPI) GetFilterLogs(id rpc.ID) []Log {
+func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]Log, error) {
 	api.filtersMu.Lock()
 	f, found := api.filters[id]
 	api.filtersMu.Unlock()
 
 	if !found || f.typ != LogsSubscription {
-		return []Log{}
+		return []Log{}, nil
 	}
 
 	filter := New(api.chainDb)
@@ -367,7 +443,11 @@ func (api *PublicFilterAPI) GetFilterLogs(id rpc.ID) []Log {
 	filter.SetAddresses(f.crit.Addresses)
 	filter.SetTopics(f.crit.Topics)
 
-	return returnLogs(filter.Find())
+	logs, err := filter.FindContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return returnLogs(logs), nil
 }
 
 // GetFilterChanges returns the logs for the filter with the given id since
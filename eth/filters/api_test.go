@@ -19,9 +19,12 @@ package filters
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -195,3 +198,52 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 		)
 	}
 }
+
+// Tests that rapidly arriving heads are throttled to at most one delivery per
+// interval, and that the latest head is always delivered once things quiet down.
+func TestThrottleHeads(t *testing.T) {
+	var (
+		interval  = 50 * time.Millisecond
+		headers   = make(chan *types.Header)
+		quit      = make(chan struct{})
+		delivered = make(chan *types.Header, 100)
+		times     = make(chan time.Time, 100)
+	)
+	defer close(quit)
+
+	go throttleHeads(headers, quit, interval, func(h *types.Header) {
+		times <- time.Now()
+		delivered <- h
+	})
+	// Feed a burst of heads much faster than the throttling interval
+	const count = 50
+	for i := 1; i <= count; i++ {
+		headers <- &types.Header{Number: big.NewInt(int64(i))}
+		time.Sleep(2 * time.Millisecond)
+	}
+	// Wait for the final head and verify the delivery rate
+	var (
+		last     time.Time
+		received int
+	)
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case h := <-delivered:
+			at := <-times
+			received++
+			if !last.IsZero() && at.Sub(last) < interval {
+				t.Errorf("delivery %d too early: %v since previous, want at least %v", received, at.Sub(last), interval)
+			}
+			last = at
+			if h.Number.Int64() == count {
+				if received >= count {
+					t.Errorf("heads not coalesced: %d deliveries for %d heads", received, count)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("final head not delivered, %d deliveries received", received)
+		}
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"golang.org/x/net/context"
 )
 
 // Filter can be used to retrieve and filter logs
@@ -34,6 +35,8 @@ type Filter struct {
 	begin, end int64
 	addresses  []common.Address
 	topics     [][]common.Hash
+
+	ctx context.Context // Context aborting the chain walk once done, may be nil
 }
 
 // New creates a new filter which uses a bloom filter on blocks to figure out whether
@@ -92,11 +95,30 @@ func (f *Filter) Find() []Log {
 	return f.mipFind(beginBlockNo, endBlockNo, 0)
 }
 
+// FindContext filters logs with the current parameters set like Find, but stops
+// walking the chain once the given context is done, returning its error instead
+// of the partial result.
+func (f *Filter) FindContext(ctx context.Context) ([]Log, error) {
+	f.ctx = ctx
+	defer func() { f.ctx = nil }()
+
+	logs := f.Find()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// aborted reports whether the chain walk was aborted by the context of the filter.
+func (f *Filter) aborted() bool {
+	return f.ctx != nil && f.ctx.Err() != nil
+}
+
 func (f *Filter) mipFind(start, end uint64, depth int) (logs []Log) {
 	level := core.MIPMapLevels[depth]
 	// normalise numerator so we can work in level specific batches and
 	// work with the proper range checks
-	for num := start / level * level; num <= end; num += level {
+	for num := start / level * level; num <= end && !f.aborted(); num += level {
 		// find addresses in bloom filters
 		bloom := core.GetMipmapBloom(f.db, num, level)
 		for _, addr := range f.addresses {
@@ -125,7 +147,7 @@ func (f *Filter) mipFind(start, end uint64, depth int) (logs []Log) {
 func (f *Filter) getLogs(start, end uint64) (logs []Log) {
 	var block *types.Block
 
-	for i := start; i <= end; i++ {
+	for i := start; i <= end && !f.aborted(); i++ {
 		hash := core.GetCanonicalHash(f.db, i)
 		if hash != (common.Hash{}) {
 			block = core.GetBlock(f.db, hash, i)
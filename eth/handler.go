@@ -79,6 +79,8 @@ type ProtocolManager struct {
 	txSub         event.Subscription
 	minedBlockSub event.Subscription
 
+	txBatchWindow int64 // Time to gather accepted transactions for before announcing them (atomic)
+
 	// channels for fetcher, syncer, txsyncLoop
 	newPeerCh   chan *peer
 	txsyncCh    chan *txsync
@@ -740,6 +742,34 @@ func (pm *ProtocolManager) BroadcastTx(hash common.Hash, tx *types.Transaction)
 	glog.V(logger.Detail).Infoln("broadcast tx to", len(peers), "peers")
 }
 
+// BroadcastTxs will propagate a batch of transactions to all peers, sending each
+// peer the transactions it is not known to have in a single message.
+func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
+	batches := make(map[*peer]types.Transactions)
+	for _, tx := range txs {
+		for _, peer := range pm.peers.PeersWithoutTx(tx.Hash()) {
+			batches[peer] = append(batches[peer], tx)
+		}
+	}
+	for peer, batch := range batches {
+		peer.SendTransactions(batch)
+	}
+	glog.V(logger.Detail).Infoln("broadcast", len(txs), "txs to", len(batches), "peers")
+}
+
+// SetTxBroadcastWindow sets the time transactions accepted into the pool are
+// gathered for before being announced to peers together. Zero announces every
+// transaction as soon as it is accepted.
+func (pm *ProtocolManager) SetTxBroadcastWindow(window time.Duration) {
+	atomic.StoreInt64(&pm.txBatchWindow, int64(window))
+}
+
+// TxBroadcastWindow returns the time accepted transactions are gathered for
+// before being announced to peers, or zero if they are announced immediately.
+func (pm *ProtocolManager) TxBroadcastWindow() time.Duration {
+	return time.Duration(atomic.LoadInt64(&pm.txBatchWindow))
+}
+
 // Mined broadcast loop
 func (self *ProtocolManager) minedBroadcastLoop() {
 	// automatically stops if unsubscribe
@@ -752,11 +782,41 @@ func (self *ProtocolManager) minedBroadcastLoop() {
 	}
 }
 
+// txBroadcastLoop announces the transactions accepted into the pool to peers. If
+// a broadcast window is set, transactions accepted within it are batched into a
+// single announcement.
 func (self *ProtocolManager) txBroadcastLoop() {
-	// automatically stops if unsubscribe
-	for obj := range self.txSub.Chan() {
-		event := obj.Data.(core.TxPreEvent)
-		self.BroadcastTx(event.Tx.Hash(), event.Tx)
+	var (
+		batch types.Transactions
+		flush <-chan time.Time
+	)
+	for {
+		select {
+		case obj, ok := <-self.txSub.Chan():
+			// automatically stops if unsubscribe
+			if !ok {
+				return
+			}
+			event := obj.Data.(core.TxPreEvent)
+
+			window := self.TxBroadcastWindow()
+			if window == 0 && batch == nil {
+				self.BroadcastTx(event.Tx.Hash(), event.Tx)
+				continue
+			}
+			batch = append(batch, event.Tx)
+			if window == 0 {
+				// Batching was disabled meanwhile, flush without reordering
+				self.BroadcastTxs(batch)
+				batch, flush = nil, nil
+			} else if flush == nil {
+				flush = time.After(window)
+			}
+
+		case <-flush:
+			self.BroadcastTxs(batch)
+			batch, flush = nil, nil
+		}
 	}
 }
 
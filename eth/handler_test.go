@@ -31,8 +31,10 @@ import (
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
+	gometrics "github.com/rcrowley/go-metrics"
 )
 
 // Tests that protocol versions and modes of operations are matched up properly.
@@ -124,6 +126,24 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 				pm.blockchain.GetBlockByNumber(limit/2 - 8).Hash(),
 			},
 		},
+		// Skip lists should be retrievable by hash origin too, in both directions
+		{
+			&getBlockHeadersData{Origin: hashOrNumber{Hash: pm.blockchain.GetBlockByNumber(limit / 2).Hash()}, Skip: 2, Amount: 4, Reverse: true},
+			[]common.Hash{
+				pm.blockchain.GetBlockByNumber(limit / 2).Hash(),
+				pm.blockchain.GetBlockByNumber(limit/2 - 3).Hash(),
+				pm.blockchain.GetBlockByNumber(limit/2 - 6).Hash(),
+				pm.blockchain.GetBlockByNumber(limit/2 - 9).Hash(),
+			},
+		}, {
+			&getBlockHeadersData{Origin: hashOrNumber{Hash: pm.blockchain.GetBlockByNumber(limit / 2).Hash()}, Skip: 2, Amount: 4},
+			[]common.Hash{
+				pm.blockchain.GetBlockByNumber(limit / 2).Hash(),
+				pm.blockchain.GetBlockByNumber(limit/2 + 3).Hash(),
+				pm.blockchain.GetBlockByNumber(limit/2 + 6).Hash(),
+				pm.blockchain.GetBlockByNumber(limit/2 + 9).Hash(),
+			},
+		},
 		// The chain endpoints should be retrievable
 		{
 			&getBlockHeadersData{Origin: hashOrNumber{Number: 0}, Amount: 1},
@@ -515,3 +535,66 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 		}
 	}
 }
+
+// Tests that the packets and traffic of every message code are metered in both
+// directions when metrics are enabled.
+func TestMessageMetering(t *testing.T) {
+	defer func(enabled bool) { metrics.Enabled = enabled }(metrics.Enabled)
+	metrics.Enabled = true
+
+	generator := func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(testBank.Address), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}
+	pm := newTestProtocolManagerMust(t, false, 4, generator, nil)
+	peer, _ := newTestPeer("peer", eth63, pm, true)
+	defer peer.close()
+
+	// Snapshot the meters, previous tests may have advanced them already
+	count := func(name string) int64 {
+		if meter, ok := gometrics.DefaultRegistry.Get(name).(gometrics.Meter); ok {
+			return meter.Count()
+		}
+		return 0
+	}
+	names := []string{
+		"eth/msg/getblockheaders/in/packets", "eth/msg/blockheaders/out/packets", "eth/msg/blockheaders/out/traffic",
+		"eth/msg/getreceipts/in/packets", "eth/msg/getreceipts/in/traffic", "eth/msg/receipts/out/packets",
+		"eth/msg/getblockbodies/in/packets",
+	}
+	before := make(map[string]int64)
+	for _, name := range names {
+		before[name] = count(name)
+	}
+	// Request some headers and receipts a few times
+	head := pm.blockchain.CurrentBlock()
+	for i := 0; i < 3; i++ {
+		p2p.Send(peer.app, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Number: 1}, Amount: 1})
+		if err := p2p.ExpectMsg(peer.app, BlockHeadersMsg, []*types.Header{pm.blockchain.GetHeaderByNumber(1)}); err != nil {
+			t.Fatalf("headers mismatch: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		p2p.Send(peer.app, GetReceiptsMsg, []common.Hash{head.Hash()})
+		if err := p2p.ExpectMsg(peer.app, ReceiptsMsg, []types.Receipts{core.GetBlockReceipts(pm.chaindb, head.Hash(), head.NumberU64())}); err != nil {
+			t.Fatalf("receipts mismatch: %v", err)
+		}
+	}
+	// Ensure only the meters of the exchanged message codes advanced
+	for name, want := range map[string]int64{
+		"eth/msg/getblockheaders/in/packets": 3,
+		"eth/msg/blockheaders/out/packets":   3,
+		"eth/msg/getreceipts/in/packets":     2,
+		"eth/msg/receipts/out/packets":       2,
+		"eth/msg/getblockbodies/in/packets":  0,
+	} {
+		if have := count(name) - before[name]; have != want {
+			t.Errorf("meter %s mismatch: have %d, want %d", name, have, want)
+		}
+	}
+	for _, name := range []string{"eth/msg/blockheaders/out/traffic", "eth/msg/getreceipts/in/traffic"} {
+		if count(name) <= before[name] {
+			t.Errorf("meter %s did not advance", name)
+		}
+	}
+}
@@ -22,17 +22,22 @@ package eth
 import (
 	"crypto/ecdsa"
 	"crypto/rand"
+	"io/ioutil"
 	"math/big"
+	"os"
 	"sort"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 )
@@ -82,6 +87,60 @@ func newTestProtocolManagerMust(t *testing.T, fastSync bool, blocks int, generat
 	return pm
 }
 
+// newTestChainEthereum creates a bare Ethereum service exposing only the chain
+// and database of a test protocol manager, enough for the APIs operating on them.
+func newTestChainEthereum(pm *ProtocolManager) *Ethereum {
+	return &Ethereum{blockchain: pm.blockchain, chainDb: pm.chaindb}
+}
+
+// newTestMinerEthereum creates an Ethereum service on top of a test protocol
+// manager with the given chain, wired to a live transaction pool, a miner and an
+// account manager backed by a temporary key directory. The returned function
+// tears all of them down.
+func newTestMinerEthereum(t *testing.T, blocks int, generator func(int, *core.BlockGen)) (*Ethereum, func()) {
+	pm := newTestProtocolManagerMust(t, false, blocks, generator, nil)
+
+	keydir, err := ioutil.TempDir("", "eth-miner-test")
+	if err != nil {
+		pm.Stop()
+		t.Fatalf("failed to create key directory: %v", err)
+	}
+	mux := new(event.TypeMux)
+	pool := core.NewTxPool(pm.blockchain.Config(), mux, pm.blockchain.State, pm.blockchain.GasLimit)
+	pool.Pending() // Initializes the pending state of the pool
+
+	eth := &Ethereum{
+		blockchain:     pm.blockchain,
+		chainDb:        pm.chaindb,
+		txPool:         pool,
+		eventMux:       mux,
+		accountManager: accounts.NewManager(keydir, accounts.LightScryptN, accounts.LightScryptP),
+	}
+	eth.miner = miner.New(eth, pm.blockchain.Config(), mux, core.FakePow{})
+
+	teardown := func() {
+		pool.Stop()
+		pm.Stop()
+		os.RemoveAll(keydir)
+	}
+	return eth, teardown
+}
+
+// waitPendingTxs waits until the pending block of the miner contains the given
+// number of transactions, failing the test if it doesn't happen in time.
+func waitPendingTxs(t *testing.T, eth *Ethereum, count int) {
+	for i := 0; ; i++ {
+		block, _ := eth.miner.Pending()
+		if len(block.Transactions()) == count {
+			return
+		}
+		if i == 100 {
+			t.Fatalf("pending transaction count mismatch: have %d, want %d", len(block.Transactions()), count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 // testTxPool is a fake, helper transaction pool for testing purposes
 type testTxPool struct {
 	pool  []*types.Transaction        // Collection of all transactions
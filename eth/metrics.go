@@ -17,8 +17,11 @@
 package eth
 
 import (
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
+	gometrics "github.com/rcrowley/go-metrics"
 )
 
 var (
@@ -56,11 +59,51 @@ var (
 	miscOutTrafficMeter       = metrics.NewMeter("eth/misc/out/traffic")
 )
 
+// msgNames are the names of the eth protocol messages, labelling their individual
+// meters. The message codes of all protocol versions are distinct.
+var msgNames = map[uint64]string{
+	StatusMsg:          "status",
+	NewBlockHashesMsg:  "newblockhashes",
+	TxMsg:              "txs",
+	GetBlockHeadersMsg: "getblockheaders",
+	BlockHeadersMsg:    "blockheaders",
+	GetBlockBodiesMsg:  "getblockbodies",
+	BlockBodiesMsg:     "blockbodies",
+	NewBlockMsg:        "newblock",
+	GetNodeDataMsg:     "getnodedata",
+	NodeDataMsg:        "nodedata",
+	GetReceiptsMsg:     "getreceipts",
+	ReceiptsMsg:        "receipts",
+}
+
+// msgMeters are the packet and traffic meters of a single message code.
+type msgMeters struct {
+	packets gometrics.Meter
+	traffic gometrics.Meter
+}
+
+// newMsgMeters creates the meters of every eth protocol message code for the
+// given direction ("in" or "out"), named eth/msg/<message>/<direction>/...
+func newMsgMeters(direction string) map[uint64]msgMeters {
+	meters := make(map[uint64]msgMeters, len(msgNames))
+	for code, name := range msgNames {
+		meters[code] = msgMeters{
+			packets: metrics.NewMeter(fmt.Sprintf("eth/msg/%s/%s/packets", name, direction)),
+			traffic: metrics.NewMeter(fmt.Sprintf("eth/msg/%s/%s/traffic", name, direction)),
+		}
+	}
+	return meters
+}
+
 // meteredMsgReadWriter is a wrapper around a p2p.MsgReadWriter, capable of
-// accumulating the above defined metrics based on the data stream contents.
+// accumulating the above defined metrics based on the data stream contents,
+// as well as the packets and traffic of each individual message code.
 type meteredMsgReadWriter struct {
 	p2p.MsgReadWriter     // Wrapped message stream to meter
 	version           int // Protocol version to select correct meters
+
+	inMeters  map[uint64]msgMeters // Meters of the received messages by code
+	outMeters map[uint64]msgMeters // Meters of the sent messages by code
 }
 
 // newMeteredMsgWriter wraps a p2p MsgReadWriter with metering support. If the
@@ -69,7 +112,11 @@ func newMeteredMsgWriter(rw p2p.MsgReadWriter) p2p.MsgReadWriter {
 	if !metrics.Enabled {
 		return rw
 	}
-	return &meteredMsgReadWriter{MsgReadWriter: rw}
+	return &meteredMsgReadWriter{
+		MsgReadWriter: rw,
+		inMeters:      newMsgMeters("in"),
+		outMeters:     newMsgMeters("out"),
+	}
 }
 
 // Init sets the protocol version used by the stream to know which meters to
@@ -107,6 +154,10 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
 
+	if meters, ok := rw.inMeters[msg.Code]; ok {
+		meters.packets.Mark(1)
+		meters.traffic.Mark(int64(msg.Size))
+	}
 	return msg, err
 }
 
@@ -134,6 +185,10 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
 
+	if meters, ok := rw.outMeters[msg.Code]; ok {
+		meters.packets.Mark(1)
+		meters.traffic.Mark(int64(msg.Size))
+	}
 	// Send the packet to the p2p layer
 	return rw.MsgReadWriter.WriteMsg(msg)
 }
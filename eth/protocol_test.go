@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -174,6 +175,46 @@ func testSendTransactions(t *testing.T, protocol int) {
 	wg.Wait()
 }
 
+// Tests that transactions accepted within the broadcast window are announced to
+// peers in a single batch.
+func TestBatchedTransactionBroadcast62(t *testing.T) { testBatchedTransactionBroadcast(t, 62) }
+func TestBatchedTransactionBroadcast63(t *testing.T) { testBatchedTransactionBroadcast(t, 63) }
+
+func testBatchedTransactionBroadcast(t *testing.T, protocol int) {
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil)
+	pm.SetTxBroadcastWindow(100 * time.Millisecond)
+	p, _ := newTestPeer("peer", protocol, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	// Accept a number of transactions in rapid succession
+	txs := make([]*types.Transaction, 3)
+	for nonce := range txs {
+		txs[nonce] = newTestTransaction(testAccount, uint64(nonce), 0)
+		pm.eventMux.Post(core.TxPreEvent{Tx: txs[nonce]})
+	}
+	// Ensure they are all announced in a single message
+	msg, err := p.app.ReadMsg()
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if msg.Code != TxMsg {
+		t.Fatalf("message code mismatch: have %d, want %d", msg.Code, TxMsg)
+	}
+	var announced []*types.Transaction
+	if err := msg.Decode(&announced); err != nil {
+		t.Fatalf("failed to decode transactions: %v", err)
+	}
+	if len(announced) != len(txs) {
+		t.Fatalf("batch size mismatch: have %d, want %d", len(announced), len(txs))
+	}
+	for i, tx := range announced {
+		if tx.Hash() != txs[i].Hash() {
+			t.Errorf("tx %d: hash mismatch: have %x, want %x", i, tx.Hash(), txs[i].Hash())
+		}
+	}
+}
+
 // Tests that the custom union field encoder and decoder works correctly.
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
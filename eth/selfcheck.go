@@ -0,0 +1,180 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// selfCheckBlocks is the number of most recent canonical blocks whose indexes
+// and receipts are verified by the self-check.
+const selfCheckBlocks = 128
+
+// selfCheck verifies that the transaction lookup entries and receipts of the
+// most recent canonical blocks are present and consistent with the blocks: the
+// receipt count must match the transaction count, every receipt bloom must match
+// its logs and the block bloom the receipts. Anomalies are collected rather than
+// aborting the check. The number of blocks checked is returned along with them.
+func selfCheck(chain *core.BlockChain, db ethdb.Database, blocks uint64) (uint64, []string) {
+	var (
+		anomalies []string
+		checked   uint64
+	)
+	report := func(format string, args ...interface{}) {
+		anomalies = append(anomalies, fmt.Sprintf(format, args...))
+	}
+	head := chain.CurrentBlock().NumberU64()
+	for ; checked < blocks && checked <= head; checked++ {
+		number := head - checked
+
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			report("block #%d: missing", number)
+			continue
+		}
+		hash, txs := block.Hash(), block.Transactions()
+
+		// Verify the transaction lookup entries and individual receipts
+		for i, tx := range txs {
+			if _, blockHash, blockNumber, index := core.GetTransaction(db, tx.Hash()); blockHash != hash || blockNumber != number || index != uint64(i) {
+				if blockHash == (common.Hash{}) {
+					report("block #%d: tx %x: lookup entry missing", number, tx.Hash())
+				} else {
+					report("block #%d: tx %x: lookup entry points to block #%d [%x…] index %d, want index %d", number, tx.Hash(), blockNumber, blockHash[:4], index, i)
+				}
+			}
+			if receipt := core.GetReceipt(db, tx.Hash()); receipt == nil {
+				report("block #%d: tx %x: receipt missing", number, tx.Hash())
+			} else if receipt.TxHash != tx.Hash() {
+				report("block #%d: tx %x: receipt belongs to tx %x", number, tx.Hash(), receipt.TxHash)
+			}
+		}
+		// Verify the receipts stored for the block as a whole
+		receipts := core.GetBlockReceipts(db, hash, number)
+		if len(receipts) != len(txs) {
+			report("block #%d: %d receipts for %d transactions", number, len(receipts), len(txs))
+			continue
+		}
+		for i, receipt := range receipts {
+			if bloom := types.CreateBloom(types.Receipts{receipt}); bloom != receipt.Bloom {
+				report("block #%d: receipt %d: bloom does not match logs", number, i)
+			}
+		}
+		if bloom := types.CreateBloom(receipts); bloom != block.Bloom() {
+			report("block #%d: header bloom does not match receipts", number)
+		}
+	}
+	return checked, anomalies
+}
+
+// repairCleanStretch is the number of consecutive consistent canonical blocks
+// after which the canonical chain repair stops walking further back.
+const repairCleanStretch = 1024
+
+// RepairReport lists the inconsistencies found and fixed by a canonical chain
+// repair, identified by block number.
+type RepairReport struct {
+	Head      uint64   `json:"head"`      // Number of the stored head block the repair started from
+	Checked   uint64   `json:"checked"`   // Number of canonical blocks checked
+	Canonical []uint64 `json:"canonical"` // Blocks whose canonical number to hash mapping was rewritten
+	Stale     []uint64 `json:"stale"`     // Numbers beyond the head header whose stale canonical mapping was deleted
+	Reindexed []uint64 `json:"reindexed"` // Blocks whose transaction lookup entries were rewritten
+}
+
+// repairCanonical walks the chain back from the stored head block following the
+// parent hashes, rewriting every canonical number to hash mapping and transaction
+// lookup entry disagreeing with it. Canonical mappings left beyond the head
+// header are deleted; the ones between the head block and header belong to the
+// header chain (e.g. during a fast sync) and are kept. The walk stops at the
+// genesis block or once a long enough stretch of consistent blocks was seen, as
+// unclean shutdowns only affect recent blocks.
+//
+// The repair runs under the chain locks so it can't race with imports and reorgs.
+func repairCanonical(chain *core.BlockChain, db ethdb.Database) (report *RepairReport, err error) {
+	chain.RunLocked(func() {
+		report, err = repairCanonicalLocked(db)
+	})
+	return report, err
+}
+
+// repairCanonicalLocked is the lock free implementation of repairCanonical.
+func repairCanonicalLocked(db ethdb.Database) (*RepairReport, error) {
+	hash := core.GetHeadBlockHash(db)
+	if hash == (common.Hash{}) {
+		return nil, errors.New("no head block stored")
+	}
+	number := core.GetBlockNumber(db, hash)
+	block := core.GetBlock(db, hash, number)
+	if block == nil {
+		return nil, fmt.Errorf("head block %x missing", hash)
+	}
+	report := &RepairReport{Head: number, Canonical: []uint64{}, Stale: []uint64{}, Reindexed: []uint64{}}
+
+	// Drop the canonical mappings left beyond the head header, e.g. by an interrupted rewind
+	cutoff := number
+	if head := core.GetHeadHeaderHash(db); head != (common.Hash{}) {
+		if header := core.GetHeader(db, head, core.GetBlockNumber(db, head)); header != nil && header.Number.Uint64() > cutoff {
+			cutoff = header.Number.Uint64()
+		}
+	}
+	for n := cutoff + 1; core.GetCanonicalHash(db, n) != (common.Hash{}); n++ {
+		core.DeleteCanonicalHash(db, n)
+		report.Stale = append(report.Stale, n)
+	}
+	// Walk back from the head, fixing the canonical mappings and transaction indexes
+	for clean := 0; clean < repairCleanStretch; {
+		report.Checked++
+
+		repaired := false
+		if core.GetCanonicalHash(db, number) != hash {
+			if err := core.WriteCanonicalHash(db, hash, number); err != nil {
+				return report, fmt.Errorf("block #%d: failed to write canonical hash: %v", number, err)
+			}
+			report.Canonical = append(report.Canonical, number)
+			repaired = true
+		}
+		for i, tx := range block.Transactions() {
+			if _, blockHash, blockNumber, index := core.GetTransaction(db, tx.Hash()); blockHash != hash || blockNumber != number || index != uint64(i) {
+				if err := core.WriteTransactions(db, block); err != nil {
+					return report, fmt.Errorf("block #%d: failed to write transactions: %v", number, err)
+				}
+				report.Reindexed = append(report.Reindexed, number)
+				repaired = true
+				break
+			}
+		}
+		if repaired {
+			clean = 0
+		} else {
+			clean++
+		}
+		if number == 0 {
+			break
+		}
+		hash, number = block.ParentHash(), number-1
+		if block = core.GetBlock(db, hash, number); block == nil {
+			return report, fmt.Errorf("block #%d [%x…] missing", number, hash[:4])
+		}
+	}
+	return report, nil
+}
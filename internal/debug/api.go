@@ -64,6 +64,16 @@ func (*HandlerT) Vmodule(pattern string) error {
 	return glog.GetVModule().Set(pattern)
 }
 
+// SetModuleVerbosity sets the glog verbosity of a single package, e.g.
+// "eth/downloader", or of source files matching a vmodule pattern, keeping the
+// levels set for the others. A zero level removes the package specific level.
+func (*HandlerT) SetModuleVerbosity(module string, level int) (bool, error) {
+	if err := glog.GetVModule().SetModule(module, level); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // BacktraceAt sets the glog backtrace location.
 // See package glog for details on pattern syntax.
 func (*HandlerT) BacktraceAt(location string) error {
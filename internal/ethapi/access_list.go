@@ -0,0 +1,95 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// accessListTracer is an EVM tracer that records every account and storage
+// slot touched during execution. Entries are deduplicated but keep the order
+// in which they were first accessed.
+type accessListTracer struct {
+	addresses []common.Address                        // Accounts in order of first access
+	slots     map[common.Address][]common.Hash        // Storage slots per account in order of first access
+	seen      map[common.Address]map[common.Hash]bool // Deduplication set for storage slots
+}
+
+// newAccessListTracer creates a new tracer with an empty access list.
+func newAccessListTracer() *accessListTracer {
+	return &accessListTracer{
+		slots: make(map[common.Address][]common.Hash),
+		seen:  make(map[common.Address]map[common.Hash]bool),
+	}
+}
+
+// addAddress marks an account as accessed if it wasn't already.
+func (t *accessListTracer) addAddress(addr common.Address) {
+	if _, ok := t.seen[addr]; ok {
+		return
+	}
+	t.addresses = append(t.addresses, addr)
+	t.seen[addr] = make(map[common.Hash]bool)
+}
+
+// addSlot marks a storage slot of an account (and the account itself) as accessed.
+func (t *accessListTracer) addSlot(addr common.Address, slot common.Hash) {
+	t.addAddress(addr)
+	if t.seen[addr][slot] {
+		return
+	}
+	t.seen[addr][slot] = true
+	t.slots[addr] = append(t.slots[addr], slot)
+}
+
+// CaptureState implements vm.Tracer, inspecting each executed opcode for
+// storage and account accesses.
+func (t *accessListTracer) CaptureState(env vm.Environment, pc uint64, op vm.OpCode, gas, cost *big.Int, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) {
+	t.addAddress(contract.Address())
+
+	data := stack.Data()
+	switch op {
+	case vm.SLOAD, vm.SSTORE:
+		if len(data) >= 1 {
+			t.addSlot(contract.Address(), common.BigToHash(data[len(data)-1]))
+		}
+	case vm.BALANCE, vm.EXTCODESIZE, vm.EXTCODECOPY, vm.SUICIDE:
+		if len(data) >= 1 {
+			t.addAddress(common.BigToAddress(data[len(data)-1]))
+		}
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL:
+		if len(data) >= 2 {
+			t.addAddress(common.BigToAddress(data[len(data)-2]))
+		}
+	}
+}
+
+// accessList returns the collected accounts and storage slots.
+func (t *accessListTracer) accessList() []AccessTuple {
+	list := make([]AccessTuple, len(t.addresses))
+	for i, addr := range t.addresses {
+		keys := t.slots[addr]
+		if keys == nil {
+			keys = []common.Hash{}
+		}
+		list[i] = AccessTuple{Address: addr, StorageKeys: keys}
+	}
+	return list
+}
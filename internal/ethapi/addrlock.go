@@ -0,0 +1,97 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// nonceLock serializes nonce assignment, signing and submission of transactions
+// per sender account across all API instances, preventing concurrent requests
+// from picking the same nonce.
+var nonceLock = new(addrLocker)
+
+// addrLocker is a collection of mutexes, one for each account address.
+type addrLocker struct {
+	mu    sync.Mutex
+	locks map[common.Address]*sync.Mutex
+}
+
+// lock returns the mutex of the given address, creating it if necessary.
+func (l *addrLocker) lock(address common.Address) *sync.Mutex {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locks == nil {
+		l.locks = make(map[common.Address]*sync.Mutex)
+	}
+	if _, ok := l.locks[address]; !ok {
+		l.locks[address] = new(sync.Mutex)
+	}
+	return l.locks[address]
+}
+
+// LockAddr locks an account's mutex. It's used to prevent another transaction
+// from getting the same nonce until the lock is released.
+func (l *addrLocker) LockAddr(address common.Address) {
+	l.lock(address).Lock()
+}
+
+// UnlockAddr unlocks the mutex of the given account.
+func (l *addrLocker) UnlockAddr(address common.Address) {
+	l.lock(address).Unlock()
+}
+
+// LockAddrs locks the mutexes of all the given accounts. The locks are taken in
+// a fixed order to avoid deadlocking against other multi-account holders.
+func (l *addrLocker) LockAddrs(addresses []common.Address) {
+	for _, address := range uniqueAddrs(addresses) {
+		l.LockAddr(address)
+	}
+}
+
+// UnlockAddrs unlocks the mutexes of all the given accounts.
+func (l *addrLocker) UnlockAddrs(addresses []common.Address) {
+	for _, address := range uniqueAddrs(addresses) {
+		l.UnlockAddr(address)
+	}
+}
+
+// uniqueAddrs returns the distinct addresses of the given list in sorted order.
+func uniqueAddrs(addresses []common.Address) []common.Address {
+	seen := make(map[common.Address]bool)
+	unique := make(addrsByBytes, 0, len(addresses))
+	for _, address := range addresses {
+		if !seen[address] {
+			seen[address] = true
+			unique = append(unique, address)
+		}
+	}
+	sort.Sort(unique)
+	return unique
+}
+
+// addrsByBytes implements sort.Interface to order addresses by their bytes.
+type addrsByBytes []common.Address
+
+func (a addrsByBytes) Len() int           { return len(a) }
+func (a addrsByBytes) Less(i, j int) bool { return bytes.Compare(a[i][:], a[j][:]) < 0 }
+func (a addrsByBytes) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
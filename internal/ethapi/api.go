@@ -17,11 +17,12 @@
 package ethapi
 
 import (
-	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strings"
 	"time"
 
@@ -44,6 +45,16 @@ import (
 
 const defaultGas = uint64(90000)
 
+// defaultTxGas returns the gas allowance of a transaction not specifying one: the
+// fixed default, raised to the intrinsic gas of the transaction if the gas costs
+// of the network (see core.IntrinsicGasCosts) make that higher.
+func defaultTxGas(b Backend, to *common.Address, data string) *rpc.HexNumber {
+	if intrinsic := b.ChainConfig().IntrinsicGas(common.FromHex(data), to == nil, true); intrinsic.Cmp(new(big.Int).SetUint64(defaultGas)) > 0 {
+		return rpc.NewHexNumber(intrinsic)
+	}
+	return rpc.NewHexNumber(defaultGas)
+}
+
 // PublicEthereumAPI provides an API to access Ethereum related information.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicEthereumAPI struct {
@@ -111,7 +122,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range pending {
 		dump := make(map[string]*RPCTransaction)
 		for nonce, tx := range txs {
-			dump[fmt.Sprintf("%d", nonce)] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", nonce)] = NewRPCPendingTransaction(tx)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -119,7 +130,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range queue {
 		dump := make(map[string]*RPCTransaction)
 		for nonce, tx := range txs {
-			dump[fmt.Sprintf("%d", nonce)] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", nonce)] = NewRPCPendingTransaction(tx)
 		}
 		content["queued"][account.Hex()] = dump
 	}
@@ -170,6 +181,124 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	return content
 }
 
+// NonceGaps returns the nonces missing between the current state nonce of an
+// account and its highest queued transaction. These are the transactions that
+// need to be (re)sent for the queued ones to become executable.
+func (s *PublicTxPoolAPI) NonceGaps(ctx context.Context, address common.Address) ([]uint64, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	nonce, err := state.GetNonce(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	pending, queue := s.b.TxPoolContent()
+
+	known := make(map[uint64]bool)
+	highest := uint64(0)
+	for _, txs := range []types.Transactions{pending[address], queue[address]} {
+		for _, tx := range txs {
+			known[tx.Nonce()] = true
+			if tx.Nonce() > highest {
+				highest = tx.Nonce()
+			}
+		}
+	}
+	gaps := []uint64{}
+	for ; nonce < highest; nonce++ {
+		if !known[nonce] {
+			gaps = append(gaps, nonce)
+		}
+	}
+	return gaps, nil
+}
+
+// PoolTransitionResult is the notification sent when a transaction moves between
+// the queued and pending sets of the transaction pool.
+type PoolTransitionResult struct {
+	Hash common.Hash `json:"hash"`
+	From string      `json:"from"`
+	To   string      `json:"to"`
+}
+
+// NewPoolTransitions creates a subscription that is notified whenever a pool
+// transaction is promoted from the queued set to the pending one, e.g. when an
+// earlier nonce fills a gap, or demoted back, e.g. when a preceding transaction
+// is dropped. Transactions added in an executable state are promoted right away.
+func (s *PublicTxPoolAPI) NewPoolTransitions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+	events := s.b.EventMux().Subscribe(core.TxTransitionEvent{})
+
+	go func() {
+		defer events.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events.Chan():
+				if ev == nil {
+					return
+				}
+				transition := ev.Data.(core.TxTransitionEvent)
+				notifier.Notify(rpcSub.ID, &PoolTransitionResult{Hash: transition.Tx.Hash(), From: transition.From, To: transition.To})
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// PoolPressureResult is the notification sent when the utilization of the
+// transaction pool crosses one of its pressure thresholds.
+type PoolPressureResult struct {
+	Pending  int     `json:"pending"`
+	Queued   int     `json:"queued"`
+	Capacity int     `json:"capacity"`
+	PctFull  float64 `json:"pctFull"`
+}
+
+// PoolPressure creates a subscription that is notified whenever the utilization
+// of the transaction pool crosses one of the configured thresholds, 80% and 95%
+// by default, in either direction. This allows reacting before the pool starts
+// dropping transactions.
+func (s *PublicTxPoolAPI) PoolPressure(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+	events := s.b.EventMux().Subscribe(core.TxPoolPressureEvent{})
+
+	go func() {
+		defer events.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events.Chan():
+				if ev == nil {
+					return
+				}
+				pressure := ev.Data.(core.TxPoolPressureEvent)
+				notifier.Notify(rpcSub.ID, &PoolPressureResult{Pending: pressure.Pending, Queued: pressure.Queued, Capacity: pressure.Capacity, PctFull: pressure.PctFull})
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // PublicAccountAPI provides an API to access accounts managed by this node.
 // It offers only methods that can retrieve accounts.
 type PublicAccountAPI struct {
@@ -212,6 +341,46 @@ func (s *PrivateAccountAPI) ListAccounts() []common.Address {
 	return addresses
 }
 
+// AccountState is the on-chain state of a managed account.
+type AccountState struct {
+	Address common.Address `json:"address"`
+	Balance *rpc.HexNumber `json:"balance"`
+	Nonce   *rpc.HexNumber `json:"nonce"`
+}
+
+// AccountsWithState returns the balance and nonce of every account this node
+// manages at the given block number. For the pending block, nonces are the pool's
+// view and include transactions not yet in a block.
+func (s *PrivateAccountAPI) AccountsWithState(ctx context.Context, blockNr rpc.BlockNumber) ([]AccountState, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	accounts := s.am.Accounts()
+	results := make([]AccountState, len(accounts))
+	for i, acc := range accounts {
+		balance, err := state.GetBalance(ctx, acc.Address)
+		if err != nil {
+			return nil, err
+		}
+		var nonce uint64
+		if blockNr == rpc.PendingBlockNumber {
+			nonce, err = s.b.GetPoolNonce(ctx, acc.Address)
+		} else {
+			nonce, err = state.GetNonce(ctx, acc.Address)
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[i] = AccountState{
+			Address: acc.Address,
+			Balance: rpc.NewHexNumber(balance),
+			Nonce:   rpc.NewHexNumber(nonce),
+		}
+	}
+	return results, nil
+}
+
 // NewAccount will create a new account and returns the address for the new account.
 func (s *PrivateAccountAPI) NewAccount(password string) (common.Address, error) {
 	acc, err := s.am.NewAccount(password)
@@ -235,11 +404,17 @@ func (s *PrivateAccountAPI) ImportRawKey(privkey string, password string) (commo
 
 // UnlockAccount will unlock the account associated with the given address with
 // the given password for duration seconds. If duration is nil it will use a
-// default of 300 seconds. It returns an indication if the account was unlocked.
-func (s *PrivateAccountAPI) UnlockAccount(addr common.Address, password string, duration *rpc.HexNumber) (bool, error) {
+// default of 300 seconds, while zero unlocks indefinitely. The latter is only
+// allowed over local endpoints if the node restricts unlocking, as is the case
+// when the HTTP-RPC server is exposed. It returns an indication if the account
+// was unlocked.
+func (s *PrivateAccountAPI) UnlockAccount(ctx context.Context, addr common.Address, password string, duration *rpc.HexNumber) (bool, error) {
 	if duration == nil {
 		duration = rpc.NewHexNumber(300)
 	}
+	if duration.Int64() <= 0 && s.b.RestrictUnlock() && !rpc.IsLocal(ctx) {
+		return false, rpc.ErrUnauthorized("indefinite unlock forbidden while HTTP-RPC is exposed, use a bounded duration or the IPC endpoint")
+	}
 	a := accounts.Account{Address: addr}
 	d := time.Duration(duration.Int64()) * time.Second
 	if err := s.am.TimedUnlock(a, password, d); err != nil {
@@ -248,6 +423,36 @@ func (s *PrivateAccountAPI) UnlockAccount(addr common.Address, password string,
 	return true, nil
 }
 
+// VerifyPassword checks whether the given password decrypts the key of the
+// account, without unlocking it. A wrong password is reported as false, while
+// an unknown account is an error.
+func (s *PrivateAccountAPI) VerifyPassword(addr common.Address, password string) (bool, error) {
+	switch err := s.am.VerifyPassphrase(accounts.Account{Address: addr}, password); err {
+	case nil:
+		return true, nil
+	case accounts.ErrDecrypt:
+		return false, nil
+	case accounts.ErrNoMatch:
+		return false, rpc.ErrNotFound("account %x not found", addr)
+	default:
+		return false, err
+	}
+}
+
+// ChangePassword re-encrypts the key of the account under a new password. The
+// key file is replaced atomically and left untouched if the old password is
+// wrong or the new file cannot be written.
+func (s *PrivateAccountAPI) ChangePassword(addr common.Address, oldPassword, newPassword string) (bool, error) {
+	switch err := s.am.Update(accounts.Account{Address: addr}, oldPassword, newPassword); err {
+	case nil:
+		return true, nil
+	case accounts.ErrNoMatch:
+		return false, rpc.ErrNotFound("account %x not found", addr)
+	default:
+		return false, err
+	}
+}
+
 // LockAccount will lock the account associated with the given address when it's unlocked.
 func (s *PrivateAccountAPI) LockAccount(addr common.Address) bool {
 	return s.am.Lock(addr) == nil
@@ -257,6 +462,11 @@ func (s *PrivateAccountAPI) LockAccount(addr common.Address) bool {
 // tries to sign it with the key associated with args.To. If the given passwd isn't
 // able to decrypt the key it fails.
 func (s *PrivateAccountAPI) SendTransaction(ctx context.Context, args SendTxArgs, passwd string) (common.Hash, error) {
+	// Hold the sender's lock until the transaction is in the pool, so that
+	// concurrent sends can't pick the same nonce
+	nonceLock.LockAddr(args.From)
+	defer nonceLock.UnlockAddr(args.From)
+
 	var err error
 	args, err = prepareSendTxArgs(ctx, args, s.b)
 	if err != nil {
@@ -278,12 +488,12 @@ func (s *PrivateAccountAPI) SendTransaction(ctx context.Context, args SendTxArgs
 		tx = types.NewTransaction(args.Nonce.Uint64(), *args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
 	}
 
-	signature, err := s.am.SignWithPassphrase(args.From, passwd, tx.SigHash().Bytes())
+	signature, err := s.am.SignWithPassphrase(args.From, passwd, sigHash(tx, args.ChainId.BigInt()).Bytes())
 	if err != nil {
-		return common.Hash{}, err
+		return common.Hash{}, accountErr(err)
 	}
 
-	return submitTransaction(ctx, s.b, tx, signature)
+	return submitTransaction(ctx, s.b, tx, signature, args.ChainId.BigInt())
 }
 
 // SignAndSendTransaction was renamed to SendTransaction. This method is deprecated
@@ -320,6 +530,99 @@ func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address common.Add
 	return state.GetBalance(ctx, address)
 }
 
+// BalanceChangeResult is the notification sent by a NewBalanceChanges
+// subscription for a watched account whose balance changed.
+type BalanceChangeResult struct {
+	Address    common.Address `json:"address"`
+	OldBalance *rpc.HexNumber `json:"oldBalance"`
+	NewBalance *rpc.HexNumber `json:"newBalance"`
+	Block      *rpc.HexNumber `json:"block"`
+}
+
+// NewBalanceChanges creates a subscription that, on every new chain head, sends a
+// notification for each of the given accounts whose balance differs from the one
+// at the previously seen head. Balances are always taken from the canonical state,
+// so changes undone by a reorg are reported as well.
+func (s *PublicBlockChainAPI) NewBalanceChanges(ctx context.Context, addresses []common.Address) (*rpc.Subscription, error) {
+	if len(addresses) == 0 {
+		return &rpc.Subscription{}, rpc.ErrInvalidArgs("no addresses to watch")
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	// Subscribe and snapshot the balances before returning, so no head is missed
+	heads := s.b.EventMux().Subscribe(core.ChainHeadEvent{})
+
+	balances, _, err := s.balancesAt(ctx, rpc.LatestBlockNumber, addresses)
+	if err != nil {
+		heads.Unsubscribe()
+		return &rpc.Subscription{}, err
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		defer heads.Unsubscribe()
+
+		// Notifications are dropped until the subscription is activated, so only
+		// start comparing balances afterwards, not to lose a change
+		select {
+		case <-rpcSub.Active():
+		case <-rpcSub.Err():
+			return
+		case <-notifier.Closed():
+			return
+		}
+		for {
+			select {
+			case ev := <-heads.Chan():
+				if ev == nil {
+					return
+				}
+				head := ev.Data.(core.ChainHeadEvent).Block
+				current, header, err := s.balancesAt(ctx, rpc.BlockNumber(head.NumberU64()), addresses)
+				if err != nil {
+					glog.V(logger.Debug).Infof("failed to retrieve balances at block #%d: %v", head.NumberU64(), err)
+					continue
+				}
+				for i, address := range addresses {
+					if current[i].Cmp(balances[i]) != 0 {
+						notifier.Notify(rpcSub.ID, &BalanceChangeResult{
+							Address:    address,
+							OldBalance: rpc.NewHexNumber(balances[i]),
+							NewBalance: rpc.NewHexNumber(current[i]),
+							Block:      rpc.NewHexNumber(header.Number),
+						})
+					}
+				}
+				balances = current
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// balancesAt retrieves the balances of the given accounts in the state of the
+// given block, along with the header of the block.
+func (s *PublicBlockChainAPI) balancesAt(ctx context.Context, blockNr rpc.BlockNumber, addresses []common.Address) ([]*big.Int, *types.Header, error) {
+	state, header, err := s.b.StateAndHeaderByNumber(blockNr)
+	if state == nil || err != nil {
+		return nil, nil, fmt.Errorf("state of block %d not available: %v", blockNr, err)
+	}
+	balances := make([]*big.Int, len(addresses))
+	for i, address := range addresses {
+		if balances[i], err = state.GetBalance(ctx, address); err != nil {
+			return nil, nil, err
+		}
+	}
+	return balances, header, nil
+}
+
 // GetBlockByNumber returns the requested block. When blockNr is -1 the chain head is returned. When fullTx is true all
 // transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, blockNr rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
@@ -337,6 +640,54 @@ func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, blockNr rpc.
 	return nil, err
 }
 
+// GetBlockByTimestamp returns the latest canonical block with a timestamp not
+// after the given one, or the genesis block if all blocks are later. As block
+// timestamps are strictly increasing, the chain is binary searched.
+func (s *PublicBlockChainAPI) GetBlockByTimestamp(ctx context.Context, ts uint64) (map[string]interface{}, error) {
+	var (
+		head = s.b.HeaderByNumber(rpc.LatestBlockNumber).Number.Uint64()
+		err  error
+	)
+	// Find the first block after the timestamp, the one before is the result
+	after := sort.Search(int(head)+1, func(i int) bool {
+		header := s.b.HeaderByNumber(rpc.BlockNumber(i))
+		if header == nil {
+			err = rpc.ErrNotFound("block #%d not found", i)
+			return true
+		}
+		return header.Time.Uint64() > ts
+	})
+	if err != nil {
+		return nil, err
+	}
+	number := 0
+	if after > 0 {
+		number = after - 1
+	}
+	block, err := s.b.BlockByNumber(ctx, rpc.BlockNumber(number))
+	if block == nil {
+		if err == nil {
+			err = rpc.ErrNotFound("block #%d not found", number)
+		}
+		return nil, err
+	}
+	return s.rpcOutputBlock(block, true, false)
+}
+
+// GetCanonicalHash returns the hash of the canonical block at the given number
+// straight from the number to hash mapping, without loading the block, or the
+// zero hash if the number is above the chain head.
+func (s *PublicBlockChainAPI) GetCanonicalHash(number uint64) (common.Hash, error) {
+	if head := s.b.HeaderByNumber(rpc.LatestBlockNumber).Number.Uint64(); number > head {
+		return common.Hash{}, nil
+	}
+	hash := core.GetCanonicalHash(s.b.ChainDb(), number)
+	if hash == (common.Hash{}) {
+		return common.Hash{}, rpc.ErrNotFound("canonical hash of block #%d not found", number)
+	}
+	return hash, nil
+}
+
 // GetBlockByHash returns the requested block. When fullTx is true all transactions in the block are returned in full
 // detail, otherwise only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetBlockByHash(ctx context.Context, blockHash common.Hash, fullTx bool) (map[string]interface{}, error) {
@@ -395,7 +746,9 @@ func (s *PublicBlockChainAPI) GetUncleCountByBlockHash(ctx context.Context, bloc
 	return nil
 }
 
-// GetCode returns the code stored at the given address in the state for the given block number.
+// GetCode returns the code stored at the given address in the state for the given
+// block number. At the rpc.PendingBlockNumber meta block number the miner's pending
+// state is used, exposing contracts deployed by transactions not yet mined.
 func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (string, error) {
 	state, _, err := s.b.StateAndHeaderByNumber(blockNr)
 	if state == nil || err != nil {
@@ -423,6 +776,72 @@ func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.A
 	return res.Hex(), nil
 }
 
+// VerifiedStorageResult is a storage value along with the merkle proofs needed
+// to check it without trusting the node: the account proof links the account
+// to the state root of the block, the storage proof links the value to the
+// storage root of the account.
+type VerifiedStorageResult struct {
+	Value        string   `json:"value"`
+	StorageHash  string   `json:"storageHash"`
+	AccountProof []string `json:"accountProof"`
+	StorageProof []string `json:"storageProof"`
+}
+
+// GetStorageAtVerified returns the storage from the state at the given address,
+// key and block number, along with the merkle proofs of the value. If the slot
+// is empty, the storage proof proves its absence from the storage trie.
+func (s *PublicBlockChainAPI) GetStorageAtVerified(ctx context.Context, address common.Address, key common.Hash, blockNr rpc.BlockNumber) (*VerifiedStorageResult, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	value, err := state.GetState(ctx, address, key)
+	if err != nil {
+		return nil, err
+	}
+	accountProof, err := state.GetProof(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	root, storageProof, err := state.GetStorageProof(ctx, address, key)
+	if err != nil {
+		return nil, err
+	}
+	return &VerifiedStorageResult{
+		Value:        value.Hex(),
+		StorageHash:  root.Hex(),
+		AccountProof: encodeProof(accountProof),
+		StorageProof: encodeProof(storageProof),
+	}, nil
+}
+
+// GetStorageRoot returns the root of the storage trie of the given account at
+// the given block number, against which storage proofs can be verified. Accounts
+// without storage have the empty trie root, nonexistent accounts are an error.
+func (s *PublicBlockChainAPI) GetStorageRoot(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (common.Hash, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(blockNr)
+	if state == nil || err != nil {
+		return common.Hash{}, err
+	}
+	exist, err := state.Exist(ctx, address)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if !exist {
+		return common.Hash{}, rpc.ErrNotFound("account %x not found", address)
+	}
+	return state.GetStorageRoot(ctx, address)
+}
+
+// encodeProof converts the nodes of a merkle proof into hex strings.
+func encodeProof(proof []rlp.RawValue) []string {
+	nodes := make([]string, len(proof))
+	for i, node := range proof {
+		nodes[i] = common.ToHex(node)
+	}
+	return nodes
+}
+
 // callmsg is the message type used for call transations.
 type callmsg struct {
 	addr          common.Address
@@ -453,14 +872,35 @@ type CallArgs struct {
 	Data     string          `json:"data"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (string, *big.Int, error) {
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, tracer vm.Tracer) (string, *big.Int, error) {
 	defer func(start time.Time) { glog.V(logger.Debug).Infof("call took %v", time.Since(start)) }(time.Now())
 
 	state, header, err := s.b.StateAndHeaderByNumber(blockNr)
 	if state == nil || err != nil {
 		return "0x", common.Big0, err
 	}
+	msg := s.callMessage(args)
+
+	// Execute the call and return
+	vmenv, vmError, err := s.b.GetVMEnv(ctx, msg, state, header, tracer)
+	if err != nil {
+		return "0x", common.Big0, err
+	}
+	gp := new(core.GasPool).AddGas(common.MaxBig)
+	res, gas, err := core.ApplyMessage(vmenv, msg, gp)
+	if err := vmError(); err != nil {
+		return "0x", common.Big0, err
+	}
+	if len(res) == 0 { // backwards compatability
+		return "0x", gas, err
+	}
+	return common.ToHex(res), gas, err
+}
 
+// callMessage assembles the CALL invocation described by the call arguments,
+// defaulting the sender to the first local account and the gas allowance and
+// price to generous values.
+func (s *PublicBlockChainAPI) callMessage(args CallArgs) callmsg {
 	// Set the account address to interact with
 	var addr common.Address
 	if args.From == (common.Address{}) {
@@ -491,36 +931,288 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	if msg.gasPrice.Cmp(common.Big0) == 0 {
 		msg.gasPrice = new(big.Int).Mul(big.NewInt(50), common.Shannon)
 	}
-
-	// Execute the call and return
-	vmenv, vmError, err := s.b.GetVMEnv(ctx, msg, state, header)
-	if err != nil {
-		return "0x", common.Big0, err
-	}
-	gp := new(core.GasPool).AddGas(common.MaxBig)
-	res, gas, err := core.ApplyMessage(vmenv, msg, gp)
-	if err := vmError(); err != nil {
-		return "0x", common.Big0, err
-	}
-	if len(res) == 0 { // backwards compatability
-		return "0x", gas, err
-	}
-	return common.ToHex(res), gas, err
+	return msg
 }
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is usefull to execute and retrieve values.
 func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (string, error) {
-	result, _, err := s.doCall(ctx, args, blockNr)
+	result, _, err := s.doCall(ctx, args, blockNr, nil)
 	return result, err
 }
 
 // EstimateGas returns an estimate of the amount of gas needed to execute the given transaction.
+// The estimate includes the intrinsic gas of the transaction according to the gas
+// schedule of the network, see IntrinsicGas.
 func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (*rpc.HexNumber, error) {
-	_, gas, err := s.doCall(ctx, args, rpc.PendingBlockNumber)
+	_, gas, err := s.doCall(ctx, args, rpc.PendingBlockNumber, nil)
 	return rpc.NewHexNumber(gas), err
 }
 
+// IntrinsicGasResult contains the intrinsic gas costs charged to every transaction
+// before its execution.
+type IntrinsicGasResult struct {
+	TxGas                 *rpc.HexNumber `json:"txGas"`                 // Per transaction not creating a contract
+	TxGasContractCreation *rpc.HexNumber `json:"txGasContractCreation"` // Per transaction creating a contract
+	TxDataZeroGas         *rpc.HexNumber `json:"txDataZeroGas"`         // Per zero byte of transaction data
+	TxDataNonZeroGas      *rpc.HexNumber `json:"txDataNonZeroGas"`      // Per non-zero byte of transaction data
+}
+
+// IntrinsicGas returns the intrinsic gas costs in effect, which private networks
+// may have overridden from the mainnet defaults in their genesis configuration.
+func (s *PublicBlockChainAPI) IntrinsicGas() *IntrinsicGasResult {
+	costs := s.b.ChainConfig().IntrinsicCosts()
+	return &IntrinsicGasResult{
+		TxGas:                 rpc.NewHexNumber(costs.TxGas),
+		TxGasContractCreation: rpc.NewHexNumber(costs.TxGasContractCreation),
+		TxDataZeroGas:         rpc.NewHexNumber(costs.TxDataZeroGas),
+		TxDataNonZeroGas:      rpc.NewHexNumber(costs.TxDataNonZeroGas),
+	}
+}
+
+// AccessTuple is a single account touched during a call, along with all the
+// storage slots of that account that were read or written.
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// AccessListResult is the result of CreateAccessList, containing the set of
+// accounts and storage slots touched by a call and the gas it consumed.
+type AccessListResult struct {
+	AccessList []AccessTuple  `json:"accessList"`
+	GasUsed    *rpc.HexNumber `json:"gasUsed"`
+}
+
+// CreateAccessList executes the given call on the state for the given block
+// number and reports every account and storage slot it accessed, along with
+// the amount of gas used. Nothing is committed to the state/blockchain.
+func (s *PublicBlockChainAPI) CreateAccessList(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (*AccessListResult, error) {
+	tracer := newAccessListTracer()
+	if args.To != nil {
+		tracer.addAddress(*args.To)
+	}
+	_, gas, err := s.doCall(ctx, args, blockNr, tracer)
+	if err != nil {
+		return nil, err
+	}
+	return &AccessListResult{
+		AccessList: tracer.accessList(),
+		GasUsed:    rpc.NewHexNumber(gas),
+	}, nil
+}
+
+// maxGasStatsBlocks is the maximum number of blocks GasStats will aggregate
+// over in a single request.
+const maxGasStatsBlocks = 1024
+
+// BlockGasPrices contains the distribution of gas prices paid by the
+// transactions included in a single block. Empty blocks have nil prices.
+type BlockGasPrices struct {
+	Number *rpc.HexNumber `json:"number"`
+	Min    *rpc.HexNumber `json:"min"`
+	Median *rpc.HexNumber `json:"median"`
+	Max    *rpc.HexNumber `json:"max"`
+}
+
+// GasStatsResult contains gas usage statistics aggregated over a block range.
+type GasStatsResult struct {
+	From        *rpc.HexNumber   `json:"from"`
+	To          *rpc.HexNumber   `json:"to"`
+	AvgGasUsed  *rpc.HexNumber   `json:"avgGasUsed"`
+	AvgGasLimit *rpc.HexNumber   `json:"avgGasLimit"`
+	Utilization float64          `json:"utilization"` // Percentage of the gas limit used over the range
+	GasPrices   []BlockGasPrices `json:"gasPrices"`
+}
+
+// resolveBlockNumber converts the latest and pending meta block numbers into
+// the number of the current chain head, leaving others as they are.
+func (s *PublicBlockChainAPI) resolveBlockNumber(blockNr rpc.BlockNumber) uint64 {
+	if blockNr < 0 {
+		return s.b.HeaderByNumber(rpc.LatestBlockNumber).Number.Uint64()
+	}
+	return uint64(blockNr)
+}
+
+// GasStats returns the average gas usage, average gas limit and gas limit
+// utilization over the given (inclusive) block range, along with the minimum,
+// median and maximum gas price of the transactions included in each block.
+func (s *PublicBlockChainAPI) GasStats(ctx context.Context, from, to rpc.BlockNumber) (*GasStatsResult, error) {
+	first, last := s.resolveBlockNumber(from), s.resolveBlockNumber(to)
+	if first > last {
+		return nil, rpc.ErrInvalidArgs("invalid block range #%d-#%d", first, last)
+	}
+	if last-first+1 > maxGasStatsBlocks {
+		return nil, rpc.ErrInvalidArgs("block range too large: %d > %d", last-first+1, maxGasStatsBlocks)
+	}
+	var (
+		gasUsed  = new(big.Int)
+		gasLimit = new(big.Int)
+		prices   = make([]BlockGasPrices, 0, last-first+1)
+	)
+	for number := first; number <= last; number++ {
+		block, err := s.b.BlockByNumber(ctx, rpc.BlockNumber(number))
+		if block == nil {
+			if err == nil {
+				err = rpc.ErrNotFound("block #%d not found", number)
+			}
+			return nil, err
+		}
+		gasUsed.Add(gasUsed, block.GasUsed())
+		gasLimit.Add(gasLimit, block.GasLimit())
+
+		entry := BlockGasPrices{Number: rpc.NewHexNumber(number)}
+		if txs := block.Transactions(); len(txs) > 0 {
+			min, median, max := GasPriceSpread(txs)
+			entry.Min = rpc.NewHexNumber(min)
+			entry.Median = rpc.NewHexNumber(median)
+			entry.Max = rpc.NewHexNumber(max)
+		}
+		prices = append(prices, entry)
+	}
+	count := new(big.Int).SetUint64(last - first + 1)
+
+	utilization := 0.0
+	if gasLimit.Sign() > 0 {
+		utilization, _ = new(big.Rat).SetFrac(new(big.Int).Mul(gasUsed, big.NewInt(100)), gasLimit).Float64()
+	}
+	return &GasStatsResult{
+		From:        rpc.NewHexNumber(first),
+		To:          rpc.NewHexNumber(last),
+		AvgGasUsed:  rpc.NewHexNumber(new(big.Int).Div(gasUsed, count)),
+		AvgGasLimit: rpc.NewHexNumber(new(big.Int).Div(gasLimit, count)),
+		Utilization: utilization,
+		GasPrices:   prices,
+	}, nil
+}
+
+// FeeHistoryResult contains the gas used ratio of a range of blocks, along with
+// the gas prices paid at the requested percentiles within each block.
+type FeeHistoryResult struct {
+	OldestBlock  *rpc.HexNumber     `json:"oldestBlock"`
+	Reward       [][]*rpc.HexNumber `json:"reward,omitempty"`
+	GasUsedRatio []float64          `json:"gasUsedRatio"`
+}
+
+// txGasAndPrice is the gas used and gas price of a single included transaction,
+// used to compute the gas weighted price percentiles of a block.
+type txGasAndPrice struct {
+	gasUsed  *big.Int
+	gasPrice *big.Int
+}
+
+type txsByGasPrice []txGasAndPrice
+
+func (s txsByGasPrice) Len() int           { return len(s) }
+func (s txsByGasPrice) Less(i, j int) bool { return s[i].gasPrice.Cmp(s[j].gasPrice) < 0 }
+func (s txsByGasPrice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// FeeHistory returns the gas used ratio of the blockCount blocks ending with
+// lastBlock and, for every block, the gas prices at the requested percentiles of
+// the gas used by its transactions. Percentiles must be monotonically increasing
+// values between 0 and 100. Empty blocks have nil rewards.
+func (s *PublicBlockChainAPI) FeeHistory(ctx context.Context, blockCount int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*FeeHistoryResult, error) {
+	if blockCount < 1 {
+		return nil, rpc.ErrInvalidArgs("invalid block count %d", blockCount)
+	}
+	if blockCount > maxGasStatsBlocks {
+		return nil, rpc.ErrInvalidArgs("block count too large: %d > %d", blockCount, maxGasStatsBlocks)
+	}
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 {
+			return nil, rpc.ErrInvalidArgs("invalid reward percentile %f", p)
+		}
+		if i > 0 && p < rewardPercentiles[i-1] {
+			return nil, rpc.ErrInvalidArgs("invalid reward percentile #%d %f < #%d %f", i, p, i-1, rewardPercentiles[i-1])
+		}
+	}
+	last := s.resolveBlockNumber(lastBlock)
+	if uint64(blockCount) > last+1 {
+		blockCount = int(last + 1)
+	}
+	first := last + 1 - uint64(blockCount)
+
+	result := &FeeHistoryResult{
+		OldestBlock:  rpc.NewHexNumber(first),
+		GasUsedRatio: make([]float64, blockCount),
+	}
+	if len(rewardPercentiles) > 0 {
+		result.Reward = make([][]*rpc.HexNumber, blockCount)
+	}
+	for i := 0; i < blockCount; i++ {
+		block, err := s.b.BlockByNumber(ctx, rpc.BlockNumber(first+uint64(i)))
+		if block == nil {
+			if err == nil {
+				err = rpc.ErrNotFound("block #%d not found", first+uint64(i))
+			}
+			return nil, err
+		}
+		if block.GasLimit().Sign() > 0 {
+			result.GasUsedRatio[i], _ = new(big.Rat).SetFrac(block.GasUsed(), block.GasLimit()).Float64()
+		}
+		if len(rewardPercentiles) == 0 || len(block.Transactions()) == 0 {
+			continue
+		}
+		receipts, err := s.b.GetReceipts(ctx, block.Hash())
+		if err != nil {
+			return nil, err
+		}
+		if len(receipts) != len(block.Transactions()) {
+			return nil, rpc.ErrNotFound("receipts of block #%d not found", block.NumberU64())
+		}
+		sorted := make(txsByGasPrice, len(receipts))
+		for j, tx := range block.Transactions() {
+			sorted[j] = txGasAndPrice{gasUsed: receipts[j].GasUsed, gasPrice: tx.GasPrice()}
+		}
+		sort.Sort(sorted)
+
+		var (
+			reward = make([]*rpc.HexNumber, len(rewardPercentiles))
+			index  = 0
+			sum    = new(big.Int).Set(sorted[0].gasUsed)
+		)
+		for j, p := range rewardPercentiles {
+			threshold, _ := new(big.Float).Mul(new(big.Float).SetInt(block.GasUsed()), big.NewFloat(p/100)).Int(nil)
+			for sum.Cmp(threshold) < 0 && index < len(sorted)-1 {
+				index++
+				sum.Add(sum, sorted[index].gasUsed)
+			}
+			reward[j] = rpc.NewHexNumber(sorted[index].gasPrice)
+		}
+		result.Reward[i] = reward
+	}
+	return result, nil
+}
+
+// GasPriceSpread returns the minimum, median and maximum gas price paid by a set
+// of transactions. For even transaction counts the median is the average of the
+// two middle prices. All are nil if there are no transactions.
+func GasPriceSpread(txs types.Transactions) (min, median, max *big.Int) {
+	if len(txs) == 0 {
+		return nil, nil, nil
+	}
+	sorted := make([]*big.Int, len(txs))
+	for i, tx := range txs {
+		sorted[i] = tx.GasPrice()
+	}
+	sort.Sort(bigIntSlice(sorted))
+
+	median = new(big.Int).Set(sorted[len(sorted)/2])
+	if len(sorted)%2 == 0 {
+		median.Add(median, sorted[len(sorted)/2-1])
+		median.Div(median, common.Big2)
+	}
+	return sorted[0], median, sorted[len(sorted)-1]
+}
+
+// bigIntSlice attaches the methods of sort.Interface to []*big.Int, sorting in
+// increasing order.
+type bigIntSlice []*big.Int
+
+func (s bigIntSlice) Len() int           { return len(s) }
+func (s bigIntSlice) Less(i, j int) bool { return s[i].Cmp(s[j]) < 0 }
+func (s bigIntSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as the amount of
 // gas used and the return value
@@ -650,8 +1342,8 @@ type RPCTransaction struct {
 	S                *rpc.HexNumber  `json:"s"`
 }
 
-// newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
-func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
+// NewRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
+func NewRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 	from, _ := tx.FromFrontier()
 	v, r, s := tx.SignatureValues()
 	return &RPCTransaction{
@@ -773,6 +1465,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionByBlockNumberAndIndex(ctx conte
 }
 
 // GetTransactionByBlockHashAndIndex returns the transaction for the given block hash and index.
+// Unknown blocks and out of range indexes yield nil without an error.
 func (s *PublicTransactionPoolAPI) GetTransactionByBlockHashAndIndex(ctx context.Context, blockHash common.Hash, index rpc.HexNumber) (*RPCTransaction, error) {
 	if block, _ := s.b.GetBlock(ctx, blockHash); block != nil {
 		return newRPCTransactionFromBlockIndex(block, index.Int())
@@ -798,6 +1491,14 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByBlockHashAndIndex(ctx cont
 
 // GetTransactionCount returns the number of transactions the given address has sent for the given block number
 func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*rpc.HexNumber, error) {
+	// The pending count is the pool's view, including sends not yet in a block
+	if blockNr == rpc.PendingBlockNumber {
+		nonce, err := s.b.GetPoolNonce(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		return rpc.NewHexNumber(nonce), nil
+	}
 	state, _, err := s.b.StateAndHeaderByNumber(blockNr)
 	if state == nil || err != nil {
 		return nil, err
@@ -809,8 +1510,67 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 	return rpc.NewHexNumber(nonce), nil
 }
 
+// FeeAdvice groups the information a wallet needs to assemble a new transaction
+// for an account that has a good chance of being mined soon.
+type FeeAdvice struct {
+	GasPrice  *rpc.HexNumber `json:"gasPrice"`  // Gas price suggested by the oracle
+	Nonce     *rpc.HexNumber `json:"nonce"`     // Next nonce of the account, including pooled transactions
+	Pending   *rpc.HexNumber `json:"pending"`   // Number of transactions of the account in the pool
+	NeedsBump bool           `json:"needsBump"` // Whether pooled transactions would need replacing to be priced at the suggestion
+}
+
+// SuggestFees returns the recommended gas price, the next pool nonce for the
+// given account, and whether the account already has transactions waiting in
+// the pool that are priced below the recommendation (and would need replacing).
+func (s *PublicTransactionPoolAPI) SuggestFees(ctx context.Context, address common.Address) (*FeeAdvice, error) {
+	price, err := s.b.SuggestPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := s.b.GetPoolNonce(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	pending, queued := s.b.TxPoolContent()
+
+	advice := &FeeAdvice{
+		GasPrice: rpc.NewHexNumber(price),
+		Nonce:    rpc.NewHexNumber(nonce),
+	}
+	count := 0
+	for _, txs := range []types.Transactions{pending[address], queued[address]} {
+		for _, tx := range txs {
+			if tx.GasPrice().Cmp(price) < 0 {
+				advice.NeedsBump = true
+			}
+			count++
+		}
+	}
+	advice.Pending = rpc.NewHexNumber(count)
+	return advice, nil
+}
+
+// txMetaMinSize is the smallest possible RLP encoding of a transaction lookup
+// entry: a list header, a 33 byte hash string and two single byte integers.
+const txMetaMinSize = 1 + 33 + 1 + 1
+
+// errTxNotIndexed is returned if no lookup entry exists for a transaction.
+var errTxNotIndexed = errors.New("transaction not indexed")
+
+// corruptTxIndexError is returned if the lookup entry of a transaction exists,
+// but cannot be decoded.
+type corruptTxIndexError struct {
+	hash common.Hash
+	err  error
+}
+
+func (e *corruptTxIndexError) Error() string {
+	return fmt.Sprintf("corrupt index entry for transaction %x: %v", e.hash, e.err)
+}
+
 // getTransactionBlockData fetches the meta data for the given transaction from the chain database. This is useful to
-// retrieve block information for a hash. It returns the block hash, block index and transaction index.
+// retrieve block information for a hash. It returns the block hash, block index and transaction index. If the entry
+// is missing errTxNotIndexed is returned, if it's malformed a *corruptTxIndexError.
 func getTransactionBlockData(chainDb ethdb.Database, txHash common.Hash) (common.Hash, uint64, uint64, error) {
 	var txBlock struct {
 		BlockHash  common.Hash
@@ -819,20 +1579,56 @@ func getTransactionBlockData(chainDb ethdb.Database, txHash common.Hash) (common
 	}
 
 	blockData, err := chainDb.Get(append(txHash.Bytes(), 0x0001))
-	if err != nil {
-		return common.Hash{}, uint64(0), uint64(0), err
+	if err != nil || len(blockData) == 0 {
+		return common.Hash{}, uint64(0), uint64(0), errTxNotIndexed
 	}
-
-	reader := bytes.NewReader(blockData)
-	if err = rlp.Decode(reader, &txBlock); err != nil {
-		return common.Hash{}, uint64(0), uint64(0), err
+	if len(blockData) < txMetaMinSize {
+		err := fmt.Errorf("entry too short (%d bytes, want at least %d)", len(blockData), txMetaMinSize)
+		return common.Hash{}, uint64(0), uint64(0), &corruptTxIndexError{txHash, err}
+	}
+	if err = rlp.DecodeBytes(blockData, &txBlock); err != nil {
+		return common.Hash{}, uint64(0), uint64(0), &corruptTxIndexError{txHash, err}
 	}
 
 	return txBlock.BlockHash, txBlock.BlockIndex, txBlock.Index, nil
 }
 
-// GetTransactionByHash returns the transaction for the given hash
-func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, txHash common.Hash) (*RPCTransaction, error) {
+// ReplacedTransactionResult is returned by GetTransactionByHash in place of an
+// unknown transaction if it was replaced by another one at the same nonce.
+type ReplacedTransactionResult struct {
+	Status     string      `json:"status"`
+	ReplacedBy common.Hash `json:"replacedBy"`
+}
+
+// GetTransactionByHash returns the transaction for the given hash, or nil if it's
+// unknown. If withStatus is set and the transaction was recently replaced in the
+// pool, a ReplacedTransactionResult pointing to its replacement is returned instead.
+func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, txHash common.Hash, withStatus *bool) (interface{}, error) {
+	tx, err := s.getTransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		return tx, nil
+	}
+	if withStatus != nil && *withStatus {
+		if replacement, ok := s.b.GetPoolReplacement(txHash); ok {
+			return &ReplacedTransactionResult{Status: "replaced", ReplacedBy: replacement}, nil
+		}
+	}
+	return nil, nil
+}
+
+// TransactionInPool returns whether the transaction pool currently holds the
+// transaction with the given hash. It's a cheap alternative to retrieving the
+// whole transaction for callers polling whether a submission is still pending.
+func (s *PublicTransactionPoolAPI) TransactionInPool(txHash common.Hash) (bool, error) {
+	return s.b.GetPoolTransaction(txHash) != nil, nil
+}
+
+// getTransactionByHash retrieves the RPC representation of a pending or mined
+// transaction, or nil if it's unknown.
+func (s *PublicTransactionPoolAPI) getTransactionByHash(ctx context.Context, txHash common.Hash) (*RPCTransaction, error) {
 	var tx *types.Transaction
 	var isPending bool
 	var err error
@@ -845,11 +1641,14 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, txH
 	}
 
 	if isPending {
-		return newRPCPendingTransaction(tx), nil
+		return NewRPCPendingTransaction(tx), nil
 	}
 
 	blockHash, _, _, err := getTransactionBlockData(s.b.ChainDb(), txHash)
 	if err != nil {
+		if _, corrupt := err.(*corruptTxIndexError); corrupt {
+			return nil, err
+		}
 		glog.V(logger.Debug).Infof("%v\n", err)
 		return nil, nil
 	}
@@ -878,6 +1677,33 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
 func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (map[string]interface{}, error) {
+	return s.formatReceipt(txHash)
+}
+
+// maxReceiptsPerRequest is the maximum number of receipts GetTransactionReceipts
+// will look up in a single request.
+const maxReceiptsPerRequest = 1024
+
+// GetTransactionReceipts returns the transaction receipts for the given transaction
+// hashes, in the order requested. Entries of unknown transactions are nil.
+func (s *PublicTransactionPoolAPI) GetTransactionReceipts(hashes []common.Hash) ([]map[string]interface{}, error) {
+	if len(hashes) > maxReceiptsPerRequest {
+		return nil, rpc.ErrInvalidArgs("too many transaction hashes: %d > %d", len(hashes), maxReceiptsPerRequest)
+	}
+	receipts := make([]map[string]interface{}, len(hashes))
+	for i, hash := range hashes {
+		receipt, err := s.formatReceipt(hash)
+		if err != nil {
+			return nil, err
+		}
+		receipts[i] = receipt
+	}
+	return receipts, nil
+}
+
+// formatReceipt retrieves the receipt of the given transaction and assembles its
+// RPC representation. Nil is returned if the receipt is not found.
+func (s *PublicTransactionPoolAPI) formatReceipt(txHash common.Hash) (map[string]interface{}, error) {
 	receipt := core.GetReceipt(s.b.ChainDb(), txHash)
 	if receipt == nil {
 		glog.V(logger.Debug).Infof("receipt not found for transaction %s", txHash.Hex())
@@ -892,6 +1718,9 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (ma
 
 	txBlock, blockIndex, index, err := getTransactionBlockData(s.b.ChainDb(), txHash)
 	if err != nil {
+		if _, corrupt := err.(*corruptTxIndexError); corrupt {
+			return nil, err
+		}
 		glog.V(logger.Debug).Infof("%v\n", err)
 		return nil, nil
 	}
@@ -915,6 +1744,8 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (ma
 		"contractAddress":   nil,
 		"logs":              receipt.Logs,
 		"logsBloom":         receipt.Bloom,
+		"logIndex":          rpc.NewHexNumber(logIndexBase(s.b.ChainDb(), txBlock, blockIndex, index)),
+		"confirmations":     rpc.NewHexNumber(transactionConfirmations(s.b, txBlock, blockIndex)),
 	}
 	if receipt.Logs == nil {
 		fields["logs"] = []vm.Logs{}
@@ -926,13 +1757,178 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(txHash common.Hash) (ma
 	return fields, nil
 }
 
+// logIndexBase returns the block-wide index of the first log emitted by the
+// transaction at the given index, i.e. the number of logs emitted by all the
+// transactions preceding it in the block.
+func logIndexBase(db ethdb.Database, blockHash common.Hash, blockNumber uint64, index uint64) uint64 {
+	var base uint64
+	for i, receipt := range core.GetBlockReceipts(db, blockHash, blockNumber) {
+		if uint64(i) >= index {
+			break
+		}
+		base += uint64(len(receipt.Logs))
+	}
+	return base
+}
+
+// TransactionPosition is the location of a mined transaction in the canonical chain.
+type TransactionPosition struct {
+	BlockHash        common.Hash    `json:"blockHash"`
+	BlockNumber      *rpc.HexNumber `json:"blockNumber"`
+	TransactionIndex *rpc.HexNumber `json:"transactionIndex"`
+}
+
+// GetTransactionPosition returns the block and index at which the given transaction
+// was included. Unlike the other transaction lookups, an error is returned if the
+// transaction is not mined yet, either because it is still pending or unknown.
+func (s *PublicTransactionPoolAPI) GetTransactionPosition(txHash common.Hash) (*TransactionPosition, error) {
+	blockHash, blockNumber, index, err := getTransactionBlockData(s.b.ChainDb(), txHash)
+	if err != nil {
+		if _, corrupt := err.(*corruptTxIndexError); corrupt {
+			return nil, err
+		}
+		if s.b.GetPoolTransaction(txHash) != nil {
+			return nil, rpc.ErrNotFound("transaction %x is pending", txHash)
+		}
+		return nil, rpc.ErrNotFound("transaction %x not found", txHash)
+	}
+	return &TransactionPosition{
+		BlockHash:        blockHash,
+		BlockNumber:      rpc.NewHexNumber(blockNumber),
+		TransactionIndex: rpc.NewHexNumber(index),
+	}, nil
+}
+
+// transactionConfirmations returns the number of blocks built on top of (and
+// including) the given block, or zero if the block is no longer canonical.
+func transactionConfirmations(b Backend, blockHash common.Hash, blockNumber uint64) uint64 {
+	if core.GetCanonicalHash(b.ChainDb(), blockNumber) != blockHash {
+		return 0
+	}
+	head := b.HeaderByNumber(rpc.LatestBlockNumber)
+	if head == nil || head.Number.Uint64() < blockNumber {
+		return 0
+	}
+	return head.Number.Uint64() - blockNumber + 1
+}
+
+// AwaitTransactionResult is the notification sent by an AwaitTransaction
+// subscription. Error is set if the transaction left the pool without being
+// mined, e.g. because it was dropped or replaced.
+type AwaitTransactionResult struct {
+	BlockHash     common.Hash    `json:"blockHash"`
+	BlockNumber   *rpc.HexNumber `json:"blockNumber"`
+	Confirmations *rpc.HexNumber `json:"confirmations"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// AwaitTransaction creates a subscription that sends a single notification once
+// the given transaction was mined and confirmed by the requested number of
+// canonical blocks (including its own). Confirmations are re-counted on every
+// new head, so blocks reorged away do not count.
+func (s *PublicTransactionPoolAPI) AwaitTransaction(ctx context.Context, txHash common.Hash, confirmations int) (*rpc.Subscription, error) {
+	if confirmations < 1 {
+		return &rpc.Subscription{}, rpc.ErrInvalidArgs("invalid confirmation count %d", confirmations)
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		heads := s.b.EventMux().Subscribe(core.ChainHeadEvent{})
+		defer heads.Unsubscribe()
+
+		// Notifications are dropped until the subscription is activated, so only
+		// report an already reached status afterwards
+		select {
+		case <-rpcSub.Active():
+		case <-rpcSub.Err():
+			return
+		case <-notifier.Closed():
+			return
+		}
+		for {
+			if result := s.awaitStatus(txHash, uint64(confirmations)); result != nil {
+				notifier.Notify(rpcSub.ID, result)
+				return
+			}
+			select {
+			case ev := <-heads.Chan():
+				if ev == nil {
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// awaitStatus checks whether an awaited transaction reached the requested
+// number of confirmations or is gone, returning nil if it's still pending.
+func (s *PublicTransactionPoolAPI) awaitStatus(txHash common.Hash, confirmations uint64) *AwaitTransactionResult {
+	blockHash, blockNumber, _, err := getTransactionBlockData(s.b.ChainDb(), txHash)
+	if err == nil {
+		if confs := transactionConfirmations(s.b, blockHash, blockNumber); confs > 0 {
+			if confs < confirmations {
+				return nil
+			}
+			return &AwaitTransactionResult{
+				BlockHash:     blockHash,
+				BlockNumber:   rpc.NewHexNumber(blockNumber),
+				Confirmations: rpc.NewHexNumber(confs),
+			}
+		}
+	}
+	if s.b.GetPoolTransaction(txHash) != nil {
+		return nil
+	}
+	return &AwaitTransactionResult{Error: fmt.Sprintf("transaction %x dropped or replaced", txHash)}
+}
+
+// sigHash returns the hash to be signed for a transaction. If a chain id is given
+// the signature will be replay protected, otherwise the legacy scheme is used.
+func sigHash(tx *types.Transaction, chainId *big.Int) common.Hash {
+	if chainId == nil {
+		return tx.SigHash()
+	}
+	return tx.ProtectedSigHash(chainId)
+}
+
+// withSignature returns a copy of the transaction with the given signature, made
+// over the hash returned by sigHash for the same chain id.
+func withSignature(tx *types.Transaction, signature []byte, chainId *big.Int) (*types.Transaction, error) {
+	if chainId == nil {
+		return tx.WithSignature(signature)
+	}
+	return tx.WithProtectedSignature(signature, chainId)
+}
+
 // sign is a helper function that signs a transaction with the private key of the given address.
-func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
-	signature, err := s.b.AccountManager().Sign(addr, tx.SigHash().Bytes())
+// If chainId is non-nil the signature is bound to it, protecting against replays on other chains.
+func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transaction, chainId *big.Int) (*types.Transaction, error) {
+	signature, err := s.b.AccountManager().Sign(addr, sigHash(tx, chainId).Bytes())
 	if err != nil {
-		return nil, err
+		return nil, accountErr(err)
+	}
+	return withSignature(tx, signature, chainId)
+}
+
+// accountErr converts account manager failures into an unauthorized RPC error,
+// so clients can tell a locked or unknown account apart from other failures.
+func accountErr(err error) error {
+	switch err {
+	case accounts.ErrLocked, accounts.ErrNoMatch, accounts.ErrDecrypt:
+		return rpc.ErrUnauthorized("%v", err)
 	}
-	return tx.WithSignature(signature)
+	return err
 }
 
 // SendTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
@@ -944,12 +1940,13 @@ type SendTxArgs struct {
 	Value    *rpc.HexNumber  `json:"value"`
 	Data     string          `json:"data"`
 	Nonce    *rpc.HexNumber  `json:"nonce"`
+	ChainId  *rpc.HexNumber  `json:"chainId"` // Chain id to bind the signature to, nil for legacy signing
 }
 
 // prepareSendTxArgs is a helper function that fills in default values for unspecified tx fields.
 func prepareSendTxArgs(ctx context.Context, args SendTxArgs, b Backend) (SendTxArgs, error) {
 	if args.Gas == nil {
-		args.Gas = rpc.NewHexNumber(defaultGas)
+		args.Gas = defaultTxGas(b, args.To, args.Data)
 	}
 	if args.GasPrice == nil {
 		price, err := b.SuggestPrice(ctx)
@@ -964,12 +1961,30 @@ func prepareSendTxArgs(ctx context.Context, args SendTxArgs, b Backend) (SendTxA
 	return args, nil
 }
 
+// checkGasPrice ensures the gas price of a transaction meets the minimum of the
+// pool for its sender, failing with core.ErrCheap like the pool does. Local
+// transactions bypass the pool's own check, so without it they'd be accepted but
+// never picked up by the miner.
+func checkGasPrice(b Backend, tx *types.Transaction) error {
+	min := b.MinGasPrice()
+	if from, err := tx.From(); err == nil {
+		min = b.SenderMinGasPrice(from)
+	}
+	if tx.GasPrice().Cmp(min) < 0 {
+		return core.ErrCheap
+	}
+	return nil
+}
+
 // submitTransaction is a helper function that submits tx to txPool and creates a log entry.
-func submitTransaction(ctx context.Context, b Backend, tx *types.Transaction, signature []byte) (common.Hash, error) {
-	signedTx, err := tx.WithSignature(signature)
+func submitTransaction(ctx context.Context, b Backend, tx *types.Transaction, signature []byte, chainId *big.Int) (common.Hash, error) {
+	signedTx, err := withSignature(tx, signature, chainId)
 	if err != nil {
 		return common.Hash{}, err
 	}
+	if err := checkGasPrice(b, signedTx); err != nil {
+		return common.Hash{}, err
+	}
 
 	if err := b.SendTx(ctx, signedTx); err != nil {
 		return common.Hash{}, err
@@ -989,6 +2004,11 @@ func submitTransaction(ctx context.Context, b Backend, tx *types.Transaction, si
 // SendTransaction creates a transaction for the given argument, sign it and submit it to the
 // transaction pool.
 func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args SendTxArgs) (common.Hash, error) {
+	// Hold the sender's lock until the transaction is in the pool, so that
+	// concurrent sends can't pick the same nonce
+	nonceLock.LockAddr(args.From)
+	defer nonceLock.UnlockAddr(args.From)
+
 	var err error
 	args, err = prepareSendTxArgs(ctx, args, s.b)
 	if err != nil {
@@ -1010,19 +2030,56 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 		tx = types.NewTransaction(args.Nonce.Uint64(), *args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
 	}
 
-	signature, err := s.b.AccountManager().Sign(args.From, tx.SigHash().Bytes())
+	signature, err := s.b.AccountManager().Sign(args.From, sigHash(tx, args.ChainId.BigInt()).Bytes())
 	if err != nil {
-		return common.Hash{}, err
+		return common.Hash{}, accountErr(err)
 	}
 
-	return submitTransaction(ctx, s.b, tx, signature)
+	return submitTransaction(ctx, s.b, tx, signature, args.ChainId.BigInt())
+}
+
+// errEmptyRawTransaction is returned if SendRawTransaction is called without any
+// transaction data.
+var errEmptyRawTransaction = rpc.ErrInvalidArgs("empty raw transaction")
+
+// decodeRawTransaction decodes a hex encoded signed transaction.
+func decodeRawTransaction(encodedTx string) (*types.Transaction, error) {
+	data := common.FromHex(encodedTx)
+	if len(data) == 0 {
+		return nil, errEmptyRawTransaction
+	}
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(data, tx); err != nil {
+		return nil, rpc.ErrInvalidArgs("failed to RLP-decode transaction (got %d bytes), expected list [nonce, gasPrice, gas, to, value, data, v, r, s]: %v", len(data), err)
+	}
+	return tx, nil
+}
+
+// checkReplayProtection rejects replayable transactions if the node is configured
+// to do so.
+func checkReplayProtection(b Backend, tx *types.Transaction) error {
+	if chainId := b.StrictChainId(); chainId != nil {
+		if !tx.Protected() {
+			return rpc.ErrInvalidArgs("transaction not replay protected, chain id %v required", chainId)
+		}
+		if tx.ChainId().Cmp(chainId) != 0 {
+			return rpc.ErrInvalidArgs("transaction signed for chain id %v, chain id %v required", tx.ChainId(), chainId)
+		}
+	}
+	return nil
 }
 
 // SendRawTransaction will add the signed transaction to the transaction pool.
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encodedTx string) (string, error) {
-	tx := new(types.Transaction)
-	if err := rlp.DecodeBytes(common.FromHex(encodedTx), tx); err != nil {
+	tx, err := decodeRawTransaction(encodedTx)
+	if err != nil {
+		return "", err
+	}
+	if err := checkReplayProtection(s.b, tx); err != nil {
+		return "", err
+	}
+	if err := checkGasPrice(s.b, tx); err != nil {
 		return "", err
 	}
 
@@ -1044,11 +2101,51 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 	return tx.Hash().Hex(), nil
 }
 
+// ValidationResult is the outcome of validating a signed transaction.
+type ValidationResult struct {
+	Valid  bool            `json:"valid"`
+	Reason string          `json:"reason,omitempty"` // Why the transaction would be rejected
+	From   *common.Address `json:"from"`             // Recovered sender, nil if the signature is invalid
+}
+
+// ValidateRawTransaction runs the checks SendRawTransaction would on a signed
+// transaction (signature, replay protection, gas price, nonce, balance, ...)
+// without adding it to the transaction pool. Failed checks are reported in the
+// result, an error is only returned if the transaction cannot be decoded.
+func (s *PublicTransactionPoolAPI) ValidateRawTransaction(ctx context.Context, encodedTx string) (ValidationResult, error) {
+	tx, err := decodeRawTransaction(encodedTx)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	from, err := tx.From()
+	if err != nil {
+		return ValidationResult{Reason: fmt.Sprintf("%v: %v", core.ErrInvalidSender, err)}, nil
+	}
+	result := ValidationResult{From: &from}
+	if err := checkReplayProtection(s.b, tx); err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+	if err := checkGasPrice(s.b, tx); err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+	if err := s.b.ValidateTx(ctx, tx); err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+	result.Valid = true
+	return result, nil
+}
+
 // Sign signs the given hash using the key that matches the address. The key must be
 // unlocked in order to sign the hash.
 func (s *PublicTransactionPoolAPI) Sign(addr common.Address, hash common.Hash) (string, error) {
-	signature, error := s.b.AccountManager().Sign(addr, hash[:])
-	return common.ToHex(signature), error
+	signature, err := s.b.AccountManager().Sign(addr, hash[:])
+	if err != nil {
+		return "", accountErr(err)
+	}
+	return common.ToHex(signature), nil
 }
 
 // SignTransactionArgs represents the arguments to sign a transaction.
@@ -1060,6 +2157,11 @@ type SignTransactionArgs struct {
 	Gas      *rpc.HexNumber
 	GasPrice *rpc.HexNumber
 	Data     string
+	ChainId  *rpc.HexNumber // Chain id to bind the signature to, nil for legacy signing
+
+	MethodSignature string        // Contract method to encode into Data if empty, e.g. "transfer(address,uint256)"
+	Args            []interface{} // Arguments of the contract method
+	ABI             string        // JSON ABI of the contract to resolve the method against, optional
 
 	BlockNumber int64
 }
@@ -1076,6 +2178,7 @@ type Tx struct {
 	GasLimit *rpc.HexNumber  `json:"gas"`
 	GasPrice *rpc.HexNumber  `json:"gasPrice"`
 	Hash     common.Hash     `json:"hash"`
+	ChainId  *rpc.HexNumber  `json:"chainId,omitempty"`
 }
 
 // UnmarshalJSON parses JSON data into tx.
@@ -1089,6 +2192,7 @@ func (tx *Tx) UnmarshalJSON(b []byte) (err error) {
 		GasLimit *rpc.HexNumber  `json:"gas"`
 		GasPrice *rpc.HexNumber  `json:"gasPrice"`
 		Hash     common.Hash     `json:"hash"`
+		ChainId  *rpc.HexNumber  `json:"chainId"`
 	}{}
 
 	if err := json.Unmarshal(b, &req); err != nil {
@@ -1103,8 +2207,9 @@ func (tx *Tx) UnmarshalJSON(b []byte) (err error) {
 	tx.GasLimit = req.GasLimit
 	tx.GasPrice = req.GasPrice
 	tx.Hash = req.Hash
+	tx.ChainId = req.ChainId
 
-	data := common.Hex2Bytes(tx.Data)
+	data := common.FromHex(tx.Data)
 
 	if tx.Nonce == nil {
 		return fmt.Errorf("need nonce")
@@ -1128,14 +2233,21 @@ func (tx *Tx) UnmarshalJSON(b []byte) (err error) {
 	return nil
 }
 
-// SignTransactionResult represents a RLP encoded signed transaction.
+// SignTransactionResult represents a RLP encoded signed transaction. If the
+// transaction was signed for a specific chain, RawLegacy additionally holds the
+// same transaction signed without replay protection.
 type SignTransactionResult struct {
-	Raw string `json:"raw"`
-	Tx  *Tx    `json:"tx"`
+	Raw       string `json:"raw"`
+	RawLegacy string `json:"rawLegacy,omitempty"`
+	Tx        *Tx    `json:"tx"`
 }
 
 func newTx(t *types.Transaction) *Tx {
 	from, _ := t.FromFrontier()
+	var chainId *rpc.HexNumber
+	if t.Protected() {
+		chainId = rpc.NewHexNumber(t.ChainId())
+	}
 	return &Tx{
 		tx:       t,
 		To:       t.To(),
@@ -1146,18 +2258,15 @@ func newTx(t *types.Transaction) *Tx {
 		GasLimit: rpc.NewHexNumber(t.Gas()),
 		GasPrice: rpc.NewHexNumber(t.GasPrice()),
 		Hash:     t.Hash(),
+		ChainId:  chainId,
 	}
 }
 
-// SignTransaction will sign the given transaction with the from account.
-// The node needs to have the private key of the account corresponding with
-// the given from address and it needs to be unlocked.
-func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args SignTransactionArgs) (*SignTransactionResult, error) {
-	if args.Gas == nil {
-		args.Gas = rpc.NewHexNumber(defaultGas)
-	}
+// assembleTransaction is a helper function that fills in default values for the
+// unspecified fields of args and creates the unsigned transaction from them.
+func assembleTransaction(ctx context.Context, b Backend, args SignTransactionArgs) (*types.Transaction, error) {
 	if args.GasPrice == nil {
-		price, err := s.b.SuggestPrice(ctx)
+		price, err := b.SuggestPrice(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -1166,23 +2275,86 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sig
 	if args.Value == nil {
 		args.Value = rpc.NewHexNumber(0)
 	}
+	if args.Data == "" && args.MethodSignature != "" {
+		data, err := packCallData(args.MethodSignature, args.ABI, args.Args)
+		if err != nil {
+			return nil, rpc.ErrInvalidArgs("failed to encode %s call: %v", args.MethodSignature, err)
+		}
+		args.Data = common.ToHex(data)
+	}
+	if args.Gas == nil {
+		args.Gas = defaultTxGas(b, args.To, args.Data)
+	}
 
 	if args.Nonce == nil {
-		nonce, err := s.b.GetPoolNonce(ctx, args.From)
+		nonce, err := b.GetPoolNonce(ctx, args.From)
 		if err != nil {
 			return nil, err
 		}
 		args.Nonce = rpc.NewHexNumber(nonce)
 	}
 
-	var tx *types.Transaction
 	if args.To == nil {
-		tx = types.NewContractCreation(args.Nonce.Uint64(), args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
-	} else {
-		tx = types.NewTransaction(args.Nonce.Uint64(), *args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data))
+		return types.NewContractCreation(args.Nonce.Uint64(), args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data)), nil
 	}
+	return types.NewTransaction(args.Nonce.Uint64(), *args.To, args.Value.BigInt(), args.Gas.BigInt(), args.GasPrice.BigInt(), common.FromHex(args.Data)), nil
+}
 
-	signedTx, err := s.sign(args.From, tx)
+// SignTransaction will sign the given transaction with the from account.
+// The node needs to have the private key of the account corresponding with
+// the given from address and it needs to be unlocked.
+func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args SignTransactionArgs) (*SignTransactionResult, error) {
+	nonceLock.LockAddr(args.From)
+	defer nonceLock.UnlockAddr(args.From)
+
+	return s.signTransaction(ctx, args)
+}
+
+// SignTransactions signs a batch of transactions, holding the nonce locks of all
+// senders for the duration of the batch. Omitted nonces are assigned sequentially
+// per sender across the batch. If any transaction fails, the batch is aborted.
+func (s *PublicTransactionPoolAPI) SignTransactions(ctx context.Context, args []*SignTransactionArgs) ([]*SignTransactionResult, error) {
+	senders := make([]common.Address, 0, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			return nil, rpc.ErrInvalidArgs("transaction %d: missing arguments", i)
+		}
+		senders = append(senders, arg.From)
+	}
+	nonceLock.LockAddrs(senders)
+	defer nonceLock.UnlockAddrs(senders)
+
+	var (
+		nonces  = make(map[common.Address]uint64)
+		results = make([]*SignTransactionResult, len(args))
+	)
+	for i, arg := range args {
+		txArgs := *arg
+		if nonce, ok := nonces[txArgs.From]; ok && txArgs.Nonce == nil {
+			txArgs.Nonce = rpc.NewHexNumber(nonce)
+		}
+		result, err := s.signTransaction(ctx, txArgs)
+		if err != nil {
+			return nil, rpc.ErrWrap(err, "transaction %d", i)
+		}
+		nonces[txArgs.From] = result.Tx.tx.Nonce() + 1
+		results[i] = result
+	}
+	return results, nil
+}
+
+// signTransaction assembles and signs a transaction. The caller must hold the
+// nonce lock of the sender.
+func (s *PublicTransactionPoolAPI) signTransaction(ctx context.Context, args SignTransactionArgs) (*SignTransactionResult, error) {
+	// Reject unmanaged senders before any work is done on the transaction
+	if !s.b.AccountManager().HasAddress(args.From) {
+		return nil, rpc.ErrUnauthorized("unknown account %s", args.From.Hex())
+	}
+	tx, err := assembleTransaction(ctx, s.b, args)
+	if err != nil {
+		return nil, err
+	}
+	signedTx, err := s.sign(args.From, tx, args.ChainId.BigInt())
 	if err != nil {
 		return nil, err
 	}
@@ -1191,8 +2363,84 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sig
 	if err != nil {
 		return nil, err
 	}
+	result := &SignTransactionResult{Raw: "0x" + common.Bytes2Hex(data), Tx: newTx(signedTx)}
+
+	// Callers relaying through replay unaware channels may need the legacy form too
+	if args.ChainId != nil {
+		legacyTx, err := s.sign(args.From, tx, nil)
+		if err != nil {
+			return nil, err
+		}
+		legacy, err := rlp.EncodeToBytes(legacyTx)
+		if err != nil {
+			return nil, err
+		}
+		result.RawLegacy = "0x" + common.Bytes2Hex(legacy)
+	}
+	return result, nil
+}
+
+// UnsignedTransactionResult represents a transaction assembled for external
+// signing, along with the hash the signature needs to be made over.
+type UnsignedTransactionResult struct {
+	Tx      *Tx    `json:"tx"`
+	SigHash string `json:"sigHash"`
+}
+
+// BuildUnsignedTransaction fills in the default values for the unspecified fields
+// of the given transaction and returns it unsigned, along with the hash to sign.
+// No private keys are accessed, allowing the signing to be done offline, after
+// which the transaction can be submitted through SubmitSignedTransaction.
+func (s *PublicTransactionPoolAPI) BuildUnsignedTransaction(ctx context.Context, args SignTransactionArgs) (*UnsignedTransactionResult, error) {
+	tx, err := assembleTransaction(ctx, s.b, args)
+	if err != nil {
+		return nil, err
+	}
+	result := newTx(tx)
+	result.From, result.ChainId = args.From, args.ChainId
+
+	return &UnsignedTransactionResult{
+		Tx:      result,
+		SigHash: sigHash(tx, args.ChainId.BigInt()).Hex(),
+	}, nil
+}
+
+// SubmitSignedTransaction attaches the given hex encoded 65 byte [R || S || V]
+// signature to a transaction built by BuildUnsignedTransaction and submits it
+// to the transaction pool. The recovery id V may be either 0/1 or 27/28.
+func (s *PublicTransactionPoolAPI) SubmitSignedTransaction(ctx context.Context, tx *Tx, signature string) (common.Hash, error) {
+	if tx == nil || tx.tx == nil {
+		return common.Hash{}, rpc.ErrInvalidArgs("missing transaction")
+	}
+	sig := common.FromHex(signature)
+	if len(sig) != 65 {
+		return common.Hash{}, rpc.ErrInvalidArgs("invalid signature length: got %d bytes, want 65", len(sig))
+	}
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	if sig[64] > 1 {
+		return common.Hash{}, rpc.ErrInvalidArgs("invalid signature recovery id %d", sig[64])
+	}
+	return submitTransaction(ctx, s.b, tx.tx, sig, tx.ChainId.BigInt())
+}
 
-	return &SignTransactionResult{"0x" + common.Bytes2Hex(data), newTx(signedTx)}, nil
+// MinGasPrice returns the minimum gas price transactions need to pay to be
+// accepted into the transaction pool.
+func (s *PublicTransactionPoolAPI) MinGasPrice() *rpc.HexNumber {
+	return rpc.NewHexNumber(s.b.MinGasPrice())
+}
+
+// GetContractAddress returns the address of the contract created by a transaction
+// sent from the given account with the given nonce.
+func (s *PublicTransactionPoolAPI) GetContractAddress(from common.Address, nonce rpc.HexNumber) common.Address {
+	return crypto.CreateAddress(from, nonce.Uint64())
+}
+
+// GetCreate2Address returns the address of the contract created by the CREATE2
+// opcode executed by the given account with the given salt and init code hash.
+func (s *PublicTransactionPoolAPI) GetCreate2Address(from common.Address, salt common.Hash, initCodeHash common.Hash) common.Address {
+	return crypto.CreateAddress2(from, salt, initCodeHash.Bytes())
 }
 
 // PendingTransactions returns the transactions that are in the transaction pool and have a from address that is one of
@@ -1203,7 +2451,7 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() []*RPCTransaction {
 	for _, tx := range pending {
 		from, _ := tx.FromFrontier()
 		if s.b.AccountManager().HasAddress(from) {
-			transactions = append(transactions, newRPCPendingTransaction(tx))
+			transactions = append(transactions, NewRPCPendingTransaction(tx))
 		}
 	}
 	return transactions
@@ -1212,6 +2460,30 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() []*RPCTransaction {
 // Resend accepts an existing transaction and a new gas price and limit. It will remove the given transaction from the
 // pool and reinsert it with the new gas price and limit.
 func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, tx Tx, gasPrice, gasLimit *rpc.HexNumber) (common.Hash, error) {
+	result, err := s.ResendDetailed(ctx, tx, gasPrice, gasLimit)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return result.NewHash, nil
+}
+
+// ResendResult describes a pool transaction replaced by Resend, allowing callers
+// to update their records of it.
+type ResendResult struct {
+	OldHash     common.Hash    `json:"oldHash"`
+	NewHash     common.Hash    `json:"newHash"`
+	OldGasPrice *rpc.HexNumber `json:"oldGasPrice"`
+	NewGasPrice *rpc.HexNumber `json:"newGasPrice"`
+}
+
+// ResendDetailed works like Resend, but reports both the replaced and the new
+// transaction. The replacement is announced through a core.TxBumpEvent.
+func (s *PublicTransactionPoolAPI) ResendDetailed(ctx context.Context, tx Tx, gasPrice, gasLimit *rpc.HexNumber) (*ResendResult, error) {
+	// Prevent a concurrent send from the same account while the replacement is
+	// signed and the original swapped out of the pool
+	nonceLock.LockAddr(tx.From)
+	defer nonceLock.UnlockAddr(tx.From)
+
 	pending := s.b.GetPoolTransactions()
 	for _, p := range pending {
 		if pFrom, err := p.FromFrontier(); err == nil && pFrom == tx.From && p.SigHash() == tx.tx.SigHash() {
@@ -1229,21 +2501,31 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, tx Tx, gasPrice,
 				newTx = types.NewTransaction(tx.tx.Nonce(), *tx.tx.To(), tx.tx.Value(), gasLimit.BigInt(), gasPrice.BigInt(), tx.tx.Data())
 			}
 
-			signedTx, err := s.sign(tx.From, newTx)
+			var chainId *big.Int
+			if p.Protected() {
+				chainId = p.ChainId()
+			}
+			signedTx, err := s.sign(tx.From, newTx, chainId)
 			if err != nil {
-				return common.Hash{}, err
+				return nil, err
 			}
 
 			s.b.RemoveTx(tx.Hash)
 			if err = s.b.SendTx(ctx, signedTx); err != nil {
-				return common.Hash{}, err
+				return nil, err
 			}
-
-			return signedTx.Hash(), nil
+			go s.b.EventMux().Post(core.TxBumpEvent{Old: p, New: signedTx})
+
+			return &ResendResult{
+				OldHash:     p.Hash(),
+				NewHash:     signedTx.Hash(),
+				OldGasPrice: rpc.NewHexNumber(p.GasPrice()),
+				NewGasPrice: rpc.NewHexNumber(signedTx.GasPrice()),
+			}, nil
 		}
 	}
 
-	return common.Hash{}, fmt.Errorf("Transaction %#x not found", tx.Hash)
+	return nil, rpc.ErrNotFound("Transaction %#x not found", tx.Hash)
 }
 
 // PublicDebugAPI is the collection of Etheruem APIs exposed over the public
@@ -1262,7 +2544,7 @@ func NewPublicDebugAPI(b Backend) *PublicDebugAPI {
 func (api *PublicDebugAPI) GetBlockRlp(ctx context.Context, number uint64) (string, error) {
 	block, _ := api.b.BlockByNumber(ctx, rpc.BlockNumber(number))
 	if block == nil {
-		return "", fmt.Errorf("block #%d not found", number)
+		return "", rpc.ErrNotFound("block #%d not found", number)
 	}
 	encoded, err := rlp.EncodeToBytes(block)
 	if err != nil {
@@ -1275,7 +2557,7 @@ func (api *PublicDebugAPI) GetBlockRlp(ctx context.Context, number uint64) (stri
 func (api *PublicDebugAPI) PrintBlock(ctx context.Context, number uint64) (string, error) {
 	block, _ := api.b.BlockByNumber(ctx, rpc.BlockNumber(number))
 	if block == nil {
-		return "", fmt.Errorf("block #%d not found", number)
+		return "", rpc.ErrNotFound("block #%d not found", number)
 	}
 	return fmt.Sprintf("%s", block), nil
 }
@@ -1284,7 +2566,7 @@ func (api *PublicDebugAPI) PrintBlock(ctx context.Context, number uint64) (strin
 func (api *PublicDebugAPI) SeedHash(ctx context.Context, number uint64) (string, error) {
 	block, _ := api.b.BlockByNumber(ctx, rpc.BlockNumber(number))
 	if block == nil {
-		return "", fmt.Errorf("block #%d not found", number)
+		return "", rpc.ErrNotFound("block #%d not found", number)
 	}
 	hash, err := ethash.GetSeedHash(number)
 	if err != nil {
@@ -0,0 +1,1938 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"golang.org/x/net/context"
+)
+
+// Tests that the access list of a call contains every storage slot the contract
+// reads, attributed to the contract itself.
+func TestCreateAccessList(t *testing.T) {
+	// Contract returning the sum of storage slots 1 and 2
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	code := []byte{
+		byte(vm.PUSH1), 0x01, byte(vm.SLOAD),
+		byte(vm.PUSH1), 0x02, byte(vm.SLOAD),
+		byte(vm.ADD),
+		byte(vm.PUSH1), 0x00, byte(vm.MSTORE),
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN),
+	}
+	backend := newTestBackend(t, []testAccount{{
+		Address: contract,
+		Code:    code,
+		Storage: map[common.Hash]common.Hash{
+			common.BigToHash(common.Big1): common.BigToHash(common.Big2),
+			common.BigToHash(common.Big2): common.BigToHash(common.Big3),
+		},
+	}}, 0, nil)
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+	result, err := api.CreateAccessList(context.Background(), CallArgs{From: testBankAddress, To: &contract}, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to create access list: %v", err)
+	}
+	if len(result.AccessList) != 1 {
+		t.Fatalf("access list length mismatch: have %d, want 1", len(result.AccessList))
+	}
+	if result.AccessList[0].Address != contract {
+		t.Errorf("accessed address mismatch: have %x, want %x", result.AccessList[0].Address, contract)
+	}
+	keys := result.AccessList[0].StorageKeys
+	if len(keys) != 2 || keys[0] != common.BigToHash(common.Big1) || keys[1] != common.BigToHash(common.Big2) {
+		t.Errorf("storage keys mismatch: have %x, want [1, 2]", keys)
+	}
+	if result.GasUsed.Int64() == 0 {
+		t.Errorf("expected non-zero gas usage")
+	}
+}
+
+// Tests that fee advice reports the pool nonce and any pooled transactions of
+// an account that would need replacing at the suggested price.
+func TestSuggestFees(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+	price, _ := backend.SuggestPrice(context.Background())
+
+	// Without any pooled transactions the advice should be a clean slate
+	advice, err := api.SuggestFees(context.Background(), testBankAddress)
+	if err != nil {
+		t.Fatalf("failed to retrieve fee advice: %v", err)
+	}
+	if advice.GasPrice.BigInt().Cmp(price) != 0 {
+		t.Errorf("gas price mismatch: have %v, want %v", advice.GasPrice.BigInt(), price)
+	}
+	if advice.Nonce.Uint64() != 0 || advice.Pending.Int() != 0 || advice.NeedsBump {
+		t.Errorf("unexpected advice for idle account: nonce %d, pending %d, bump %v", advice.Nonce.Uint64(), advice.Pending.Int(), advice.NeedsBump)
+	}
+	// Pool an underpriced transaction and ensure it's reported
+	tx, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+	if err := backend.SendTx(context.Background(), tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if advice, err = api.SuggestFees(context.Background(), testBankAddress); err != nil {
+		t.Fatalf("failed to retrieve fee advice: %v", err)
+	}
+	if advice.Nonce.Uint64() != 1 || advice.Pending.Int() != 1 || !advice.NeedsBump {
+		t.Errorf("unexpected advice for busy account: nonce %d, pending %d, bump %v", advice.Nonce.Uint64(), advice.Pending.Int(), advice.NeedsBump)
+	}
+}
+
+// Tests that receipts report a growing number of confirmations as the chain
+// progresses, dropping to zero once the containing block is reorged out.
+func TestReceiptConfirmations(t *testing.T) {
+	tx, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+	backend := newTestBackend(t, nil, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(tx)
+	})
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+	confirmations := func() uint64 {
+		fields, err := api.GetTransactionReceipt(tx.Hash())
+		if err != nil || fields == nil {
+			t.Fatalf("failed to retrieve receipt: %v", err)
+		}
+		return fields["confirmations"].(*rpc.HexNumber).Uint64()
+	}
+	if have := confirmations(); have != 1 {
+		t.Errorf("confirmations mismatch after inclusion: have %d, want 1", have)
+	}
+	// Advance the head and check that confirmations grow
+	blocks, _ := core.GenerateChain(nil, backend.chain.CurrentBlock(), backend.db, 2, nil)
+	if _, err := backend.chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to extend chain: %v", err)
+	}
+	if have := confirmations(); have != 3 {
+		t.Errorf("confirmations mismatch after extension: have %d, want 3", have)
+	}
+	// Rewind the chain and replace the transaction's block with a fork
+	backend.chain.SetHead(0)
+	fork, _ := core.GenerateChain(nil, backend.chain.Genesis(), backend.db, 3, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{0x02})
+	})
+	if _, err := backend.chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	if have := confirmations(); have != 0 {
+		t.Errorf("confirmations mismatch after reorg: have %d, want 0", have)
+	}
+}
+
+// Tests that canonical hashes match the full block lookups, also at heights that
+// were reorged, and are zero above the chain head.
+func TestGetCanonicalHash(t *testing.T) {
+	backend := newTestBackend(t, nil, 3, nil)
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+	check := func(head uint64) {
+		for number := uint64(0); number <= head+2; number++ {
+			hash, err := api.GetCanonicalHash(number)
+			if err != nil {
+				t.Fatalf("block #%d: failed to retrieve canonical hash: %v", number, err)
+			}
+			want := common.Hash{}
+			if number <= head {
+				block, err := api.GetBlockByNumber(context.Background(), rpc.BlockNumber(number), false)
+				if err != nil || block == nil {
+					t.Fatalf("block #%d: failed to retrieve block: %v", number, err)
+				}
+				want = block["hash"].(common.Hash)
+			}
+			if hash != want {
+				t.Errorf("block #%d: canonical hash mismatch: have %x, want %x", number, hash, want)
+			}
+		}
+	}
+	check(3)
+	reorged := backend.chain.GetBlockByNumber(2)
+
+	// Reorg the last two blocks away with a longer fork
+	fork, _ := core.GenerateChain(nil, backend.chain.GetBlockByNumber(1), backend.db, 3, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{0x02})
+	})
+	if _, err := backend.chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	check(4)
+	if hash, _ := api.GetCanonicalHash(2); hash == reorged.Hash() {
+		t.Errorf("reorged block #2 still canonical")
+	}
+}
+
+// Tests that gas statistics are correctly aggregated over a block range.
+func TestGasStats(t *testing.T) {
+	prices := [][]int64{nil, {10}, {20, 5, 30}}
+	backend := newTestBackend(t, nil, len(prices), func(i int, gen *core.BlockGen) {
+		for _, price := range prices[i] {
+			tx, _ := types.NewTransaction(gen.TxNonce(testBankAddress), common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(price), nil).SignECDSA(testBankKey)
+			gen.AddTx(tx)
+		}
+	})
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+	stats, err := api.GasStats(context.Background(), 1, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to retrieve gas stats: %v", err)
+	}
+	gasLimit := new(big.Int)
+	for i := uint64(1); i <= 3; i++ {
+		gasLimit.Add(gasLimit, backend.chain.GetHeaderByNumber(i).GasLimit)
+	}
+	if have, want := stats.AvgGasUsed.Int64(), int64(28000); have != want {
+		t.Errorf("average gas used mismatch: have %d, want %d", have, want)
+	}
+	if have, want := stats.AvgGasLimit.BigInt(), new(big.Int).Div(gasLimit, big.NewInt(3)); have.Cmp(want) != 0 {
+		t.Errorf("average gas limit mismatch: have %v, want %v", have, want)
+	}
+	if len(stats.GasPrices) != 3 {
+		t.Fatalf("gas price entry count mismatch: have %d, want 3", len(stats.GasPrices))
+	}
+	if entry := stats.GasPrices[0]; entry.Min != nil || entry.Median != nil || entry.Max != nil {
+		t.Errorf("empty block prices mismatch: have %v/%v/%v, want nil", entry.Min, entry.Median, entry.Max)
+	}
+	if entry := stats.GasPrices[2]; entry.Min.Int64() != 5 || entry.Median.Int64() != 20 || entry.Max.Int64() != 30 {
+		t.Errorf("block prices mismatch: have %v/%v/%v, want 5/20/30", entry.Min, entry.Median, entry.Max)
+	}
+	// Ensure overly large ranges are rejected
+	if _, err := api.GasStats(context.Background(), 0, maxGasStatsBlocks); err == nil {
+		t.Errorf("expected error for oversized range")
+	}
+}
+
+// Tests that the fee history reports the gas weighted price percentiles of each
+// block, with empty blocks yielding no rewards.
+func TestFeeHistory(t *testing.T) {
+	prices := [][]int64{{40, 10, 30, 20}, nil, {7}}
+	backend := newTestBackend(t, nil, len(prices), func(i int, gen *core.BlockGen) {
+		for _, price := range prices[i] {
+			tx, _ := types.NewTransaction(gen.TxNonce(testBankAddress), common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(price), nil).SignECDSA(testBankKey)
+			gen.AddTx(tx)
+		}
+	})
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+	history, err := api.FeeHistory(context.Background(), 3, rpc.LatestBlockNumber, []float64{0, 50, 100})
+	if err != nil {
+		t.Fatalf("failed to retrieve fee history: %v", err)
+	}
+	if history.OldestBlock.Int64() != 1 {
+		t.Errorf("oldest block mismatch: have %d, want 1", history.OldestBlock.Int64())
+	}
+	if len(history.GasUsedRatio) != 3 || len(history.Reward) != 3 {
+		t.Fatalf("result length mismatch: have %d ratios, %d rewards, want 3", len(history.GasUsedRatio), len(history.Reward))
+	}
+	want := [][]int64{{10, 20, 40}, nil, {7, 7, 7}}
+	for i, rewards := range history.Reward {
+		if want[i] == nil {
+			if rewards != nil {
+				t.Errorf("block %d: expected nil rewards, have %v", i, rewards)
+			}
+			if history.GasUsedRatio[i] != 0 {
+				t.Errorf("block %d: expected zero gas used ratio, have %f", i, history.GasUsedRatio[i])
+			}
+			continue
+		}
+		for j, reward := range rewards {
+			if reward.Int64() != want[i][j] {
+				t.Errorf("block %d, percentile %d: reward mismatch: have %d, want %d", i, j, reward.Int64(), want[i][j])
+			}
+		}
+	}
+	// Ensure invalid percentiles are rejected
+	if _, err := api.FeeHistory(context.Background(), 1, rpc.LatestBlockNumber, []float64{50, 10}); err == nil {
+		t.Errorf("expected error for decreasing percentiles")
+	}
+}
+
+// Tests that transactions can be signed with replay protection on demand, and
+// that ones bound to a foreign chain id are rejected.
+func TestSendTransactionChainId(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	backend.config.EIP155Block = big.NewInt(0)
+	backend.config.ChainId = big.NewInt(1)
+	if _, err := backend.am.ImportECDSA(testBankKey, "secret"); err != nil {
+		t.Fatalf("failed to import test key: %v", err)
+	}
+	api := NewPrivateAccountAPI(backend)
+	send := func(chainId *rpc.HexNumber) (*types.Transaction, error) {
+		to := common.Address{0x01}
+		hash, err := api.SendTransaction(context.Background(), SendTxArgs{From: testBankAddress, To: &to, ChainId: chainId}, "secret")
+		if err != nil {
+			return nil, err
+		}
+		return backend.pool.Get(hash), nil
+	}
+	// Both legacy and protected transactions for the local chain should be accepted
+	for i, chainId := range []*rpc.HexNumber{nil, rpc.NewHexNumber(1)} {
+		tx, err := send(chainId)
+		if err != nil {
+			t.Fatalf("tx %d: failed to send transaction: %v", i, err)
+		}
+		if tx.Protected() != (chainId != nil) {
+			t.Errorf("tx %d: protection mismatch: have %v, want %v", i, tx.Protected(), chainId != nil)
+		}
+		if from, err := tx.From(); err != nil || from != testBankAddress {
+			t.Errorf("tx %d: sender mismatch: have %x (%v), want %x", i, from, err, testBankAddress)
+		}
+	}
+	// Transactions bound to a different chain should be rejected
+	if _, err := send(rpc.NewHexNumber(2)); err != core.ErrInvalidChainId {
+		t.Errorf("error mismatch: have %v, want %v", err, core.ErrInvalidChainId)
+	}
+}
+
+// Tests that changing the password of an account re-encrypts its key, while a
+// wrong old password leaves the key file untouched.
+func TestChangePassword(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	account, err := backend.am.ImportECDSA(testBankKey, "old")
+	if err != nil {
+		t.Fatalf("failed to import test key: %v", err)
+	}
+	api := NewPrivateAccountAPI(backend)
+
+	// Ensure a wrong old password fails without touching the key file
+	blob, _ := ioutil.ReadFile(account.File)
+	if ok, err := api.ChangePassword(testBankAddress, "wrong", "new"); ok || err != accounts.ErrDecrypt {
+		t.Errorf("wrong password change result mismatch: have %v, %v, want false, %v", ok, err, accounts.ErrDecrypt)
+	}
+	if after, _ := ioutil.ReadFile(account.File); !bytes.Equal(blob, after) {
+		t.Errorf("key file modified by failed password change")
+	}
+	if _, err := api.ChangePassword(common.Address{0x01}, "old", "new"); err == nil {
+		t.Errorf("password of unknown account changed")
+	}
+	// Change the password and ensure only the new one unlocks the account
+	if ok, err := api.ChangePassword(testBankAddress, "old", "new"); !ok || err != nil {
+		t.Fatalf("failed to change password: %v, %v", ok, err)
+	}
+	if err := backend.am.Unlock(account, "old"); err != accounts.ErrDecrypt {
+		t.Errorf("old password unlock error mismatch: have %v, want %v", err, accounts.ErrDecrypt)
+	}
+	if err := backend.am.Unlock(account, "new"); err != nil {
+		t.Errorf("failed to unlock with new password: %v", err)
+	}
+}
+
+// Tests that indefinite unlocks are refused over HTTP if the node restricts them,
+// while bounded unlocks and indefinite ones over local endpoints are allowed.
+func TestRestrictedUnlock(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	if _, err := backend.am.ImportECDSA(testBankKey, "secret"); err != nil {
+		t.Fatalf("failed to import test key: %v", err)
+	}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("personal", NewPrivateAccountAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	remote, err := rpc.DialHTTP(httpServer.URL)
+	if err != nil {
+		t.Fatalf("failed to dial HTTP endpoint: %v", err)
+	}
+	defer remote.Close()
+	local := rpc.DialInProc(server)
+	defer local.Close()
+
+	tests := []struct {
+		restrict bool
+		client   *rpc.Client
+		duration uint64
+		fail     bool
+	}{
+		{restrict: true, client: remote, duration: 0, fail: true},
+		{restrict: true, client: remote, duration: 60},
+		{restrict: true, client: local, duration: 0},
+		{restrict: true, client: local, duration: 60},
+		{restrict: false, client: remote, duration: 0},
+	}
+	for i, tt := range tests {
+		backend.restrictUnlock = tt.restrict
+		backend.am.Lock(testBankAddress)
+
+		var unlocked bool
+		err := tt.client.Call(&unlocked, "personal_unlockAccount", testBankAddress, "secret", rpc.NewHexNumber(tt.duration))
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: restricted unlock succeeded", i)
+			}
+			if _, err := backend.am.Sign(testBankAddress, make([]byte, 32)); err != accounts.ErrLocked {
+				t.Errorf("test %d: account unlocked despite refusal: %v", i, err)
+			}
+			continue
+		}
+		if err != nil || !unlocked {
+			t.Errorf("test %d: unlock failed: %v, %v", i, unlocked, err)
+		}
+	}
+}
+
+// Tests that passwords can be verified without unlocking the account, wrong ones
+// being reported as such and unknown accounts as errors.
+func TestVerifyPassword(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	if _, err := backend.am.ImportECDSA(testBankKey, "secret"); err != nil {
+		t.Fatalf("failed to import test key: %v", err)
+	}
+	api := NewPrivateAccountAPI(backend)
+
+	if ok, err := api.VerifyPassword(testBankAddress, "secret"); !ok || err != nil {
+		t.Errorf("correct password rejected: %v, %v", ok, err)
+	}
+	if ok, err := api.VerifyPassword(testBankAddress, "wrong"); ok || err != nil {
+		t.Errorf("wrong password result mismatch: have %v, %v, want false, nil", ok, err)
+	}
+	if _, err := api.VerifyPassword(common.Address{0x01}, "secret"); err == nil {
+		t.Errorf("unknown account verified")
+	} else if _, ok := err.(*rpc.NotFoundError); !ok {
+		t.Errorf("unknown account error type mismatch: have %T, want *rpc.NotFoundError", err)
+	}
+	if _, err := backend.am.Sign(testBankAddress, make([]byte, 32)); err != accounts.ErrLocked {
+		t.Errorf("account unlocked by verification: %v", err)
+	}
+}
+
+// Tests that the state of all managed accounts is reported at both the latest
+// and the pending block, the latter including transactions still in the pool.
+func TestAccountsWithState(t *testing.T) {
+	var (
+		keyA, _ = crypto.GenerateKey()
+		keyB, _ = crypto.GenerateKey()
+		addrA   = crypto.PubkeyToAddress(keyA.PublicKey)
+		addrB   = crypto.PubkeyToAddress(keyB.PublicKey)
+	)
+	backend := newTestBackend(t, []testAccount{
+		{Address: addrA, Balance: big.NewInt(1000000)},
+		{Address: addrB, Balance: big.NewInt(2000000)},
+	}, 2, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(addrA), addrB, big.NewInt(1000), big.NewInt(21000), new(big.Int), nil).SignECDSA(keyA)
+		block.AddTx(tx)
+	})
+	defer backend.close()
+
+	for _, key := range []*ecdsa.PrivateKey{testBankKey, keyA, keyB} {
+		if _, err := backend.am.ImportECDSA(key, "secret"); err != nil {
+			t.Fatalf("failed to import key: %v", err)
+		}
+	}
+	api := NewPrivateAccountAPI(backend)
+
+	// Queue up a transaction from the bank in the pool
+	to := common.Address{0x01}
+	if _, err := api.SendTransaction(context.Background(), SendTxArgs{From: testBankAddress, To: &to}, "secret"); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+
+	tests := []struct {
+		number rpc.BlockNumber
+		want   map[common.Address]AccountState
+	}{
+		{rpc.LatestBlockNumber, map[common.Address]AccountState{
+			testBankAddress: {Balance: rpc.NewHexNumber(testBankFunds), Nonce: rpc.NewHexNumber(0)},
+			addrA:           {Balance: rpc.NewHexNumber(1000000 - 2*1000), Nonce: rpc.NewHexNumber(2)},
+			addrB:           {Balance: rpc.NewHexNumber(2000000 + 2*1000), Nonce: rpc.NewHexNumber(0)},
+		}},
+		{rpc.PendingBlockNumber, map[common.Address]AccountState{
+			testBankAddress: {Balance: rpc.NewHexNumber(testBankFunds), Nonce: rpc.NewHexNumber(1)},
+			addrA:           {Balance: rpc.NewHexNumber(1000000 - 2*1000), Nonce: rpc.NewHexNumber(2)},
+			addrB:           {Balance: rpc.NewHexNumber(2000000 + 2*1000), Nonce: rpc.NewHexNumber(0)},
+		}},
+	}
+	for i, tt := range tests {
+		states, err := api.AccountsWithState(context.Background(), tt.number)
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve account states: %v", i, err)
+		}
+		if len(states) != len(tt.want) {
+			t.Fatalf("test %d: account count mismatch: have %d, want %d", i, len(states), len(tt.want))
+		}
+		for _, state := range states {
+			want, ok := tt.want[state.Address]
+			if !ok {
+				t.Errorf("test %d: unexpected account %x", i, state.Address)
+				continue
+			}
+			if state.Balance.BigInt().Cmp(want.Balance.BigInt()) != 0 || state.Nonce.Uint64() != want.Nonce.Uint64() {
+				t.Errorf("test %d: account %x mismatch: have balance %v nonce %v, want balance %v nonce %v",
+					i, state.Address, state.Balance.BigInt(), state.Nonce.Uint64(), want.Balance.BigInt(), want.Nonce.Uint64())
+			}
+		}
+	}
+}
+
+// Tests that transactions can be assembled without access to any keys, signed
+// externally and then submitted.
+func TestOfflineSigning(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	backend.config.EIP155Block = big.NewInt(0)
+	backend.config.ChainId = big.NewInt(1)
+	api := NewPublicTransactionPoolAPI(backend)
+
+	for i, chainId := range []*rpc.HexNumber{nil, rpc.NewHexNumber(1)} {
+		to := common.Address{0x01}
+		unsigned, err := api.BuildUnsignedTransaction(context.Background(), SignTransactionArgs{From: testBankAddress, To: &to, Data: "0x0102", ChainId: chainId})
+		if err != nil {
+			t.Fatalf("tx %d: failed to build transaction: %v", i, err)
+		}
+		if unsigned.Tx.Nonce.Uint64() != uint64(i) {
+			t.Errorf("tx %d: nonce mismatch: have %d, want %d", i, unsigned.Tx.Nonce.Uint64(), i)
+		}
+		// Round trip the transaction through JSON as an RPC client would
+		blob, err := json.Marshal(unsigned.Tx)
+		if err != nil {
+			t.Fatalf("tx %d: failed to encode transaction: %v", i, err)
+		}
+		tx := new(Tx)
+		if err := json.Unmarshal(blob, tx); err != nil {
+			t.Fatalf("tx %d: failed to decode transaction: %v", i, err)
+		}
+		// Sign the hash externally and submit the transaction
+		signature, err := crypto.Sign(common.HexToHash(unsigned.SigHash).Bytes(), testBankKey)
+		if err != nil {
+			t.Fatalf("tx %d: failed to sign transaction: %v", i, err)
+		}
+		hash, err := api.SubmitSignedTransaction(context.Background(), tx, common.ToHex(signature))
+		if err != nil {
+			t.Fatalf("tx %d: failed to submit transaction: %v", i, err)
+		}
+		pooled := backend.pool.Get(hash)
+		if pooled == nil {
+			t.Fatalf("tx %d: transaction not pooled", i)
+		}
+		if from, err := pooled.From(); err != nil || from != testBankAddress {
+			t.Errorf("tx %d: sender mismatch: have %x (%v), want %x", i, from, err, testBankAddress)
+		}
+		if pooled.Protected() != (chainId != nil) || len(pooled.Data()) != 2 {
+			t.Errorf("tx %d: pooled transaction mismatch: protected %v, data %x", i, pooled.Protected(), pooled.Data())
+		}
+	}
+	// Ensure malformed signatures are rejected
+	unsigned, _ := api.BuildUnsignedTransaction(context.Background(), SignTransactionArgs{From: testBankAddress})
+	if _, err := api.SubmitSignedTransaction(context.Background(), unsigned.Tx, "0x0102"); err == nil {
+		t.Errorf("expected error for short signature")
+	}
+}
+
+// Tests that contract calls given as a method signature and arguments are ABI
+// encoded into the transaction data, unless raw data is given explicitly.
+func TestStructuredTransactionData(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+
+	transfer := "0xa9059cbb" +
+		"000000000000000000000000000000000000000000000000000000000000c0de" +
+		"00000000000000000000000000000000000000000000000000000000000003e8"
+	tokenABI := `[{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}]}]`
+
+	tests := []struct {
+		args string
+		data string
+		fail bool
+	}{
+		// Canonical signature with arguments as an RPC client would send them
+		{args: `{"methodSignature": "transfer(address,uint256)", "args": ["0x000000000000000000000000000000000000c0de", "1000"]}`, data: transfer},
+		{args: `{"methodSignature": "transfer(address,uint256)", "args": ["0x000000000000000000000000000000000000c0de", 1000]}`, data: transfer},
+		{args: `{"methodSignature": "transfer(address,uint256)", "args": ["0x000000000000000000000000000000000000c0de", "0x3e8"]}`, data: transfer},
+		// Method name resolved against a provided contract ABI
+		{args: `{"methodSignature": "transfer", "abi": ` + strconv.Quote(tokenABI) + `, "args": ["0x000000000000000000000000000000000000c0de", "1000"]}`, data: transfer},
+		// Explicit data takes precedence
+		{args: `{"data": "0x0102", "methodSignature": "transfer(address,uint256)", "args": []}`, data: "0x0102"},
+		// Malformed calls are rejected
+		{args: `{"methodSignature": "transfer(address,uint256)", "args": ["0x000000000000000000000000000000000000c0de"]}`, fail: true},
+		{args: `{"methodSignature": "transfer(address,uint256)", "args": ["0xc0de", "1000"]}`, fail: true},
+		{args: `{"methodSignature": "transfer(address,uint8)", "args": ["0x000000000000000000000000000000000000c0de", "1000"]}`, fail: true},
+		{args: `{"methodSignature": "transfer(address,uint256)", "abi": ` + strconv.Quote(tokenABI) + `, "args": ["0x000000000000000000000000000000000000c0de", "-1"]}`, fail: true},
+		{args: `{"methodSignature": "approve", "abi": ` + strconv.Quote(tokenABI) + `, "args": []}`, fail: true},
+	}
+	for i, tt := range tests {
+		var args SignTransactionArgs
+		if err := json.Unmarshal([]byte(tt.args), &args); err != nil {
+			t.Fatalf("test %d: failed to decode arguments: %v", i, err)
+		}
+		args.From, args.To = testBankAddress, &common.Address{0x01}
+
+		unsigned, err := api.BuildUnsignedTransaction(context.Background(), args)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: malformed call encoded: %s", i, unsigned.Tx.Data)
+			} else if _, ok := err.(*rpc.InvalidArgsError); !ok {
+				t.Errorf("test %d: error type mismatch: have %T, want *rpc.InvalidArgsError", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: failed to build transaction: %v", i, err)
+		}
+		if unsigned.Tx.Data != tt.data {
+			t.Errorf("test %d: data mismatch: have %s, want %s", i, unsigned.Tx.Data, tt.data)
+		}
+	}
+}
+
+// Tests that malformed raw transactions are rejected with descriptive errors.
+func TestSendRawTransactionErrors(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+
+	tx, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+	valid, _ := rlp.EncodeToBytes(tx)
+	notTx, _ := rlp.EncodeToBytes([]interface{}{uint(1), "two", []byte{3}})
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", errEmptyRawTransaction.Error()},
+		{"0x", errEmptyRawTransaction.Error()},
+		{common.ToHex(valid[:len(valid)-10]), fmt.Sprintf("failed to RLP-decode transaction (got %d bytes)", len(valid)-10)},
+		{common.ToHex(notTx), fmt.Sprintf("failed to RLP-decode transaction (got %d bytes)", len(notTx))},
+	}
+	for i, tt := range tests {
+		_, err := api.SendRawTransaction(context.Background(), tt.input)
+		if err == nil {
+			t.Errorf("test %d: expected error, got none", i)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("test %d: error mismatch: have %q, want prefix %q", i, err, tt.want)
+		}
+	}
+	// Ensure the valid transaction is still accepted
+	if _, err := api.SendRawTransaction(context.Background(), common.ToHex(valid)); err != nil {
+		t.Errorf("failed to send valid transaction: %v", err)
+	}
+}
+
+// Tests that gas estimation, transaction gas defaults and the reported costs all
+// honor the intrinsic gas costs overridden by a private network's chain config,
+// without affecting the mainnet costs.
+func TestIntrinsicGasOverride(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	backend.config.IntrinsicGasCosts = &core.IntrinsicGasCosts{TxGas: big.NewInt(100000)}
+
+	api := NewPublicBlockChainAPI(backend)
+	if costs := api.IntrinsicGas(); costs.TxGas.Int64() != 100000 || costs.TxDataNonZeroGas.BigInt().Cmp(params.TxDataNonZeroGas) != 0 {
+		t.Errorf("intrinsic gas costs mismatch: have %d/%d, want %d/%v", costs.TxGas.Int64(), costs.TxDataNonZeroGas.Int64(), 100000, params.TxDataNonZeroGas)
+	}
+	// Estimate a plain value transfer, which only costs intrinsic gas
+	to := common.Address{0x01}
+	gas, err := api.EstimateGas(context.Background(), CallArgs{
+		From:     testBankAddress,
+		To:       &to,
+		Gas:      *rpc.NewHexNumber(1000000),
+		GasPrice: *rpc.NewHexNumber(1),
+		Value:    *rpc.NewHexNumber(1),
+	})
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if gas.Int64() != 100000 {
+		t.Errorf("estimated gas mismatch: have %d, want %d", gas.Int64(), 100000)
+	}
+	// Transactions without a gas allowance must default to enough to be valid
+	args, err := prepareSendTxArgs(context.Background(), SendTxArgs{From: testBankAddress, To: &to, GasPrice: rpc.NewHexNumber(1)}, backend)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction: %v", err)
+	}
+	if args.Gas.Int64() != 100000 {
+		t.Errorf("default gas mismatch: have %d, want %d", args.Gas.Int64(), 100000)
+	}
+	if params.TxGas.Cmp(big.NewInt(21000)) != 0 {
+		t.Errorf("mainnet intrinsic gas changed: have %v, want 21000", params.TxGas)
+	}
+	backend.config.IntrinsicGasCosts = nil
+	if args, _ = prepareSendTxArgs(context.Background(), SendTxArgs{From: testBankAddress, To: &to, GasPrice: rpc.NewHexNumber(1)}, backend); args.Gas.Uint64() != defaultGas {
+		t.Errorf("default gas mismatch: have %d, want %d", args.Gas.Uint64(), defaultGas)
+	}
+}
+
+// Tests that transactions are retrieved by block hash and index, with unknown
+// blocks and out of range indexes yielding nil without an error.
+func TestGetTransactionByBlockHashAndIndex(t *testing.T) {
+	backend := newTestBackend(t, nil, 1, func(i int, gen *core.BlockGen) {
+		for j := 0; j < 2; j++ {
+			tx, _ := types.NewTransaction(gen.TxNonce(testBankAddress), common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+			gen.AddTx(tx)
+		}
+	})
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+	block := backend.chain.CurrentBlock()
+
+	tests := []struct {
+		hash  common.Hash
+		index int
+		want  *types.Transaction
+	}{
+		{common.Hash{0xff}, 0, nil},                // unknown block
+		{block.Hash(), 0, block.Transactions()[0]}, // first transaction
+		{block.Hash(), 1, block.Transactions()[1]}, // last transaction
+		{block.Hash(), 2, nil},                     // index out of range
+		{backend.chain.Genesis().Hash(), 0, nil},   // block without transactions
+	}
+	for i, tt := range tests {
+		tx, err := api.GetTransactionByBlockHashAndIndex(context.Background(), tt.hash, *rpc.NewHexNumber(tt.index))
+		if err != nil {
+			t.Errorf("test %d: failed to retrieve transaction: %v", i, err)
+			continue
+		}
+		switch {
+		case tt.want == nil && tx != nil:
+			t.Errorf("test %d: unexpected transaction %x", i, tx.Hash)
+		case tt.want != nil && tx == nil:
+			t.Errorf("test %d: transaction missing, want %x", i, tt.want.Hash())
+		case tt.want != nil && (tx.Hash != tt.want.Hash() || tx.BlockHash != tt.hash || tx.TransactionIndex.Int() != tt.index):
+			t.Errorf("test %d: transaction mismatch: have %x (block %x, index %d), want %x (block %x, index %d)",
+				i, tx.Hash, tx.BlockHash, tx.TransactionIndex.Int(), tt.want.Hash(), tt.hash, tt.index)
+		}
+	}
+}
+
+// Tests that receipts can be retrieved in batches, in the requested order and with
+// nil entries for unknown transactions.
+func TestGetTransactionReceipts(t *testing.T) {
+	backend := newTestBackend(t, nil, 2, func(i int, gen *core.BlockGen) {
+		tx, _ := types.NewTransaction(gen.TxNonce(testBankAddress), common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		gen.AddTx(tx)
+	})
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+
+	first := backend.chain.GetBlockByNumber(1).Transactions()[0].Hash()
+	second := backend.chain.GetBlockByNumber(2).Transactions()[0].Hash()
+	hashes := []common.Hash{second, {0xff}, first, {0xfe}}
+
+	receipts, err := api.GetTransactionReceipts(hashes)
+	if err != nil {
+		t.Fatalf("failed to retrieve receipts: %v", err)
+	}
+	if len(receipts) != len(hashes) {
+		t.Fatalf("receipt count mismatch: have %d, want %d", len(receipts), len(hashes))
+	}
+	for i, hash := range hashes {
+		want, err := api.GetTransactionReceipt(hash)
+		if err != nil {
+			t.Fatalf("receipt %d: failed to retrieve single receipt: %v", i, err)
+		}
+		switch {
+		case want == nil && receipts[i] != nil:
+			t.Errorf("receipt %d: unexpected receipt for unknown transaction %x", i, hash)
+		case want != nil && receipts[i] == nil:
+			t.Errorf("receipt %d: receipt missing for transaction %x", i, hash)
+		case want != nil && receipts[i]["transactionHash"] != hash:
+			t.Errorf("receipt %d: transaction hash mismatch: have %v, want %x", i, receipts[i]["transactionHash"], hash)
+		case want != nil && !reflect.DeepEqual(receipts[i], want):
+			t.Errorf("receipt %d: mismatch with single lookup: have %v, want %v", i, receipts[i], want)
+		}
+	}
+	// Requests over the cap should be rejected
+	if _, err := api.GetTransactionReceipts(make([]common.Hash, maxReceiptsPerRequest+1)); err == nil {
+		t.Errorf("oversized request accepted")
+	}
+}
+
+// Tests that the position of mined transactions is reported along with the log
+// index base of their receipts, and that unmined transactions are errors.
+func TestGetTransactionPosition(t *testing.T) {
+	// Create a block with two log emitting contract creations and a transfer
+	emitter := common.FromHex("0x60006000a0") // PUSH1 0 PUSH1 0 LOG0
+	backend := newTestBackend(t, nil, 1, func(i int, gen *core.BlockGen) {
+		for j := 0; j < 2; j++ {
+			tx, _ := types.NewContractCreation(gen.TxNonce(testBankAddress), new(big.Int), big.NewInt(100000), big.NewInt(1), emitter).SignECDSA(testBankKey)
+			gen.AddTx(tx)
+		}
+		tx, _ := types.NewTransaction(gen.TxNonce(testBankAddress), common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		gen.AddTx(tx)
+	})
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+	block := backend.chain.CurrentBlock()
+
+	for i, tx := range block.Transactions() {
+		pos, err := api.GetTransactionPosition(tx.Hash())
+		if err != nil {
+			t.Fatalf("tx %d: failed to retrieve position: %v", i, err)
+		}
+		if pos.BlockHash != block.Hash() || pos.BlockNumber.Uint64() != block.NumberU64() || pos.TransactionIndex.Int() != i {
+			t.Errorf("tx %d: position mismatch: have block %x #%d index %d, want block %x #%d index %d",
+				i, pos.BlockHash, pos.BlockNumber.Uint64(), pos.TransactionIndex.Int(), block.Hash(), block.NumberU64(), i)
+		}
+		receipt, err := api.GetTransactionReceipt(tx.Hash())
+		if err != nil || receipt == nil {
+			t.Fatalf("tx %d: failed to retrieve receipt: %v", i, err)
+		}
+		if base := receipt["logIndex"].(*rpc.HexNumber).Int(); base != i {
+			t.Errorf("tx %d: log index base mismatch: have %d, want %d", i, base, i)
+		}
+	}
+	// Pending and unknown transactions should be reported as errors
+	pending, _ := types.NewTransaction(3, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+	if err := backend.pool.Add(pending); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	for _, hash := range []common.Hash{pending.Hash(), {0xff}} {
+		if pos, err := api.GetTransactionPosition(hash); err == nil {
+			t.Errorf("tx %x: unmined transaction positioned at %+v", hash, pos)
+		}
+	}
+}
+
+// Tests that validating raw transactions reports the checks they fail, without
+// adding them to the pool.
+func TestValidateRawTransaction(t *testing.T) {
+	// Create a chain where the bank already sent a transaction
+	backend := newTestBackend(t, nil, 1, func(i int, gen *core.BlockGen) {
+		tx, _ := types.NewTransaction(gen.TxNonce(testBankAddress), common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		gen.AddTx(tx)
+	})
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+
+	encode := func(tx *types.Transaction) string {
+		data, _ := rlp.EncodeToBytes(tx)
+		return common.ToHex(data)
+	}
+	sign := func(nonce uint64, value *big.Int) string {
+		tx, _ := types.NewTransaction(nonce, common.Address{0x01}, value, big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		return encode(tx)
+	}
+	unsigned, _ := types.NewTransaction(1, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).WithSignature(make([]byte, 65))
+
+	tests := []struct {
+		input  string
+		valid  bool
+		from   bool
+		reason string
+	}{
+		{sign(1, big.NewInt(1)), true, true, ""},
+		{encode(unsigned), false, false, core.ErrInvalidSender.Error()},
+		{sign(0, big.NewInt(1)), false, true, core.ErrNonce.Error()},
+		{sign(1, testBankFunds), false, true, core.ErrInsufficientFunds.Error()},
+	}
+	for i, tt := range tests {
+		result, err := api.ValidateRawTransaction(context.Background(), tt.input)
+		if err != nil {
+			t.Errorf("test %d: failed to validate transaction: %v", i, err)
+			continue
+		}
+		if result.Valid != tt.valid || !strings.HasPrefix(result.Reason, tt.reason) {
+			t.Errorf("test %d: result mismatch: have valid %v (%q), want %v (%q)", i, result.Valid, result.Reason, tt.valid, tt.reason)
+		}
+		if tt.from && (result.From == nil || *result.From != testBankAddress) {
+			t.Errorf("test %d: sender mismatch: have %v, want %x", i, result.From, testBankAddress)
+		}
+		if !tt.from && result.From != nil {
+			t.Errorf("test %d: unexpected sender %x", i, *result.From)
+		}
+	}
+	// Validation must not have added anything to the pool
+	if pending, queued := backend.pool.Stats(); pending != 0 || queued != 0 {
+		t.Errorf("pool modified: %d pending, %d queued", pending, queued)
+	}
+	// Undecodable input is an error
+	if _, err := api.ValidateRawTransaction(context.Background(), "0x"); err == nil {
+		t.Errorf("expected error for empty transaction")
+	}
+}
+
+// Tests that in strict chain id mode raw transactions not signed for the chain
+// id of the node are rejected, while matching ones are accepted.
+func TestSendRawTransactionStrictChainId(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	backend.config.EIP155Block = big.NewInt(0)
+	backend.config.ChainId = big.NewInt(1)
+	backend.strictChainId = true
+	api := NewPublicTransactionPoolAPI(backend)
+
+	encode := func(nonce uint64, chainId *big.Int) string {
+		tx := types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil)
+		if chainId == nil {
+			tx, _ = tx.SignECDSA(testBankKey)
+		} else {
+			tx, _ = tx.SignProtectedECDSA(testBankKey, chainId)
+		}
+		raw, _ := rlp.EncodeToBytes(tx)
+		return common.ToHex(raw)
+	}
+	tests := []struct {
+		chainId *big.Int
+		fail    bool
+	}{
+		{nil, true},
+		{big.NewInt(2), true},
+		{big.NewInt(1), false},
+	}
+	for i, tt := range tests {
+		_, err := api.SendRawTransaction(context.Background(), encode(0, tt.chainId))
+		if tt.fail {
+			if _, ok := err.(*rpc.InvalidArgsError); !ok {
+				t.Errorf("test %d: error mismatch: have %v, want invalid args", i, err)
+			}
+		} else if err != nil {
+			t.Errorf("test %d: failed to send transaction: %v", i, err)
+		}
+	}
+	// Unprotected transactions are accepted again with strict mode disabled
+	backend.strictChainId = false
+	if _, err := api.SendRawTransaction(context.Background(), encode(1, nil)); err != nil {
+		t.Errorf("failed to send unprotected transaction: %v", err)
+	}
+}
+
+// Tests that missing and corrupt transaction lookup entries are distinguished,
+// the former being reported as an unknown transaction and the latter as an error.
+func TestTransactionBlockDataErrors(t *testing.T) {
+	backend := newTestBackend(t, nil, 1, func(i int, block *core.BlockGen) {
+		tx, _ := types.NewTransaction(block.TxNonce(testBankAddress), common.Address{0x01}, big.NewInt(1), big.NewInt(21000), nil, nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	})
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+	hash := backend.chain.GetBlockByNumber(1).Transactions()[0].Hash()
+	key := append(hash.Bytes(), 0x01)
+
+	if _, _, _, err := getTransactionBlockData(backend.db, hash); err != nil {
+		t.Fatalf("failed to retrieve valid entry: %v", err)
+	}
+	// Missing entries should be reported as not indexed
+	backend.db.Delete(key)
+	if _, _, _, err := getTransactionBlockData(backend.db, hash); err != errTxNotIndexed {
+		t.Errorf("absent entry: error mismatch: have %v, want %v", err, errTxNotIndexed)
+	}
+	if tx, err := api.GetTransactionByHash(context.Background(), hash, nil); tx != nil || err != nil {
+		t.Errorf("absent entry: have %v, %v, want nil transaction and error", tx, err)
+	}
+	// Short and undecodable entries should be reported as corrupt
+	for i, blob := range [][]byte{{0xc1, 0x80}, append([]byte{0xc0}, make([]byte, 40)...)} {
+		backend.db.Put(key, blob)
+		if _, _, _, err := getTransactionBlockData(backend.db, hash); err == nil {
+			t.Errorf("corrupt entry %d: expected error, got none", i)
+		} else if _, ok := err.(*corruptTxIndexError); !ok {
+			t.Errorf("corrupt entry %d: error type mismatch: have %T, want *corruptTxIndexError", i, err)
+		}
+		if tx, err := api.GetTransactionByHash(context.Background(), hash, nil); tx != nil || err == nil {
+			t.Errorf("corrupt entry %d: have %v, %v, want error", i, tx, err)
+		}
+	}
+}
+
+// Tests that resending a pool transaction at a bumped price reports both the old
+// and the new transaction, and announces the old one as dropped.
+func TestResendDetailed(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	if _, err := backend.am.ImportECDSA(testBankKey, "secret"); err != nil {
+		t.Fatalf("failed to import test key: %v", err)
+	}
+	if err := backend.am.Unlock(accounts.Account{Address: testBankAddress}, "secret"); err != nil {
+		t.Fatalf("failed to unlock test key: %v", err)
+	}
+	api := NewPublicTransactionPoolAPI(backend)
+
+	tx, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+	if err := backend.SendTx(context.Background(), tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	bumps := backend.mux.Subscribe(core.TxBumpEvent{})
+	defer bumps.Unsubscribe()
+
+	result, err := api.ResendDetailed(context.Background(), *newTx(tx), rpc.NewHexNumber(2), nil)
+	if err != nil {
+		t.Fatalf("failed to resend transaction: %v", err)
+	}
+	if result.OldHash != tx.Hash() || result.OldGasPrice.Int64() != 1 || result.NewGasPrice.Int64() != 2 {
+		t.Errorf("result mismatch: have old %x at %v, new at %v, want old %x at 1, new at 2", result.OldHash, result.OldGasPrice.BigInt(), result.NewGasPrice.BigInt(), tx.Hash())
+	}
+	replacement := backend.pool.Get(result.NewHash)
+	if replacement == nil || replacement.GasPrice().Int64() != 2 {
+		t.Fatalf("replacement %x not pooled at the new price: %v", result.NewHash, replacement)
+	}
+	if backend.pool.Get(tx.Hash()) != nil {
+		t.Errorf("replaced transaction still pooled")
+	}
+	select {
+	case ev := <-bumps.Chan():
+		if bump := ev.Data.(core.TxBumpEvent); bump.Old.Hash() != tx.Hash() || bump.New.Hash() != result.NewHash {
+			t.Errorf("bump event mismatch: have %x replaced by %x, want %x replaced by %x", bump.Old.Hash(), bump.New.Hash(), tx.Hash(), result.NewHash)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("bump event timeout")
+	}
+}
+
+// Tests that concurrent transaction sends from the same account are assigned
+// distinct, sequential nonces.
+func TestConcurrentSendTransaction(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	if _, err := backend.am.ImportECDSA(testBankKey, "secret"); err != nil {
+		t.Fatalf("failed to import test key: %v", err)
+	}
+	if err := backend.am.Unlock(accounts.Account{Address: testBankAddress}, "secret"); err != nil {
+		t.Fatalf("failed to unlock test key: %v", err)
+	}
+	var (
+		public  = NewPublicTransactionPoolAPI(backend)
+		private = NewPrivateAccountAPI(backend)
+	)
+	const sends = 16
+
+	var (
+		wg     sync.WaitGroup
+		hashes = make(chan common.Hash, sends)
+		errs   = make(chan error, sends)
+	)
+	for i := 0; i < sends; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var (
+				to   = common.Address{byte(i)}
+				args = SendTxArgs{From: testBankAddress, To: &to}
+				hash common.Hash
+				err  error
+			)
+			if i%2 == 0 {
+				hash, err = public.SendTransaction(context.Background(), args)
+			} else {
+				hash, err = private.SendTransaction(context.Background(), args, "secret")
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			hashes <- hash
+		}(i)
+	}
+	wg.Wait()
+	close(hashes)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("failed to send transaction: %v", err)
+	}
+	nonces := make(map[uint64]bool)
+	for hash := range hashes {
+		tx := backend.pool.Get(hash)
+		if tx == nil {
+			t.Fatalf("transaction %x missing from pool", hash)
+		}
+		if nonces[tx.Nonce()] {
+			t.Errorf("duplicate nonce %d", tx.Nonce())
+		}
+		nonces[tx.Nonce()] = true
+	}
+	for nonce := uint64(0); nonce < sends; nonce++ {
+		if !nonces[nonce] {
+			t.Errorf("nonce %d not assigned", nonce)
+		}
+	}
+}
+
+// Tests that transactions paying less than the pool's minimum gas price are
+// rejected, while ones paying exactly the minimum are accepted.
+func TestMinGasPriceEnforcement(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	if _, err := backend.am.ImportECDSA(testBankKey, "secret"); err != nil {
+		t.Fatalf("failed to import test key: %v", err)
+	}
+	if err := backend.am.Unlock(accounts.Account{Address: testBankAddress}, "secret"); err != nil {
+		t.Fatalf("failed to unlock test key: %v", err)
+	}
+	// Raise the pool minimum and wait for it to be picked up
+	min := big.NewInt(10000000000)
+	backend.mux.Post(core.GasPriceChanged{Price: min})
+	for i := 0; backend.pool.MinGasPrice().Cmp(min) != 0; i++ {
+		if i == 100 {
+			t.Fatalf("minimum gas price not updated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	api := NewPublicTransactionPoolAPI(backend)
+	if have := api.MinGasPrice().BigInt(); have.Cmp(min) != 0 {
+		t.Errorf("minimum gas price mismatch: have %v, want %v", have, min)
+	}
+	to := common.Address{0x01}
+	below := rpc.NewHexNumber(new(big.Int).Sub(min, big.NewInt(1)))
+
+	// Transactions below the minimum should be rejected through both endpoints
+	if _, err := api.SendTransaction(context.Background(), SendTxArgs{From: testBankAddress, To: &to, GasPrice: below}); err != core.ErrCheap {
+		t.Errorf("below minimum transaction error mismatch: have %v, want %v", err, core.ErrCheap)
+	}
+	tx, _ := types.NewTransaction(0, to, big.NewInt(1), big.NewInt(21000), below.BigInt(), nil).SignECDSA(testBankKey)
+	raw, _ := rlp.EncodeToBytes(tx)
+	if _, err := api.SendRawTransaction(context.Background(), common.ToHex(raw)); err != core.ErrCheap {
+		t.Errorf("below minimum raw transaction error mismatch: have %v, want %v", err, core.ErrCheap)
+	}
+	if pending, queued := backend.pool.Stats(); pending+queued != 0 {
+		t.Errorf("rejected transactions in pool: %d pending, %d queued", pending, queued)
+	}
+	// Transactions paying exactly the minimum should be accepted
+	if _, err := api.SendTransaction(context.Background(), SendTxArgs{From: testBankAddress, To: &to, GasPrice: rpc.NewHexNumber(min)}); err != nil {
+		t.Errorf("at minimum transaction rejected: %v", err)
+	}
+	tx, _ = types.NewTransaction(1, to, big.NewInt(1), big.NewInt(21000), min, nil).SignECDSA(testBankKey)
+	raw, _ = rlp.EncodeToBytes(tx)
+	if _, err := api.SendRawTransaction(context.Background(), common.ToHex(raw)); err != nil {
+		t.Errorf("at minimum raw transaction rejected: %v", err)
+	}
+}
+
+// Tests that the pending transaction count reflects transactions waiting in the
+// pool, while the latest count only reflects the chain.
+func TestPendingTransactionCount(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+	count := func(blockNr rpc.BlockNumber) uint64 {
+		nonce, err := api.GetTransactionCount(context.Background(), testBankAddress, blockNr)
+		if err != nil {
+			t.Fatalf("failed to retrieve %v transaction count: %v", blockNr, err)
+		}
+		return nonce.Uint64()
+	}
+	for i := uint64(0); i < 2; i++ {
+		tx, _ := types.NewTransaction(i, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		raw, _ := rlp.EncodeToBytes(tx)
+		if _, err := api.SendRawTransaction(context.Background(), common.ToHex(raw)); err != nil {
+			t.Fatalf("failed to send transaction %d: %v", i, err)
+		}
+		if have := count(rpc.PendingBlockNumber); have != i+1 {
+			t.Errorf("tx %d: pending count mismatch: have %d, want %d", i, have, i+1)
+		}
+		if have := count(rpc.LatestBlockNumber); have != 0 {
+			t.Errorf("tx %d: latest count mismatch: have %d, want 0", i, have)
+		}
+	}
+}
+
+// Tests that service failures carry stable error codes, so clients can branch
+// on the failure reason.
+func TestTypedErrorCodes(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	var (
+		public  = NewPublicTransactionPoolAPI(backend)
+		private = NewPrivateAccountAPI(backend)
+		debug   = NewPublicDebugAPI(backend)
+		unknown = common.Address{0xff}
+		to      = common.Address{0x01}
+	)
+	check := func(name string, err error, code int) {
+		rpcErr, ok := err.(rpc.Error)
+		if !ok {
+			t.Errorf("%s: expected rpc error, got %v", name, err)
+			return
+		}
+		if rpcErr.ErrorCode() != code {
+			t.Errorf("%s: error code mismatch: have %d, want %d", name, rpcErr.ErrorCode(), code)
+		}
+	}
+	_, err := public.SendTransaction(context.Background(), SendTxArgs{From: unknown, To: &to})
+	check("send from unknown account", err, rpc.ErrCodeUnauthorized)
+
+	_, err = private.SendTransaction(context.Background(), SendTxArgs{From: unknown, To: &to}, "secret")
+	check("send from unknown account with passphrase", err, rpc.ErrCodeUnauthorized)
+
+	_, err = public.Sign(unknown, common.Hash{})
+	check("sign with unknown account", err, rpc.ErrCodeUnauthorized)
+
+	_, err = public.SendRawTransaction(context.Background(), "0x")
+	check("send empty raw transaction", err, rpc.ErrCodeInvalidArgs)
+
+	_, err = debug.GetBlockRlp(context.Background(), 1000)
+	check("unknown block", err, rpc.ErrCodeNotFound)
+}
+
+// Tests that signing a transaction from an account the node doesn't manage fails
+// upfront with a clear error.
+func TestSignTransactionUnknownAccount(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+	unknown, to := common.Address{0xff}, common.Address{0x01}
+
+	_, err := api.SignTransaction(context.Background(), SignTransactionArgs{From: unknown, To: &to})
+	if err == nil || err.Error() != "unknown account "+unknown.Hex() {
+		t.Fatalf("error mismatch: have %v, want unknown account %s", err, unknown.Hex())
+	}
+	if rpcErr, ok := err.(rpc.Error); !ok || rpcErr.ErrorCode() != rpc.ErrCodeUnauthorized {
+		t.Errorf("error code mismatch: have %v, want %d", err, rpc.ErrCodeUnauthorized)
+	}
+	_, err = api.SignTransactions(context.Background(), []*SignTransactionArgs{{From: unknown, To: &to}})
+	if err == nil || err.Error() != "transaction 0: unknown account "+unknown.Hex() {
+		t.Errorf("batch error mismatch: have %v, want transaction 0: unknown account %s", err, unknown.Hex())
+	}
+	if rpcErr, ok := err.(rpc.Error); !ok || rpcErr.ErrorCode() != rpc.ErrCodeUnauthorized {
+		t.Errorf("batch error code mismatch: have %v, want %d", err, rpc.ErrCodeUnauthorized)
+	}
+}
+
+// Tests that contract addresses are predicted for both the CREATE and CREATE2
+// schemes.
+func TestContractAddressPrediction(t *testing.T) {
+	api := NewPublicTransactionPoolAPI(nil)
+
+	from := common.HexToAddress("0x970e8128ab834e8eac17ab8e3812f010678cf791")
+	for nonce, want := range []string{
+		"0x333c3310824b7c685133f2bedb2ca4b8b4df633d",
+		"0x8bda78331c916a08481428e4b07c96d3e916d165",
+		"0xc9ddedf451bc62ce88bf9292afb13df35b670699",
+	} {
+		if have := api.GetContractAddress(from, *rpc.NewHexNumber(nonce)); have != common.HexToAddress(want) {
+			t.Errorf("nonce %d: contract address mismatch: have %x, want %s", nonce, have, want)
+		}
+	}
+	var (
+		deployer = common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+		salt     = common.HexToHash("0x00000000000000000000000000000000000000000000000000000000cafebabe")
+		initHash = crypto.Keccak256Hash(common.FromHex("0xdeadbeef"))
+		want     = common.HexToAddress("0x60f3f640a8508fC6a86d45DF051962668E1e8AC7")
+	)
+	if have := api.GetCreate2Address(deployer, salt, initHash); have != want {
+		t.Errorf("create2 address mismatch: have %x, want %x", have, want)
+	}
+}
+
+// Tests that signing a transaction for a specific chain also returns the legacy
+// form, both recovering to the same sender.
+func TestSignTransactionLegacyForm(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	if _, err := backend.am.ImportECDSA(testBankKey, "secret"); err != nil {
+		t.Fatalf("failed to import test key: %v", err)
+	}
+	if err := backend.am.Unlock(accounts.Account{Address: testBankAddress}, "secret"); err != nil {
+		t.Fatalf("failed to unlock test key: %v", err)
+	}
+	backend.config.EIP155Block = big.NewInt(0)
+	backend.config.ChainId = big.NewInt(1)
+	api := NewPublicTransactionPoolAPI(backend)
+	to := common.Address{0x01}
+
+	// Without a chain id only the legacy form is produced
+	res, err := api.SignTransaction(context.Background(), SignTransactionArgs{From: testBankAddress, To: &to})
+	if err != nil {
+		t.Fatalf("failed to sign unprotected transaction: %v", err)
+	}
+	if res.RawLegacy != "" {
+		t.Errorf("unexpected legacy form for unprotected transaction: %s", res.RawLegacy)
+	}
+	// With a chain id both forms are returned
+	res, err = api.SignTransaction(context.Background(), SignTransactionArgs{From: testBankAddress, To: &to, ChainId: rpc.NewHexNumber(1)})
+	if err != nil {
+		t.Fatalf("failed to sign protected transaction: %v", err)
+	}
+	if res.RawLegacy == "" || res.RawLegacy == res.Raw {
+		t.Fatalf("legacy form mismatch: raw %s, legacy %s", res.Raw, res.RawLegacy)
+	}
+	protected, legacy := new(types.Transaction), new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(res.Raw), protected); err != nil {
+		t.Fatalf("failed to decode protected transaction: %v", err)
+	}
+	if err := rlp.DecodeBytes(common.FromHex(res.RawLegacy), legacy); err != nil {
+		t.Fatalf("failed to decode legacy transaction: %v", err)
+	}
+	if !protected.Protected() || legacy.Protected() {
+		t.Errorf("protection mismatch: raw %v, legacy %v", protected.Protected(), legacy.Protected())
+	}
+	for name, tx := range map[string]*types.Transaction{"raw": protected, "legacy": legacy} {
+		if from, err := tx.From(); err != nil || from != testBankAddress {
+			t.Errorf("%s: sender mismatch: have %x (%v), want %x", name, from, err, testBankAddress)
+		}
+	}
+}
+
+// Tests that batch signing assigns sequential nonces to transactions of the same
+// sender and aborts on the first failing transaction.
+func TestSignTransactions(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	if _, err := backend.am.ImportECDSA(testBankKey, "secret"); err != nil {
+		t.Fatalf("failed to import test key: %v", err)
+	}
+	if err := backend.am.Unlock(accounts.Account{Address: testBankAddress}, "secret"); err != nil {
+		t.Fatalf("failed to unlock test key: %v", err)
+	}
+	api := NewPublicTransactionPoolAPI(backend)
+	to := common.Address{0x01}
+
+	batch := []*SignTransactionArgs{
+		{From: testBankAddress, To: &to},
+		{From: testBankAddress, To: &to},
+		{From: testBankAddress, To: &to},
+	}
+	results, err := api.SignTransactions(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("failed to sign batch: %v", err)
+	}
+	if len(results) != len(batch) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), len(batch))
+	}
+	for i, res := range results {
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(common.FromHex(res.Raw), tx); err != nil {
+			t.Fatalf("tx %d: failed to decode: %v", i, err)
+		}
+		if tx.Nonce() != uint64(i) {
+			t.Errorf("tx %d: nonce mismatch: have %d, want %d", i, tx.Nonce(), i)
+		}
+		if from, err := tx.From(); err != nil || from != testBankAddress {
+			t.Errorf("tx %d: sender mismatch: have %x (%v), want %x", i, from, err, testBankAddress)
+		}
+	}
+	// A failing transaction should abort the batch, reporting its index
+	batch[1] = &SignTransactionArgs{From: common.Address{0xff}, To: &to}
+	if _, err := api.SignTransactions(context.Background(), batch); err == nil || !strings.HasPrefix(err.Error(), "transaction 1:") {
+		t.Errorf("failing batch error mismatch: have %v, want transaction 1 failure", err)
+	}
+}
+
+// Tests that blocks are looked up by timestamp, returning the latest block not
+// after the requested time.
+func TestGetBlockByTimestamp(t *testing.T) {
+	// Blocks are generated 10 seconds apart from the genesis
+	backend := newTestBackend(t, nil, 5, func(i int, block *core.BlockGen) {})
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+	tests := []struct {
+		name   string
+		ts     uint64
+		number int64
+	}{
+		{"before genesis", testGenesisTime - 1, 0},
+		{"genesis", testGenesisTime, 0},
+		{"exact", testGenesisTime + 20, 2},
+		{"between", testGenesisTime + 25, 2},
+		{"head", testGenesisTime + 50, 5},
+		{"after head", testGenesisTime + 1000, 5},
+	}
+	for _, tt := range tests {
+		block, err := api.GetBlockByTimestamp(context.Background(), tt.ts)
+		if err != nil {
+			t.Errorf("%s: failed to retrieve block: %v", tt.name, err)
+			continue
+		}
+		if number := block["number"].(*rpc.HexNumber).BigInt().Int64(); number != tt.number {
+			t.Errorf("%s: block number mismatch: have %d, want %d", tt.name, number, tt.number)
+		}
+	}
+}
+
+// Tests that verified storage lookups return proofs that check out against the
+// state root of the block, both for present and for missing storage slots.
+func TestGetStorageAtVerified(t *testing.T) {
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	backend := newTestBackend(t, []testAccount{{
+		Address: contract,
+		Code:    []byte{byte(vm.STOP)},
+		Storage: map[common.Hash]common.Hash{
+			common.BigToHash(common.Big1): common.BigToHash(common.Big2),
+			common.BigToHash(common.Big2): common.BigToHash(common.Big3),
+		},
+	}}, 1, func(i int, block *core.BlockGen) {})
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+	root := backend.HeaderByNumber(rpc.LatestBlockNumber).Root
+
+	tests := []struct {
+		key   common.Hash
+		value common.Hash
+	}{
+		{common.BigToHash(common.Big1), common.BigToHash(common.Big2)},
+		{common.BigToHash(common.Big2), common.BigToHash(common.Big3)},
+		{common.BigToHash(common.Big3), common.Hash{}},
+	}
+	for i, tt := range tests {
+		result, err := api.GetStorageAtVerified(context.Background(), contract, tt.key, rpc.LatestBlockNumber)
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve storage: %v", i, err)
+		}
+		if result.Value != tt.value.Hex() {
+			t.Errorf("test %d: value mismatch: have %s, want %s", i, result.Value, tt.value.Hex())
+		}
+		// Verify the account against the state root of the block
+		enc, err := trie.VerifyProof(root, crypto.Keccak256(contract[:]), decodeProof(result.AccountProof))
+		if err != nil {
+			t.Fatalf("test %d: invalid account proof: %v", i, err)
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(enc, &account); err != nil {
+			t.Fatalf("test %d: failed to decode account: %v", i, err)
+		}
+		if account.Root.Hex() != result.StorageHash {
+			t.Errorf("test %d: storage root mismatch: have %s, want %x", i, result.StorageHash, account.Root)
+		}
+		// Verify the value against the storage root of the account
+		enc, err = trie.VerifyProof(account.Root, crypto.Keccak256(tt.key[:]), decodeProof(result.StorageProof))
+		if err != nil {
+			t.Fatalf("test %d: invalid storage proof: %v", i, err)
+		}
+		var value []byte
+		if enc != nil {
+			if err := rlp.DecodeBytes(enc, &value); err != nil {
+				t.Fatalf("test %d: failed to decode value: %v", i, err)
+			}
+		}
+		if common.BytesToHash(value) != tt.value {
+			t.Errorf("test %d: proven value mismatch: have %x, want %x", i, value, tt.value)
+		}
+	}
+}
+
+// Tests that the storage root of an account matches the root of a storage trie
+// recomputed from its contents, and that nonexistent accounts are rejected.
+func TestGetStorageRoot(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0x00000000000000000000000000000000000000c0")
+		plain    = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+		missing  = common.HexToAddress("0x00000000000000000000000000000000000000c2")
+		storage  = map[common.Hash]common.Hash{
+			common.BigToHash(common.Big1): common.BigToHash(common.Big2),
+			common.BigToHash(common.Big2): common.BigToHash(common.Big3),
+		}
+	)
+	backend := newTestBackend(t, []testAccount{
+		{Address: contract, Code: []byte{byte(vm.STOP)}, Storage: storage},
+		{Address: plain, Balance: common.Big1},
+	}, 1, func(i int, block *core.BlockGen) {})
+	defer backend.close()
+
+	// Recompute the storage root of the contract from scratch
+	db, _ := ethdb.NewMemDatabase()
+	tr, _ := trie.NewSecure(common.Hash{}, db, 0)
+	for key, value := range storage {
+		enc, _ := rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
+		tr.Update(key[:], enc)
+	}
+	api := NewPublicBlockChainAPI(backend)
+
+	root, err := api.GetStorageRoot(context.Background(), contract, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to retrieve contract storage root: %v", err)
+	}
+	if want := tr.Hash(); root != want {
+		t.Errorf("contract storage root mismatch: have %x, want %x", root, want)
+	}
+	root, err = api.GetStorageRoot(context.Background(), plain, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to retrieve plain account storage root: %v", err)
+	}
+	if empty, _ := trie.New(common.Hash{}, db); root != empty.Hash() {
+		t.Errorf("plain account storage root mismatch: have %x, want %x", root, empty.Hash())
+	}
+	if _, err := api.GetStorageRoot(context.Background(), missing, rpc.LatestBlockNumber); err == nil {
+		t.Errorf("nonexistent account storage root retrieved")
+	} else if _, ok := err.(*rpc.NotFoundError); !ok {
+		t.Errorf("nonexistent account error type mismatch: have %T, want *rpc.NotFoundError", err)
+	}
+}
+
+// decodeProof converts hex encoded proof nodes back into their binary form.
+func decodeProof(proof []string) []rlp.RawValue {
+	nodes := make([]rlp.RawValue, len(proof))
+	for i, node := range proof {
+		nodes[i] = common.FromHex(node)
+	}
+	return nodes
+}
+
+// Tests that awaiting a transaction notifies once it reaches the requested number
+// of confirmations, and right away if it's already confirmed or unknown.
+func TestAwaitTransaction(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", NewPublicTransactionPoolAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	tx, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+	if err := backend.pool.Add(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	results := make(chan AwaitTransactionResult)
+	sub, err := client.EthSubscribe(context.Background(), results, "awaitTransaction", tx.Hash(), 3)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Mine the transaction and advance the head one block at a time
+	blocks, _ := core.GenerateChain(nil, backend.chain.CurrentBlock(), backend.db, 3, func(i int, gen *core.BlockGen) {
+		if i == 0 {
+			gen.AddTx(tx)
+		}
+	})
+	for i, block := range blocks[:2] {
+		if _, err := backend.chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("failed to insert block %d: %v", i, err)
+		}
+		select {
+		case result := <-results:
+			t.Fatalf("premature notification at block %d: %+v", i, result)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if _, err := backend.chain.InsertChain(blocks[2:]); err != nil {
+		t.Fatalf("failed to insert last block: %v", err)
+	}
+	select {
+	case result := <-results:
+		if result.Error != "" {
+			t.Fatalf("unexpected failure: %s", result.Error)
+		}
+		if result.BlockHash != blocks[0].Hash() || result.BlockNumber.Uint64() != 1 || result.Confirmations.Uint64() != 3 {
+			t.Errorf("notification mismatch: have %x #%d (%d confs), want %x #1 (3 confs)", result.BlockHash, result.BlockNumber.Uint64(), result.Confirmations.Uint64(), blocks[0].Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("confirmation notification timeout")
+	}
+	// Transactions already confirmed at subscription time are reported right away
+	mined := make(chan AwaitTransactionResult)
+	msub, err := client.EthSubscribe(context.Background(), mined, "awaitTransaction", tx.Hash(), 2)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer msub.Unsubscribe()
+
+	select {
+	case result := <-mined:
+		if result.Error != "" || result.BlockHash != blocks[0].Hash() || result.Confirmations.Uint64() != 3 {
+			t.Errorf("mined notification mismatch: have %+v, want %x (3 confs)", result, blocks[0].Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("mined notification timeout")
+	}
+	// Unknown transactions are reported as gone
+	unknown := make(chan AwaitTransactionResult)
+	usub, err := client.EthSubscribe(context.Background(), unknown, "awaitTransaction", common.Hash{0xff}, 1)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer usub.Unsubscribe()
+
+	select {
+	case result := <-unknown:
+		if result.Error == "" {
+			t.Errorf("expected failure for unknown transaction, got %+v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("failure notification timeout")
+	}
+}
+
+// Tests that balance change notifications are sent for watched accounts whose
+// balance changed with a new head, including changes undone by a reorg.
+func TestBalanceChanges(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", NewPublicBlockChainAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	var (
+		receiver = common.Address{0x01}
+		idle     = common.Address{0x02}
+	)
+	results := make(chan BalanceChangeResult)
+	sub, err := client.EthSubscribe(context.Background(), results, "newBalanceChanges", []common.Address{receiver, idle})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	expect := func(old, new int64, block uint64) {
+		select {
+		case result := <-results:
+			if result.Address != receiver || result.OldBalance.Int64() != old || result.NewBalance.Int64() != new || result.Block.Uint64() != block {
+				t.Errorf("notification mismatch: have %x %d -> %d at #%d, want %x %d -> %d at #%d",
+					result.Address, result.OldBalance.Int64(), result.NewBalance.Int64(), result.Block.Uint64(), receiver, old, new, block)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("notification timeout")
+		}
+		select {
+		case result := <-results:
+			t.Fatalf("unexpected notification: %+v", result)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	// Send value to the watched account
+	genesis := backend.chain.CurrentBlock()
+	blocks, _ := core.GenerateChain(nil, genesis, backend.db, 1, func(i int, gen *core.BlockGen) {
+		tx, _ := types.NewTransaction(gen.TxNonce(testBankAddress), receiver, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		gen.AddTx(tx)
+	})
+	if _, err := backend.chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	expect(0, 1000, 1)
+
+	// Reorg the transfer away with a longer empty chain
+	fork, _ := core.GenerateChain(nil, genesis, backend.db, 2, nil)
+	if _, err := backend.chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	expect(1000, 0, 2)
+}
+
+// Tests that the nonces missing between the state nonce of an account and its
+// highest queued transaction are reported as gaps.
+func TestNonceGaps(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	api := NewPublicTxPoolAPI(backend)
+	send := func(nonce uint64) {
+		tx, _ := types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		if err := backend.pool.Add(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+	}
+	check := func(want []uint64) {
+		gaps, err := api.NonceGaps(context.Background(), testBankAddress)
+		if err != nil {
+			t.Fatalf("failed to retrieve nonce gaps: %v", err)
+		}
+		if !reflect.DeepEqual(gaps, want) {
+			t.Errorf("nonce gap mismatch: have %v, want %v", gaps, want)
+		}
+	}
+	check([]uint64{})
+
+	send(0)
+	send(2)
+	check([]uint64{1})
+
+	send(1)
+	check([]uint64{})
+}
+
+// Tests that transactions replaced in the pool are reported as such if requested,
+// while the default lookup still returns nil for them.
+func TestGetReplacedTransaction(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	api := NewPublicTransactionPoolAPI(backend)
+
+	old, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+	replacement, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(2), nil).SignECDSA(testBankKey)
+	for _, tx := range []*types.Transaction{old, replacement} {
+		if err := backend.pool.Add(tx); err != nil {
+			t.Fatalf("failed to add transaction %x: %v", tx.Hash(), err)
+		}
+	}
+	if result, err := api.GetTransactionByHash(context.Background(), old.Hash(), nil); result != nil || err != nil {
+		t.Errorf("default lookup: have %v, %v, want nil", result, err)
+	}
+	withStatus := true
+	result, err := api.GetTransactionByHash(context.Background(), old.Hash(), &withStatus)
+	if err != nil {
+		t.Fatalf("failed to retrieve replaced transaction: %v", err)
+	}
+	want := &ReplacedTransactionResult{Status: "replaced", ReplacedBy: replacement.Hash()}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("status mismatch: have %+v, want %+v", result, want)
+	}
+	// The replacement itself should be returned as a standard transaction
+	result, err = api.GetTransactionByHash(context.Background(), replacement.Hash(), &withStatus)
+	if tx, ok := result.(*RPCTransaction); err != nil || !ok || tx.Hash != replacement.Hash() {
+		t.Errorf("replacement lookup: have %v, %v, want transaction %x", result, err, replacement.Hash())
+	}
+}
+
+// Tests that bundled transactions are executed in order, each seeing the state
+// changes of the previous ones, without the changes being committed.
+func TestCallBundle(t *testing.T) {
+	var (
+		store  = common.Address{0x01}
+		broken = common.Address{0x02}
+	)
+	// Store the call data into slot 0 if any, otherwise return slot 0
+	code := []byte{
+		byte(vm.CALLDATASIZE), byte(vm.ISZERO), byte(vm.PUSH1), 0x0c, byte(vm.JUMPI),
+		byte(vm.PUSH1), 0x00, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.STOP),
+		byte(vm.JUMPDEST), byte(vm.PUSH1), 0x00, byte(vm.SLOAD), byte(vm.PUSH1), 0x00, byte(vm.MSTORE),
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN),
+	}
+	backend := newTestBackend(t, []testAccount{
+		{Address: store, Code: code},
+		{Address: broken, Code: []byte{byte(vm.PUSH1), 0x00, byte(vm.JUMP)}},
+	}, 0, nil)
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+
+	value := common.BigToHash(big.NewInt(42))
+	encode := func(nonce uint64, to common.Address, data []byte) string {
+		tx, _ := types.NewTransaction(nonce, to, new(big.Int), big.NewInt(100000), big.NewInt(1), data).SignECDSA(testBankKey)
+		raw, _ := rlp.EncodeToBytes(tx)
+		return common.ToHex(raw)
+	}
+	bundle := []string{
+		encode(0, store, value.Bytes()),
+		encode(1, store, nil),
+		encode(2, broken, nil),
+	}
+	results, err := api.CallBundle(context.Background(), bundle, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to call bundle: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("result count mismatch: have %d, want 3", len(results))
+	}
+	if results[0].Failed || results[1].Failed {
+		t.Errorf("storing transactions failed: %+v, %+v", results[0], results[1])
+	}
+	if results[1].ReturnValue != common.ToHex(value.Bytes()) {
+		t.Errorf("dependent return value mismatch: have %s, want %x", results[1].ReturnValue, value)
+	}
+	if !results[2].Failed || results[2].Error == "" {
+		t.Errorf("broken transaction not reported as failed: %+v", results[2])
+	}
+	for i, result := range results {
+		if result.GasUsed.BigInt().Sign() == 0 {
+			t.Errorf("result %d: no gas used", i)
+		}
+	}
+	// Ensure nothing was committed
+	state, _, _ := backend.StateAndHeaderByNumber(rpc.LatestBlockNumber)
+	if stored, _ := state.GetState(context.Background(), store, common.Hash{}); stored != (common.Hash{}) {
+		t.Errorf("bundle changes committed: slot 0 = %x", stored)
+	}
+	if _, err := api.CallBundle(context.Background(), []string{"0x01"}, rpc.LatestBlockNumber); err == nil {
+		t.Errorf("undecodable transaction accepted")
+	}
+}
+
+// Tests that bundled calls are estimated in order, each seeing the state changes
+// of the previous ones, and that failing calls abort the estimation.
+func TestEstimateGasBundle(t *testing.T) {
+	gate := common.Address{0x01}
+
+	// Store the call data into slot 0 if any, otherwise set slot 1 if slot 0 is
+	// already set and fail if not
+	code := []byte{
+		byte(vm.CALLDATASIZE), byte(vm.ISZERO), byte(vm.PUSH1), 0x0c, byte(vm.JUMPI),
+		byte(vm.PUSH1), 0x00, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.STOP),
+		byte(vm.JUMPDEST), byte(vm.PUSH1), 0x00, byte(vm.SLOAD), byte(vm.PUSH1), 0x16, byte(vm.JUMPI),
+		byte(vm.PUSH1), 0x00, byte(vm.JUMP),
+		byte(vm.JUMPDEST), byte(vm.PUSH1), 0x01, byte(vm.PUSH1), 0x01, byte(vm.SSTORE), byte(vm.STOP),
+	}
+	backend := newTestBackend(t, []testAccount{{Address: gate, Code: code}}, 0, nil)
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+
+	open := CallArgs{From: testBankAddress, To: &gate, Data: common.ToHex(common.BigToHash(big.NewInt(1)).Bytes())}
+	pass := CallArgs{From: testBankAddress, To: &gate}
+
+	// The gated call alone should fail, as its precondition is not met
+	if _, err := api.EstimateGasBundle(context.Background(), []CallArgs{pass}, rpc.LatestBlockNumber); err == nil {
+		t.Errorf("failing call estimated")
+	}
+	// Preceded by the call opening the gate, both should be estimated
+	estimates, err := api.EstimateGasBundle(context.Background(), []CallArgs{open, pass}, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to estimate bundle: %v", err)
+	}
+	if len(estimates) != 2 {
+		t.Fatalf("estimate count mismatch: have %d, want 2", len(estimates))
+	}
+	for i, estimate := range estimates {
+		if min := params.TxGas.Uint64() + params.SstoreSetGas.Uint64(); estimate.Uint64() <= min {
+			t.Errorf("estimate %d: have %d, want above %d", i, estimate.Uint64(), min)
+		}
+	}
+	// Ensure nothing was committed
+	state, _, _ := backend.StateAndHeaderByNumber(rpc.LatestBlockNumber)
+	if stored, _ := state.GetState(context.Background(), gate, common.Hash{}); stored != (common.Hash{}) {
+		t.Errorf("bundle changes committed: slot 0 = %x", stored)
+	}
+	if _, err := api.EstimateGasBundle(context.Background(), nil, rpc.LatestBlockNumber); err == nil {
+		t.Errorf("empty bundle accepted")
+	}
+}
+
+// Tests that filling the nonce gap before a queued transaction notifies pool
+// transition subscribers of its promotion.
+func TestPoolTransitions(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	defer backend.close()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", NewPublicTxPoolAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	results := make(chan PoolTransitionResult)
+	sub, err := client.EthSubscribe(context.Background(), results, "newPoolTransitions")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	send := func(nonce uint64) *types.Transaction {
+		tx, _ := types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+		if err := backend.pool.Add(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+		return tx
+	}
+	// Queue a gapped transaction, which must not be promoted
+	gapped := send(1)
+	select {
+	case result := <-results:
+		t.Fatalf("unexpected transition: %+v", result)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// Fill the gap and wait for the gapped transaction to be promoted
+	filler := send(0)
+	promoted := make(map[common.Hash]bool)
+	for len(promoted) < 2 {
+		select {
+		case result := <-results:
+			if result.From != "queued" || result.To != "pending" {
+				t.Errorf("transition of %x mismatch: have %s -> %s, want queued -> pending", result.Hash, result.From, result.To)
+			}
+			promoted[result.Hash] = true
+		case <-time.After(time.Second):
+			t.Fatalf("promotion timeout, have %d promotions", len(promoted))
+		}
+	}
+	if !promoted[gapped.Hash()] || !promoted[filler.Hash()] {
+		t.Errorf("promoted transactions mismatch: have %v, want %x and %x", promoted, gapped.Hash(), filler.Hash())
+	}
+}
+
+// Tests that the pool membership check only reports transactions still pending.
+func TestTransactionInPool(t *testing.T) {
+	mined, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+	backend := newTestBackend(t, nil, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(mined)
+	})
+	defer backend.close()
+
+	pending, _ := types.NewTransaction(1, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+	if err := backend.SendTx(context.Background(), pending); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	api := NewPublicTransactionPoolAPI(backend)
+	tests := []struct {
+		name string
+		hash common.Hash
+		want bool
+	}{
+		{"pending", pending.Hash(), true},
+		{"mined", mined.Hash(), false},
+		{"unknown", common.Hash{0x01}, false},
+	}
+	for _, tt := range tests {
+		have, err := api.TransactionInPool(tt.hash)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if have != tt.want {
+			t.Errorf("%s: pool membership mismatch: have %v, want %v", tt.name, have, tt.want)
+		}
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"golang.org/x/net/context"
 )
@@ -40,6 +41,7 @@ type Backend interface {
 	ProtocolVersion() int
 	SuggestPrice(ctx context.Context) (*big.Int, error)
 	ChainDb() ethdb.Database
+	ChainConfig() *core.ChainConfig
 	EventMux() *event.TypeMux
 	AccountManager() *accounts.Manager
 	// BlockChain API
@@ -50,14 +52,20 @@ type Backend interface {
 	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
 	GetTd(blockHash common.Hash) *big.Int
-	GetVMEnv(ctx context.Context, msg core.Message, state State, header *types.Header) (vm.Environment, func() error, error)
+	GetVMEnv(ctx context.Context, msg core.Message, state State, header *types.Header, tracer vm.Tracer) (vm.Environment, func() error, error)
 	// TxPool API
 	SendTx(ctx context.Context, signedTx *types.Transaction) error
+	ValidateTx(ctx context.Context, signedTx *types.Transaction) error
 	RemoveTx(txHash common.Hash)
 	GetPoolTransactions() types.Transactions
 	GetPoolTransaction(txHash common.Hash) *types.Transaction
+	GetPoolReplacement(txHash common.Hash) (common.Hash, bool)
 	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
 	Stats() (pending int, queued int)
+	MinGasPrice() *big.Int
+	SenderMinGasPrice(addr common.Address) *big.Int
+	StrictChainId() *big.Int // Chain id raw transactions must be signed for, nil if not enforced
+	RestrictUnlock() bool    // Whether indefinite unlocks are only allowed over local endpoints
 	TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
 }
 
@@ -66,6 +74,11 @@ type State interface {
 	GetCode(ctx context.Context, addr common.Address) ([]byte, error)
 	GetState(ctx context.Context, a common.Address, b common.Hash) (common.Hash, error)
 	GetNonce(ctx context.Context, addr common.Address) (uint64, error)
+	GetProof(ctx context.Context, addr common.Address) ([]rlp.RawValue, error)
+	GetStorageProof(ctx context.Context, a common.Address, b common.Hash) (common.Hash, []rlp.RawValue, error)
+	GetStorageRoot(ctx context.Context, addr common.Address) (common.Hash, error)
+	Exist(ctx context.Context, addr common.Address) (bool, error)
+	Copy() State // Independent copy of the state that may be freely modified
 }
 
 func GetAPIs(apiBackend Backend, solcPath string) []rpc.API {
@@ -0,0 +1,165 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/net/context"
+)
+
+// CallResult is the outcome of a single transaction executed by CallBundle.
+type CallResult struct {
+	Hash        common.Hash    `json:"hash"`
+	GasUsed     *rpc.HexNumber `json:"gasUsed"`
+	Failed      bool           `json:"failed"`
+	Error       string         `json:"error,omitempty"`
+	ReturnValue string         `json:"returnValue"`
+}
+
+// failureTracer is an EVM tracer that records the error aborting the outermost
+// call frame of an execution, ignoring failures of nested calls.
+type failureTracer struct {
+	depth int   // Depth of the outermost call frame, zero until the first step
+	err   error // Error aborting the outermost call frame
+}
+
+// CaptureState implements vm.Tracer, remembering top level execution errors.
+func (t *failureTracer) CaptureState(env vm.Environment, pc uint64, op vm.OpCode, gas, cost *big.Int, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) {
+	if t.depth == 0 {
+		t.depth = depth
+	}
+	if err != nil && depth == t.depth {
+		t.err = err
+	}
+}
+
+// CallBundle executes the given raw transactions in order on a copy of the state
+// of the given block, each seeing the effects of the previous ones, and reports
+// the outcome of every one of them. Nonces aren't checked and the senders are
+// credited enough balance to pay for their transactions. Nothing is committed.
+func (s *PublicBlockChainAPI) CallBundle(ctx context.Context, txs []string, blockNr rpc.BlockNumber) ([]CallResult, error) {
+	if len(txs) == 0 {
+		return nil, rpc.ErrInvalidArgs("empty transaction bundle")
+	}
+	bundle := make([]*types.Transaction, len(txs))
+	for i, encoded := range txs {
+		bundle[i] = new(types.Transaction)
+		if err := rlp.DecodeBytes(common.FromHex(encoded), bundle[i]); err != nil {
+			return nil, rpc.ErrInvalidArgs("transaction %d: failed to RLP-decode: %v", i, err)
+		}
+	}
+	state, header, err := s.b.StateAndHeaderByNumber(blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	state = state.Copy()
+
+	results := make([]CallResult, len(bundle))
+	for i, tx := range bundle {
+		from, err := tx.From()
+		if err != nil {
+			return nil, rpc.ErrInvalidArgs("transaction %d: invalid sender: %v", i, err)
+		}
+		msg := callmsg{
+			addr:     from,
+			to:       tx.To(),
+			gas:      tx.Gas(),
+			gasPrice: tx.GasPrice(),
+			value:    tx.Value(),
+			data:     tx.Data(),
+		}
+		tracer := new(failureTracer)
+		vmenv, vmError, err := s.b.GetVMEnv(ctx, msg, state, header, tracer)
+		if err != nil {
+			return nil, err
+		}
+		ret, gas, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(common.MaxBig))
+		if err == nil {
+			err = vmError()
+		}
+		if err != nil {
+			return nil, rpc.ErrWrap(err, "transaction %d", i)
+		}
+		if db, ok := vmenv.Db().(interface {
+			DeleteSuicides()
+		}); ok {
+			db.DeleteSuicides()
+		}
+		results[i] = CallResult{
+			Hash:        tx.Hash(),
+			GasUsed:     rpc.NewHexNumber(gas),
+			Failed:      tracer.err != nil,
+			ReturnValue: common.ToHex(ret),
+		}
+		if tracer.err != nil {
+			results[i].Error = tracer.err.Error()
+		}
+	}
+	return results, nil
+}
+
+// EstimateGasBundle estimates the gas needed by each of the given calls when
+// executed in order on a copy of the state of the given block, each seeing the
+// effects of the previous ones (e.g. a token approval followed by a transfer
+// relying on it). A call failing at the top level aborts the estimation, as its
+// estimate would be meaningless. Nothing is committed.
+func (s *PublicBlockChainAPI) EstimateGasBundle(ctx context.Context, calls []CallArgs, blockNr rpc.BlockNumber) ([]*rpc.HexNumber, error) {
+	if len(calls) == 0 {
+		return nil, rpc.ErrInvalidArgs("empty call bundle")
+	}
+	state, header, err := s.b.StateAndHeaderByNumber(blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	state = state.Copy()
+
+	estimates := make([]*rpc.HexNumber, len(calls))
+	for i, args := range calls {
+		msg := s.callMessage(args)
+
+		tracer := new(failureTracer)
+		vmenv, vmError, err := s.b.GetVMEnv(ctx, msg, state, header, tracer)
+		if err != nil {
+			return nil, err
+		}
+		_, gas, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(common.MaxBig))
+		if err == nil {
+			err = vmError()
+		}
+		if err != nil {
+			return nil, rpc.ErrWrap(err, "call %d", i)
+		}
+		if tracer.err != nil {
+			return nil, fmt.Errorf("call %d failed: %v", i, tracer.err)
+		}
+		if db, ok := vmenv.Db().(interface {
+			DeleteSuicides()
+		}); ok {
+			db.DeleteSuicides()
+		}
+		estimates[i] = rpc.NewHexNumber(gas)
+	}
+	return estimates, nil
+}
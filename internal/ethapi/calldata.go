@@ -0,0 +1,225 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// packCallData ABI encodes a contract method call from its signature and JSON
+// decoded arguments. The signature is either the canonical one of the method,
+// e.g. "transfer(address,uint256)", or just its name if the JSON ABI of the
+// contract is given to resolve it against.
+func packCallData(signature string, contract string, args []interface{}) ([]byte, error) {
+	var method abi.Method
+	if contract != "" {
+		parsed, err := abi.JSON(strings.NewReader(contract))
+		if err != nil {
+			return nil, fmt.Errorf("invalid contract ABI: %v", err)
+		}
+		name := signature
+		if i := strings.IndexByte(signature, '('); i >= 0 {
+			name = signature[:i]
+		}
+		var ok bool
+		if method, ok = parsed.Methods[name]; !ok {
+			return nil, fmt.Errorf("method %q not found in contract ABI", name)
+		}
+		if name != signature && method.Sig() != signature {
+			return nil, fmt.Errorf("method signature mismatch: have %s, contract ABI has %s", signature, method.Sig())
+		}
+	} else {
+		var err error
+		if method, err = parseMethodSignature(signature); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) != len(method.Inputs) {
+		return nil, fmt.Errorf("argument count mismatch: have %d, want %d for %s", len(args), len(method.Inputs), method.Sig())
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		value, err := abiValue(method.Inputs[i].Type, arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i, err)
+		}
+		values[i] = value.Interface()
+	}
+	return abi.ABI{Methods: map[string]abi.Method{method.Name: method}}.Pack(method.Name, values...)
+}
+
+// parseMethodSignature creates the ABI description of a method from its
+// signature, e.g. "transfer(address,uint256)".
+func parseMethodSignature(signature string) (abi.Method, error) {
+	lparen, rparen := strings.IndexByte(signature, '('), strings.LastIndexByte(signature, ')')
+	if lparen <= 0 || rparen != len(signature)-1 {
+		return abi.Method{}, fmt.Errorf("invalid method signature %q", signature)
+	}
+	method := abi.Method{Name: signature[:lparen]}
+	if params := signature[lparen+1 : rparen]; params != "" {
+		for _, param := range strings.Split(params, ",") {
+			typ, err := abi.NewType(strings.TrimSpace(param))
+			if err != nil {
+				return abi.Method{}, fmt.Errorf("invalid method signature %q: %v", signature, err)
+			}
+			method.Inputs = append(method.Inputs, abi.Argument{Type: typ})
+		}
+	}
+	return method, nil
+}
+
+var (
+	bigType   = reflect.TypeOf(new(big.Int))
+	bytesType = reflect.TypeOf([]byte(nil))
+
+	// intTypes are the Go types the ABI packer expects for sized integers.
+	intTypes = map[reflect.Kind]reflect.Type{
+		reflect.Int8: reflect.TypeOf(int8(0)), reflect.Int16: reflect.TypeOf(int16(0)),
+		reflect.Int32: reflect.TypeOf(int32(0)), reflect.Int64: reflect.TypeOf(int64(0)),
+		reflect.Uint8: reflect.TypeOf(uint8(0)), reflect.Uint16: reflect.TypeOf(uint16(0)),
+		reflect.Uint32: reflect.TypeOf(uint32(0)), reflect.Uint64: reflect.TypeOf(uint64(0)),
+	}
+)
+
+// abiGoType returns the Go type the ABI packer expects for values of t.
+func abiGoType(t abi.Type) reflect.Type {
+	switch {
+	case t.T == abi.BytesTy || t.T == abi.FixedBytesTy:
+		return bytesType
+	case t.IsSlice || t.IsArray:
+		return reflect.SliceOf(abiGoType(*t.Elem))
+	case t.T == abi.IntTy || t.T == abi.UintTy:
+		if typ, ok := intTypes[t.Kind]; ok {
+			return typ
+		}
+		return bigType
+	case t.T == abi.AddressTy:
+		return reflect.TypeOf(common.Address{})
+	case t.T == abi.BoolTy:
+		return reflect.TypeOf(false)
+	default:
+		return reflect.TypeOf("")
+	}
+}
+
+// abiValue converts a JSON decoded argument into the Go value the ABI packer
+// expects for type t. Integers may be given as decimal or hex strings, or as
+// JSON numbers if small enough to be represented exactly; addresses and byte
+// arrays as hex strings.
+func abiValue(t abi.Type, arg interface{}) (reflect.Value, error) {
+	switch {
+	case t.T == abi.BytesTy || t.T == abi.FixedBytesTy:
+		s, ok := arg.(string)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%v: want hex string, have %T", t, arg)
+		}
+		b := common.FromHex(s)
+		if t.T == abi.FixedBytesTy && len(b) != t.SliceSize {
+			return reflect.Value{}, fmt.Errorf("%v: want %d bytes, have %d", t, t.SliceSize, len(b))
+		}
+		return reflect.ValueOf(b), nil
+
+	case t.IsSlice || t.IsArray:
+		list, ok := arg.([]interface{})
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%v: want array, have %T", t, arg)
+		}
+		if t.IsArray && len(list) != t.SliceSize {
+			return reflect.Value{}, fmt.Errorf("%v: want %d elements, have %d", t, t.SliceSize, len(list))
+		}
+		slice := reflect.MakeSlice(abiGoType(t), len(list), len(list))
+		for i, elem := range list {
+			value, err := abiValue(*t.Elem, elem)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %v", i, err)
+			}
+			slice.Index(i).Set(value)
+		}
+		return slice, nil
+
+	case t.T == abi.IntTy || t.T == abi.UintTy:
+		n, err := abiInteger(arg)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%v: %v", t, err)
+		}
+		bits := n.BitLen()
+		if n.Sign() < 0 {
+			bits = new(big.Int).Not(n).BitLen() // two's complement magnitude
+		}
+		if (t.T == abi.UintTy && (n.Sign() < 0 || bits > t.Size)) || (t.T == abi.IntTy && bits >= t.Size) {
+			return reflect.Value{}, fmt.Errorf("%v: %v out of range", t, n)
+		}
+		typ := abiGoType(t)
+		if typ == bigType {
+			return reflect.ValueOf(n), nil
+		}
+		value := reflect.New(typ).Elem()
+		if t.T == abi.UintTy {
+			value.SetUint(n.Uint64())
+		} else {
+			value.SetInt(n.Int64())
+		}
+		return value, nil
+
+	case t.T == abi.AddressTy:
+		s, ok := arg.(string)
+		if !ok || !common.IsHexAddress(s) {
+			return reflect.Value{}, fmt.Errorf("%v: invalid address %v", t, arg)
+		}
+		return reflect.ValueOf(common.HexToAddress(s)), nil
+
+	case t.T == abi.BoolTy:
+		b, ok := arg.(bool)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%v: want bool, have %T", t, arg)
+		}
+		return reflect.ValueOf(b), nil
+
+	case t.T == abi.StringTy:
+		s, ok := arg.(string)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%v: want string, have %T", t, arg)
+		}
+		return reflect.ValueOf(s), nil
+	}
+	return reflect.Value{}, fmt.Errorf("unsupported argument type %v", t)
+}
+
+// abiInteger parses a JSON decoded integer argument.
+func abiInteger(arg interface{}) (*big.Int, error) {
+	switch arg := arg.(type) {
+	case string:
+		n, ok := new(big.Int).SetString(arg, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", arg)
+		}
+		return n, nil
+	case float64:
+		if arg != math.Trunc(arg) || math.Abs(arg) > 1<<53 {
+			return nil, fmt.Errorf("inexact integer %v, pass it as a string", arg)
+		}
+		return big.NewInt(int64(arg)), nil
+	}
+	return nil, fmt.Errorf("want integer, have %T", arg)
+}
@@ -0,0 +1,289 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// This file contains some shared testing functionality, common to multiple
+// different files and modules being tested.
+
+package ethapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/net/context"
+)
+
+var (
+	testBankKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testBankAddress = crypto.PubkeyToAddress(testBankKey.PublicKey)
+	testBankFunds   = big.NewInt(1000000000000000000)
+	testGenesisTime = uint64(1000000)
+)
+
+// testAccount is a genesis allocation used to initialize the test chain.
+type testAccount struct {
+	Address common.Address
+	Balance *big.Int
+	Code    []byte
+	Storage map[common.Hash]common.Hash
+}
+
+// testBackend is a minimal ethapi.Backend implementation backed by a real
+// blockchain and transaction pool, but without networking or mining.
+type testBackend struct {
+	db     ethdb.Database
+	mux    *event.TypeMux
+	config *core.ChainConfig
+	chain  *core.BlockChain
+	pool   *core.TxPool
+	am     *accounts.Manager
+	keydir string
+
+	strictChainId  bool // Whether raw transactions must be signed for the chain id
+	restrictUnlock bool // Whether indefinite unlocks are only allowed over local endpoints
+}
+
+// newTestBackend creates a chain with the test bank and the given accounts in
+// genesis, then generates and imports the requested number of blocks.
+func newTestBackend(t *testing.T, alloc []testAccount, blocks int, generator func(int, *core.BlockGen)) *testBackend {
+	db, _ := ethdb.NewMemDatabase()
+
+	accountJson := fmt.Sprintf(`"0x%x":{"balance":"%v"}`, testBankAddress, testBankFunds)
+	for _, account := range alloc {
+		balance := account.Balance
+		if balance == nil {
+			balance = new(big.Int)
+		}
+		storage := make([]string, 0, len(account.Storage))
+		for key, value := range account.Storage {
+			storage = append(storage, fmt.Sprintf(`"%x":"%x"`, key, value))
+		}
+		accountJson += fmt.Sprintf(`,"0x%x":{"balance":"%v","code":"%x","storage":{%s}}`, account.Address, balance, account.Code, strings.Join(storage, ","))
+	}
+	genesis, err := core.WriteGenesisBlock(db, strings.NewReader(fmt.Sprintf(`{
+	"nonce":"0x%x",
+	"timestamp":"0x%x",
+	"gasLimit":"0x%x",
+	"difficulty":"0x%x",
+	"alloc": {%s}
+}`, types.EncodeNonce(0), testGenesisTime, params.GenesisGasLimit.Bytes(), params.GenesisDifficulty.Bytes(), accountJson)))
+	if err != nil {
+		t.Fatalf("failed to write genesis: %v", err)
+	}
+	var (
+		mux    = new(event.TypeMux)
+		config = core.MakeChainConfig()
+	)
+	chain, err := core.NewBlockChain(db, config, core.FakePow{}, mux)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	blocksChain, _ := core.GenerateChain(nil, genesis, db, blocks, generator)
+	if _, err := chain.InsertChain(blocksChain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	keydir, err := ioutil.TempDir("", "ethapi-test")
+	if err != nil {
+		t.Fatalf("failed to create key directory: %v", err)
+	}
+	pool := core.NewTxPool(config, mux, chain.State, chain.GasLimit)
+	pool.Pending() // Initializes the pending state of the pool
+
+	return &testBackend{
+		db:     db,
+		mux:    mux,
+		config: config,
+		chain:  chain,
+		pool:   pool,
+		am:     accounts.NewManager(keydir, accounts.LightScryptN, accounts.LightScryptP),
+		keydir: keydir,
+	}
+}
+
+// close tears down the background goroutines and temporary files of the backend.
+func (b *testBackend) close() {
+	b.pool.Stop()
+	b.chain.Stop()
+	os.RemoveAll(b.keydir)
+}
+
+func (b *testBackend) Downloader() *downloader.Downloader { return nil }
+func (b *testBackend) ProtocolVersion() int               { return 63 }
+func (b *testBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(20000000000), nil
+}
+func (b *testBackend) ChainDb() ethdb.Database              { return b.db }
+func (b *testBackend) ChainConfig() *core.ChainConfig       { return b.config }
+func (b *testBackend) EventMux() *event.TypeMux             { return b.mux }
+func (b *testBackend) AccountManager() *accounts.Manager    { return b.am }
+func (b *testBackend) SetHead(number uint64)                { b.chain.SetHead(number) }
+func (b *testBackend) GetTd(blockHash common.Hash) *big.Int { return b.chain.GetTdByHash(blockHash) }
+
+func (b *testBackend) HeaderByNumber(blockNr rpc.BlockNumber) *types.Header {
+	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
+		return b.chain.CurrentBlock().Header()
+	}
+	return b.chain.GetHeaderByNumber(uint64(blockNr))
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
+	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
+		return b.chain.CurrentBlock(), nil
+	}
+	return b.chain.GetBlockByNumber(uint64(blockNr)), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(blockNr rpc.BlockNumber) (State, *types.Header, error) {
+	header := b.HeaderByNumber(blockNr)
+	if header == nil {
+		return nil, nil, nil
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return testState{statedb}, header, err
+}
+
+func (b *testBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
+	return b.chain.GetBlockByHash(blockHash), nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
+	return core.GetBlockReceipts(b.db, blockHash, core.GetBlockNumber(b.db, blockHash)), nil
+}
+
+func (b *testBackend) GetVMEnv(ctx context.Context, msg core.Message, st State, header *types.Header, tracer vm.Tracer) (vm.Environment, func() error, error) {
+	statedb := st.(testState).state
+	addr, _ := msg.From()
+	statedb.GetOrNewStateObject(addr).SetBalance(common.MaxBig)
+
+	config := vm.Config{}
+	if tracer != nil {
+		config.Debug, config.Tracer = true, tracer
+	}
+	return core.NewEnv(statedb, b.config, b.chain, msg, header, config), func() error { return nil }, nil
+}
+
+func (b *testBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
+	b.pool.SetLocal(signedTx)
+	return b.pool.Add(signedTx)
+}
+
+func (b *testBackend) ValidateTx(ctx context.Context, signedTx *types.Transaction) error {
+	return b.pool.Validate(signedTx)
+}
+
+func (b *testBackend) RemoveTx(txHash common.Hash) { b.pool.Remove(txHash) }
+
+func (b *testBackend) GetPoolTransactions() types.Transactions {
+	var txs types.Transactions
+	for _, batch := range b.pool.Pending() {
+		txs = append(txs, batch...)
+	}
+	return txs
+}
+
+func (b *testBackend) GetPoolTransaction(hash common.Hash) *types.Transaction {
+	return b.pool.Get(hash)
+}
+
+func (b *testBackend) GetPoolReplacement(hash common.Hash) (common.Hash, bool) {
+	return b.pool.ReplacedBy(hash)
+}
+
+func (b *testBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return b.pool.State().GetNonce(addr), nil
+}
+
+func (b *testBackend) Stats() (pending int, queued int) { return b.pool.Stats() }
+func (b *testBackend) MinGasPrice() *big.Int            { return b.pool.MinGasPrice() }
+
+func (b *testBackend) SenderMinGasPrice(addr common.Address) *big.Int {
+	if price := b.pool.SenderMinGasPrice(addr); price != nil {
+		return price
+	}
+	return b.pool.MinGasPrice()
+}
+
+func (b *testBackend) StrictChainId() *big.Int {
+	if !b.strictChainId {
+		return nil
+	}
+	return b.config.ChainId
+}
+
+func (b *testBackend) RestrictUnlock() bool { return b.restrictUnlock }
+
+func (b *testBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	return b.pool.Content()
+}
+
+// testState wraps a full state database to implement the ethapi.State interface.
+type testState struct {
+	state *state.StateDB
+}
+
+func (s testState) GetBalance(ctx context.Context, addr common.Address) (*big.Int, error) {
+	return s.state.GetBalance(addr), nil
+}
+
+func (s testState) GetCode(ctx context.Context, addr common.Address) ([]byte, error) {
+	return s.state.GetCode(addr), nil
+}
+
+func (s testState) GetState(ctx context.Context, a common.Address, b common.Hash) (common.Hash, error) {
+	return s.state.GetState(a, b), nil
+}
+
+func (s testState) GetNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return s.state.GetNonce(addr), nil
+}
+
+func (s testState) GetProof(ctx context.Context, addr common.Address) ([]rlp.RawValue, error) {
+	return s.state.GetProof(addr), nil
+}
+
+func (s testState) GetStorageProof(ctx context.Context, a common.Address, b common.Hash) (common.Hash, []rlp.RawValue, error) {
+	root, proof := s.state.GetStorageProof(a, b)
+	return root, proof, nil
+}
+
+func (s testState) GetStorageRoot(ctx context.Context, addr common.Address) (common.Hash, error) {
+	return s.state.GetStorageRoot(addr), nil
+}
+
+func (s testState) Exist(ctx context.Context, addr common.Address) (bool, error) {
+	return s.state.Exist(addr), nil
+}
+
+func (s testState) Copy() State {
+	return testState{s.state.Copy()}
+}
@@ -261,6 +261,91 @@ web3._extend({
 			name: 'httpGet',
 			call: 'admin_httpGet',
 			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'dropPeer',
+			call: 'admin_dropPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'peerInfo',
+			call: 'admin_peerInfo',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setMaxReorgDepth',
+			call: 'admin_setMaxReorgDepth',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'exportPool',
+			call: 'admin_exportPool',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'importPool',
+			call: 'admin_importPool',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setResponseLimits',
+			call: 'admin_setResponseLimits',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'setSenderPolicy',
+			call: 'admin_setSenderPolicy',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'setSenderMinGasPrice',
+			call: 'admin_setSenderMinGasPrice',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'setTxBroadcastWindow',
+			call: 'admin_setTxBroadcastWindow',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setMaxDebugCalls',
+			call: 'admin_setMaxDebugCalls',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setMinServingPeers',
+			call: 'admin_setMinServingPeers',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setTxBumping',
+			call: 'admin_setTxBumping',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'setRPCTimeout',
+			call: 'admin_setRPCTimeout',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'dumpPoolToFile',
+			call: 'admin_dumpPoolToFile',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'loadPoolFromFile',
+			call: 'admin_loadPoolFromFile',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'repairCanonical',
+			call: 'admin_repairCanonical',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'setPoolPressureThresholds',
+			call: 'admin_setPoolPressureThresholds',
+			params: 1
 		})
 	],
 	properties:
@@ -276,6 +361,42 @@ web3._extend({
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
+		}),
+		new web3._extend.Property({
+			name: 'maxReorgDepth',
+			getter: 'admin_maxReorgDepth'
+		}),
+		new web3._extend.Property({
+			name: 'responseLimits',
+			getter: 'admin_responseLimits'
+		}),
+		new web3._extend.Property({
+			name: 'txBroadcastWindow',
+			getter: 'admin_txBroadcastWindow'
+		}),
+		new web3._extend.Property({
+			name: 'maxDebugCalls',
+			getter: 'admin_maxDebugCalls'
+		}),
+		new web3._extend.Property({
+			name: 'minServingPeers',
+			getter: 'admin_minServingPeers'
+		}),
+		new web3._extend.Property({
+			name: 'txBumping',
+			getter: 'admin_txBumping'
+		}),
+		new web3._extend.Property({
+			name: 'rpcTimeouts',
+			getter: 'admin_rpcTimeouts'
+		}),
+		new web3._extend.Property({
+			name: 'poolPressureThresholds',
+			getter: 'admin_poolPressureThresholds'
+		}),
+		new web3._extend.Property({
+			name: 'configuredPeers',
+			getter: 'admin_configuredPeers'
 		})
 	]
 });
@@ -428,6 +549,94 @@ web3._extend({
 			call: 'debug_traceTransaction',
 			params: 2,
 			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getBadBlocks',
+			call: 'debug_getBadBlocks',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'verifyState',
+			call: 'debug_verifyState',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getRawHeaderByNumber',
+			call: 'debug_getRawHeaderByNumber',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getRawHeaderByHash',
+			call: 'debug_getRawHeaderByHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'reindexTransactions',
+			call: 'debug_reindexTransactions',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'verifyHeaders',
+			call: 'debug_verifyHeaders',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setCanonicalHead',
+			call: 'debug_setCanonicalHead',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getBlockBody',
+			call: 'debug_getBlockBody',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'tracePendingTransaction',
+			call: 'debug_tracePendingTransaction',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'processBlock',
+			call: 'debug_processBlock',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'replayBlocks',
+			call: 'debug_replayBlocks',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'selfCheck',
+			call: 'debug_selfCheck',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'traceBlockTransactions',
+			call: 'debug_traceBlockTransactions',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'stateSizeDelta',
+			call: 'debug_stateSizeDelta',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'futureBlocks',
+			call: 'debug_futureBlocks',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'setModuleVerbosity',
+			call: 'debug_setModuleVerbosity',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'getAccountActivity',
+			call: 'debug_getAccountActivity',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
 		})
 	],
 	properties: []
@@ -481,6 +690,124 @@ web3._extend({
 			},
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.toHex]
+		}),
+		new web3._extend.Method({
+			name: 'createAccessList',
+			call: 'eth_createAccessList',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'suggestFees',
+			call: 'eth_suggestFees',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'gasStats',
+			call: 'eth_gasStats',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'feeHistory',
+			call: 'eth_feeHistory',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'buildUnsignedTransaction',
+			call: 'eth_buildUnsignedTransaction',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'submitSignedTransaction',
+			call: 'eth_submitSignedTransaction',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getContractAddress',
+			call: 'eth_getContractAddress',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'getCreate2Address',
+			call: 'eth_getCreate2Address',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'signTransactions',
+			call: 'eth_signTransactions',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getBlockByTimestamp',
+			call: 'eth_getBlockByTimestamp',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getStorageAtVerified',
+			call: 'eth_getStorageAtVerified',
+			params: 3,
+			inputFormatter: [null, null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'oldestBlock',
+			call: 'eth_oldestBlock',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'callBundle',
+			call: 'eth_callBundle',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getStorageRoot',
+			call: 'eth_getStorageRoot',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'validateRawTransaction',
+			call: 'eth_validateRawTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getTransactionReceipts',
+			call: 'eth_getTransactionReceipts',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getTransactionPosition',
+			call: 'eth_getTransactionPosition',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'estimateGasBundle',
+			call: 'eth_estimateGasBundle',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'transactionInPool',
+			call: 'eth_transactionInPool',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getCanonicalHash',
+			call: 'eth_getCanonicalHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'resendDetailed',
+			call: 'eth_resendDetailed',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputTransactionFormatter, web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal]
 		})
 	],
 	properties:
@@ -496,6 +823,15 @@ web3._extend({
 				}
 				return formatted;
 			}
+		}),
+		new web3._extend.Property({
+			name: 'minGasPrice',
+			getter: 'eth_minGasPrice',
+			outputFormatter: web3._extend.utils.toBigNumber
+		}),
+		new web3._extend.Property({
+			name: 'intrinsicGas',
+			getter: 'eth_intrinsicGas'
 		})
 	]
 });
@@ -548,6 +884,46 @@ web3._extend({
 			call: 'miner_makeDAG',
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'setTxOrdering',
+			call: 'miner_setTxOrdering',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'predictBlockTransactions',
+			call: 'miner_predictBlockTransactions',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'minerState',
+			call: 'miner_minerState',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'setAutoDAGLookahead',
+			call: 'miner_setAutoDAGLookahead',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'dagProgress',
+			call: 'miner_dAGProgress',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'cancelDAG',
+			call: 'miner_cancelDAG',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setEtherbaseRotation',
+			call: 'miner_setEtherbaseRotation',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'pendingBlockFeeStats',
+			call: 'miner_pendingBlockFeeStats',
+			params: 0
 		})
 	],
 	properties: []
@@ -583,6 +959,22 @@ web3._extend({
 			call: 'personal_sendTransaction',
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'accountsWithState',
+			call: 'personal_accountsWithState',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'verifyPassword',
+			call: 'personal_verifyPassword',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'changePassword',
+			call: 'personal_changePassword',
+			params: 3
 		})
 	]
 });
@@ -620,7 +1012,14 @@ web3._extend({
 const TxPool_JS = `
 web3._extend({
 	property: 'txpool',
-	methods: [],
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'nonceGaps',
+			call: 'txpool_nonceGaps',
+			params: 1
+		})
+	],
 	properties:
 	[
 		new web3._extend.Property({
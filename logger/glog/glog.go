@@ -348,6 +348,37 @@ func (m *moduleSpec) Set(value string) error {
 	return nil
 }
 
+// SetModule sets the verbosity of the source files matching a single vmodule
+// pattern, e.g. "eth/downloader=3" for the pattern "eth/downloader" and level 3,
+// keeping the levels of the other patterns. The pattern takes precedence over
+// any existing one it overlaps with. A zero level removes the pattern.
+func (m *moduleSpec) SetModule(pattern string, level int) error {
+	if len(pattern) == 0 || strings.ContainsAny(pattern, ",=") {
+		return errVmoduleSyntax
+	}
+	if level < 0 {
+		return errors.New("negative value for vmodule level")
+	}
+	re, err := compileModulePattern(pattern)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+
+	var filter []modulePat
+	if level > 0 {
+		filter = append(filter, modulePat{re, Level(level)})
+	}
+	for _, f := range m.filter {
+		if f.pattern.String() != re.String() {
+			filter = append(filter, f)
+		}
+	}
+	logging.setVState(logging.verbosity, filter, true)
+	return nil
+}
+
 // compiles a vmodule pattern to a regular expression.
 func compileModulePattern(pat string) (*regexp.Regexp, error) {
 	re := ".*"
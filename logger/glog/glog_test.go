@@ -290,6 +290,49 @@ func TestVmoduleOff(t *testing.T) {
 	}
 }
 
+// Test that setting the verbosity of a single module only changes the threshold
+// of that module, keeping the levels of the others.
+func TestVmoduleSetModule(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	logging.vmodule.Set("notthisfile=3")
+	defer logging.vmodule.Set("")
+
+	if V(1) {
+		t.Fatal("V enabled for 1 before setting module")
+	}
+	if err := logging.vmodule.SetModule("logger/glog", 2); err != nil {
+		t.Fatalf("failed to set module level: %v", err)
+	}
+	if !V(2) {
+		t.Error("V not enabled for 2")
+	}
+	if V(3) {
+		t.Error("V enabled for 3")
+	}
+	if have, want := logging.vmodule.String(), `.*/logger/glog/[^/]+\.go$=2,.*/notthisfile/[^/]+\.go$=3`; have != want {
+		t.Errorf("filter mismatch: have %q, want %q", have, want)
+	}
+	// Removing the module level should leave the other module untouched
+	if err := logging.vmodule.SetModule("logger/glog", 0); err != nil {
+		t.Fatalf("failed to remove module level: %v", err)
+	}
+	if V(1) {
+		t.Error("V enabled for 1 after removing module")
+	}
+	if have, want := logging.vmodule.String(), `.*/notthisfile/[^/]+\.go$=3`; have != want {
+		t.Errorf("filter mismatch: have %q, want %q", have, want)
+	}
+	for _, module := range []string{"", "a=1", "a,b"} {
+		if err := logging.vmodule.SetModule(module, 1); err == nil {
+			t.Errorf("invalid module %q accepted", module)
+		}
+	}
+	if err := logging.vmodule.SetModule("logger/glog", -1); err == nil {
+		t.Error("negative level accepted")
+	}
+}
+
 var patternTests = []struct{ input, want string }{
 	{"foo/bar/x.go", ".*/foo/bar/x\\.go$"},
 	{"foo/*/x.go", ".*/foo(/.*)?/x\\.go$"},
@@ -20,6 +20,7 @@ package miner
 import (
 	"fmt"
 	"math/big"
+	"sync"
 	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/accounts"
@@ -116,6 +117,24 @@ func (m *Miner) SetGasPrice(price *big.Int) {
 	m.worker.setGasPrice(price)
 }
 
+// SetTxOrdering sets the strategy used to select transactions from the pool into
+// new blocks, either by gas price or in first-in-first-out order.
+func (self *Miner) SetTxOrdering(mode string) error {
+	switch mode {
+	case TxOrderingPrice, TxOrderingFIFO:
+		self.worker.setTxOrdering(mode)
+		return nil
+	default:
+		return fmt.Errorf("unknown transaction ordering %q, want %q or %q", mode, TxOrderingPrice, TxOrderingFIFO)
+	}
+}
+
+// PredictTransactions returns the pool transactions the next block would include
+// given the current gas limit and ordering, without sealing anything.
+func (self *Miner) PredictTransactions() (types.Transactions, error) {
+	return self.worker.predictTransactions()
+}
+
 func (self *Miner) Start(coinbase common.Address, threads int) {
 	atomic.StoreInt32(&self.shouldStart, 1)
 	self.threads = threads
@@ -177,16 +196,73 @@ func (self *Miner) SetExtra(extra []byte) error {
 		return fmt.Errorf("Extra exceeds max length. %d > %v", len(extra), params.MaximumExtraDataSize)
 	}
 
-	self.worker.extra = extra
+	self.worker.setExtra(extra)
 	return nil
 }
 
+// Extra returns the extra data included in mined blocks.
+func (self *Miner) Extra() []byte {
+	return self.worker.getExtra()
+}
+
+// GasPrice returns the minimum gas price transactions need to pay to be included
+// in mined blocks.
+func (self *Miner) GasPrice() *big.Int {
+	return self.worker.getGasPrice()
+}
+
+// Coinbase returns the address mining rewards are credited to.
+func (self *Miner) Coinbase() common.Address {
+	return self.worker.getCoinbase()
+}
+
+// Agents returns the number of mining agents registered with the miner.
+func (self *Miner) Agents() int {
+	return self.worker.agentCount()
+}
+
 // Pending returns the currently pending block and associated state.
 func (self *Miner) Pending() (*types.Block, *state.StateDB) {
 	return self.worker.pending()
 }
 
+// PendingReceipts returns the pending block along with the receipts of its
+// transactions.
+func (self *Miner) PendingReceipts() (*types.Block, types.Receipts) {
+	snap := self.worker.pendingSnapshot()
+	return snap.block, snap.receipts
+}
+
+// PendingSnapshot returns the pending block along with its state, shared by all
+// callers until the pending block is rebuilt. Unlike with Pending, the state is
+// not copied, so it must not be modified and may only be accessed while holding
+// the returned lock.
+func (self *Miner) PendingSnapshot() (*types.Block, *state.StateDB, sync.Locker) {
+	snap := self.worker.pendingSnapshot()
+	return snap.block, snap.state, &snap.lock
+}
+
 func (self *Miner) SetEtherbase(addr common.Address) {
 	self.coinbase = addr
 	self.worker.setEtherbase(addr)
 }
+
+// SetEtherbaseRotation makes successive blocks credit the given addresses in
+// round-robin order instead of the etherbase, e.g. for privacy or accounting.
+// An empty list disables the rotation, falling back to the etherbase. Lists
+// containing the zero address are refused.
+func (self *Miner) SetEtherbaseRotation(addrs []common.Address) bool {
+	for _, addr := range addrs {
+		if addr == (common.Address{}) {
+			return false
+		}
+	}
+	self.worker.setEtherbaseRotation(append([]common.Address(nil), addrs...))
+	return true
+}
+
+// EtherbaseRotation returns the addresses successive blocks credit in turn, or
+// nil if the etherbase is credited by all blocks.
+func (self *Miner) EtherbaseRotation() []common.Address {
+	return self.worker.getEtherbaseRotation()
+}
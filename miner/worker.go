@@ -46,6 +46,20 @@ const (
 	miningLogAtDepth = 5
 )
 
+// Transaction ordering strategies the block builder can use to select
+// transactions from the pool.
+const (
+	TxOrderingPrice = "price" // Highest gas price first
+	TxOrderingFIFO  = "fifo"  // First seen first
+)
+
+// txSet is an ordered set of transactions which honours account nonces.
+type txSet interface {
+	Peek() *types.Transaction
+	Shift()
+	Pop()
+}
+
 // Agent can register themself with the worker
 type Agent interface {
 	Work() chan<- *Work
@@ -108,13 +122,23 @@ type worker struct {
 	proc    core.Validator
 	chainDb ethdb.Database
 
-	coinbase common.Address
-	gasPrice *big.Int
-	extra    []byte
+	coinbase   common.Address
+	rotation   []common.Address // Coinbases credited in turn by block number, overriding coinbase if set
+	gasPrice   *big.Int
+	extra      []byte
+	txOrdering string
 
 	currentMu sync.Mutex
 	current   *Work
 
+	pendingGen uint64           // Generation of the pending block, bumped on every rebuild (atomic)
+	snapshotMu sync.Mutex       // Protects the cached pending snapshot
+	snapshot   *pendingSnapshot // Pending block and state shared by readers until rebuilt
+
+	postMu      sync.Mutex    // Protects the pending block awaiting announcement
+	postBlock   *types.Block  // Latest pending block not yet announced
+	postTrigger chan struct{} // Wakes the announcer when a block is awaiting announcement
+
 	uncleMu        sync.Mutex
 	possibleUncles map[common.Hash]*types.Block
 
@@ -136,16 +160,19 @@ func newWorker(config *core.ChainConfig, coinbase common.Address, eth Backend, m
 		chainDb:        eth.ChainDb(),
 		recv:           make(chan *Result, resultQueueSize),
 		gasPrice:       new(big.Int),
+		txOrdering:     TxOrderingPrice,
 		chain:          eth.BlockChain(),
 		proc:           eth.BlockChain().Validator(),
 		possibleUncles: make(map[common.Hash]*types.Block),
 		coinbase:       coinbase,
 		txQueue:        make(map[common.Hash]*types.Transaction),
 		agents:         make(map[Agent]struct{}),
+		postTrigger:    make(chan struct{}, 1),
 		fullValidation: false,
 	}
 	worker.events = worker.mux.Subscribe(core.ChainHeadEvent{}, core.ChainSideEvent{}, core.TxPreEvent{})
 	go worker.update()
+	go worker.announcePendingBlocks()
 
 	go worker.wait()
 	worker.commitNewWork()
@@ -159,19 +186,108 @@ func (self *worker) setEtherbase(addr common.Address) {
 	self.coinbase = addr
 }
 
+// setEtherbaseRotation sets the coinbases successive blocks are credited to in
+// round-robin order, an empty list reverting to the single etherbase.
+func (self *worker) setEtherbaseRotation(addrs []common.Address) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.rotation = addrs
+}
+
+func (self *worker) getEtherbaseRotation() []common.Address {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return append([]common.Address(nil), self.rotation...)
+}
+
+// coinbaseAt returns the coinbase credited by the block with the given number.
+// The caller must hold the worker lock.
+func (self *worker) coinbaseAt(number *big.Int) common.Address {
+	if len(self.rotation) == 0 {
+		return self.coinbase
+	}
+	return self.rotation[new(big.Int).Mod(number, big.NewInt(int64(len(self.rotation)))).Int64()]
+}
+
+func (self *worker) getCoinbase() common.Address {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.coinbase
+}
+
+func (self *worker) setExtra(extra []byte) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.extra = extra
+}
+
+func (self *worker) getExtra() []byte {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return common.CopyBytes(self.extra)
+}
+
+func (self *worker) getGasPrice() *big.Int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return new(big.Int).Set(self.gasPrice)
+}
+
+// senderGasPrice returns the minimum gas price transactions of the given sender
+// need to pay to be included, honouring any override set in the pool.
+func (self *worker) senderGasPrice(from common.Address) *big.Int {
+	if price := self.eth.TxPool().SenderMinGasPrice(from); price != nil {
+		return price
+	}
+	return self.gasPrice
+}
+
+func (self *worker) agentCount() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return len(self.agents)
+}
+
+// pendingSnapshot is a view of the pending block and its state, shared between
+// readers until the pending block is rebuilt. The state must not be modified.
+type pendingSnapshot struct {
+	gen      uint64
+	block    *types.Block
+	receipts types.Receipts
+	state    *state.StateDB
+	lock     sync.Mutex // Serialises access to state, as even reads populate its caches
+}
+
+// pending returns the pending block along with a private copy of its state.
 func (self *worker) pending() (*types.Block, *state.StateDB) {
+	snap := self.pendingSnapshot()
+
+	snap.lock.Lock()
+	defer snap.lock.Unlock()
+
+	return snap.block, snap.state.Copy()
+}
+
+// pendingSnapshot returns the cached view of the pending block, assembling a new
+// one if the pending block was rebuilt since the last call.
+func (self *worker) pendingSnapshot() *pendingSnapshot {
+	self.snapshotMu.Lock()
+	defer self.snapshotMu.Unlock()
+
+	gen := atomic.LoadUint64(&self.pendingGen)
+	if self.snapshot != nil && self.snapshot.gen == gen {
+		return self.snapshot
+	}
 	self.currentMu.Lock()
 	defer self.currentMu.Unlock()
 
+	snap := &pendingSnapshot{gen: gen, block: self.current.Block, state: self.current.state.Copy()}
+	snap.receipts = append(types.Receipts(nil), self.current.receipts...)
 	if atomic.LoadInt32(&self.mining) == 0 {
-		return types.NewBlock(
-			self.current.header,
-			self.current.txs,
-			nil,
-			self.current.receipts,
-		), self.current.state.Copy()
+		snap.block = types.NewBlock(self.current.header, self.current.txs, nil, self.current.receipts)
 	}
-	return self.current.Block, self.current.state.Copy()
+	self.snapshot = snap
+	return snap
 }
 
 func (self *worker) start() {
@@ -179,6 +295,7 @@ func (self *worker) start() {
 	defer self.mu.Unlock()
 
 	atomic.StoreInt32(&self.mining, 1)
+	atomic.AddUint64(&self.pendingGen, 1)
 
 	// spin up agents
 	for agent := range self.agents {
@@ -203,6 +320,7 @@ func (self *worker) stop() {
 	}
 
 	atomic.StoreInt32(&self.mining, 0)
+	atomic.AddUint64(&self.pendingGen, 1)
 	atomic.StoreInt32(&self.atWork, 0)
 }
 
@@ -239,7 +357,11 @@ func (self *worker) update() {
 				txs := map[common.Address]types.Transactions{acc: types.Transactions{ev.Tx}}
 				txset := types.NewTransactionsByPriceAndNonce(txs)
 
-				self.current.commitTransactions(self.mux, txset, self.gasPrice, self.chain)
+				tcount := self.current.tcount
+				self.current.commitTransactions(self.mux, txset, self.senderGasPrice, self.chain)
+				if self.current.tcount > tcount {
+					self.postPendingBlock(types.NewBlock(self.current.header, self.current.txs, nil, self.current.receipts))
+				}
 				self.currentMu.Unlock()
 			}
 		}
@@ -395,6 +517,60 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 	return nil
 }
 
+// setTxOrdering sets the strategy for selecting pool transactions into new blocks.
+func (w *worker) setTxOrdering(mode string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.txOrdering = mode
+}
+
+// orderTransactions wraps the pending transactions of the pool into a set that
+// yields them according to the configured ordering strategy. The mu lock must
+// be held by the caller.
+func (self *worker) orderTransactions(pending map[common.Address]types.Transactions) txSet {
+	if self.txOrdering == TxOrderingFIFO {
+		return types.NewTransactionsByArrivalAndNonce(pending)
+	}
+	return types.NewTransactionsByPriceAndNonce(pending)
+}
+
+// predictTransactions runs the transaction selection of the block builder on
+// top of the current head, returning the pool transactions the next block would
+// include. Neither the pending block nor the pool are modified.
+func (self *worker) predictTransactions() (types.Transactions, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.currentMu.Lock()
+	header := types.CopyHeader(self.current.header)
+	self.currentMu.Unlock()
+
+	parent := self.chain.GetBlock(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent block %x not found", header.ParentHash)
+	}
+	state, err := self.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	if self.config.DAOForkSupport && self.config.DAOForkBlock != nil && self.config.DAOForkBlock.Cmp(header.Number) == 0 {
+		core.ApplyDAOHardFork(state)
+	}
+	header.GasUsed = new(big.Int)
+	work := &Work{
+		config:        self.config,
+		state:         state,
+		header:        header,
+		ownedAccounts: accountAddressesSet(self.eth.AccountManager().Accounts()),
+	}
+	// Execute on a throwaway event mux so no pending events leak out
+	txs := self.orderTransactions(self.eth.TxPool().Pending())
+	work.commitTransactions(new(event.TypeMux), txs, self.senderGasPrice, self.chain)
+
+	return work.txs, nil
+}
+
 func (w *worker) setGasPrice(p *big.Int) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -423,7 +599,7 @@ func (self *worker) isBlockLocallyMined(current *Work, deepBlockNum uint64) bool
 
 	//Does the block at {deepBlockNum} send earnings to my coinbase?
 	var block = self.chain.GetBlockByNumber(deepBlockNum)
-	return block != nil && block.Coinbase() == self.coinbase
+	return block != nil && block.Coinbase() == self.coinbaseAt(block.Number())
 }
 
 func (self *worker) logLocalMinedBlocks(current, previous *Work) {
@@ -459,14 +635,14 @@ func (self *worker) commitNewWork() {
 		time.Sleep(wait)
 	}
 
-	num := parent.Number()
+	num := new(big.Int).Add(parent.Number(), common.Big1)
 	header := &types.Header{
 		ParentHash: parent.Hash(),
-		Number:     num.Add(num, common.Big1),
+		Number:     num,
 		Difficulty: core.CalcDifficulty(self.config, uint64(tstamp), parent.Time().Uint64(), parent.Number(), parent.Difficulty()),
 		GasLimit:   core.CalcGasLimit(parent),
 		GasUsed:    new(big.Int),
-		Coinbase:   self.coinbase,
+		Coinbase:   self.coinbaseAt(num),
 		Extra:      self.extra,
 		Time:       big.NewInt(tstamp),
 	}
@@ -495,8 +671,8 @@ func (self *worker) commitNewWork() {
 	if self.config.DAOForkSupport && self.config.DAOForkBlock != nil && self.config.DAOForkBlock.Cmp(header.Number) == 0 {
 		core.ApplyDAOHardFork(work.state)
 	}
-	txs := types.NewTransactionsByPriceAndNonce(self.eth.TxPool().Pending())
-	work.commitTransactions(self.mux, txs, self.gasPrice, self.chain)
+	txs := self.orderTransactions(self.eth.TxPool().Pending())
+	work.commitTransactions(self.mux, txs, self.senderGasPrice, self.chain)
 
 	self.eth.TxPool().RemoveBatch(work.lowGasTxs)
 	self.eth.TxPool().RemoveBatch(work.failedTxs)
@@ -540,6 +716,39 @@ func (self *worker) commitNewWork() {
 		self.logLocalMinedBlocks(work, previous)
 	}
 	self.push(work)
+	self.postPendingBlock(work.Block)
+}
+
+// postPendingBlock invalidates any cached view of the pending block and schedules
+// the rebuilt one for announcement. A block not yet announced is replaced by the
+// newer one, so subscribers never see the pending block go backwards.
+func (self *worker) postPendingBlock(block *types.Block) {
+	atomic.AddUint64(&self.pendingGen, 1)
+
+	self.postMu.Lock()
+	self.postBlock = block
+	self.postMu.Unlock()
+
+	select {
+	case self.postTrigger <- struct{}{}:
+	default: // announcer already woken, it will pick up the latest block
+	}
+}
+
+// announcePendingBlocks posts the pending blocks scheduled by postPendingBlock
+// one at a time, in the order they were built, without blocking the worker on
+// slow subscribers.
+func (self *worker) announcePendingBlocks() {
+	for range self.postTrigger {
+		self.postMu.Lock()
+		block := self.postBlock
+		self.postBlock = nil
+		self.postMu.Unlock()
+
+		if block != nil {
+			self.mux.Post(core.PendingBlockEvent{Block: block})
+		}
+	}
 }
 
 func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
@@ -557,7 +766,7 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	return nil
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, gasPrice *big.Int, bc *core.BlockChain) {
+func (env *Work) commitTransactions(mux *event.TypeMux, txs txSet, gasPrice func(common.Address) *big.Int, bc *core.BlockChain) {
 	gp := new(core.GasPool).AddGas(env.header.GasLimit)
 
 	var coalescedLogs vm.Logs
@@ -573,15 +782,21 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 		from, _ := tx.From()
 
 		// Ignore any transactions (and accounts subsequently) with low gas limits
-		if tx.GasPrice().Cmp(gasPrice) < 0 && !env.ownedAccounts.Has(from) {
+		if ask := gasPrice(from); tx.GasPrice().Cmp(ask) < 0 && !env.ownedAccounts.Has(from) {
 			// Pop the current low-priced transaction without shifting in the next from the account
-			glog.V(logger.Info).Infof("Transaction (%x) below gas price (tx=%v ask=%v). All sequential txs from this address(%x) will be ignored\n", tx.Hash().Bytes()[:4], common.CurrencyToString(tx.GasPrice()), common.CurrencyToString(gasPrice), from[:4])
+			glog.V(logger.Info).Infof("Transaction (%x) below gas price (tx=%v ask=%v). All sequential txs from this address(%x) will be ignored\n", tx.Hash().Bytes()[:4], common.CurrencyToString(tx.GasPrice()), common.CurrencyToString(ask), from[:4])
 
 			env.lowGasTxs = append(env.lowGasTxs, tx)
 			txs.Pop()
 
 			continue
 		}
+		// Skip replay protected transactions not yet includable in this block
+		if !env.config.ValidChainId(env.header.Number, tx) {
+			glog.V(logger.Detail).Infof("Transaction (%x) has an invalid chain id for this block, skipping\n", tx.Hash().Bytes()[:4])
+			txs.Pop()
+			continue
+		}
 		// Start executing the transaction
 		env.state.StartRecord(tx.Hash(), common.Hash{}, env.tcount)
 
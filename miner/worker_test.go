@@ -0,0 +1,285 @@
+// Copyright 2014 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testBackend is a minimal miner.Backend with a genesis-only chain.
+type testBackend struct {
+	am    *accounts.Manager
+	chain *core.BlockChain
+	pool  *core.TxPool
+	db    ethdb.Database
+}
+
+func (b *testBackend) AccountManager() *accounts.Manager { return b.am }
+func (b *testBackend) BlockChain() *core.BlockChain      { return b.chain }
+func (b *testBackend) TxPool() *core.TxPool              { return b.pool }
+func (b *testBackend) ChainDb() ethdb.Database           { return b.db }
+
+// newTestBackend creates a genesis-only chain funding the given accounts, along
+// with a transaction pool on top. The returned function releases all resources.
+func newTestBackend(t testing.TB, alloc ...core.GenesisAccount) (*testBackend, func()) {
+	db, _ := ethdb.NewMemDatabase()
+	core.WriteGenesisBlockForTesting(db, alloc...)
+
+	config := core.MakeChainConfig()
+	mux := new(event.TypeMux)
+	chain, err := core.NewBlockChain(db, config, core.FakePow{}, mux)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	pool := core.NewTxPool(config, mux, chain.State, chain.GasLimit)
+	pool.Pending() // Initializes the pending state of the pool
+
+	keydir, err := ioutil.TempDir("", "miner-test")
+	if err != nil {
+		t.Fatalf("failed to create key directory: %v", err)
+	}
+	backend := &testBackend{
+		am:    accounts.NewManager(keydir, accounts.LightScryptN, accounts.LightScryptP),
+		chain: chain,
+		pool:  pool,
+		db:    db,
+	}
+	return backend, func() {
+		pool.Stop()
+		chain.Stop()
+		os.RemoveAll(keydir)
+	}
+}
+
+// Tests that the pending block orders transactions by gas price or by arrival
+// depending on the selected strategy, honouring account nonces in both cases.
+func TestTxOrdering(t *testing.T) {
+	keyA, _ := crypto.GenerateKey()
+	keyB, _ := crypto.GenerateKey()
+	addrA, addrB := crypto.PubkeyToAddress(keyA.PublicKey), crypto.PubkeyToAddress(keyB.PublicKey)
+
+	backend, release := newTestBackend(t,
+		core.GenesisAccount{Address: addrA, Balance: big.NewInt(1000000000)},
+		core.GenesisAccount{Address: addrB, Balance: big.NewInt(1000000000)},
+	)
+	defer release()
+
+	// Add transactions whose arrival order disagrees with their prices
+	send := func(key *ecdsa.PrivateKey, nonce uint64, price int64) *types.Transaction {
+		tx, _ := types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(price), nil).SignECDSA(key)
+		if err := backend.pool.Add(tx); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+		return tx
+	}
+	a0 := send(keyA, 0, 1)
+	b0 := send(keyB, 0, 3)
+	a1 := send(keyA, 1, 5)
+
+	// Use a separate event mux for the worker, so pool events don't pre-apply
+	// the transactions to the pending block in arrival order
+	worker := newWorker(backend.chain.Config(), common.Address{}, backend, new(event.TypeMux))
+
+	tests := []struct {
+		mode string
+		want types.Transactions
+	}{
+		{TxOrderingPrice, types.Transactions{b0, a0, a1}},
+		{TxOrderingFIFO, types.Transactions{a0, b0, a1}},
+	}
+	for _, tt := range tests {
+		worker.setTxOrdering(tt.mode)
+		worker.commitNewWork()
+
+		block, _ := worker.pending()
+		have := block.Transactions()
+		if len(have) != len(tt.want) {
+			t.Errorf("%s: transaction count mismatch: have %d, want %d", tt.mode, len(have), len(tt.want))
+			continue
+		}
+		for i := range have {
+			if have[i].Hash() != tt.want[i].Hash() {
+				t.Errorf("%s: transaction %d mismatch: have %x, want %x", tt.mode, i, have[i].Hash(), tt.want[i].Hash())
+			}
+		}
+	}
+}
+
+// Tests that unknown transaction ordering strategies are rejected.
+func TestTxOrderingUnknown(t *testing.T) {
+	miner := &Miner{worker: &worker{txOrdering: TxOrderingPrice}}
+	if err := miner.SetTxOrdering("random"); err == nil {
+		t.Fatalf("unknown ordering accepted")
+	}
+	if miner.worker.txOrdering != TxOrderingPrice {
+		t.Errorf("ordering changed: have %q, want %q", miner.worker.txOrdering, TxOrderingPrice)
+	}
+	if err := miner.SetTxOrdering(TxOrderingFIFO); err != nil {
+		t.Fatalf("fifo ordering rejected: %v", err)
+	}
+}
+
+// Tests that predicting the next block's transactions runs the full selection
+// logic, stopping at the block gas limit, without touching the pending block
+// or the transaction pool.
+func TestPredictTransactions(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	backend, release := newTestBackend(t, core.GenesisAccount{Address: addr, Balance: big.NewInt(1000000000)})
+	defer release()
+
+	worker := newWorker(backend.chain.Config(), common.Address{}, backend, new(event.TypeMux))
+
+	// Fill the pool with more value transfers than fit into a single block
+	limit := int(new(big.Int).Div(worker.current.header.GasLimit, params.TxGas).Int64())
+	for i := 0; i < limit+10; i++ {
+		tx, _ := types.NewTransaction(uint64(i), common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(1), nil).SignECDSA(key)
+		if err := backend.pool.Add(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	txs, err := worker.predictTransactions()
+	if err != nil {
+		t.Fatalf("failed to predict transactions: %v", err)
+	}
+	if len(txs) != limit {
+		t.Fatalf("predicted transaction count mismatch: have %d, want %d", len(txs), limit)
+	}
+	for i, tx := range txs {
+		if tx.Nonce() != uint64(i) {
+			t.Errorf("transaction %d: nonce mismatch: have %d, want %d", i, tx.Nonce(), i)
+		}
+	}
+	// Ensure neither the pending block nor the pool were modified
+	if block, _ := worker.pending(); len(block.Transactions()) != 0 {
+		t.Errorf("pending block modified: have %d transactions, want 0", len(block.Transactions()))
+	}
+	if pending, _ := backend.pool.Stats(); pending != limit+10 {
+		t.Errorf("pool modified: have %d pending, want %d", pending, limit+10)
+	}
+}
+
+// Tests that the pending snapshot is shared between reads and only reassembled
+// once the pending block is rebuilt.
+func TestPendingSnapshotCache(t *testing.T) {
+	backend, release := newTestBackend(t)
+	defer release()
+
+	worker := newWorker(backend.chain.Config(), common.Address{}, backend, new(event.TypeMux))
+
+	snap := worker.pendingSnapshot()
+	if again := worker.pendingSnapshot(); again != snap {
+		t.Errorf("snapshot reassembled without pending block rebuild")
+	}
+	worker.commitNewWork()
+	if again := worker.pendingSnapshot(); again == snap {
+		t.Errorf("snapshot not reassembled after pending block rebuild")
+	}
+}
+
+// Tests that successive pending blocks credit the rotated coinbases in turn, and
+// that clearing the rotation reverts to the etherbase.
+func TestEtherbaseRotation(t *testing.T) {
+	backend, release := newTestBackend(t)
+	defer release()
+
+	etherbase := common.Address{0xe0}
+	worker := newWorker(backend.chain.Config(), etherbase, backend, new(event.TypeMux))
+	miner := &Miner{worker: worker}
+
+	if miner.SetEtherbaseRotation([]common.Address{{0x01}, {}}) {
+		t.Fatalf("rotation with zero address accepted")
+	}
+	rotation := []common.Address{{0x01}, {0x02}, {0x03}}
+	if !miner.SetEtherbaseRotation(rotation) {
+		t.Fatalf("failed to set rotation")
+	}
+	// Extend the chain block by block, checking the coinbase of each pending block
+	blocks, _ := core.GenerateChain(nil, backend.chain.CurrentBlock(), backend.db, 4, nil)
+	for i := 0; i <= len(blocks); i++ {
+		worker.commitNewWork()
+
+		pending, _ := worker.pending()
+		if pending.NumberU64() != uint64(i+1) {
+			t.Fatalf("pending block number mismatch: have %d, want %d", pending.NumberU64(), i+1)
+		}
+		if want := rotation[pending.NumberU64()%3]; pending.Coinbase() != want {
+			t.Errorf("block #%d: coinbase mismatch: have %x, want %x", pending.NumberU64(), pending.Coinbase(), want)
+		}
+		if i < len(blocks) {
+			if _, err := backend.chain.InsertChain(types.Blocks{blocks[i]}); err != nil {
+				t.Fatalf("failed to insert block %d: %v", i, err)
+			}
+		}
+	}
+	// Clearing the rotation should fall back to the etherbase
+	if !miner.SetEtherbaseRotation(nil) {
+		t.Fatalf("failed to clear rotation")
+	}
+	worker.commitNewWork()
+	if pending, _ := worker.pending(); pending.Coinbase() != etherbase {
+		t.Errorf("coinbase mismatch without rotation: have %x, want %x", pending.Coinbase(), etherbase)
+	}
+}
+
+// benchmarkPendingReads measures the cost of reading an account of the pending
+// state, optionally rebuilding the pending block before every read.
+func benchmarkPendingReads(b *testing.B, rebuild bool) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	backend, release := newTestBackend(b, core.GenesisAccount{Address: addr, Balance: big.NewInt(1000000000)})
+	defer release()
+
+	for i := 0; i < 100; i++ {
+		tx, _ := types.NewTransaction(uint64(i), common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(1), nil).SignECDSA(key)
+		if err := backend.pool.Add(tx); err != nil {
+			b.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	worker := newWorker(backend.chain.Config(), common.Address{}, backend, new(event.TypeMux))
+	miner := &Miner{worker: worker}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rebuild {
+			atomic.AddUint64(&worker.pendingGen, 1)
+		}
+		_, state, lock := miner.PendingSnapshot()
+		lock.Lock()
+		state.GetBalance(addr)
+		lock.Unlock()
+	}
+}
+
+func BenchmarkPendingReadsCached(b *testing.B)  { benchmarkPendingReads(b, false) }
+func BenchmarkPendingReadsRebuilt(b *testing.B) { benchmarkPendingReads(b, true) }
@@ -18,6 +18,7 @@ package node
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -74,6 +75,23 @@ func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
 	return true, nil
 }
 
+// DropPeer disconnects from a remote peer identified by its full or short (first
+// 8 bytes) hex node id. Static peers will be reconnected to after a while.
+func (api *PrivateAdminAPI) DropPeer(id string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	// Look up the peer and disconnect it
+	peer, err := findPeer(server, id)
+	if err != nil {
+		return false, err
+	}
+	peer.Disconnect(p2p.DiscRequested)
+	return true, nil
+}
+
 // StartRPC starts the HTTP RPC API server.
 func (api *PrivateAdminAPI) StartRPC(host *string, port *rpc.HexNumber, cors *string, apis *string) (bool, error) {
 	api.node.lock.Lock()
@@ -172,6 +190,92 @@ func (api *PrivateAdminAPI) StopWS() (bool, error) {
 	return true, nil
 }
 
+// SetResponseLimits caps the size in bytes and the nesting depth of the
+// results returned by all RPC endpoints of the node. Larger results are replaced
+// by a "response too large" error. Zero disables the individual limits.
+func (api *PrivateAdminAPI) SetResponseLimits(maxSize int, maxDepth int) (bool, error) {
+	if maxSize < 0 || maxDepth < 0 {
+		return false, rpc.ErrInvalidArgs("invalid response limits: size %d, depth %d", maxSize, maxDepth)
+	}
+	api.node.lock.Lock()
+	defer api.node.lock.Unlock()
+
+	api.node.setRPCLimits(rpc.ResponseLimits{MaxSize: maxSize, MaxDepth: maxDepth})
+	return true, nil
+}
+
+// ResponseLimits returns the limits of the results returned by the RPC
+// endpoints of the node.
+func (api *PrivateAdminAPI) ResponseLimits() rpc.ResponseLimits {
+	api.node.lock.RLock()
+	defer api.node.lock.RUnlock()
+
+	return api.node.rpcLimits
+}
+
+// RPCTimeoutsResult is the execution deadline configuration of the RPC
+// endpoints of the node.
+type RPCTimeoutsResult struct {
+	Default string            `json:"default"` // Deadline of the methods without an override
+	Methods map[string]string `json:"methods"` // Per method overrides
+}
+
+// SetRPCTimeout sets the execution deadline of an RPC method, e.g. "eth_call",
+// or the default one of all methods without an override if method is empty.
+// Requests exceeding it are aborted with a timeout error. The timeout is given
+// as a duration, e.g. "5s", zero disabling the deadline. An empty timeout removes
+// the override of the method.
+func (api *PrivateAdminAPI) SetRPCTimeout(method string, timeout string) (bool, error) {
+	var (
+		duration time.Duration
+		err      error
+	)
+	if timeout != "" {
+		if duration, err = time.ParseDuration(timeout); err != nil || duration < 0 {
+			return false, rpc.ErrInvalidArgs("invalid timeout %q", timeout)
+		}
+	} else if method == "" {
+		return false, rpc.ErrInvalidArgs("missing default timeout")
+	}
+	api.node.lock.Lock()
+	defer api.node.lock.Unlock()
+
+	// Copy the overrides, the current ones may be in use by the endpoints
+	timeouts := rpc.Timeouts{
+		Default: api.node.rpcTimeouts.Default,
+		Methods: make(map[string]time.Duration),
+	}
+	for name, duration := range api.node.rpcTimeouts.Methods {
+		timeouts.Methods[name] = duration
+	}
+	switch {
+	case method == "":
+		timeouts.Default = duration
+	case timeout == "":
+		delete(timeouts.Methods, method)
+	default:
+		timeouts.Methods[method] = duration
+	}
+	api.node.setRPCTimeouts(timeouts)
+	return true, nil
+}
+
+// RPCTimeouts returns the execution deadlines of the methods served by the RPC
+// endpoints of the node.
+func (api *PrivateAdminAPI) RPCTimeouts() RPCTimeoutsResult {
+	api.node.lock.RLock()
+	defer api.node.lock.RUnlock()
+
+	result := RPCTimeoutsResult{
+		Default: api.node.rpcTimeouts.Default.String(),
+		Methods: make(map[string]string),
+	}
+	for method, timeout := range api.node.rpcTimeouts.Methods {
+		result.Methods[method] = timeout.String()
+	}
+	return result
+}
+
 // PublicAdminAPI is the collection of administrative API methods exposed over
 // both secure and unsecure RPC channels.
 type PublicAdminAPI struct {
@@ -194,6 +298,77 @@ func (api *PublicAdminAPI) Peers() ([]*p2p.PeerInfo, error) {
 	return server.PeersInfo(), nil
 }
 
+// PeerInfo retrieves all the information we know about a single peer, identified
+// by its full or short (first 8 bytes) hex node id.
+func (api *PublicAdminAPI) PeerInfo(id string) (*p2p.PeerInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	peer, err := findPeer(server, id)
+	if err != nil {
+		return nil, err
+	}
+	return peer.Info(), nil
+}
+
+// ConfiguredPeer is a static or trusted node along with whether it's connected.
+type ConfiguredPeer struct {
+	Enode     string `json:"enode"`
+	Connected bool   `json:"connected"`
+}
+
+// StaticPeers lists the static and trusted nodes configured on the node.
+type StaticPeers struct {
+	Static  []ConfiguredPeer `json:"static"`
+	Trusted []ConfiguredPeer `json:"trusted"`
+}
+
+// ConfiguredPeers retrieves the static nodes the server keeps connections to,
+// including the ones added through admin_addPeer, and the trusted nodes, each
+// flagged with whether it's currently connected. Unlike Peers, this exposes the
+// configured nodes that fail to connect.
+func (api *PublicAdminAPI) ConfiguredPeers() (StaticPeers, error) {
+	server := api.node.Server()
+	if server == nil {
+		return StaticPeers{}, ErrNodeStopped
+	}
+	connected := make(map[discover.NodeID]bool)
+	for _, peer := range server.Peers() {
+		connected[peer.ID()] = true
+	}
+	list := func(nodes []*discover.Node) []ConfiguredPeer {
+		peers := make([]ConfiguredPeer, 0, len(nodes))
+		for _, node := range nodes {
+			peers = append(peers, ConfiguredPeer{Enode: node.String(), Connected: connected[node.ID]})
+		}
+		sort.Sort(configuredPeersByEnode(peers))
+		return peers
+	}
+	return StaticPeers{Static: list(server.StaticPeers()), Trusted: list(server.TrustedNodes)}, nil
+}
+
+// configuredPeersByEnode sorts configured peers by their enode URL.
+type configuredPeersByEnode []ConfiguredPeer
+
+func (p configuredPeersByEnode) Len() int           { return len(p) }
+func (p configuredPeersByEnode) Less(i, j int) bool { return p[i].Enode < p[j].Enode }
+func (p configuredPeersByEnode) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// findPeer looks up a connected peer by its full or short hex node id.
+func findPeer(server *p2p.Server, id string) (*p2p.Peer, error) {
+	id = strings.ToLower(strings.TrimPrefix(id, "0x"))
+	if len(id) != 2*len(discover.NodeID{}) && len(id) != 16 {
+		return nil, fmt.Errorf("invalid node id %q: want %d (full) or 16 (short) hex characters", id, 2*len(discover.NodeID{}))
+	}
+	for _, peer := range server.Peers() {
+		if strings.HasPrefix(peer.ID().String(), id) {
+			return peer, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown peer %s", id)
+}
+
 // NodeInfo retrieves all the information we know about the host node at the
 // protocol granularity.
 func (api *PublicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
@@ -0,0 +1,137 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// startTestPeerNode creates and starts a node listening on the loopback interface
+// without discovery, suitable for manually connecting peers.
+func startTestPeerNode(t *testing.T) *Node {
+	key, _ := crypto.GenerateKey()
+	stack, err := New(&Config{
+		PrivateKey:  key,
+		Name:        "test peer",
+		ListenAddr:  "127.0.0.1:0",
+		NoDiscovery: true,
+		MaxPeers:    10,
+	})
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	return stack
+}
+
+// waitPeerCount waits until the node has the given number of peers connected.
+func waitPeerCount(t *testing.T, stack *Node, count int) {
+	for i := 0; stack.Server().PeerCount() != count; i++ {
+		if i == 100 {
+			t.Fatalf("peer count mismatch: have %d, want %d", stack.Server().PeerCount(), count)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Tests that connected peers can be looked up and dropped by their node id.
+func TestPeerByID(t *testing.T) {
+	local := startTestPeerNode(t)
+	defer local.Stop()
+	remote := startTestPeerNode(t)
+	defer remote.Stop()
+
+	remote.Server().AddPeer(local.Server().Self())
+	waitPeerCount(t, local, 1)
+
+	var (
+		public  = NewPublicAdminAPI(local)
+		private = NewPrivateAdminAPI(local)
+		id      = remote.Server().Self().ID.String()
+	)
+	// Look up the peer by its full, short and prefixed ids
+	for _, query := range []string{id, id[:16], "0x" + id} {
+		info, err := public.PeerInfo(query)
+		if err != nil {
+			t.Fatalf("failed to look up peer by %q: %v", query, err)
+		}
+		if info.ID != id {
+			t.Errorf("peer id mismatch for %q: have %s, want %s", query, info.ID, id)
+		}
+	}
+	// Unknown and malformed ids should be reported
+	for _, query := range []string{"0123456789abcdef", id[:10]} {
+		if _, err := public.PeerInfo(query); err == nil {
+			t.Errorf("looked up peer by %q", query)
+		}
+		if _, err := private.DropPeer(query); err == nil {
+			t.Errorf("dropped peer by %q", query)
+		}
+	}
+	// Drop the peer and ensure it disconnects
+	if ok, err := private.DropPeer(id[:16]); !ok || err != nil {
+		t.Fatalf("failed to drop peer: %v, %v", ok, err)
+	}
+	waitPeerCount(t, local, 0)
+	if _, err := public.PeerInfo(id); err == nil {
+		t.Errorf("dropped peer still known")
+	}
+}
+
+// Tests that configured static peers are listed with their connection status,
+// including ones that can't be reached.
+func TestConfiguredPeers(t *testing.T) {
+	local := startTestPeerNode(t)
+	defer local.Stop()
+	remote := startTestPeerNode(t)
+	defer remote.Stop()
+
+	// Add a reachable static peer and one that nothing listens for
+	key, _ := crypto.GenerateKey()
+	unreachable := discover.NewNode(discover.PubkeyID(&key.PublicKey), net.ParseIP("127.0.0.1"), 1, 1)
+
+	local.Server().AddPeer(remote.Server().Self())
+	local.Server().AddPeer(unreachable)
+	waitPeerCount(t, local, 1)
+
+	peers, err := NewPublicAdminAPI(local).ConfiguredPeers()
+	if err != nil {
+		t.Fatalf("failed to retrieve configured peers: %v", err)
+	}
+	want := map[string]bool{
+		remote.Server().Self().String(): true,
+		unreachable.String():            false,
+	}
+	if len(peers.Static) != len(want) {
+		t.Fatalf("static peer count mismatch: have %d, want %d", len(peers.Static), len(want))
+	}
+	for _, peer := range peers.Static {
+		if connected, ok := want[peer.Enode]; !ok || peer.Connected != connected {
+			t.Errorf("static peer %s mismatch: have connected %v, want known and connected %v", peer.Enode, peer.Connected, connected)
+		}
+	}
+	if len(peers.Trusted) != 0 {
+		t.Errorf("trusted peers reported without configuring any: %v", peers.Trusted)
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/logger/glog"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/nat"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 var (
@@ -158,6 +159,14 @@ type Config struct {
 	// If the module list is empty, all RPC API endpoints designated public will be
 	// exposed.
 	WSModules []string
+
+	// RPCResponseLimits caps the size and nesting depth of the results returned
+	// by all RPC endpoints. Zero values disable the individual limits.
+	RPCResponseLimits rpc.ResponseLimits
+
+	// RPCTimeouts bounds the execution time of the methods served by all RPC
+	// endpoints, by default and per method. Zero durations disable the deadline.
+	RPCTimeouts rpc.Timeouts
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -77,6 +77,9 @@ type Node struct {
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
 
+	rpcLimits   rpc.ResponseLimits // Limits of the results returned by the RPC endpoints
+	rpcTimeouts rpc.Timeouts       // Execution deadlines of the methods served by the RPC endpoints
+
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
 }
@@ -121,6 +124,8 @@ func New(conf *Config) (*Node, error) {
 		ipcEndpoint:       conf.IPCEndpoint(),
 		httpEndpoint:      conf.HTTPEndpoint(),
 		wsEndpoint:        conf.WSEndpoint(),
+		rpcLimits:         conf.RPCResponseLimits,
+		rpcTimeouts:       conf.RPCTimeouts,
 		eventmux:          new(event.TypeMux),
 	}, nil
 }
@@ -289,10 +294,34 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	return nil
 }
 
+// setRPCLimits updates the response limits of all current and future RPC
+// endpoints. The caller must hold the node lock.
+func (n *Node) setRPCLimits(limits rpc.ResponseLimits) {
+	n.rpcLimits = limits
+	for _, handler := range []*rpc.Server{n.inprocHandler, n.ipcHandler, n.httpHandler, n.wsHandler} {
+		if handler != nil {
+			handler.SetResponseLimits(limits)
+		}
+	}
+}
+
+// setRPCTimeouts updates the method execution deadlines of all current and
+// future RPC endpoints. The caller must hold the node lock.
+func (n *Node) setRPCTimeouts(timeouts rpc.Timeouts) {
+	n.rpcTimeouts = timeouts
+	for _, handler := range []*rpc.Server{n.inprocHandler, n.ipcHandler, n.httpHandler, n.wsHandler} {
+		if handler != nil {
+			handler.SetTimeouts(timeouts)
+		}
+	}
+}
+
 // startInProc initializes an in-process RPC endpoint.
 func (n *Node) startInProc(apis []rpc.API) error {
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.SetResponseLimits(n.rpcLimits)
+	handler.SetTimeouts(n.rpcTimeouts)
 	for _, api := range apis {
 		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 			return err
@@ -319,6 +348,8 @@ func (n *Node) startIPC(apis []rpc.API) error {
 	}
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.SetResponseLimits(n.rpcLimits)
+	handler.SetTimeouts(n.rpcTimeouts)
 	for _, api := range apis {
 		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 			return err
@@ -350,7 +381,7 @@ func (n *Node) startIPC(apis []rpc.API) error {
 				glog.V(logger.Error).Infof("IPC accept failed: %v", err)
 				continue
 			}
-			go handler.ServeCodec(rpc.NewJSONCodec(conn), rpc.OptionMethodInvocation|rpc.OptionSubscriptions)
+			go handler.ServeCodec(rpc.NewJSONCodec(conn), rpc.OptionMethodInvocation|rpc.OptionSubscriptions|rpc.OptionLocal)
 		}
 	}()
 	// All listeners booted successfully
@@ -387,6 +418,8 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	}
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.SetResponseLimits(n.rpcLimits)
+	handler.SetTimeouts(n.rpcTimeouts)
 	for _, api := range apis {
 		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
@@ -441,6 +474,8 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	}
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.SetResponseLimits(n.rpcLimits)
+	handler.SetTimeouts(n.rpcTimeouts)
 	for _, api := range apis {
 		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
@@ -126,6 +126,14 @@ func (s *dialstate) removeStatic(n *discover.Node) {
 	delete(s.static, n.ID)
 }
 
+func (s *dialstate) staticNodes() []*discover.Node {
+	nodes := make([]*discover.Node, 0, len(s.static))
+	for _, t := range s.static {
+		nodes = append(nodes, t.dest)
+	}
+	return nodes
+}
+
 func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now time.Time) []task {
 	var newtasks []task
 	isDialing := func(id discover.NodeID) bool {
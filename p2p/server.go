@@ -143,6 +143,7 @@ type Server struct {
 	quit          chan struct{}
 	addstatic     chan *discover.Node
 	removestatic  chan *discover.Node
+	staticnodes   chan chan []*discover.Node
 	posthandshake chan *conn
 	addpeer       chan *conn
 	delpeer       chan *Peer
@@ -266,6 +267,19 @@ func (srv *Server) RemovePeer(node *discover.Node) {
 	}
 }
 
+// StaticPeers returns the nodes the server maintains connections to, both the
+// ones configured at startup and those added through AddPeer, regardless of
+// whether they are currently connected.
+func (srv *Server) StaticPeers() []*discover.Node {
+	result := make(chan []*discover.Node, 1)
+	select {
+	case srv.staticnodes <- result:
+		return <-result
+	case <-srv.quit:
+		return nil
+	}
+}
+
 // Self returns the local node's endpoint information.
 func (srv *Server) Self() *discover.Node {
 	srv.lock.Lock()
@@ -337,6 +351,7 @@ func (srv *Server) Start() (err error) {
 	srv.posthandshake = make(chan *conn)
 	srv.addstatic = make(chan *discover.Node)
 	srv.removestatic = make(chan *discover.Node)
+	srv.staticnodes = make(chan chan []*discover.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 
@@ -406,6 +421,7 @@ type dialer interface {
 	taskDone(task, time.Time)
 	addStatic(*discover.Node)
 	removeStatic(*discover.Node)
+	staticNodes() []*discover.Node
 }
 
 func (srv *Server) run(dialstate dialer) {
@@ -478,6 +494,10 @@ running:
 			if p, ok := peers[n.ID]; ok {
 				p.Disconnect(DiscRequested)
 			}
+		case result := <-srv.staticnodes:
+			// This channel is used by StaticPeers to list the
+			// nodes the dialer keeps connected.
+			result <- dialstate.staticNodes()
 		case op := <-srv.peerOp:
 			// This channel is used by Peers and PeerCount.
 			op(peers)
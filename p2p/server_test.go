@@ -303,6 +303,9 @@ func (tg taskgen) addStatic(*discover.Node) {
 }
 func (tg taskgen) removeStatic(*discover.Node) {
 }
+func (tg taskgen) staticNodes() []*discover.Node {
+	return nil
+}
 
 type testTask struct {
 	index  int
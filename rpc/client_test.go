@@ -25,6 +25,7 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -50,6 +51,210 @@ func TestClientRequest(t *testing.T) {
 	}
 }
 
+type TypedErrorService struct{}
+
+func (s *TypedErrorService) NotFound() error     { return ErrNotFound("block #%d not found", 1) }
+func (s *TypedErrorService) InvalidArgs() error  { return ErrInvalidArgs("bad range") }
+func (s *TypedErrorService) Unauthorized() error { return ErrUnauthorized("account locked") }
+func (s *TypedErrorService) Plain() error        { return fmt.Errorf("plain failure") }
+func (s *TypedErrorService) Wrapped() error      { return ErrWrap(s.NotFound(), "item %d", 2) }
+func (s *TypedErrorService) WrappedPlain() error { return ErrWrap(s.Plain(), "item %d", 3) }
+
+func TestClientTypedErrors(t *testing.T) {
+	server := newTestServer("service", new(TypedErrorService))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	tests := []struct {
+		method string
+		code   int
+		msg    string
+	}{
+		{"service_notFound", ErrCodeNotFound, "block #1 not found"},
+		{"service_invalidArgs", ErrCodeInvalidArgs, "bad range"},
+		{"service_unauthorized", ErrCodeUnauthorized, "account locked"},
+		{"service_plain", -32000, "plain failure"},
+		{"service_wrapped", ErrCodeNotFound, "item 2: block #1 not found"},
+		{"service_wrappedPlain", -32000, "item 3: plain failure"},
+	}
+	for _, tt := range tests {
+		err := client.Call(nil, tt.method)
+		rpcErr, ok := err.(Error)
+		if !ok {
+			t.Errorf("%s: expected rpc error, got %v", tt.method, err)
+			continue
+		}
+		if rpcErr.ErrorCode() != tt.code {
+			t.Errorf("%s: error code mismatch: have %d, want %d", tt.method, rpcErr.ErrorCode(), tt.code)
+		}
+		if rpcErr.Error() != tt.msg {
+			t.Errorf("%s: error message mismatch: have %q, want %q", tt.method, rpcErr.Error(), tt.msg)
+		}
+	}
+}
+
+type DumpService struct{}
+
+// Dump returns n strings of 1KB each.
+func (s *DumpService) Dump(n int) []string {
+	dump := make([]string, n)
+	for i := range dump {
+		dump[i] = strings.Repeat("x", 1024)
+	}
+	return dump
+}
+
+// DumpSubscription sends a dump of n strings for each of the given sizes once
+// the subscription is activated.
+func (s *DumpService) DumpSubscription(ctx context.Context, sizes []int) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	go func() {
+		<-sub.Active()
+		for _, n := range sizes {
+			notifier.Notify(sub.ID, s.Dump(n))
+		}
+	}()
+	return sub, nil
+}
+
+// Nested returns a value nested the given number of levels deep.
+func (s *DumpService) Nested(depth int) interface{} {
+	var v interface{} = "leaf"
+	for i := 0; i < depth; i++ {
+		v = []interface{}{v, "{["}
+	}
+	return v
+}
+
+func TestClientResponseLimits(t *testing.T) {
+	server := newTestServer("dump", new(DumpService))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	server.SetResponseLimits(ResponseLimits{MaxSize: 64 * 1024, MaxDepth: 8})
+
+	tests := []struct {
+		method string
+		arg    int
+		fail   bool
+	}{
+		{"dump_dump", 16, false},
+		{"dump_dump", 1024, true},
+		{"dump_nested", 8, false},
+		{"dump_nested", 9, true},
+	}
+	for _, tt := range tests {
+		var result interface{}
+		err := client.Call(&result, tt.method, tt.arg)
+		if !tt.fail {
+			if err != nil {
+				t.Errorf("%s(%d): unexpected error: %v", tt.method, tt.arg, err)
+			}
+			continue
+		}
+		if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != ErrCodeResponseTooLarge {
+			t.Errorf("%s(%d): error mismatch: have %v, want code %d", tt.method, tt.arg, err, ErrCodeResponseTooLarge)
+		}
+	}
+	// Oversized batch results are replaced individually
+	batch := []BatchElem{
+		{Method: "dump_dump", Args: []interface{}{1024}, Result: new([]string)},
+		{Method: "dump_dump", Args: []interface{}{16}, Result: new([]string)},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatalf("batch call failed: %v", err)
+	}
+	if rpcErr, ok := batch[0].Error.(Error); !ok || rpcErr.ErrorCode() != ErrCodeResponseTooLarge {
+		t.Errorf("oversized batch result error mismatch: have %v, want code %d", batch[0].Error, ErrCodeResponseTooLarge)
+	}
+	if batch[1].Error != nil || len(*batch[1].Result.(*[]string)) != 16 {
+		t.Errorf("batch result mismatch: %d items, %v", len(*batch[1].Result.(*[]string)), batch[1].Error)
+	}
+	// Lifting the limits allows any response
+	server.SetResponseLimits(ResponseLimits{})
+
+	var dump []string
+	if err := client.Call(&dump, "dump_dump", 1024); err != nil || len(dump) != 1024 {
+		t.Errorf("unlimited dump failed: %d items, %v", len(dump), err)
+	}
+}
+
+// Tests that oversized notifications are dropped without affecting the others
+// or the connection.
+func TestClientNotificationLimits(t *testing.T) {
+	server := newTestServer("eth", new(DumpService))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	server.SetResponseLimits(ResponseLimits{MaxSize: 64 * 1024})
+
+	dumps := make(chan []string)
+	sub, err := client.EthSubscribe(context.Background(), dumps, "dumpSubscription", []int{16, 1024, 32})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for _, want := range []int{16, 32} {
+		select {
+		case dump := <-dumps:
+			if len(dump) != want {
+				t.Fatalf("notification size mismatch: have %d items, want %d", len(dump), want)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(time.Second):
+			t.Fatalf("notification timeout, want %d items", want)
+		}
+	}
+	var dump []string
+	if err := client.Call(&dump, "eth_dump", 16); err != nil || len(dump) != 16 {
+		t.Errorf("call after dropped notification failed: %d items, %v", len(dump), err)
+	}
+}
+
+func TestClientTimeouts(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	server.SetTimeouts(Timeouts{
+		Default: 50 * time.Millisecond,
+		Methods: map[string]time.Duration{"service_echo": 0},
+	})
+	// A slow handler exceeding the default deadline is aborted
+	start := time.Now()
+	err := client.Call(nil, "service_sleep", 5*time.Second)
+	if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != ErrCodeTimeout {
+		t.Fatalf("error mismatch: have %v, want code %d", err, ErrCodeTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("handler not aborted in time: took %v", elapsed)
+	}
+	// Handlers finishing in time and overridden methods are unaffected
+	if err := client.Call(nil, "service_sleep", time.Millisecond); err != nil {
+		t.Errorf("fast handler failed: %v", err)
+	}
+	var result Result
+	if err := client.Call(&result, "service_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Errorf("overridden method failed: %v", err)
+	}
+	// A per method override takes precedence over the default
+	server.SetTimeouts(Timeouts{Methods: map[string]time.Duration{"service_sleep": 50 * time.Millisecond}})
+	err = client.Call(nil, "service_sleep", 5*time.Second)
+	if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != ErrCodeTimeout {
+		t.Errorf("override error mismatch: have %v, want code %d", err, ErrCodeTimeout)
+	}
+}
+
 func TestClientBatchRequest(t *testing.T) {
 	server := newTestServer("service", new(Service))
 	defer server.Stop()
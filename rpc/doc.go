@@ -94,5 +94,15 @@ Subscriptions are deleted when:
  - the connection which was used to create the subscription is closed. This can be initiated
    by the client and server. The server will close the connection on an write error or when
    the queue of buffered notifications gets too big.
+
+Subscriptions requested through eth_subscribeDurable instead of eth_subscribe are the
+exception to the latter, they outlive their connection. The server responds with the
+subscription id and a secret token, tags notifications with a sequence number and retains
+the most recent ones. After reconnecting, the client can resume the subscription with
+eth_resubscribe, passing the subscription id, the token and the sequence number of the
+last event it received, upon which it is sent every event it missed. Subscription callbacks
+need no changes for this: the notifier they are handed only reports Closed once a durable
+subscription ends. Durable subscriptions that are not resumed within a few minutes are
+deleted.
 */
 package rpc
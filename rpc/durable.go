@@ -0,0 +1,266 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	durableBufferSize = 256             // max events retained per durable subscription for replay
+	durableTimeout    = 5 * time.Minute // time a detached durable subscription waits to be resumed
+)
+
+// durableEvent is the notification payload of a durable subscription. The
+// sequence number can be passed to eth_resubscribe to resume after it.
+type durableEvent struct {
+	Seq  uint64      `json:"seq"`
+	Data interface{} `json:"data"`
+}
+
+// durableSubscriptionResult is the response to eth_subscribeDurable. The token
+// is only ever sent to the creator of the subscription and is required to resume
+// it, so knowing the subscription id is not enough to hijack it.
+type durableSubscriptionResult struct {
+	ID    ID     `json:"id"`
+	Token string `json:"token"`
+}
+
+// durableSubscription is a subscription that isn't tied to a single connection.
+// It retains the most recent events it sent, so that a client reconnecting can
+// resume it and receive everything it missed while it was disconnected.
+type durableSubscription struct {
+	sub      *Subscription
+	token    string // secret required to resume the subscription
+	done     func() // signals the callback that the subscription ended
+	registry *durableRegistry
+
+	lock     sync.Mutex
+	seq      uint64         // sequence number of the last event
+	events   []durableEvent // most recent events, at most durableBufferSize
+	notifier *Notifier      // connection the subscription is attached to, nil if detached
+	owner    *Notifier      // connection the subscription can be cancelled from
+	expire   *time.Timer    // drops the subscription if it isn't resumed in time
+	closed   bool
+}
+
+// newDurableSubscription creates a detached durable subscription with a random
+// resumption token, which will expire unless it is attached to a connection in
+// time. The done function is called when the subscription ends.
+func newDurableSubscription(sub *Subscription, done func(), registry *durableRegistry) *durableSubscription {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		panic("can't generate durable subscription token: " + err.Error())
+	}
+	d := &durableSubscription{sub: sub, token: hex.EncodeToString(token), done: done, registry: registry}
+	d.scheduleExpiry()
+	return d
+}
+
+// authorized returns whether the given token permits resuming the subscription.
+func (d *durableSubscription) authorized(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(d.token), []byte(token)) == 1
+}
+
+// notify retains an event and sends it to the client if the subscription is
+// currently attached to a connection. Write failures are not reported back as
+// the event can still be delivered when the subscription is resumed.
+func (d *durableSubscription) notify(data interface{}) error {
+	d.lock.Lock()
+	if d.closed {
+		d.lock.Unlock()
+		return ErrSubscriptionNotFound
+	}
+	d.seq++
+	event := durableEvent{Seq: d.seq, Data: data}
+	if len(d.events) == durableBufferSize {
+		copy(d.events, d.events[1:])
+		d.events = d.events[:len(d.events)-1]
+	}
+	d.events = append(d.events, event)
+
+	var failed ServerCodec
+	if d.notifier != nil {
+		failed = d.send(event)
+	}
+	d.lock.Unlock()
+
+	// Close the broken connection without holding the lock, it may block
+	if failed != nil {
+		failed.Close()
+	}
+	return nil
+}
+
+// send writes a single event to the attached connection, detaching from it if
+// the write fails. The failed connection is returned for the caller to close
+// once it released the lock, which must be held when calling.
+func (d *durableSubscription) send(event durableEvent) ServerCodec {
+	codec := d.notifier.codec
+	if err := codec.Write(codec.CreateNotification(string(d.sub.ID), event)); err != nil {
+		if _, tooLarge := err.(*ResponseTooLargeError); tooLarge {
+			return nil // oversized events are dropped
+		}
+		d.notifier = nil
+		d.scheduleExpiry()
+		return codec
+	}
+	return nil
+}
+
+// attach binds the subscription to a connection, replaying all retained events
+// that came after the given sequence number. If the client fell too far behind
+// the oldest events are lost, which it can detect by the gap in sequence numbers.
+func (d *durableSubscription) attach(n *Notifier, seq uint64) {
+	d.lock.Lock()
+	if d.closed {
+		d.lock.Unlock()
+		return
+	}
+	if d.expire != nil {
+		d.expire.Stop()
+		d.expire = nil
+	}
+	d.notifier = n
+
+	var failed ServerCodec
+	for _, event := range d.events {
+		if event.Seq <= seq {
+			continue
+		}
+		if failed = d.send(event); failed != nil {
+			break
+		}
+	}
+	d.lock.Unlock()
+
+	if failed != nil {
+		failed.Close()
+	}
+}
+
+// own records the connection the subscription can be cancelled from, returning
+// the previous one so the caller can drop the subscription from it.
+func (d *durableSubscription) own(n *Notifier) *Notifier {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	old := d.owner
+	d.owner = n
+	return old
+}
+
+// claim records the connection the subscription was created on as the one it
+// can be cancelled from, unless it was already resumed on another connection.
+func (d *durableSubscription) claim(n *Notifier) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.owner != nil {
+		return false
+	}
+	d.owner = n
+	return true
+}
+
+// detach unbinds the subscription from the given connection, starting to wait
+// for the client to resume it. It's a noop if the subscription was already
+// moved to a different connection.
+func (d *durableSubscription) detach(n *Notifier) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.closed || d.notifier != n {
+		return
+	}
+	d.notifier = nil
+	d.scheduleExpiry()
+}
+
+// scheduleExpiry starts the timer closing the subscription if it isn't resumed
+// in time. The lock must be held by the caller.
+func (d *durableSubscription) scheduleExpiry() {
+	var timer *time.Timer
+	timer = time.AfterFunc(durableTimeout, func() {
+		d.lock.Lock()
+		expired := d.expire == timer && d.notifier == nil
+		d.lock.Unlock()
+
+		if expired {
+			d.close()
+		}
+	})
+	d.expire = timer
+}
+
+// close terminates the subscription, signalling the callback to stop sending
+// events and dropping it from the registry.
+func (d *durableSubscription) close() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.closed {
+		return
+	}
+	d.closed = true
+	if d.expire != nil {
+		d.expire.Stop()
+	}
+	d.sub.close()
+	d.done()
+	d.registry.remove(d.sub.ID)
+}
+
+// durableRegistry tracks the durable subscriptions of a server across all of
+// its connections.
+type durableRegistry struct {
+	lock sync.RWMutex
+	subs map[ID]*durableSubscription
+}
+
+// newDurableRegistry creates an empty durable subscription registry.
+func newDurableRegistry() *durableRegistry {
+	return &durableRegistry{subs: make(map[ID]*durableSubscription)}
+}
+
+// add inserts a new durable subscription into the registry.
+func (r *durableRegistry) add(sub *durableSubscription) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.subs[sub.sub.ID] = sub
+}
+
+// get retrieves a durable subscription, or nil if the id is unknown.
+func (r *durableRegistry) get(id ID) *durableSubscription {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.subs[id]
+}
+
+// remove drops a durable subscription from the registry.
+func (r *durableRegistry) remove(id ID) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.subs, id)
+}
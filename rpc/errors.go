@@ -58,9 +58,116 @@ func (e *callbackError) ErrorCode() int { return -32000 }
 
 func (e *callbackError) Error() string { return e.message }
 
+// callbackErr converts an error returned by a service method into an RPC error,
+// keeping its code if it carries one.
+func callbackErr(err error) Error {
+	if e, ok := err.(Error); ok {
+		return e
+	}
+	return &callbackError{err.Error()}
+}
+
 // issued when a request is received after the server is issued to stop.
 type shutdownError struct{}
 
 func (e *shutdownError) ErrorCode() int { return -32000 }
 
 func (e *shutdownError) Error() string { return "server is shutting down" }
+
+// Error codes of the typed service errors below. They are stable, so clients can
+// rely on them to distinguish failure reasons programmatically. Errors returned
+// by services that don't carry a code are reported with -32000.
+const (
+	ErrCodeNotFound     = -32001 // requested object is unknown
+	ErrCodeUnauthorized = -32002 // missing credentials, e.g. a locked or unknown account
+	ErrCodeInvalidArgs  = -32602 // same as the protocol level invalid params error
+
+	ErrCodeResponseTooLarge = -32003 // response exceeds the size or depth limits of the server
+	ErrCodeServerBusy       = -32004 // request can't be served right now, e.g. too many expensive ones are running
+	ErrCodeTimeout          = -32005 // request exceeded the execution deadline of its method
+)
+
+// NotFoundError is returned by services if a requested object is unknown.
+type NotFoundError struct{ Message string }
+
+func (e *NotFoundError) ErrorCode() int { return ErrCodeNotFound }
+
+func (e *NotFoundError) Error() string { return e.Message }
+
+// ErrNotFound creates a NotFoundError with the formatted message.
+func ErrNotFound(format string, v ...interface{}) error {
+	return &NotFoundError{Message: fmt.Sprintf(format, v...)}
+}
+
+// InvalidArgsError is returned by services if the supplied arguments are
+// syntactically valid but not acceptable.
+type InvalidArgsError struct{ Message string }
+
+func (e *InvalidArgsError) ErrorCode() int { return ErrCodeInvalidArgs }
+
+func (e *InvalidArgsError) Error() string { return e.Message }
+
+// ErrInvalidArgs creates an InvalidArgsError with the formatted message.
+func ErrInvalidArgs(format string, v ...interface{}) error {
+	return &InvalidArgsError{Message: fmt.Sprintf(format, v...)}
+}
+
+// UnauthorizedError is returned by services if the caller lacks the credentials
+// needed for the request, e.g. when signing with a locked or unknown account.
+type UnauthorizedError struct{ Message string }
+
+func (e *UnauthorizedError) ErrorCode() int { return ErrCodeUnauthorized }
+
+func (e *UnauthorizedError) Error() string { return e.Message }
+
+// ErrUnauthorized creates an UnauthorizedError with the formatted message.
+func ErrUnauthorized(format string, v ...interface{}) error {
+	return &UnauthorizedError{Message: fmt.Sprintf(format, v...)}
+}
+
+// ResponseTooLargeError is returned by the server instead of a result which
+// exceeds its configured response limits.
+type ResponseTooLargeError struct{ Message string }
+
+func (e *ResponseTooLargeError) ErrorCode() int { return ErrCodeResponseTooLarge }
+
+func (e *ResponseTooLargeError) Error() string { return e.Message }
+
+// ServerBusyError is returned by services refusing a request they can't serve
+// right now, e.g. an expensive one while too many of them are already being
+// served, or a state read while the node is still syncing. The request may be
+// retried later.
+type ServerBusyError struct{ Message string }
+
+func (e *ServerBusyError) ErrorCode() int { return ErrCodeServerBusy }
+
+func (e *ServerBusyError) Error() string { return e.Message }
+
+// ErrServerBusy creates a ServerBusyError with the formatted message.
+func ErrServerBusy(format string, v ...interface{}) error {
+	return &ServerBusyError{Message: fmt.Sprintf(format, v...)}
+}
+
+// TimeoutError is returned by the server instead of the result of a request that
+// exceeded the execution deadline configured for its method.
+type TimeoutError struct{ Message string }
+
+func (e *TimeoutError) ErrorCode() int { return ErrCodeTimeout }
+
+func (e *TimeoutError) Error() string { return e.Message }
+
+// WrappedError prefixes the error of a service with some context, e.g. the item
+// of a batch it's about, keeping the code of the original error.
+type WrappedError struct {
+	Message string
+	Err     error
+}
+
+func (e *WrappedError) ErrorCode() int { return callbackErr(e.Err).ErrorCode() }
+
+func (e *WrappedError) Error() string { return e.Message + ": " + e.Err.Error() }
+
+// ErrWrap creates a WrappedError prefixing err with the formatted message.
+func ErrWrap(err error, format string, v ...interface{}) error {
+	return &WrappedError{Message: fmt.Sprintf(format, v...), Err: err}
+}
@@ -38,7 +38,7 @@ func (srv *Server) ServeListener(l net.Listener) error {
 			return err
 		}
 		glog.V(logger.Detail).Infoln("accepted conn", conn.RemoteAddr())
-		go srv.ServeCodec(NewJSONCodec(conn), OptionMethodInvocation|OptionSubscriptions)
+		go srv.ServeCodec(NewJSONCodec(conn), OptionMethodInvocation|OptionSubscriptions|OptionLocal)
 	}
 }
 
@@ -33,7 +33,9 @@ const (
 	jsonrpcVersion         = "2.0"
 	serviceMethodSeparator = "_"
 	subscribeMethod        = "eth_subscribe"
+	durableSubscribeMethod = "eth_subscribeDurable"
 	unsubscribeMethod      = "eth_unsubscribe"
+	resubscribeMethod      = "eth_resubscribe"
 	notificationMethod     = "eth_subscription"
 )
 
@@ -83,6 +85,8 @@ type jsonCodec struct {
 	encMu  sync.Mutex         // guards e
 	e      *json.Encoder      // encodes responses
 	rw     io.ReadWriteCloser // connection
+
+	limits func() ResponseLimits // limits results and notifications must obey, nil if unlimited
 }
 
 func (err *jsonError) Error() string {
@@ -164,8 +168,8 @@ func parseRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error) {
 	}
 
 	// subscribe are special, they will always use `subscribeMethod` as first param in the payload
-	if in.Method == subscribeMethod {
-		reqs := []rpcRequest{rpcRequest{id: &in.Id, isPubSub: true}}
+	if in.Method == subscribeMethod || in.Method == durableSubscribeMethod {
+		reqs := []rpcRequest{rpcRequest{id: &in.Id, isPubSub: true, isDurable: in.Method == durableSubscribeMethod}}
 		if len(in.Payload) > 0 {
 			// first param must be subscription name
 			var subscribeMethod [1]string
@@ -182,9 +186,9 @@ func parseRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error) {
 		return nil, false, &invalidRequestError{"Unable to parse subscription request"}
 	}
 
-	if in.Method == unsubscribeMethod {
+	if in.Method == unsubscribeMethod || in.Method == resubscribeMethod {
 		return []rpcRequest{rpcRequest{id: &in.Id, isPubSub: true,
-			method: unsubscribeMethod, params: in.Payload}}, false, nil
+			method: in.Method, params: in.Payload}}, false, nil
 	}
 
 	elems := strings.Split(in.Method, serviceMethodSeparator)
@@ -217,8 +221,8 @@ func parseBatchRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error)
 		id := &in[i].Id
 
 		// subscribe are special, they will always use `subscribeMethod` as first param in the payload
-		if r.Method == subscribeMethod {
-			requests[i] = rpcRequest{id: id, isPubSub: true}
+		if r.Method == subscribeMethod || r.Method == durableSubscribeMethod {
+			requests[i] = rpcRequest{id: id, isPubSub: true, isDurable: r.Method == durableSubscribeMethod}
 			if len(r.Payload) > 0 {
 				// first param must be subscription name
 				var subscribeMethod [1]string
@@ -236,8 +240,8 @@ func parseBatchRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error)
 			return nil, true, &invalidRequestError{"Unable to parse (un)subscribe request arguments"}
 		}
 
-		if r.Method == unsubscribeMethod {
-			requests[i] = rpcRequest{id: id, isPubSub: true, method: unsubscribeMethod, params: r.Payload}
+		if r.Method == unsubscribeMethod || r.Method == resubscribeMethod {
+			requests[i] = rpcRequest{id: id, isPubSub: true, method: r.Method, params: r.Payload}
 			continue
 		}
 
@@ -335,14 +339,70 @@ func (c *jsonCodec) CreateNotification(subid string, event interface{}) interfac
 		Params: jsonSubscription{Subscription: subid, Result: event}}
 }
 
+// setResponseLimits sets the source of the limits the results and notifications
+// written by the codec must obey. It must be called before the codec is used.
+func (c *jsonCodec) setResponseLimits(limits func() ResponseLimits) {
+	c.limits = limits
+}
+
 // Write message to client
 func (c *jsonCodec) Write(res interface{}) error {
 	c.encMu.Lock()
 	defer c.encMu.Unlock()
 
+	if c.limits != nil {
+		if limits := c.limits(); limits.MaxSize > 0 || limits.MaxDepth > 0 {
+			limited, err := c.limitMessage(res, limits)
+			if err != nil {
+				return err
+			}
+			res = limited
+		}
+	}
 	return c.e.Encode(res)
 }
 
+// limitMessage pre-encodes the results and notification payloads of a message
+// within the given limits, so the full message is never built for oversized
+// ones. Results exceeding the limits are replaced by an error response, while
+// oversized notifications are dropped by returning the error.
+func (c *jsonCodec) limitMessage(res interface{}, limits ResponseLimits) (interface{}, error) {
+	switch msg := res.(type) {
+	case []interface{}:
+		batch := make([]interface{}, len(msg))
+		for i, elem := range msg {
+			limited, err := c.limitMessage(elem, limits)
+			if err != nil {
+				return nil, err
+			}
+			batch[i] = limited
+		}
+		return batch, nil
+
+	case *jsonSuccessResponse:
+		enc, err := encodeLimited(msg.Result, limits)
+		if err != nil {
+			if rpcErr, ok := err.(Error); ok {
+				return c.CreateErrorResponse(msg.Id, rpcErr), nil
+			}
+			return c.CreateErrorResponse(msg.Id, &callbackError{err.Error()}), nil
+		}
+		return &jsonSuccessResponse{Version: msg.Version, Id: msg.Id, Result: enc}, nil
+
+	case *jsonNotification:
+		if msg.Params.Result == nil {
+			return msg, nil
+		}
+		enc, err := encodeLimited(msg.Params.Result, limits)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonNotification{Version: msg.Version, Method: msg.Method,
+			Params: jsonSubscription{Subscription: msg.Params.Subscription, Result: enc}}, nil
+	}
+	return res, nil
+}
+
 // Close the underlying connection
 func (c *jsonCodec) Close() {
 	c.closer.Do(func() {
@@ -0,0 +1,75 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// limitedCodec is implemented by the codecs able to enforce the response limits
+// of the server while encoding results and notifications.
+type limitedCodec interface {
+	setResponseLimits(limits func() ResponseLimits)
+}
+
+// encodeLimited JSON encodes a result or notification payload, enforcing the
+// given limits on the encoding. Oversized values are reported by a
+// ResponseTooLargeError instead of being sent to the client.
+func encodeLimited(v interface{}, limits ResponseLimits) (json.RawMessage, error) {
+	enc, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if limits.MaxSize > 0 && len(enc) > limits.MaxSize {
+		return nil, &ResponseTooLargeError{fmt.Sprintf("response too large: %d bytes > %d", len(enc), limits.MaxSize)}
+	}
+	if limits.MaxDepth > 0 {
+		if depth := jsonDepth(enc); depth > limits.MaxDepth {
+			return nil, &ResponseTooLargeError{fmt.Sprintf("response too deep: %d levels > %d", depth, limits.MaxDepth)}
+		}
+	}
+	return json.RawMessage(enc), nil
+}
+
+// jsonDepth returns the maximum nesting depth of objects and arrays in a valid
+// JSON encoding.
+func jsonDepth(enc []byte) int {
+	var (
+		depth, max int
+		inString   bool
+		escaped    bool
+	)
+	for _, c := range enc {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			if depth++; depth > max {
+				max = depth
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return max
+}
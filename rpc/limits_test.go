@@ -0,0 +1,50 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"strings"
+	"testing"
+)
+
+// Tests that values exceeding the size or depth limits are refused with a
+// ResponseTooLargeError, while the ones within them are encoded unchanged.
+func TestEncodeLimited(t *testing.T) {
+	items := make([]string, 100)
+	for i := range items {
+		items[i] = "0123456789"
+	}
+	value := map[string]interface{}{"items": items}
+
+	if _, err := encodeLimited(value, ResponseLimits{MaxSize: 256}); err == nil {
+		t.Fatalf("oversized value encoded")
+	} else if _, ok := err.(*ResponseTooLargeError); !ok {
+		t.Fatalf("error type mismatch: have %T, want *ResponseTooLargeError", err)
+	}
+	if _, err := encodeLimited(value, ResponseLimits{MaxDepth: 1}); err == nil {
+		t.Fatalf("overly deep value encoded")
+	} else if _, ok := err.(*ResponseTooLargeError); !ok {
+		t.Fatalf("error type mismatch: have %T, want *ResponseTooLargeError", err)
+	}
+	enc, err := encodeLimited(value, ResponseLimits{MaxSize: 2048, MaxDepth: 2})
+	if err != nil {
+		t.Fatalf("failed to encode value within limits: %v", err)
+	}
+	if want := `{"items":["` + strings.Join(items, `","`) + `"]}`; string(enc) != want {
+		t.Errorf("encoding mismatch: have %s, want %s", enc, want)
+	}
+}
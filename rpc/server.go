@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"runtime"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
@@ -45,13 +46,27 @@ const (
 
 	// OptionSubscriptions is an indication that the codec suports RPC notifications
 	OptionSubscriptions = 1 << iota // support pub sub
+
+	// OptionLocal is an indication that the codec serves a local endpoint (IPC or
+	// in-process) which only the node operator can reach
+	OptionLocal = 1 << iota
 )
 
+// localKey is used to mark the context of requests received over local endpoints.
+type localKey struct{}
+
+// IsLocal returns whether the request of ctx was received over a local endpoint.
+func IsLocal(ctx context.Context) bool {
+	local, _ := ctx.Value(localKey{}).(bool)
+	return local
+}
+
 // NewServer will create a new server instance with no registered handlers.
 func NewServer() *Server {
 	server := &Server{
 		services:      make(serviceRegistry),
 		subscriptions: make(subscriptionRegistry),
+		durable:       newDurableRegistry(),
 		codecs:        set.New(),
 		run:           1,
 	}
@@ -162,11 +177,21 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// enforce the response limits on results and notifications alike while encoding
+	if limited, ok := codec.(limitedCodec); ok {
+		limited.setResponseLimits(s.ResponseLimits)
+	}
 	// if the codec supports notification include a notifier that callbacks can use
 	// to send notification to clients. It is thight to the codec/connection. If the
 	// connection is closed the notifier will stop and cancels all active subscriptions.
 	if options&OptionSubscriptions == OptionSubscriptions {
-		ctx = context.WithValue(ctx, notifierKey{}, newNotifier(codec))
+		notifier := newNotifier(codec, s.durable)
+		defer notifier.detach()
+
+		ctx = context.WithValue(ctx, notifierKey{}, notifier)
+	}
+	if options&OptionLocal == OptionLocal {
+		ctx = context.WithValue(ctx, localKey{}, true)
 	}
 	s.codecsMu.Lock()
 	if atomic.LoadInt32(&s.run) != 1 { // server stopped
@@ -283,10 +308,40 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, &invalidParamsError{"Expected subscription id as first argument"}), nil
 	}
 
+	if req.isResubscribe { // resume durable subscription, params are the subscription id, its token and last seen sequence number
+		if len(req.args) >= 3 && req.args[0].Kind() == reflect.String && req.args[1].Kind() == reflect.String {
+			notifier, supported := NotifierFromContext(ctx)
+			if !supported { // interface doesn't support subscriptions (e.g. http)
+				return codec.CreateErrorResponse(&req.id, &callbackError{ErrNotificationsUnsupported.Error()}), nil
+			}
+
+			subid, token, seq := ID(req.args[0].String()), req.args[1].String(), req.args[2].Uint()
+			if err := notifier.resubscribe(subid, token); err != nil {
+				return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
+			}
+
+			// replay missed events after the response was sent to the client
+			resumeSub := func() {
+				notifier.resume(subid, seq)
+			}
+
+			return codec.CreateResponse(req.id, true), resumeSub
+		}
+		return codec.CreateErrorResponse(&req.id, &invalidParamsError{"Expected subscription id, token and sequence number as arguments"}), nil
+	}
+
 	if req.callb.isSubscribe {
-		subid, err := s.createSubscription(ctx, codec, req)
+		subctx := ctx
+		if req.isDurable { // let the callback create a subscription outliving the connection
+			notifier, supported := NotifierFromContext(ctx)
+			if !supported { // interface doesn't support subscriptions (e.g. http)
+				return codec.CreateErrorResponse(&req.id, &callbackError{ErrNotificationsUnsupported.Error()}), nil
+			}
+			subctx = context.WithValue(ctx, notifierKey{}, notifier.durableNotifier())
+		}
+		subid, err := s.createSubscription(subctx, codec, req)
 		if err != nil {
-			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
+			return codec.CreateErrorResponse(&req.id, callbackErr(err)), nil
 		}
 
 		// active the subscription after the sub id was successfully sent to the client
@@ -295,6 +350,12 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 			notifier.activate(subid)
 		}
 
+		// hand the resumption token of durable subscriptions to their creator only
+		if req.isDurable {
+			if sub := s.durable.get(subid); sub != nil {
+				return codec.CreateResponse(req.id, &durableSubscriptionResult{ID: subid, Token: sub.token}), activateSub
+			}
+		}
 		return codec.CreateResponse(req.id, subid), activateSub
 	}
 
@@ -306,6 +367,14 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, rpcErr), nil
 	}
 
+	// bound the execution time of the method if a deadline is configured for it
+	timeout := s.methodTimeout(req.svcname + serviceMethodSeparator + formatName(req.callb.method.Name))
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	arguments := []reflect.Value{req.callb.rcvr}
 	if req.callb.hasCtx {
 		arguments = append(arguments, reflect.ValueOf(ctx))
@@ -316,6 +385,9 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 
 	// execute RPC method and return result
 	reply := req.callb.method.Func.Call(arguments)
+	if timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		return codec.CreateErrorResponse(&req.id, &TimeoutError{fmt.Sprintf("request timed out after %v", timeout)}), nil
+	}
 	if len(reply) == 0 {
 		return codec.CreateResponse(req.id, nil), nil
 	}
@@ -323,13 +395,57 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
+			res := codec.CreateErrorResponse(&req.id, callbackErr(e))
 			return res, nil
 		}
 	}
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
 
+// SetResponseLimits sets the limits results returned by the server must obey.
+func (s *Server) SetResponseLimits(limits ResponseLimits) {
+	s.limitsMu.Lock()
+	defer s.limitsMu.Unlock()
+
+	s.limits = limits
+}
+
+// ResponseLimits returns the limits results returned by the server must obey.
+func (s *Server) ResponseLimits() ResponseLimits {
+	s.limitsMu.RLock()
+	defer s.limitsMu.RUnlock()
+
+	return s.limits
+}
+
+// SetTimeouts sets the execution deadlines of the methods served by the server.
+func (s *Server) SetTimeouts(timeouts Timeouts) {
+	s.limitsMu.Lock()
+	defer s.limitsMu.Unlock()
+
+	s.timeouts = timeouts
+}
+
+// Timeouts returns the execution deadlines of the methods served by the server.
+func (s *Server) Timeouts() Timeouts {
+	s.limitsMu.RLock()
+	defer s.limitsMu.RUnlock()
+
+	return s.timeouts
+}
+
+// methodTimeout returns the execution deadline of the given method, zero if its
+// execution time is unbounded.
+func (s *Server) methodTimeout(method string) time.Duration {
+	s.limitsMu.RLock()
+	defer s.limitsMu.RUnlock()
+
+	if timeout, ok := s.timeouts.Methods[method]; ok {
+		return timeout
+	}
+	return s.timeouts.Default
+}
+
 // exec executes the given request and writes the result back using the codec.
 func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest) {
 	var response interface{}
@@ -410,6 +526,17 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 			continue
 		}
 
+		if r.isPubSub && r.method == resubscribeMethod {
+			requests[i] = &serverRequest{id: r.id, isResubscribe: true}
+			argTypes := []reflect.Type{reflect.TypeOf(""), reflect.TypeOf(""), reflect.TypeOf(uint64(0))} // expect subscription id, token and last seen sequence number
+			if args, err := codec.ParseRequestArguments(argTypes, r.params); err == nil {
+				requests[i].args = args
+			} else {
+				requests[i].err = &invalidParamsError{err.Error()}
+			}
+			continue
+		}
+
 		if svc, ok = s.services[r.service]; !ok { // rpc method isn't available
 			requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
 			continue
@@ -417,7 +544,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 		if r.isPubSub { // eth_subscribe, r.method contains the subscription method name
 			if callb, ok := svc.subscriptions[r.method]; ok {
-				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb, isDurable: r.isDurable}
 				if r.params != nil && len(callb.argTypes) > 0 {
 					argTypes := []reflect.Type{reflect.TypeOf("")}
 					argTypes = append(argTypes, callb.argTypes...)
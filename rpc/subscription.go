@@ -36,8 +36,11 @@ type ID string
 // a Subscription is created by a notifier and tight to that notifier. The client can use
 // this subscription to wait for an unsubscribe request for the client, see Err().
 type Subscription struct {
-	ID  ID
-	err chan error // closed on unsubscribe
+	ID      ID
+	err     chan error           // closed on unsubscribe
+	errOnce sync.Once            // guards closing err
+	active  chan struct{}        // closed on activation
+	durable *durableSubscription // set if the subscription survives its connection
 }
 
 // Err returns a channel that is closed when the client send an unsubscribe request.
@@ -45,6 +48,19 @@ func (s *Subscription) Err() <-chan error {
 	return s.err
 }
 
+// close signals the callback that the subscription ended. It's safe to call
+// multiple times, e.g. when a resumed subscription is cancelled concurrently.
+func (s *Subscription) close() {
+	s.errOnce.Do(func() { close(s.err) })
+}
+
+// Active returns a channel that is closed once the subscription is activated,
+// i.e. its ID was sent to the client and notifications are no longer dropped.
+// Callbacks reporting an already existing state should wait on it before doing so.
+func (s *Subscription) Active() <-chan struct{} {
+	return s.active
+}
+
 // notifierKey is used to store a notifier within the connection context.
 type notifierKey struct{}
 
@@ -56,15 +72,35 @@ type Notifier struct {
 	stopped  bool
 	active   map[ID]*Subscription
 	inactive map[ID]*Subscription
+	durable  *durableRegistry // server wide registry of durable subscriptions
+
+	conn      *Notifier        // notifier of the connection, if this one creates a durable subscription
+	closed    chan interface{} // closed when the durable subscription created through this notifier ends
+	closeOnce sync.Once
 }
 
 // newNotifier creates a new notifier that can be used to send subscription
 // notifications to the client.
-func newNotifier(codec ServerCodec) *Notifier {
+func newNotifier(codec ServerCodec, durable *durableRegistry) *Notifier {
 	return &Notifier{
 		codec:    codec,
 		active:   make(map[ID]*Subscription),
 		inactive: make(map[ID]*Subscription),
+		durable:  durable,
+	}
+}
+
+// durableNotifier returns a notifier through which a subscription callback creates
+// a durable subscription on this connection. Its Closed channel is closed when the
+// subscription ends instead of when the connection does, so the callback keeps
+// producing events while the client is disconnected. Callbacks are expected to
+// create a single subscription.
+func (n *Notifier) durableNotifier() *Notifier {
+	return &Notifier{
+		codec:   n.codec,
+		durable: n.durable,
+		conn:    n,
+		closed:  make(chan interface{}),
 	}
 }
 
@@ -78,8 +114,21 @@ func NotifierFromContext(ctx context.Context) (*Notifier, bool) {
 // RPC connection. By default subscriptions are inactive and notifications
 // are dropped until the subscription is marked as active. This is done
 // by the RPC server after the subscription ID is send to the client.
+//
+// If the client requested a durable subscription through eth_subscribeDurable,
+// the subscription survives the RPC connection being dropped. Notifications then
+// carry a sequence number and the most recent ones are retained, allowing the
+// client to resume the subscription on a new connection through eth_resubscribe
+// and receive the events it missed in the meantime.
 func (n *Notifier) CreateSubscription() *Subscription {
-	s := &Subscription{NewID(), make(chan error)}
+	s := &Subscription{ID: NewID(), err: make(chan error), active: make(chan struct{})}
+	if n.conn != nil {
+		durable := n
+		done := func() { durable.closeOnce.Do(func() { close(durable.closed) }) }
+		s.durable = newDurableSubscription(s, done, n.durable)
+		n.durable.add(s.durable)
+		n = n.conn
+	}
 	n.subMu.Lock()
 	n.inactive[s.ID] = s
 	n.subMu.Unlock()
@@ -88,7 +137,13 @@ func (n *Notifier) CreateSubscription() *Subscription {
 
 // Notify sends a notification to the client with the given data as payload.
 // If an error occurs the RPC connection is closed and the error is returned.
+// Notifications exceeding the response limits of the server are dropped with a
+// ResponseTooLargeError instead, keeping the connection open.
 func (n *Notifier) Notify(id ID, data interface{}) error {
+	// Durable subscriptions might have moved to a different connection
+	if sub := n.durable.get(id); sub != nil {
+		return sub.notify(data)
+	}
 	n.subMu.RLock()
 	defer n.subMu.RUnlock()
 
@@ -96,15 +151,22 @@ func (n *Notifier) Notify(id ID, data interface{}) error {
 	if active {
 		notification := n.codec.CreateNotification(string(id), data)
 		if err := n.codec.Write(notification); err != nil {
-			n.codec.Close()
+			// Oversized notifications are dropped, the connection is still fine
+			if _, tooLarge := err.(*ResponseTooLargeError); !tooLarge {
+				n.codec.Close()
+			}
 			return err
 		}
 	}
 	return nil
 }
 
-// Closed returns a channel that is closed when the RPC connection is closed.
+// Closed returns a channel that is closed when the RPC connection is closed, or
+// for durable subscriptions, when the subscription ends.
 func (n *Notifier) Closed() <-chan interface{} {
+	if n.closed != nil {
+		return n.closed
+	}
 	return n.codec.Closed()
 }
 
@@ -112,13 +174,66 @@ func (n *Notifier) Closed() <-chan interface{} {
 // If the subscription could not be found ErrSubscriptionNotFound is returned.
 func (n *Notifier) unsubscribe(id ID) error {
 	n.subMu.Lock()
-	defer n.subMu.Unlock()
-	if s, found := n.active[id]; found {
-		close(s.err)
-		delete(n.active, id)
-		return nil
+	s, found := n.active[id]
+	delete(n.active, id)
+	n.subMu.Unlock()
+
+	if !found {
+		return ErrSubscriptionNotFound
+	}
+	if s.durable != nil {
+		s.durable.close()
+	} else {
+		s.close()
+	}
+	return nil
+}
+
+// resubscribe registers a durable subscription with this notifier, allowing it
+// to be cancelled from this connection instead of the one it was previously
+// registered with. Notifications will not be delivered until resume is called.
+// If the subscription could not be found or the token doesn't match the one
+// handed out on its creation, ErrSubscriptionNotFound is returned.
+func (n *Notifier) resubscribe(id ID, token string) error {
+	sub := n.durable.get(id)
+	if sub == nil || !sub.authorized(token) {
+		return ErrSubscriptionNotFound
+	}
+	n.subMu.Lock()
+	n.active[id] = sub.sub
+	n.subMu.Unlock()
+
+	if old := sub.own(n); old != nil && old != n {
+		old.subMu.Lock()
+		delete(old.active, id)
+		old.subMu.Unlock()
+	}
+	return nil
+}
+
+// resume attaches a durable subscription to this notifier, sending all retained
+// events that came after the given sequence number.
+func (n *Notifier) resume(id ID, seq uint64) {
+	if sub := n.durable.get(id); sub != nil {
+		sub.attach(n, seq)
+	}
+}
+
+// detach is called when the RPC connection is closed and marks all durable
+// subscriptions attached to this notifier as awaiting to be resumed.
+func (n *Notifier) detach() {
+	n.subMu.RLock()
+	var subs []*durableSubscription
+	for _, sub := range n.active {
+		if sub.durable != nil {
+			subs = append(subs, sub.durable)
+		}
+	}
+	n.subMu.RUnlock()
+
+	for _, sub := range subs {
+		sub.detach(n)
 	}
-	return ErrSubscriptionNotFound
 }
 
 // activate enables a subscription. Until a subscription is enabled all
@@ -127,9 +242,22 @@ func (n *Notifier) unsubscribe(id ID) error {
 // send to the client before the subscription ID is send to the client.
 func (n *Notifier) activate(id ID) {
 	n.subMu.Lock()
-	defer n.subMu.Unlock()
-	if sub, found := n.inactive[id]; found {
-		n.active[id] = sub
+	sub, found := n.inactive[id]
+	if found {
 		delete(n.inactive, id)
+		close(sub.active)
+
+		// Durable subscriptions might have been resumed elsewhere in the meantime
+		if sub.durable == nil || sub.durable.claim(n) {
+			n.active[id] = sub
+		} else {
+			found = false
+		}
+	}
+	n.subMu.Unlock()
+
+	// Send any events a durable subscription buffered in the meantime
+	if found && sub.durable != nil {
+		sub.durable.attach(n, 0)
 	}
 }
@@ -19,6 +19,7 @@ package rpc
 import (
 	"encoding/json"
 	"net"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -32,6 +33,8 @@ type NotificationTestService struct {
 
 	gotHangSubscriptionReq  chan struct{}
 	unblockHangSubscription chan struct{}
+
+	durableEvents chan int // events to send on durable subscriptions
 }
 
 func (s *NotificationTestService) Echo(i int) int {
@@ -105,6 +108,30 @@ func (s *NotificationTestService) HangSubscription(ctx context.Context, val int)
 	return subscription, nil
 }
 
+// Feed forwards the values fed through s.durableEvents until the subscription is
+// cancelled or its connection is closed.
+func (s *NotificationTestService) Feed(ctx context.Context) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	subscription := notifier.CreateSubscription()
+
+	go func() {
+		for {
+			select {
+			case val := <-s.durableEvents:
+				notifier.Notify(subscription.ID, val)
+			case <-subscription.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return subscription, nil
+}
+
 func TestNotifications(t *testing.T) {
 	server := NewServer()
 	service := &NotificationTestService{}
@@ -163,3 +190,167 @@ func TestNotifications(t *testing.T) {
 		t.Error("unsubscribe callback not called after closing connection")
 	}
 }
+
+// Tests that events sent on a durable subscription while the client is
+// disconnected are delivered once the subscription is resumed with its token.
+func TestDurableSubscriptionResume(t *testing.T) {
+	server := NewServer()
+	service := &NotificationTestService{durableEvents: make(chan int)}
+
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatalf("unable to register test service %v", err)
+	}
+	connect := func() (net.Conn, *json.Encoder, *json.Decoder) {
+		clientConn, serverConn := net.Pipe()
+		go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation|OptionSubscriptions)
+		return clientConn, json.NewEncoder(clientConn), json.NewDecoder(clientConn)
+	}
+	expectEvents := func(in *json.Decoder, from, to int) {
+		for i := from; i <= to; i++ {
+			var notification jsonNotification
+			if err := in.Decode(&notification); err != nil {
+				t.Fatalf("failed to read notification %d: %v", i, err)
+			}
+			event := notification.Params.Result.(map[string]interface{})
+			if seq := int(event["seq"].(float64)); seq != i {
+				t.Fatalf("sequence number mismatch: have %d, want %d", seq, i)
+			}
+			if data := int(event["data"].(float64)); data != 100+i {
+				t.Fatalf("event %d data mismatch: have %d, want %d", i, data, 100+i)
+			}
+		}
+	}
+	// Subscribe and receive a few events
+	conn, out, in := connect()
+	request := map[string]interface{}{"id": 1, "method": "eth_subscribeDurable", "version": "2.0", "params": []interface{}{"feed"}}
+	if err := out.Encode(request); err != nil {
+		t.Fatal(err)
+	}
+	var response jsonSuccessResponse
+	if err := in.Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	result, ok := response.Result.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected subscription id and token, got %T", response.Result)
+	}
+	subid, token := result["id"].(string), result["token"].(string)
+	if subid == "" || len(token) != 64 {
+		t.Fatalf("invalid subscription id %q or token %q", subid, token)
+	}
+	go func() {
+		for i := 1; i <= 3; i++ {
+			service.durableEvents <- 100 + i
+		}
+	}()
+	expectEvents(in, 1, 3)
+
+	// Drop the connection and send some events while disconnected
+	conn.Close()
+	for i := 4; i <= 6; i++ {
+		service.durableEvents <- 100 + i
+	}
+	// Resuming without the right token must fail
+	conn, out, in = connect()
+	defer conn.Close()
+
+	var errResponse jsonErrResponse
+	for _, params := range [][]interface{}{{subid, "", 3}, {subid, strings.Repeat("00", 32), 3}, {subid, 3}} {
+		request = map[string]interface{}{"id": 2, "method": "eth_resubscribe", "version": "2.0", "params": params}
+		if err := out.Encode(request); err != nil {
+			t.Fatal(err)
+		}
+		if err := in.Decode(&errResponse); err != nil {
+			t.Fatal(err)
+		}
+		if errResponse.Error.Message == "" {
+			t.Fatalf("subscription resumed with params %v", params)
+		}
+	}
+	// Resume the subscription and ensure the missed events are delivered
+	request = map[string]interface{}{"id": 2, "method": "eth_resubscribe", "version": "2.0", "params": []interface{}{subid, token, 3}}
+	if err := out.Encode(request); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Result != true {
+		t.Fatalf("failed to resume subscription: %v", response.Result)
+	}
+	expectEvents(in, 4, 6)
+
+	// Ensure new events are sent directly on the new connection
+	go func() { service.durableEvents <- 107 }()
+	expectEvents(in, 7, 7)
+
+	// Unknown subscriptions can't be resumed
+	request = map[string]interface{}{"id": 3, "method": "eth_resubscribe", "version": "2.0", "params": []interface{}{"0x1234", token, 0}}
+	if err := out.Encode(request); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Decode(&errResponse); err != nil {
+		t.Fatal(err)
+	}
+	if errResponse.Error.Message != ErrSubscriptionNotFound.Error() {
+		t.Errorf("unexpected error resuming unknown subscription: %v", errResponse.Error.Message)
+	}
+}
+
+// Tests that a durable subscription resumed on a second connection can only be
+// cancelled once, no matter which of the connections tries to.
+func TestDurableSubscriptionUnsubscribeBoth(t *testing.T) {
+	server := NewServer()
+	service := &NotificationTestService{durableEvents: make(chan int)}
+
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatalf("unable to register test service %v", err)
+	}
+	connect := func() (net.Conn, *json.Encoder, *json.Decoder) {
+		clientConn, serverConn := net.Pipe()
+		go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation|OptionSubscriptions)
+		return clientConn, json.NewEncoder(clientConn), json.NewDecoder(clientConn)
+	}
+	call := func(out *json.Encoder, in *json.Decoder, method string, params ...interface{}) (interface{}, string) {
+		request := map[string]interface{}{"id": 1, "method": method, "version": "2.0", "params": params}
+		if err := out.Encode(request); err != nil {
+			t.Fatal(err)
+		}
+		var response struct {
+			Result interface{}
+			Error  *jsonError
+		}
+		if err := in.Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != nil {
+			return nil, response.Error.Message
+		}
+		return response.Result, ""
+	}
+	// Subscribe on one connection and resume on another while the first is still open
+	conn1, out1, in1 := connect()
+	defer conn1.Close()
+
+	result, _ := call(out1, in1, "eth_subscribeDurable", "feed")
+	sub := result.(map[string]interface{})
+	subid, token := sub["id"].(string), sub["token"].(string)
+
+	conn2, out2, in2 := connect()
+	defer conn2.Close()
+
+	if result, msg := call(out2, in2, "eth_resubscribe", subid, token, 0); result != true {
+		t.Fatalf("failed to resume subscription: %v", msg)
+	}
+	// Cancel it on both connections, only the first may succeed
+	if result, msg := call(out2, in2, "eth_unsubscribe", subid); result != true {
+		t.Fatalf("failed to unsubscribe on the resuming connection: %v", msg)
+	}
+	if _, msg := call(out1, in1, "eth_unsubscribe", subid); msg != ErrSubscriptionNotFound.Error() {
+		t.Fatalf("unexpected error unsubscribing on the original connection: %q", msg)
+	}
+	// The server must still be serving requests
+	if result, msg := call(out1, in1, "eth_echo", 5); result != 5.0 {
+		t.Fatalf("echo failed after double unsubscribe: %v %v", result, msg)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/fatih/set.v0"
 )
@@ -64,6 +65,8 @@ type serverRequest struct {
 	callb         *callback
 	args          []reflect.Value
 	isUnsubscribe bool
+	isResubscribe bool
+	isDurable     bool // subscription requested through eth_subscribeDurable
 	err           Error
 }
 
@@ -77,20 +80,41 @@ type Server struct {
 	services       serviceRegistry
 	muSubcriptions sync.Mutex // protects subscriptions
 	subscriptions  subscriptionRegistry
+	durable        *durableRegistry // subscriptions surviving their connection
 
 	run      int32
 	codecsMu sync.Mutex
 	codecs   *set.Set
+
+	limits   ResponseLimits
+	timeouts Timeouts
+	limitsMu sync.RWMutex
+}
+
+// ResponseLimits caps the results and notifications sent by a server, protecting
+// it from requests producing huge responses. Zero values disable the limit.
+type ResponseLimits struct {
+	MaxSize  int `json:"maxSize"`  // Maximum size of a JSON encoded result in bytes
+	MaxDepth int `json:"maxDepth"` // Maximum nesting depth of a JSON encoded result
+}
+
+// Timeouts bounds the execution time of the regular method calls served by a
+// server. Methods accepting a context are expected to abort when it expires, the
+// results of the others are discarded. Zero durations disable the deadline.
+type Timeouts struct {
+	Default time.Duration            // Deadline of the methods without an override
+	Methods map[string]time.Duration // Per method overrides, keyed by e.g. "eth_call"
 }
 
 // rpcRequest represents a raw incoming RPC request
 type rpcRequest struct {
-	service  string
-	method   string
-	id       interface{}
-	isPubSub bool
-	params   interface{}
-	err      Error // invalid batch element
+	service   string
+	method    string
+	id        interface{}
+	isPubSub  bool
+	isDurable bool // durable subscription request
+	params    interface{}
+	err       Error // invalid batch element
 }
 
 // Error wraps RPC errors, which contain an error code in addition to the message.
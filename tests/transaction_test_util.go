@@ -209,7 +209,7 @@ func verifyTxFields(txTest TransactionTest, decodedTx *types.Transaction) (err e
 		return fmt.Errorf("S mismatch: %v %v", expectedS, s)
 	}
 	expectedV := mustConvertUint(txTest.Transaction.V, 16)
-	if uint64(v) != expectedV {
+	if v.Uint64() != expectedV {
 		return fmt.Errorf("V mismatch: %v %v", expectedV, v)
 	}
 
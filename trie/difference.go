@@ -0,0 +1,216 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DiffNodeFn is called by Diff for every node stored in the database which is
+// only present in one of the compared tries, with the size of its encoding.
+type DiffNodeFn func(hash common.Hash, size int, inA bool) error
+
+// DiffLeafFn is called by Diff for every leaf whose value differs between the
+// compared tries. The value missing from one of the tries is nil.
+type DiffLeafFn func(a, b []byte) error
+
+// Diff walks the tries with roots a and b side by side in key order, reporting
+// the stored nodes and leaves which differ between them. Subtries with the same
+// hash at the same position in both tries are skipped without being loaded, so
+// the cost is proportional to the difference instead of the size of the tries.
+// Nodes are compared by position, so a subtrie moved elsewhere is reported as
+// missing from one trie and present in the other. Either callback may be nil.
+func Diff(db Database, a, b common.Hash, onNode DiffNodeFn, onLeaf DiffLeafFn) error {
+	itA, itB := newDiffIterator(db, a), newDiffIterator(db, b)
+	for {
+		x, y := itA.top(), itB.top()
+
+		var cmp int
+		switch {
+		case x == nil && y == nil:
+			return nil
+		case x == nil:
+			cmp = 1
+		case y == nil:
+			cmp = -1
+		default:
+			cmp = bytes.Compare(x.path, y.path) // pre-order, as prefixes sort first
+		}
+		switch {
+		case cmp < 0:
+			if err := itA.report(x, nil, true, onNode, onLeaf); err != nil {
+				return err
+			}
+			if err := itA.next(); err != nil {
+				return err
+			}
+		case cmp > 0:
+			if err := itB.report(y, nil, false, onNode, onLeaf); err != nil {
+				return err
+			}
+			if err := itB.next(); err != nil {
+				return err
+			}
+		default:
+			// Identical subtries need neither reporting nor descending into
+			if x.hash != nil && bytes.Equal(x.hash, y.hash) {
+				itA.pop()
+				itB.pop()
+				continue
+			}
+			if err := itA.report(x, y, true, onNode, onLeaf); err != nil {
+				return err
+			}
+			if err := itB.report(y, x, false, onNode, onLeaf); err != nil {
+				return err
+			}
+			if err := itA.next(); err != nil {
+				return err
+			}
+			if err := itB.next(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// diffItem is a node pending iteration by a diffIterator.
+type diffItem struct {
+	path []byte   // Hex encoded key prefix of the node, ending with the terminator for leaves
+	hash hashNode // Hash of the node if it is stored separately, nil if embedded
+	node node     // Node itself, a hashNode until resolved
+	size int      // Size of the stored encoding of the node, zero if embedded
+}
+
+// diffIterator traverses a trie pre-order, resolving nodes from the database
+// only when reached.
+type diffIterator struct {
+	db    Database
+	root  common.Hash
+	stack []*diffItem // Nodes pending iteration, the current one on top
+}
+
+// newDiffIterator creates a pre-order iterator over the trie with the given root.
+func newDiffIterator(db Database, root common.Hash) *diffIterator {
+	it := &diffIterator{db: db, root: root}
+	if root != (common.Hash{}) && root != EmptyRoot {
+		hash := hashNode(root.Bytes())
+		it.stack = append(it.stack, &diffItem{hash: hash, node: hash})
+	}
+	return it
+}
+
+// top returns the current node of the iteration, or nil if it's finished.
+func (it *diffIterator) top() *diffItem {
+	if len(it.stack) == 0 {
+		return nil
+	}
+	return it.stack[len(it.stack)-1]
+}
+
+// resolve loads the given item from the database if it's not yet resolved.
+func (it *diffIterator) resolve(item *diffItem) error {
+	hash, ok := item.node.(hashNode)
+	if !ok {
+		return nil
+	}
+	enc, err := it.db.Get(hash)
+	if err != nil || enc == nil {
+		return &MissingNodeError{
+			RootHash:  it.root,
+			NodeHash:  common.BytesToHash(hash),
+			Key:       compactHexEncode(item.path),
+			PrefixLen: len(item.path),
+		}
+	}
+	n, err := decodeNode(hash, enc, 0)
+	if err != nil {
+		return err
+	}
+	item.node, item.size = n, len(enc)
+	return nil
+}
+
+// report passes the given item, which differs from its counterpart at the same
+// position in the other trie (nil if there is none), to the callbacks.
+func (it *diffIterator) report(item, other *diffItem, inA bool, onNode DiffNodeFn, onLeaf DiffLeafFn) error {
+	if value, ok := item.node.(valueNode); ok {
+		if onLeaf == nil {
+			return nil
+		}
+		if other == nil {
+			if inA {
+				return onLeaf(value, nil)
+			}
+			return onLeaf(nil, value)
+		}
+		// Both leaves exist, report the pair only once
+		if otherValue := other.node.(valueNode); inA && !bytes.Equal(value, otherValue) {
+			return onLeaf(value, otherValue)
+		}
+		return nil
+	}
+	if item.hash == nil || onNode == nil {
+		return nil
+	}
+	if err := it.resolve(item); err != nil {
+		return err
+	}
+	return onNode(common.BytesToHash(item.hash), item.size, inA)
+}
+
+// pop skips the current node of the iteration along with its whole subtrie.
+func (it *diffIterator) pop() *diffItem {
+	item := it.top()
+	it.stack = it.stack[:len(it.stack)-1]
+	return item
+}
+
+// next pops the current node of the iteration, descending into its children.
+func (it *diffIterator) next() error {
+	if err := it.resolve(it.top()); err != nil {
+		return err
+	}
+	item := it.pop()
+
+	switch n := item.node.(type) {
+	case *fullNode:
+		// Push in reverse so the children are visited in key order
+		for i := len(n.Children) - 1; i >= 0; i-- {
+			if n.Children[i] != nil {
+				it.push(item.path, []byte{byte(i)}, n.Children[i])
+			}
+		}
+	case *shortNode:
+		it.push(item.path, n.Key, n.Val)
+	}
+	return nil
+}
+
+// push schedules a child node with the given key relative to its parent.
+func (it *diffIterator) push(parent, key []byte, child node) {
+	path := make([]byte, 0, len(parent)+len(key))
+	path = append(append(path, parent...), key...)
+
+	item := &diffItem{path: path, node: child}
+	if hash, ok := child.(hashNode); ok {
+		item.hash = hash
+	}
+	it.stack = append(it.stack, item)
+}
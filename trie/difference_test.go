@@ -0,0 +1,155 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that diffing two tries reports exactly the nodes and leaves that differ
+// between them, without loading the subtries they have in common.
+func TestDiff(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	// Create two tries sharing most of their content
+	trieA, _ := New(common.Hash{}, db)
+	trieB, _ := New(common.Hash{}, db)
+	for i := 0; i < 1000; i++ {
+		key := crypto.Keccak256([]byte(fmt.Sprintf("key-%d", i)))
+		value := []byte(fmt.Sprintf("value-%d", i))
+
+		trieA.Update(key, value)
+		switch {
+		case i%100 == 1:
+			// Missing from trie B
+		case i%100 == 2:
+			trieB.Update(key, []byte(fmt.Sprintf("changed-%d", i)))
+		default:
+			trieB.Update(key, value)
+		}
+	}
+	trieB.Update(crypto.Keccak256([]byte("extra")), []byte("extra"))
+
+	rootA, _ := trieA.Commit()
+	rootB, _ := trieB.Commit()
+
+	// Gather the expected differences by brute force
+	nodesA, nodesB := trieNodes(t, db, rootA), trieNodes(t, db, rootB)
+
+	wantNodes := make(map[common.Hash]bool)
+	for hash := range nodesA {
+		if _, ok := nodesB[hash]; !ok {
+			wantNodes[hash] = true
+		}
+	}
+	for hash := range nodesB {
+		if _, ok := nodesA[hash]; !ok {
+			wantNodes[hash] = false
+		}
+	}
+	// Diff the tries and cross check with the expectations
+	counter := &countingDB{db, make(map[string]int)}
+
+	gotNodes := make(map[common.Hash]bool)
+	onNode := func(hash common.Hash, size int, inA bool) error {
+		if want := map[bool]map[common.Hash]int{true: nodesA, false: nodesB}[inA][hash]; size != want {
+			t.Errorf("node %x: size mismatch: have %d, want %d", hash, size, want)
+		}
+		gotNodes[hash] = inA
+		return nil
+	}
+	var removed, added, changed int
+	onLeaf := func(a, b []byte) error {
+		switch {
+		case b == nil:
+			removed++
+		case a == nil:
+			added++
+		case bytes.Equal(a, b):
+			t.Errorf("equal leaves reported: %q", a)
+		default:
+			changed++
+		}
+		return nil
+	}
+	if err := Diff(counter, rootA, rootB, onNode, onLeaf); err != nil {
+		t.Fatalf("failed to diff tries: %v", err)
+	}
+	if len(gotNodes) != len(wantNodes) {
+		t.Errorf("reported node count mismatch: have %d, want %d", len(gotNodes), len(wantNodes))
+	}
+	for hash, inA := range wantNodes {
+		if got, ok := gotNodes[hash]; !ok || got != inA {
+			t.Errorf("node %x: report mismatch: have %v/%v, want true/%v", hash, ok, got, inA)
+		}
+	}
+	if removed != 10 || added != 1 || changed != 10 {
+		t.Errorf("leaf differences mismatch: have %d/%d/%d removed/added/changed, want 10/1/10", removed, added, changed)
+	}
+	if loaded, total := len(counter.gets), len(nodesA)+len(nodesB); loaded >= total/2 {
+		t.Errorf("too many nodes loaded: %d out of %d", loaded, total)
+	}
+	// Identical and empty tries should not differ at all
+	if err := Diff(db, rootA, rootA, onNode, onLeaf); err != nil || len(gotNodes) != len(wantNodes) {
+		t.Errorf("identical tries reported as different: %v", err)
+	}
+	gotNodes = make(map[common.Hash]bool)
+	if err := Diff(db, rootA, common.Hash{}, onNode, nil); err != nil {
+		t.Fatalf("failed to diff against empty trie: %v", err)
+	}
+	if len(gotNodes) != len(nodesA) {
+		t.Errorf("node count mismatch against empty trie: have %d, want %d", len(gotNodes), len(nodesA))
+	}
+}
+
+// Tests that a node missing from the database is reported as an error.
+func TestDiffMissingNode(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	trieA, _ := New(common.Hash{}, db)
+	trieA.Update([]byte("120000"), []byte("qwerqwerqwerqwerqwerqwerqwerqwer"))
+	trieA.Update([]byte("123456"), []byte("asdfasdfasdfasdfasdfasdfasdfasdf"))
+	rootA, _ := trieA.Commit()
+
+	if err := Diff(db, common.HexToHash("0x01"), rootA, nil, nil); err == nil {
+		t.Fatalf("missing root not reported")
+	} else if _, ok := err.(*MissingNodeError); !ok {
+		t.Fatalf("error type mismatch: have %T, want *MissingNodeError", err)
+	}
+}
+
+// trieNodes gathers the sizes of all the stored nodes of a trie.
+func trieNodes(t *testing.T, db Database, root common.Hash) map[common.Hash]int {
+	trie, err := New(root, db)
+	if err != nil {
+		t.Fatalf("failed to open trie %x: %v", root, err)
+	}
+	nodes := make(map[common.Hash]int)
+	for it := NewNodeIterator(trie); it.Next(); {
+		if it.Hash != (common.Hash{}) {
+			enc, _ := db.Get(it.Hash.Bytes())
+			nodes[it.Hash] = len(enc)
+		}
+	}
+	return nodes
+}
@@ -87,6 +87,12 @@ func (t *Trie) Prove(key []byte) []rlp.RawValue {
 	return proof
 }
 
+// Prove constructs a merkle proof for key. The key is hashed before lookup,
+// so the proof has to be verified against the hash of the key.
+func (t *SecureTrie) Prove(key []byte) []rlp.RawValue {
+	return t.trie.Prove(t.hashKey(key))
+}
+
 // VerifyProof checks merkle proofs. The given proof must contain the
 // value for key in a trie with the given root hash. VerifyProof
 // returns an error if the proof contains invalid trie nodes or the